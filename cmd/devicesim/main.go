@@ -0,0 +1,234 @@
+// Command devicesim plays a fiscal device against the API: it registers with
+// a fresh key pair, signs an operator in, opens a fiscal day, submits signed and chained invoices,
+// credit and debit notes, and closes the day with its own counters. Faults
+// can be injected to check that the server reports the matching validation
+// codes.
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"fiscalization-api/internal/devicesim"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/pkg/client"
+)
+
+func main() {
+	var cfg devicesim.Config
+	flag.StringVar(&cfg.ServerURL, "server", "http://localhost:8080", "API base URL; https uses mTLS with the issued certificate")
+	flag.IntVar(&cfg.DeviceID, "device", 1001, "device ID")
+	flag.StringVar(&cfg.SerialNo, "serial", "DEMO-0001", "device serial number")
+	flag.StringVar(&cfg.ActivationKey, "activation-key", "DEMO1001", "device activation key")
+	flag.StringVar(&cfg.ModelName, "model", "DemoPOS", "device model name")
+	flag.StringVar(&cfg.ModelVersion, "model-version", "1.0", "device model version")
+	flag.StringVar(&cfg.CAFile, "ca", "", "CA certificate used to verify the server over https")
+	flag.BoolVar(&cfg.Insecure, "insecure", false, "skip server certificate verification over https")
+	flag.StringVar(&cfg.Username, "user", "retail.admin", "operator username of the device's taxpayer")
+	flag.StringVar(&cfg.Password, "password", "Demo@12345", "operator password")
+	invoices := flag.Int("invoices", 3, "number of invoices to submit before the credit and debit note")
+	currency := flag.String("currency", "USD", "receipt currency")
+	faultList := flag.String("faults", "none", "comma-separated faults to inject: gap, bad-signature, wrong-total, wrong-tax")
+	faultAt := flag.Int("fault-at", 2, "invoice the faults are injected into")
+	globalNo := flag.Int("global-no", -1, "global number of the last receipt the server has, if it cannot be read from the fiscal day status")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("devicesim: ")
+
+	faults, err := devicesim.ParseFaults(*faultList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(faults) > 0 && (*faultAt < 1 || *faultAt > *invoices) {
+		log.Fatalf("-fault-at must be between 1 and %d", *invoices)
+	}
+
+	sim := &simulation{invoices: *invoices, currency: *currency, faults: faults, faultAt: *faultAt, globalNo: *globalNo}
+	if err := sim.run(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+	if sim.failures > 0 {
+		fmt.Printf("\n%d check(s) failed\n", sim.failures)
+		os.Exit(1)
+	}
+	fmt.Println("\nall checks passed")
+}
+
+type simulation struct {
+	invoices int
+	currency string
+	faults   []devicesim.Fault
+	faultAt  int
+	globalNo int
+
+	serverCert *x509.Certificate
+	failures   int
+}
+
+func (s *simulation) run(ctx context.Context, cfg devicesim.Config) error {
+	device, err := devicesim.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	taxpayer, err := device.VerifyTaxpayer(ctx)
+	if err != nil {
+		return fmt.Errorf("verify taxpayer: %w", err)
+	}
+	s.step("taxpayer %s (TIN %s), branch %s", taxpayer.TaxPayerName, taxpayer.TaxPayerTIN, taxpayer.DeviceBranchName)
+
+	if err := device.Register(ctx); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+	s.step("registered device %d, certificate issued", device.DeviceID())
+
+	operator, err := device.Login(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	s.step("signed in as %s %s (%s)", operator.PersonName, operator.PersonSurname, operator.Username)
+
+	certs, err := device.Client().GetServerCertificate(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("get server certificate: %w", err)
+	}
+	if len(certs.Certificate) == 0 {
+		return fmt.Errorf("server returned no certificate")
+	}
+	if s.serverCert, err = client.ParseCertificate(certs.Certificate[0]); err != nil {
+		return fmt.Errorf("server certificate: %w", err)
+	}
+
+	config, err := device.FetchConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("get config: %w", err)
+	}
+	s.step("%d applicable taxes, VAT number %q", len(config.ApplicableTaxes), config.VATNumber)
+
+	if err := device.Sync(ctx); err != nil {
+		return err
+	}
+	if s.globalNo >= 0 {
+		device.SetGlobalNo(s.globalNo)
+	}
+
+	day, err := device.OpenDay(ctx)
+	if err != nil {
+		return fmt.Errorf("open fiscal day: %w", err)
+	}
+	s.step("opened fiscal day %d", day.FiscalDayNo)
+
+	var original *models.Receipt
+	var originalItems []devicesim.Item
+	for i := 1; i <= s.invoices; i++ {
+		items, err := device.Basket(1 + i%3)
+		if err != nil {
+			return err
+		}
+
+		var faults []devicesim.Fault
+		if i == s.faultAt {
+			faults = s.faults
+		}
+
+		receipt := devicesim.NewReceipt(models.ReceiptTypeFiscalInvoice, s.currency, models.MoneyTypeCash, items)
+		if err := s.submit(ctx, device, receipt, faults); err != nil {
+			return err
+		}
+		if original == nil && len(faults) == 0 {
+			original, originalItems = receipt, items
+		}
+	}
+
+	if original != nil {
+		// Return and re-charge one unit of the first item of a clean invoice
+		item := originalItems[0]
+		item.Quantity = 1
+
+		credit := devicesim.NewReceipt(models.ReceiptTypeCreditNote, s.currency, models.MoneyTypeCash, []devicesim.Item{item})
+		devicesim.NoteFor(credit, original, original.ReceiptID, "Returned item")
+		if err := s.submit(ctx, device, credit, nil); err != nil {
+			return err
+		}
+
+		debit := devicesim.NewReceipt(models.ReceiptTypeDebitNote, s.currency, models.MoneyTypeCash, []devicesim.Item{item})
+		devicesim.NoteFor(debit, original, original.ReceiptID, "Price correction")
+		if err := s.submit(ctx, device, debit, nil); err != nil {
+			return err
+		}
+	}
+
+	closed, err := device.CloseDay(ctx)
+	var apiErr *models.APIError
+	switch {
+	case len(s.faults) > 0 && errors.As(err, &apiErr):
+		s.check(apiErr.ErrorCode == models.ErrCodeFISC04, "close refused with %s (%s); force-close the day from the admin API before the next run", apiErr.ErrorCode, apiErr.Title)
+	case len(s.faults) > 0:
+		s.check(false, "close accepted although receipts have validation errors")
+	case err != nil:
+		return fmt.Errorf("close fiscal day: %w", err)
+	default:
+		s.check(true, "closed fiscal day %d with %d counters", day.FiscalDayNo, len(closed.FiscalDayCounters))
+		if err := client.VerifyFiscalDaySignature(s.serverCert, closed.FiscalDayServerSignature); err != nil {
+			s.check(false, "fiscal day server signature: %v", err)
+		}
+	}
+	return nil
+}
+
+// submit sends receipt and checks the server reported exactly the validation
+// codes the faults should cause
+func (s *simulation) submit(ctx context.Context, device *devicesim.Device, receipt *models.Receipt, faults []devicesim.Fault) error {
+	resp, err := device.Submit(ctx, receipt, faults...)
+	if err != nil {
+		return fmt.Errorf("submit %s: %w", receipt.ReceiptType, err)
+	}
+
+	reported := make(map[string]bool)
+	for _, validationError := range resp.ValidationErrors {
+		code, _, _ := strings.Cut(validationError, ":")
+		reported[code] = true
+	}
+
+	// A clean receipt must not be flagged at all; a faulty one must carry
+	// at least the codes of its faults
+	expected := devicesim.ExpectedCodes(faults)
+	ok := len(expected) > 0 || len(reported) == 0
+	for _, code := range expected {
+		if !reported[code] {
+			ok = false
+		}
+	}
+
+	label := fmt.Sprintf("%s #%d (global %d) %.2f %s", receipt.ReceiptType, receipt.ReceiptCounter, receipt.ReceiptGlobalNo, receipt.ReceiptTotal, receipt.ReceiptCurrency)
+	if len(resp.ValidationErrors) > 0 {
+		s.check(ok, "%s: %s", label, strings.Join(resp.ValidationErrors, "; "))
+	} else {
+		s.check(ok, "%s: accepted as receipt %d", label, resp.ReceiptID)
+	}
+
+	if err := client.VerifyReceiptSignature(s.serverCert, receipt.ReceiptDeviceSignature.Signature, resp); err != nil {
+		s.check(false, "%s: server signature: %v", label, err)
+	}
+	return nil
+}
+
+func (s *simulation) step(format string, args ...interface{}) {
+	fmt.Printf("     %s\n", fmt.Sprintf(format, args...))
+}
+
+func (s *simulation) check(ok bool, format string, args ...interface{}) {
+	mark := "ok  "
+	if !ok {
+		mark = "FAIL"
+		s.failures++
+	}
+	fmt.Printf("%s %s\n", mark, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+)
+
+// adminClient provisions the taxpayer and devices used by a run through the
+// admin API
+type adminClient struct {
+	serverURL string
+	token     string
+	client    *http.Client
+}
+
+func newAdminClient(serverURL string) *adminClient {
+	return &adminClient{
+		serverURL: strings.TrimRight(serverURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (a *adminClient) login(ctx context.Context, username, password string) error {
+	var resp models.AdminLoginResponse
+	req := models.AdminLoginRequest{Username: username, Password: password}
+	if err := a.do(ctx, http.MethodPost, "/api/admin/login", req, &resp); err != nil {
+		return err
+	}
+	a.token = resp.Token
+	return nil
+}
+
+// createTaxpayer creates the taxpayer every simulated device belongs to
+func (a *adminClient) createTaxpayer(ctx context.Context, tin, name string) (*models.Taxpayer, error) {
+	req := models.CreateTaxpayerRequest{
+		TIN:               tin,
+		Name:              name,
+		Status:            "Active",
+		TaxPayerDayMaxHrs: 24,
+	}
+
+	var taxpayer models.Taxpayer
+	if err := a.do(ctx, http.MethodPost, "/api/admin/companies", req, &taxpayer); err != nil {
+		return nil, err
+	}
+	return &taxpayer, nil
+}
+
+// provisionDevice creates an online device with a random activation key
+func (a *adminClient) provisionDevice(ctx context.Context, taxpayerID int64, deviceID int, serialNo, model, version string) (*models.Device, error) {
+	key := make([]byte, 8)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	for i := range key {
+		key[i] = alphabet[int(key[i])%len(alphabet)]
+	}
+
+	req := models.AdminCreateDeviceRequest{
+		DeviceID:           deviceID,
+		TaxpayerID:         taxpayerID,
+		DeviceSerialNo:     serialNo,
+		DeviceModelName:    model,
+		DeviceModelVersion: version,
+		ActivationKey:      string(key),
+		OperatingMode:      models.DeviceOperatingModeOnline,
+		BranchName:         fmt.Sprintf("Till %d", deviceID),
+		BranchAddress:      models.Address{Province: "Harare", City: "Harare", Street: "Load Test Ave", HouseNo: "1"},
+	}
+
+	var device models.Device
+	if err := a.do(ctx, http.MethodPost, "/api/admin/devices", req, &device); err != nil {
+		return nil, err
+	}
+	device.ActivationKey = req.ActivationKey
+	return &device, nil
+}
+
+// createUser creates the user the devices sign in as. It is a manager, since
+// cashiers cannot close fiscal days.
+func (a *adminClient) createUser(ctx context.Context, taxpayerID int64, username, password string) error {
+	req := models.AdminCreateUserRequest{
+		TaxpayerID:    taxpayerID,
+		Username:      username,
+		Password:      password,
+		PersonName:    "Load",
+		PersonSurname: "Test",
+		UserRole:      models.UserRoleManager,
+		Email:         username + "@loadtest.invalid",
+		PhoneNo:       "+263770000000",
+	}
+	var user models.AdminUserRow
+	return a.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/users", taxpayerID), req, &user)
+}
+
+func (a *adminClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.serverURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
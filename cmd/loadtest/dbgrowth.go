@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// growthTables are the tables written by receipt submission and day closing
+var growthTables = []string{
+	"receipts",
+	"receipt_lines",
+	"receipt_taxes",
+	"receipt_payments",
+	"fiscal_days",
+	"fiscal_counters",
+	"certificates_history",
+	"audit_logs",
+}
+
+// dbProbe measures row counts and on-disk size of the database behind the
+// server. DSNs are postgres://... URLs or sqlite:<path>.
+type dbProbe struct {
+	db         *sqlx.DB
+	sqlitePath string
+}
+
+type dbSnapshot struct {
+	rows       map[string]int64
+	tableBytes map[string]int64 // PostgreSQL only
+	totalBytes int64
+}
+
+func openDBProbe(dsn string) (*dbProbe, error) {
+	if path, ok := strings.CutPrefix(dsn, "sqlite:"); ok {
+		db, err := sqlx.Open("sqlite3", "file:"+path+"?mode=ro&_busy_timeout=5000")
+		if err != nil {
+			return nil, err
+		}
+		return &dbProbe{db: db, sqlitePath: path}, nil
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &dbProbe{db: db}, nil
+}
+
+func (p *dbProbe) Close() error { return p.db.Close() }
+
+func (p *dbProbe) snapshot(ctx context.Context) (*dbSnapshot, error) {
+	snap := &dbSnapshot{rows: make(map[string]int64), tableBytes: make(map[string]int64)}
+
+	for _, table := range growthTables {
+		var rows int64
+		if err := p.db.GetContext(ctx, &rows, "SELECT COUNT(*) FROM "+table); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		snap.rows[table] = rows
+
+		if p.sqlitePath == "" {
+			var size int64
+			if err := p.db.GetContext(ctx, &size, "SELECT pg_total_relation_size($1)", table); err != nil {
+				return nil, fmt.Errorf("failed to size %s: %w", table, err)
+			}
+			snap.tableBytes[table] = size
+		}
+	}
+
+	if p.sqlitePath == "" {
+		if err := p.db.GetContext(ctx, &snap.totalBytes, "SELECT pg_database_size(current_database())"); err != nil {
+			return nil, fmt.Errorf("failed to size database: %w", err)
+		}
+		return snap, nil
+	}
+
+	// The write-ahead log holds recent pages until it is checkpointed
+	for _, path := range []string{p.sqlitePath, p.sqlitePath + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			snap.totalBytes += info.Size()
+		}
+	}
+	return snap, nil
+}
+
+// reportGrowth prints the rows and bytes added between before and after
+func reportGrowth(w io.Writer, before, after *dbSnapshot) {
+	fmt.Fprintf(w, "\nDatabase growth\n")
+	for _, table := range growthTables {
+		rows := after.rows[table] - before.rows[table]
+		if rows == 0 && after.tableBytes[table] == before.tableBytes[table] {
+			continue
+		}
+		line := fmt.Sprintf("  %-20s +%d rows", table, rows)
+		if size := after.tableBytes[table] - before.tableBytes[table]; size != 0 {
+			line += ", " + signedBytes(size)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	growth := after.totalBytes - before.totalBytes
+	fmt.Fprintf(w, "  %-20s %s (now %s)\n", "total", signedBytes(growth), formatBytes(after.totalBytes))
+	if receipts := after.rows["receipts"] - before.rows["receipts"]; receipts > 0 {
+		fmt.Fprintf(w, "  %-20s %s\n", "per receipt", formatBytes(growth/receipts))
+	}
+}
+
+func signedBytes(n int64) string {
+	if n < 0 {
+		return formatBytes(n)
+	}
+	return "+" + formatBytes(n)
+}
+
+func formatBytes(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%s%d B", sign, n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s%.1f %ciB", sign, float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,297 @@
+// Command loadtest measures receipt throughput. It provisions a taxpayer with
+// many devices through the admin API, registers each device with its own
+// certificate, and has every device submit signed, chained receipts at a
+// fixed rate. At the end it reports latency percentiles, errors by error code
+// and, when given database access, how much the database grew.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"fiscalization-api/internal/devicesim"
+	"fiscalization-api/internal/models"
+)
+
+type options struct {
+	server        string
+	adminUser     string
+	adminPassword string
+	devices       int
+	firstDevice   int
+	rate          float64
+	duration      time.Duration
+	rampUp        time.Duration
+	items         int
+	setupWorkers  int
+	timeout       time.Duration
+	closeDays     bool
+	dsn           string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.server, "server", "http://localhost:8080", "API base URL")
+	flag.StringVar(&opts.adminUser, "admin-user", "superadmin", "admin API username")
+	flag.StringVar(&opts.adminPassword, "admin-password", "ZimraAdmin2024!", "admin API password")
+	flag.IntVar(&opts.devices, "devices", 20, "number of simulated devices")
+	flag.IntVar(&opts.firstDevice, "first-device", 0, "ID of the first provisioned device; 0 picks an unused range from the clock")
+	flag.Float64Var(&opts.rate, "rate", 1, "receipts per second submitted by each device")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "how long devices keep submitting")
+	flag.DurationVar(&opts.rampUp, "ramp-up", 10*time.Second, "period over which devices start submitting")
+	flag.IntVar(&opts.items, "items", 3, "lines per receipt")
+	flag.IntVar(&opts.setupWorkers, "setup-workers", 20, "devices provisioned and registered in parallel")
+	flag.DurationVar(&opts.timeout, "timeout", 30*time.Second, "HTTP request timeout")
+	flag.BoolVar(&opts.closeDays, "close", true, "close every fiscal day with device counters at the end")
+	flag.StringVar(&opts.dsn, "db", "", "database to measure growth of: postgres://... or sqlite:<path>")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("loadtest: ")
+
+	if opts.devices < 1 || opts.rate <= 0 || opts.items < 1 {
+		log.Fatal("-devices, -rate and -items must be positive")
+	}
+	if opts.firstDevice == 0 {
+		opts.firstDevice = int(time.Now().Unix()%100000)*10000 + 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, opts options) error {
+	var probe *dbProbe
+	var before *dbSnapshot
+	if opts.dsn != "" {
+		var err error
+		if probe, err = openDBProbe(opts.dsn); err != nil {
+			return err
+		}
+		defer probe.Close()
+		if before, err = probe.snapshot(ctx); err != nil {
+			return err
+		}
+	}
+
+	devices, err := setup(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("running      %d devices at %.2g receipts/s each (%.1f/s fleet-wide) for %s\n",
+		len(devices), opts.rate, opts.rate*float64(len(devices)), opts.duration)
+
+	rec := newRecorder()
+	started := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		delay := opts.rampUp * time.Duration(i) / time.Duration(len(devices))
+		wg.Add(1)
+		go func(device *devicesim.Device) {
+			defer wg.Done()
+			submitLoop(runCtx, device, delay, opts, rec)
+		}(device)
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	rec.report(os.Stdout, elapsed)
+
+	if opts.closeDays && ctx.Err() == nil {
+		closeDays(ctx, devices, opts.setupWorkers)
+	}
+
+	if probe != nil {
+		after, err := probe.snapshot(context.Background())
+		if err != nil {
+			return err
+		}
+		reportGrowth(os.Stdout, before, after)
+	}
+	return nil
+}
+
+// setup creates a taxpayer and a user of it, then provisions, registers, signs
+// the user in and opens a fiscal day for every device. Devices that fail are reported and left out of the run.
+func setup(ctx context.Context, opts options) ([]*devicesim.Device, error) {
+	admin := newAdminClient(opts.server)
+	if err := admin.login(ctx, opts.adminUser, opts.adminPassword); err != nil {
+		return nil, fmt.Errorf("admin login: %w", err)
+	}
+
+	tin := fmt.Sprintf("9%09d", opts.firstDevice%1000000000)
+	taxpayer, err := admin.createTaxpayer(ctx, tin, fmt.Sprintf("Load Test %s", time.Now().Format("2006-01-02 15:04:05")))
+	if err != nil {
+		return nil, fmt.Errorf("create taxpayer: %w", err)
+	}
+	fmt.Printf("taxpayer     %d (TIN %s), devices %d-%d\n", taxpayer.ID, tin, opts.firstDevice, opts.firstDevice+opts.devices-1)
+
+	operator := models.LoginRequest{Username: "load." + tin, Password: "Load@" + tin}
+	if err := admin.createUser(ctx, taxpayer.ID, operator.Username, operator.Password); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+
+	started := time.Now()
+	devices := make([]*devicesim.Device, opts.devices)
+	forEach(opts.devices, opts.setupWorkers, func(i int) {
+		deviceID := opts.firstDevice + i
+		device, err := setupDevice(ctx, admin, taxpayer.ID, deviceID, operator, opts)
+		if err != nil {
+			log.Printf("device %d: %v", deviceID, err)
+			return
+		}
+		devices[i] = device
+	})
+
+	ready := devices[:0]
+	for _, device := range devices {
+		if device != nil {
+			ready = append(ready, device)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, fmt.Errorf("no device could be set up")
+	}
+	fmt.Printf("setup        %d/%d devices registered with a fiscal day open in %s\n", len(ready), opts.devices, time.Since(started).Round(time.Millisecond))
+	return ready, nil
+}
+
+func setupDevice(ctx context.Context, admin *adminClient, taxpayerID int64, deviceID int, operator models.LoginRequest, opts options) (*devicesim.Device, error) {
+	const model, version = "LoadPOS", "1.0"
+	serialNo := fmt.Sprintf("LOAD-%d", deviceID)
+
+	provisioned, err := admin.provisionDevice(ctx, taxpayerID, deviceID, serialNo, model, version)
+	if err != nil {
+		return nil, fmt.Errorf("provision: %w", err)
+	}
+
+	device, err := devicesim.New(devicesim.Config{
+		ServerURL:     opts.server,
+		DeviceID:      deviceID,
+		SerialNo:      serialNo,
+		ActivationKey: provisioned.ActivationKey,
+		ModelName:     model,
+		ModelVersion:  version,
+		Timeout:       opts.timeout,
+		Username:      operator.Username,
+		Password:      operator.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := device.Register(ctx); err != nil {
+		return nil, fmt.Errorf("register: %w", err)
+	}
+	if _, err := device.FetchConfig(ctx); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if _, err := device.Login(ctx); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	if _, err := device.OpenDay(ctx); err != nil {
+		return nil, fmt.Errorf("open day: %w", err)
+	}
+	return device, nil
+}
+
+// submitLoop submits one receipt per tick until ctx is done. Ticks missed
+// while a submission is in flight are dropped, so a slow server shows up as a
+// lower achieved rate.
+func submitLoop(ctx context.Context, device *devicesim.Device, delay time.Duration, opts options, rec *recorder) {
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+	defer ticker.Stop()
+
+	for {
+		items, err := device.Basket(1 + rand.Intn(opts.items))
+		if err != nil {
+			log.Printf("device %d: %v", device.DeviceID(), err)
+			return
+		}
+		receipt := devicesim.NewReceipt(models.ReceiptTypeFiscalInvoice, "USD", models.MoneyTypeCash, items)
+
+		// A submission in flight when the run ends is allowed to finish,
+		// otherwise the server may store a receipt the device never counted
+		started := time.Now()
+		resp, err := device.Submit(context.WithoutCancel(ctx), receipt)
+		var validationErrors []string
+		if resp != nil {
+			validationErrors = resp.ValidationErrors
+		}
+		rec.record(time.Since(started), validationErrors, err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeDays closes every device's fiscal day with the counters the device
+// kept, which also checks the server stored every accepted receipt
+func closeDays(ctx context.Context, devices []*devicesim.Device, workers int) {
+	var mu sync.Mutex
+	failures := make(map[string]int)
+	forEach(len(devices), workers, func(i int) {
+		if _, err := devices[i].CloseDay(ctx); err != nil {
+			mu.Lock()
+			failures[errorClass(err)]++
+			mu.Unlock()
+		}
+	})
+
+	fmt.Printf("\nFiscal days\n")
+	closed := len(devices)
+	for _, n := range failures {
+		closed -= n
+	}
+	fmt.Printf("  closed      %d/%d\n", closed, len(devices))
+	if len(failures) > 0 {
+		printCounts(os.Stdout, failures, len(devices))
+	}
+}
+
+// forEach calls fn for 0..n-1 on up to workers goroutines
+func forEach(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
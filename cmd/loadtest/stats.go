@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiscalization-api/internal/models"
+)
+
+// recorder collects the outcome of every receipt submission
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	accepted  int
+	errors    map[string]int // by ErrCode, HTTP status or transport failure
+	flagged   map[string]int // accepted receipts by validation code
+}
+
+func newRecorder() *recorder {
+	return &recorder{errors: make(map[string]int), flagged: make(map[string]int)}
+}
+
+func (r *recorder) record(latency time.Duration, validationErrors []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies = append(r.latencies, latency)
+	if err != nil {
+		r.errors[errorClass(err)]++
+		return
+	}
+
+	r.accepted++
+	for _, validationError := range validationErrors {
+		code, _, _ := strings.Cut(validationError, ":")
+		r.flagged[code]++
+	}
+}
+
+// errorClass groups a failed submission by the error code the server
+// returned, falling back to the HTTP status or the kind of transport error
+func errorClass(err error) string {
+	var apiErr *models.APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.ErrorCode != "":
+		return apiErr.ErrorCode
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("HTTP %d", apiErr.Status)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection closed"
+	default:
+		return "transport"
+	}
+}
+
+// report prints throughput, latency percentiles and the error breakdown
+func (r *recorder) report(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := len(r.latencies)
+	fmt.Fprintf(w, "\nReceipts\n")
+	fmt.Fprintf(w, "  submitted   %d in %s (%.1f/s)\n", total, elapsed.Round(time.Second), float64(total)/elapsed.Seconds())
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  accepted    %d (%.2f%%)\n", r.accepted, percent(r.accepted, total))
+	fmt.Fprintf(w, "  failed      %d (%.2f%%)\n", total-r.accepted, percent(total-r.accepted, total))
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	fmt.Fprintf(w, "\nLatency\n")
+	for _, p := range []float64{50, 90, 95, 99, 99.9} {
+		fmt.Fprintf(w, "  p%-5v      %s\n", p, percentile(sorted, p).Round(time.Microsecond*100))
+	}
+	fmt.Fprintf(w, "  max         %s\n", sorted[len(sorted)-1].Round(time.Microsecond*100))
+
+	if len(r.errors) > 0 {
+		fmt.Fprintf(w, "\nErrors\n")
+		printCounts(w, r.errors, total)
+	}
+	if len(r.flagged) > 0 {
+		fmt.Fprintf(w, "\nValidation errors on accepted receipts\n")
+		printCounts(w, r.flagged, r.accepted)
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func printCounts(w io.Writer, counts map[string]int, of int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-18s %d (%.2f%%)\n", k, counts[k], percent(counts[k], of))
+	}
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(of)
+}
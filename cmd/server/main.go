@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,19 +14,33 @@ import (
 
 	"fiscalization-api/internal/config"
 	"fiscalization-api/internal/database"
+	"fiscalization-api/internal/email"
 	"fiscalization-api/internal/handlers"
 	"fiscalization-api/internal/middleware"
+	"fiscalization-api/internal/models"
 	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/memory"
+	"fiscalization-api/internal/repository/sqlite"
+	"fiscalization-api/internal/scheduler"
 	"fiscalization-api/internal/service"
+	"fiscalization-api/internal/sms"
+	"fiscalization-api/pkg/api"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "serve from in-memory repositories seeded with demo taxpayers and devices instead of PostgreSQL")
+	flag.Parse()
+
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		if !*demo {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		log.Printf("No configuration loaded (%v), using demo defaults", err)
+		cfg = demoConfig()
 	}
 
 	logger, err := initLogger(cfg.Server.Mode)
@@ -33,21 +49,58 @@ func main() {
 	}
 	defer logger.Sync()
 
-	db, err := database.NewConnection(cfg.Database)
-	if err != nil {
-		logger.Fatal("Failed to connect to database", zap.Error(err))
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg.Database, flag.Args()[1:], logger); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		return
 	}
-	defer database.Close(db)
 
-	logger.Info("Database connection established")
+	var repos repository.Repositories
+	var txManager repository.TxManager
+	if *demo {
+		repos, txManager = openDemoStore(logger)
+	} else {
+		db, err := database.NewConnection(cfg.Database)
+		if err != nil {
+			logger.Fatal("Failed to connect to database", zap.Error(err))
+		}
+		defer database.Close(db)
+
+		logger.Info("Database connection established", zap.String("driver", db.DriverName()))
+
+		if cfg.Database.AutoMigrate {
+			migrateOnStartup(db, cfg.Database, logger)
+		}
+
+		if cfg.Database.Driver == config.DatabaseDriverSQLite {
+			repos = sqlite.NewRepositories(db)
+			txManager = sqlite.NewTxManager(db)
+		} else {
+			repos = repository.Repositories{
+				Devices:       repository.NewDeviceRepository(db),
+				Receipts:      repository.NewReceiptRepository(db),
+				FiscalDays:    repository.NewFiscalDayRepository(db),
+				Users:         repository.NewUserRepository(db),
+				Admin:         repository.NewAdminRepository(db),
+				Notifications: repository.NewNotificationRepository(db),
+			}
+			txManager = repository.NewTxManager(db)
+		}
+	}
 
-	deviceRepo    := repository.NewDeviceRepository(db)
-	receiptRepo   := repository.NewReceiptRepository(db)
-	fiscalDayRepo := repository.NewFiscalDayRepository(db)
-	userRepo      := repository.NewUserRepository(db)
-	adminRepo     := repository.NewAdminRepository(db)
+	deviceRepo       := repos.Devices
+	receiptRepo      := repos.Receipts
+	fiscalDayRepo    := repos.FiscalDays
+	userRepo         := repos.Users
+	adminRepo        := repos.Admin
+	notificationRepo := repos.Notifications
 
 	cryptoSvc, err := service.NewCryptoService(cfg.Crypto)
+	if err != nil && *demo {
+		logger.Warn("Using an ephemeral CA and server certificate for demo mode", zap.Error(err))
+		cryptoSvc, err = service.NewEphemeralCryptoService(cfg.Crypto)
+	}
 	if err != nil {
 		logger.Fatal("Failed to initialize crypto service", zap.Error(err))
 	}
@@ -60,27 +113,58 @@ func main() {
 	validationSvc := service.NewValidationService()
 	deviceSvc     := service.NewDeviceService(deviceRepo, cryptoSvc, logger)
 	receiptSvc    := service.NewReceiptService(receiptRepo, fiscalDayRepo, deviceRepo, validationSvc, cryptoSvc, logger)
-	fiscalDaySvc  := service.NewFiscalDayService(fiscalDayRepo, receiptRepo, deviceRepo, cryptoSvc, logger)
-	userSvc       := service.NewUserService(userRepo, deviceRepo, jwtSecret, logger)
-	adminSvc      := service.NewAdminService(adminRepo, jwtSecret, logger)
+	fiscalDaySvc  := service.NewFiscalDayService(fiscalDayRepo, receiptRepo, deviceRepo, txManager, cryptoSvc, logger)
+	adminSvc      := service.NewAdminService(adminRepo, notificationRepo, fiscalDaySvc, jwtSecret, logger)
+	reportSvc     := service.NewReportService(fiscalDayRepo, deviceRepo, logger)
+
+	emailSender, err := newEmailSender(cfg.SMTP, cfg.Email, logger)
+	if err != nil {
+		logger.Fatal("Failed to load email templates", zap.Error(err))
+	}
+
+	notifier         := service.NewNotifier(emailSender, newSMSSender(cfg.SMS, logger), adminRepo, logger)
+	userSvc          := service.NewUserService(userRepo, deviceRepo, txManager, notifier, jwtSecret, logger)
+	fiscalDayMonitor := service.NewFiscalDayMonitor(fiscalDayRepo, deviceRepo, txManager, fiscalDaySvc, notifier, logger)
+	outbox           := service.NewNotificationOutbox(notificationRepo, notifier, logger)
+
+	sched := scheduler.NewScheduler(logger)
+	sched.Add("notification-outbox",
+		intervalSeconds(cfg.Scheduler.OutboxIntervalSeconds, 30), outbox.DeliverDue)
+	if cfg.Scheduler.Enabled {
+		sched.Add("fiscal-day-auto-close",
+			intervalMinutes(cfg.Scheduler.AutoCloseIntervalMinutes, 5), fiscalDayMonitor.AutoCloseExpiredDays)
+		sched.Add("fiscal-day-end-notification",
+			intervalMinutes(cfg.Scheduler.EndNotificationIntervalMinutes, 15), fiscalDayMonitor.NotifyEndingDays)
+	}
+	sched.Start(context.Background())
+	logger.Info("Scheduler started")
 
 	healthHandler    := handlers.NewHealthHandler()
+	errorHandler     := handlers.NewErrorHandler()
 	deviceHandler    := handlers.NewDeviceHandler(deviceSvc)
 	receiptHandler   := handlers.NewReceiptHandler(receiptSvc)
 	fiscalDayHandler := handlers.NewFiscalDayHandler(fiscalDaySvc)
 	userHandler      := handlers.NewUserHandler(userSvc)
 	adminHandler     := handlers.NewAdminHandler(adminSvc)
+	reportHandler    := handlers.NewReportHandler(reportSvc)
 
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
-	router.Use(gin.Recovery())
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		api.ErrorResponse(c, fmt.Errorf("panic: %v", recovered))
+	}))
 	router.Use(middleware.LoggerMiddleware(logger))
 	router.Use(middleware.CORSMiddleware())
 
-	setupRoutes(router, healthHandler, deviceHandler, receiptHandler, fiscalDayHandler, userHandler, adminHandler, jwtSecret, logger)
+	setupRoutes(router, healthHandler, errorHandler, deviceHandler, receiptHandler, fiscalDayHandler, userHandler, adminHandler, reportHandler, cfg.Server.RequestTimeouts, jwtSecret, userSvc, logger)
+
+	// Request contexts derive from baseCtx so requests still running when the
+	// shutdown grace period ends are cancelled along with their SQL
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
 	srv := &http.Server{
 		Addr:           fmt.Sprintf(":%d", cfg.Server.Port),
@@ -88,6 +172,7 @@ func main() {
 		ReadTimeout:    time.Duration(cfg.Server.ReadTimeout) * time.Second,
 		WriteTimeout:   time.Duration(cfg.Server.WriteTimeout) * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
 	}
 
 	go func() {
@@ -102,10 +187,13 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	sched.Stop()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
+		cancelRequests()
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 	logger.Info("Server exited")
@@ -118,54 +206,263 @@ func initLogger(mode string) (*zap.Logger, error) {
 	return zap.NewDevelopment()
 }
 
+// demoConfig is used by --demo when no configuration file is available
+func demoConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{
+			Port:         8080,
+			Mode:         "development",
+			ReadTimeout:  30,
+			WriteTimeout: 30,
+		},
+	}
+}
+
+// openDemoStore returns in-memory repositories seeded with demo data. Nothing
+// is persisted; every restart starts from the same seed.
+func openDemoStore(logger *zap.Logger) (repository.Repositories, repository.TxManager) {
+	store := memory.NewStore()
+	devices, err := memory.SeedDemo(context.Background(), store)
+	if err != nil {
+		logger.Fatal("Failed to seed demo data", zap.Error(err))
+	}
+
+	logger.Warn("Running in demo mode: data is kept in memory and lost on exit")
+	for _, device := range devices {
+		logger.Info("Demo device",
+			zap.Int("deviceID", device.DeviceID),
+			zap.String("serialNo", device.DeviceSerialNo),
+			zap.String("activationKey", device.ActivationKey),
+			zap.Int64("taxpayerID", device.TaxpayerID))
+	}
+	logger.Info("Demo users can log in with the demo password", zap.String("password", memory.DemoPassword))
+
+	return store.Repositories(), memory.NewTxManager(store)
+}
+
+// intervalMinutes converts a configured job interval, falling back to def when unset
+func intervalMinutes(configured, def int) time.Duration {
+	if configured <= 0 {
+		configured = def
+	}
+	return time.Duration(configured) * time.Minute
+}
+
+// intervalSeconds converts a configured job interval in seconds, falling back to def when unset
+func intervalSeconds(configured, def int) time.Duration {
+	if configured <= 0 {
+		configured = def
+	}
+	return time.Duration(configured) * time.Second
+}
+
+// defaultRequestTimeout applies when neither the route group nor the default
+// request timeout is configured
+const defaultRequestTimeout = 30
+
+// requestTimeout converts a configured request timeout in seconds, falling
+// back to def and then to defaultRequestTimeout when unset
+func requestTimeout(configured, def int) time.Duration {
+	if configured <= 0 {
+		configured = def
+	}
+	if configured <= 0 {
+		configured = defaultRequestTimeout
+	}
+	return time.Duration(configured) * time.Second
+}
+
+// newEmailSender returns the SMTP email service, or a logging mock when no
+// SMTP host is configured
+func newEmailSender(cfg config.SMTPConfig, emailCfg config.EmailConfig, logger *zap.Logger) (service.EmailSender, error) {
+	templates, err := email.NewTemplates(emailCfg.TemplatesDir, email.Branding{
+		ProductName:  emailCfg.ProductName,
+		LogoURL:      emailCfg.LogoURL,
+		Color:        emailCfg.Color,
+		SupportEmail: emailCfg.SupportEmail,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Host == "" {
+		return email.NewMockEmailService(templates, logger), nil
+	}
+	return email.NewEmailService(email.EmailConfig{
+		SMTPHost:     cfg.Host,
+		SMTPPort:     cfg.Port,
+		SMTPUsername: cfg.Username,
+		SMTPPassword: cfg.Password,
+		FromAddress:  cfg.From,
+	}, templates, logger), nil
+}
+
+// newSMSSender returns an SMS service over the configured providers, or over
+// a logging stub when none is configured
+func newSMSSender(cfg config.SMSConfig, logger *zap.Logger) service.SMSSender {
+	providers := cfg.Providers
+	if len(providers) == 0 && cfg.APIURL != "" {
+		providers = []config.SMSProviderConfig{{Name: cfg.Provider, Type: "http", APIURL: cfg.APIURL, APIKey: cfg.APIKey, Sender: cfg.Sender}}
+	}
+
+	smsCfg := sms.SMSConfig{DefaultCountryCode: cfg.DefaultCountryCode}
+	for i, p := range providers {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("sms-%d", i+1)
+		}
+
+		var provider sms.SMSProvider
+		switch p.Type {
+		case "", "http":
+			provider = sms.NewHTTPProvider(sms.HTTPProviderConfig{
+				Name:     name,
+				APIURL:   p.APIURL,
+				APIKey:   p.APIKey,
+				SenderID: p.Sender,
+			})
+		case "stub":
+			provider = sms.NewStubProvider(name, logger)
+		default:
+			logger.Fatal("Unknown SMS provider type", zap.String("provider", name), zap.String("type", p.Type))
+		}
+		smsCfg.Providers = append(smsCfg.Providers, sms.ProviderConfig{
+			Provider: provider,
+			Timeout:  time.Duration(p.TimeoutSeconds) * time.Second,
+		})
+	}
+
+	if len(smsCfg.Providers) == 0 {
+		smsCfg.Providers = []sms.ProviderConfig{{Provider: sms.NewStubProvider("stub", logger)}}
+	}
+	return sms.NewSMSService(smsCfg, logger)
+}
+
 func setupRoutes(
 	router *gin.Engine,
 	healthHandler *handlers.HealthHandler,
+	errorHandler *handlers.ErrorHandler,
 	deviceHandler *handlers.DeviceHandler,
 	receiptHandler *handlers.ReceiptHandler,
 	fiscalDayHandler *handlers.FiscalDayHandler,
 	userHandler *handlers.UserHandler,
 	adminHandler *handlers.AdminHandler,
+	reportHandler *handlers.ReportHandler,
+	timeouts config.RequestTimeoutConfig,
 	jwtSecret string,
+	operatorAuth middleware.OperatorAuthenticator,
 	logger *zap.Logger,
 ) {
+	// Deadlines are set per route group; nested deadlines can only shorten
+	// an outer one, so no group-wide default is installed above them
+	deviceTimeout    := middleware.TimeoutMiddleware(requestTimeout(timeouts.Device, timeouts.Default))
+	fiscalDayTimeout := middleware.TimeoutMiddleware(requestTimeout(timeouts.FiscalDay, timeouts.Default))
+	receiptTimeout   := middleware.TimeoutMiddleware(requestTimeout(timeouts.Receipt, timeouts.Default))
+	reportTimeout    := middleware.TimeoutMiddleware(requestTimeout(timeouts.Report, timeouts.Default))
+	userTimeout      := middleware.TimeoutMiddleware(requestTimeout(timeouts.User, timeouts.Default))
+	adminTimeout     := middleware.TimeoutMiddleware(requestTimeout(timeouts.Admin, timeouts.Default))
+
+	// Operations performed by a user of the taxpayer need the operator's
+	// token as well as the device certificate, and a role allowed to do them
+	operator := middleware.JWTAuthMiddleware(operatorAuth, logger)
+	can := func(permission models.Permission) gin.HandlerFunc {
+		return middleware.RequirePermission(permission, logger)
+	}
+
 	router.GET("/health", healthHandler.Health)
+	router.NoRoute(func(c *gin.Context) {
+		api.NotFoundResponse(c, "Route not found")
+	})
 
 	v1 := router.Group("/api/v1")
 	{
-		v1.POST("/device/verify-taxpayer", deviceHandler.VerifyTaxpayer)
-		v1.POST("/device/register", deviceHandler.RegisterDevice)
-		v1.GET("/server/certificate", deviceHandler.GetServerCertificate)
-		v1.POST("/users/login", userHandler.Login)
+		v1.GET("/errors", errorHandler.ListErrorCodes)
+		v1.GET("/errors/:code", errorHandler.GetErrorCode)
+		v1.POST("/device/verify-taxpayer", deviceTimeout, deviceHandler.VerifyTaxpayer)
+		v1.POST("/device/register", deviceTimeout, deviceHandler.RegisterDevice)
+		v1.GET("/server/certificate", deviceTimeout, deviceHandler.GetServerCertificate)
+		v1.POST("/users/login", userTimeout, userHandler.Login)
 
 		protected := v1.Group("")
 		protected.Use(middleware.CertificateAuthMiddleware(logger))
 		{
-			device := protected.Group("/device")
+			device := protected.Group("/device", deviceTimeout)
 			device.POST("/issue-certificate", deviceHandler.IssueCertificate)
 			device.GET("/config", deviceHandler.GetConfig)
 			device.GET("/status", deviceHandler.GetStatus)
 			device.POST("/ping", deviceHandler.Ping)
 
-			fd := protected.Group("/fiscal-day")
-			fd.POST("/open", fiscalDayHandler.OpenFiscalDay)
-			fd.POST("/close", fiscalDayHandler.CloseFiscalDay)
+			fd := protected.Group("/fiscal-day", fiscalDayTimeout)
+			fd.POST("/open", operator, can(models.PermissionOpenFiscalDay), fiscalDayHandler.OpenFiscalDay)
+			fd.POST("/close", operator, can(models.PermissionCloseFiscalDay), fiscalDayHandler.CloseFiscalDay)
 			fd.GET("/status", fiscalDayHandler.GetStatus)
+			fd.GET("/:fiscalDayNo", fiscalDayHandler.GetFiscalDay)
+
+			protected.Group("/receipt", receiptTimeout).POST("/submit", operator, can(models.PermissionSubmitReceipt), receiptHandler.SubmitReceipt)
+			protected.Group("/stock", deviceTimeout).GET("/list", operator, can(models.PermissionViewStock), deviceHandler.GetStockList)
 
-			protected.Group("/receipt").POST("/submit", receiptHandler.SubmitReceipt)
-			protected.Group("/stock").GET("/list", deviceHandler.GetStockList)
+			reports := protected.Group("/reports", reportTimeout, operator, can(models.PermissionViewReports))
+			reports.GET("/x", reportHandler.GetXReport)
+			reports.GET("/z", reportHandler.GetZReport)
 
-			users := protected.Group("/users")
-			users.GET("/list", userHandler.ListUsers)
-			users.POST("/create-begin", userHandler.CreateUserBegin)
+			users := protected.Group("/users", userTimeout)
+			users.GET("/list", operator, can(models.PermissionManageUsers), userHandler.ListUsers)
+			users.POST("/create-begin", operator, can(models.PermissionManageUsers), userHandler.CreateUserBegin)
 			users.POST("/create-confirm", userHandler.CreateUserConfirm)
-			users.PUT("/update", userHandler.UpdateUser)
-			users.PUT("/change-password", userHandler.ChangePassword)
+			users.POST("/refresh", userHandler.RefreshToken)
+			users.POST("/logout", operator, userHandler.Logout)
+			users.GET("/sessions", operator, userHandler.ListSessions)
+			users.PUT("/update", operator, can(models.PermissionManageUsers), userHandler.UpdateUser)
+			users.PUT("/change-password", operator, userHandler.ChangePassword)
+			users.POST("/reset-password-begin", userHandler.ResetPasswordBegin)
+			users.POST("/reset-password-confirm", userHandler.ResetPasswordConfirm)
+			users.POST("/contact-change-begin", userHandler.ContactChangeBegin)
+			users.POST("/contact-change-confirm", userHandler.ContactChangeConfirm)
 		}
 	}
 
+	// ZIMRA FDMS paths, so certified devices work unchanged. The device ID is
+	// part of the path and must match the client certificate.
+	public := router.Group("/Public/v1")
+	{
+		public.POST("/:deviceID/VerifyTaxpayerInformation", deviceTimeout, deviceHandler.VerifyTaxpayer)
+		public.POST("/:deviceID/RegisterDevice", deviceTimeout, deviceHandler.RegisterDevice)
+		public.GET("/GetServerCertificate", deviceTimeout, deviceHandler.GetServerCertificate)
+	}
+
+	fdmsDevice := router.Group("/Device/v1/:deviceID")
+	fdmsDevice.Use(middleware.CertificateAuthMiddleware(logger), middleware.DevicePathMiddleware(logger))
+	{
+		fdmsDevice.POST("/IssueCertificate", deviceTimeout, deviceHandler.IssueCertificate)
+		fdmsDevice.GET("/GetConfig", deviceTimeout, deviceHandler.GetConfig)
+		fdmsDevice.GET("/GetStatus", deviceTimeout, deviceHandler.GetStatus)
+		fdmsDevice.POST("/Ping", deviceTimeout, deviceHandler.Ping)
+		fdmsDevice.GET("/GetStockList", deviceTimeout, operator, can(models.PermissionViewStock), deviceHandler.GetStockList)
+		fdmsDevice.POST("/OpenDay", fiscalDayTimeout, operator, can(models.PermissionOpenFiscalDay), fiscalDayHandler.OpenFiscalDay)
+		fdmsDevice.POST("/CloseDay", fiscalDayTimeout, operator, can(models.PermissionCloseFiscalDay), fiscalDayHandler.CloseFiscalDay)
+		fdmsDevice.POST("/SubmitReceipt", receiptTimeout, operator, can(models.PermissionSubmitReceipt), receiptHandler.SubmitReceipt)
+	}
+
+	fdmsUser := router.Group("/User/v1/:deviceID", userTimeout)
+	fdmsUser.Use(middleware.CertificateAuthMiddleware(logger), middleware.DevicePathMiddleware(logger))
+	{
+		fdmsUser.POST("/Login", userHandler.Login)
+		fdmsUser.POST("/RefreshToken", userHandler.RefreshToken)
+		fdmsUser.POST("/Logout", operator, userHandler.Logout)
+		fdmsUser.GET("/GetUserSessions", operator, userHandler.ListSessions)
+		fdmsUser.GET("/GetUsersList", operator, can(models.PermissionManageUsers), userHandler.ListUsers)
+		fdmsUser.POST("/CreateUserBegin", operator, can(models.PermissionManageUsers), userHandler.CreateUserBegin)
+		fdmsUser.POST("/CreateUserConfirm", userHandler.CreateUserConfirm)
+		fdmsUser.POST("/UpdateUser", operator, can(models.PermissionManageUsers), userHandler.UpdateUser)
+		fdmsUser.POST("/ChangePassword", operator, userHandler.ChangePassword)
+		fdmsUser.POST("/ResetUserPasswordBegin", userHandler.ResetPasswordBegin)
+		fdmsUser.POST("/ResetUserPasswordConfirm", userHandler.ResetPasswordConfirm)
+		fdmsUser.POST("/SendSecurityCodeContactChange", userHandler.ContactChangeBegin)
+		fdmsUser.POST("/ConfirmUserContactChange", userHandler.ContactChangeConfirm)
+	}
+
 	// Admin API - separate prefix, separate auth
-	admin := router.Group("/api/admin")
+	admin := router.Group("/api/admin", adminTimeout)
 	admin.POST("/login", adminHandler.Login)
 
 	ap := admin.Group("")
@@ -188,9 +485,16 @@ func setupRoutes(
 		dv.POST("", adminHandler.ProvisionDevice)
 		dv.PATCH("/:deviceID/status", adminHandler.SetDeviceStatus)
 		dv.PATCH("/:deviceID/mode", adminHandler.SetDeviceMode)
+		dv.POST("/:deviceID/fiscal-days/:fiscalDayNo/force-close", adminHandler.ForceCloseFiscalDay)
+		dv.POST("/:deviceID/fiscal-days/:fiscalDayNo/reset", adminHandler.ResetFiscalDay)
 
 		ap.GET("/fiscal-days", adminHandler.ListFiscalDays)
 		ap.GET("/receipts", adminHandler.ListReceipts)
 		ap.GET("/audit", adminHandler.ListAuditLogs)
+
+		nt := ap.Group("/notifications")
+		nt.GET("", adminHandler.ListNotifications)
+		nt.GET("/:id", adminHandler.GetNotification)
+		nt.POST("/:id/resend", adminHandler.ResendNotification)
 	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"fiscalization-api/internal/config"
+	"fiscalization-api/internal/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status | force <version>"
+
+// runMigrate handles the migrate subcommand against the configured database
+func runMigrate(cfg config.DatabaseConfig, args []string, logger *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.NewConnection(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.Close(db)
+
+	migrator, err := database.NewMigrator(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Info("Applied migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		if err == nil && len(applied) == 0 {
+			logger.Info("No pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Info("Reverted migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		return err
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d (dirty: %t)\n", status.Version, status.Dirty)
+		for _, m := range status.Migrations {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("  %06d  %-8s  %s\n", m.Version, state, m.Name)
+		}
+		return nil
+
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		logger.Info("Forced schema version", zap.Int64("version", version))
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// migrateOnStartup applies pending migrations before the server starts
+func migrateOnStartup(db *sqlx.DB, cfg config.DatabaseConfig, logger *zap.Logger) {
+	migrator, err := database.NewMigrator(db, cfg)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", zap.Error(err))
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		logger.Info("Applied migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+	}
+	if err != nil {
+		logger.Fatal("Failed to apply migrations", zap.Error(err))
+	}
+}
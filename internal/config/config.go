@@ -8,12 +8,14 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Crypto   CryptoConfig   `yaml:"crypto"`
-	Redis    RedisConfig    `yaml:"redis"`
-	SMTP     SMTPConfig     `yaml:"smtp"`
-	SMS      SMSConfig      `yaml:"sms"`
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Crypto    CryptoConfig    `yaml:"crypto"`
+	Redis     RedisConfig     `yaml:"redis"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Email     EmailConfig     `yaml:"email"`
+	SMS       SMSConfig       `yaml:"sms"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
 }
 
 type ServerConfig struct {
@@ -21,9 +23,31 @@ type ServerConfig struct {
 	Mode         string `yaml:"mode"` // development, production
 	ReadTimeout  int    `yaml:"read_timeout"`
 	WriteTimeout int    `yaml:"write_timeout"`
+
+	RequestTimeouts RequestTimeoutConfig `yaml:"request_timeouts"`
+}
+
+// RequestTimeoutConfig holds the per-route-group request deadlines in
+// seconds. Groups left at zero use Default.
+type RequestTimeoutConfig struct {
+	Default   int `yaml:"default"`
+	Device    int `yaml:"device"`
+	FiscalDay int `yaml:"fiscal_day"`
+	Receipt   int `yaml:"receipt"`
+	Report    int `yaml:"report"`
+	User      int `yaml:"user"`
+	Admin     int `yaml:"admin"`
 }
 
+// Database drivers accepted in DatabaseConfig.Driver
+const (
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+)
+
 type DatabaseConfig struct {
+	Driver          string `yaml:"driver"` // postgres (default) or sqlite
+	Path            string `yaml:"path"`   // database file, sqlite only
 	Host            string `yaml:"host"`
 	Port            int    `yaml:"port"`
 	User            string `yaml:"user"`
@@ -32,7 +56,8 @@ type DatabaseConfig struct {
 	SSLMode         string `yaml:"sslmode"`
 	MaxOpenConns    int    `yaml:"max_open_conns"`
 	MaxIdleConns    int    `yaml:"max_idle_conns"`
-	MigrationsPath  string `yaml:"migrations_path"`
+	MigrationsPath  string `yaml:"migrations_path"` // overrides the migrations embedded in the binary
+	AutoMigrate     bool   `yaml:"auto_migrate"`    // apply pending migrations at startup
 }
 
 type CryptoConfig struct {
@@ -57,10 +82,46 @@ type SMTPConfig struct {
 	From     string `yaml:"from"`
 }
 
+// EmailConfig configures the content of emails. TemplatesDir holds
+// templates that replace the built-in ones file by file; the other fields
+// brand every email.
+type EmailConfig struct {
+	TemplatesDir string `yaml:"templates_dir"`
+	ProductName  string `yaml:"product_name"`
+	LogoURL      string `yaml:"logo_url"`
+	Color        string `yaml:"color"`
+	SupportEmail string `yaml:"support_email"`
+}
+
+// SMSConfig configures SMS delivery. Providers are tried in the order
+// listed; the single gateway fields are kept for older configurations and
+// used when no providers are listed.
 type SMSConfig struct {
-	Provider string `yaml:"provider"`
-	APIKey   string `yaml:"api_key"`
-	Sender   string `yaml:"sender"`
+	Provider           string              `yaml:"provider"`
+	APIURL             string              `yaml:"api_url"`
+	APIKey             string              `yaml:"api_key"`
+	Sender             string              `yaml:"sender"`
+	DefaultCountryCode string              `yaml:"default_country_code"`
+	Providers          []SMSProviderConfig `yaml:"providers"`
+}
+
+// SMSProviderConfig is one SMS gateway. Type is http or stub.
+type SMSProviderConfig struct {
+	Name           string `yaml:"name"`
+	Type           string `yaml:"type"`
+	APIURL         string `yaml:"api_url"`
+	APIKey         string `yaml:"api_key"`
+	Sender         string `yaml:"sender"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+// SchedulerConfig configures the background jobs. Enabled switches the fiscal
+// day jobs; the notification outbox is always delivered.
+type SchedulerConfig struct {
+	Enabled                        bool `yaml:"enabled"`
+	AutoCloseIntervalMinutes       int  `yaml:"auto_close_interval_minutes"`
+	EndNotificationIntervalMinutes int  `yaml:"end_notification_interval_minutes"`
+	OutboxIntervalSeconds          int  `yaml:"outbox_interval_seconds"`
 }
 
 func Load() (*Config, error) {
@@ -8,10 +8,15 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 // NewConnection creates a new database connection
 func NewConnection(cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	if cfg.Driver == config.DatabaseDriverSQLite {
+		return newSQLiteConnection(cfg)
+	}
+
 	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
@@ -35,6 +40,32 @@ func NewConnection(cfg config.DatabaseConfig) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// newSQLiteConnection opens the database file at cfg.Path. Transactions take
+// the write lock when they begin (_txlock=immediate), which the SQLite
+// repositories rely on in place of row locks.
+func newSQLiteConnection(cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("database path is required for the sqlite driver")
+	}
+
+	dsn := "file:" + cfg.Path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+	db, err := sqlx.Connect("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// SQLite allows a single writer; more connections only queue on the lock
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 || maxOpen > 4 {
+		maxOpen = 4
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxOpen)
+
+	return db, nil
+}
+
 // Close closes the database connection
 func Close(db *sqlx.DB) error {
 	if db != nil {
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"fiscalization-api/internal/config"
+	"fiscalization-api/migrations"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationsTable has the same layout golang-migrate uses, so databases it
+// migrated before are picked up where it left off
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating, so
+// servers starting together do not apply the same migration twice
+const migrationLockID = 7305120241
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes the schema version of a database
+type MigrationStatus struct {
+	Version    int64 // 0 when nothing has been applied
+	Dirty      bool
+	Migrations []AppliedMigration
+}
+
+// AppliedMigration is a known migration and whether the database has it
+type AppliedMigration struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies migrations and records the schema version in
+// schema_migrations
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations for cfg.Driver. They are read from
+// cfg.MigrationsPath when it is set, otherwise from the copy embedded in the
+// binary.
+func NewMigrator(db *sqlx.DB, cfg config.DatabaseConfig) (*Migrator, error) {
+	var source fs.FS = migrations.FS
+	if cfg.MigrationsPath != "" {
+		source = os.DirFS(cfg.MigrationsPath)
+	}
+
+	dir := "."
+	if cfg.Driver == config.DatabaseDriverSQLite {
+		dir = "sqlite"
+	}
+
+	loaded, err := LoadMigrations(source, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: loaded}, nil
+}
+
+// LoadMigrations reads the migration files in dir, ordered by version
+func LoadMigrations(source fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		body, err := fs.ReadFile(source, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	loaded := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		loaded = append(loaded, *m)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+
+	return loaded, nil
+}
+
+// Up applies every pending migration, each in its own transaction together
+// with the version update, and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps migrations, newest first, and returns the ones
+// reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			var previous int64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := m.apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force records version as applied and clears the dirty flag without running
+// anything, for databases whose schema was created or repaired by hand
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version < 0 {
+		return fmt.Errorf("invalid version %d", version)
+	}
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		return m.setVersion(ctx, conn, version)
+	})
+}
+
+// Status reports the recorded version and which migrations it includes
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	status := &MigrationStatus{}
+	if _, err := conn.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, err
+	}
+	if status.Version, status.Dirty, err = currentVersion(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		status.Migrations = append(status.Migrations, AppliedMigration{
+			Migration: migration,
+			Applied:   migration.Version <= status.Version,
+		})
+	}
+	return status, nil
+}
+
+// locked runs fn on a single connection with the schema table in place. On
+// PostgreSQL the advisory lock is held for the duration; SQLite transactions
+// already take the database write lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.db.DriverName() == "postgres" {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, migrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// cleanVersion returns the recorded version, refusing to go on when an
+// earlier run was left dirty
+func (m *Migrator) cleanVersion(ctx context.Context, conn *sqlx.Conn) (int64, error) {
+	version, dirty, err := currentVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d; fix the schema and run migrate force", version)
+	}
+	return version, nil
+}
+
+// apply runs body and records version in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, body string, version int64) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if err := m.setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func currentVersion(ctx context.Context, db sqlx.QueryerContext) (int64, bool, error) {
+	var row struct {
+		Version int64 `db:"version"`
+		Dirty   bool  `db:"dirty"`
+	}
+	err := sqlx.GetContext(ctx, db, &row, `SELECT version, dirty FROM schema_migrations LIMIT 1`)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return row.Version, row.Dirty, nil
+}
+
+// setVersion replaces the single schema_migrations row; version 0 means
+// nothing is applied and leaves the table empty
+func (m *Migrator) setVersion(ctx context.Context, db sqlx.ExecerContext, version int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+
+	query := m.db.Rebind(`INSERT INTO schema_migrations (version, dirty) VALUES (?, false)`)
+	_, err := db.ExecContext(ctx, query, version)
+	return err
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"fiscalization-api/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []int64
+		wantErr      bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"000010_later.up.sql":    file("SELECT 10"),
+				"000002_second.up.sql":   file("SELECT 2"),
+				"000002_second.down.sql": file("SELECT -2"),
+				"000001_first.up.sql":    file("SELECT 1"),
+				"README.md":              file("ignored"),
+				"sqlite/000001_x.up.sql": file("ignored"),
+			},
+			wantVersions: []int64{1, 2, 10},
+		},
+		{
+			name:    "down without up",
+			files:   fstest.MapFS{"000001_first.down.sql": file("SELECT 1")},
+			wantErr: true,
+		},
+		{
+			name: "same version with two names",
+			files: fstest.MapFS{
+				"000001_first.up.sql": file("SELECT 1"),
+				"000001_other.up.sql": file("SELECT 1"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadMigrations(tt.files, ".")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(loaded) != len(tt.wantVersions) {
+				t.Fatalf("LoadMigrations() returned %d migrations, want %d", len(loaded), len(tt.wantVersions))
+			}
+			for i, m := range loaded {
+				if m.Version != tt.wantVersions[i] {
+					t.Errorf("migration %d version = %d, want %d", i, m.Version, tt.wantVersions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsMatchAcrossDrivers(t *testing.T) {
+	postgres, err := LoadMigrations(migrations.FS, ".")
+	if err != nil {
+		t.Fatalf("postgres migrations: %v", err)
+	}
+	sqlite, err := LoadMigrations(migrations.FS, "sqlite")
+	if err != nil {
+		t.Fatalf("sqlite migrations: %v", err)
+	}
+
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite has %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: postgres %d_%s, sqlite %d_%s",
+				i, postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package devicesim drives the device side of the FDMS protocol: it
+// registers a device, opens and closes fiscal days and submits chained,
+// signed receipts, so the server can be exercised end to end.
+package devicesim
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/pkg/client"
+)
+
+// Config describes the simulated device and the server it talks to
+type Config struct {
+	ServerURL     string // e.g. http://localhost:8080
+	DeviceID      int
+	SerialNo      string
+	ActivationKey string
+	ModelName     string
+	ModelVersion  string
+	CAFile        string // CA used to verify the server over https
+	Insecure      bool   // skip server certificate verification over https
+	Timeout       time.Duration
+	Username      string // operator signed in for fiscal day and receipt operations
+	Password      string
+}
+
+// Device is a simulated fiscal device built on the client SDK. It keeps the
+// receipt chain of the open fiscal day and the counters the server is
+// expected to compute for it. A Device is not safe for concurrent use.
+type Device struct {
+	cfg    Config
+	key    *ecdsa.PrivateKey
+	client *client.Client
+	chain  *client.Chain
+	config *models.GetConfigResponse
+
+	// session is the operator's session started by Login
+	session models.SessionTokens
+}
+
+// New creates a device with a fresh P-256 key pair
+func New(cfg Config) (*Device, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(cfg.ServerURL, cfg.DeviceID,
+		client.WithHTTPClient(httpClient),
+		client.WithDeviceModel(cfg.ModelName, cfg.ModelVersion),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Device{
+		cfg:    cfg,
+		key:    key,
+		client: c,
+		chain:  client.NewChain(cfg.DeviceID, 0),
+	}, nil
+}
+
+// DeviceID returns the FDMS device ID
+func (d *Device) DeviceID() int { return d.cfg.DeviceID }
+
+// FiscalDayNo returns the number of the fiscal day last opened
+func (d *Device) FiscalDayNo() int { return d.chain.FiscalDayNo() }
+
+// GlobalNo returns the global number of the last submitted receipt
+func (d *Device) GlobalNo() int { return d.chain.GlobalNo() }
+
+// Config returns the configuration fetched by FetchConfig
+func (d *Device) Config() *models.GetConfigResponse { return d.config }
+
+// Client returns the API client the device uses
+func (d *Device) Client() *client.Client { return d.client }
+
+// CertificateRequest builds the PEM CSR sent on registration
+func (d *Device) CertificateRequest() (string, error) {
+	return client.NewCertificateRequest(d.key, d.cfg.SerialNo, d.cfg.DeviceID)
+}
+
+// VerifyTaxpayer checks the activation key and returns the taxpayer the
+// device belongs to
+func (d *Device) VerifyTaxpayer(ctx context.Context) (*models.VerifyTaxpayerResponse, error) {
+	return d.client.VerifyTaxpayer(ctx, d.cfg.ActivationKey, d.cfg.SerialNo)
+}
+
+// Register sends the CSR and switches to the issued certificate for all
+// further requests
+func (d *Device) Register(ctx context.Context) error {
+	csr, err := d.CertificateRequest()
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Register(ctx, d.cfg.ActivationKey, csr)
+	if err != nil {
+		return err
+	}
+	return d.client.SetCertificate(resp.Certificate, d.key)
+}
+
+// Login signs the configured operator in, so that fiscal days can be opened
+// and closed and receipts submitted. The device must be registered.
+func (d *Device) Login(ctx context.Context) (*models.User, error) {
+	resp, err := d.client.Login(ctx, d.cfg.Username, d.cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	d.client.SetOperatorToken(resp.Token)
+	d.session = resp.SessionTokens
+	return &resp.User, nil
+}
+
+// tokenRenewMargin is how long before it expires the operator's access
+// token is renewed
+const tokenRenewMargin = time.Minute
+
+// renewOperator replaces the operator's access token with the session's
+// refresh token when it is about to expire, so that long runs keep working
+func (d *Device) renewOperator(ctx context.Context) error {
+	if d.session.RefreshToken == "" || time.Until(d.session.ExpiresAt) > tokenRenewMargin {
+		return nil
+	}
+	resp, err := d.client.RefreshToken(ctx, d.session.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to renew operator token: %w", err)
+	}
+	d.client.SetOperatorToken(resp.Token)
+	d.session = resp.SessionTokens
+	return nil
+}
+
+// Certificate returns the PEM certificate issued on registration
+func (d *Device) Certificate() string { return d.client.Certificate() }
+
+// FetchConfig loads the taxpayer configuration and applicable taxes
+func (d *Device) FetchConfig(ctx context.Context) (*models.GetConfigResponse, error) {
+	resp, err := d.client.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.config = resp
+	return resp, nil
+}
+
+// Sync picks up the receipt global number where the server left it. It fails
+// when a fiscal day is still open, since the chain of that day is unknown.
+func (d *Device) Sync(ctx context.Context) error {
+	resp, err := d.client.GetFiscalDayStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	if resp.FiscalDayStatus != models.FiscalDayStatusClosed.String() && resp.FiscalDayNo != nil {
+		return fmt.Errorf("fiscal day %d is %s; close it first (POST /api/admin/devices/%d/fiscal-days/%d/force-close)",
+			*resp.FiscalDayNo, resp.FiscalDayStatus, d.cfg.DeviceID, *resp.FiscalDayNo)
+	}
+	if resp.LastReceiptGlobalNo != nil {
+		d.chain.SetGlobalNo(*resp.LastReceiptGlobalNo)
+	}
+	return nil
+}
+
+// SetGlobalNo overrides the global number of the last submitted receipt
+func (d *Device) SetGlobalNo(globalNo int) { d.chain.SetGlobalNo(globalNo) }
+
+// OpenDay opens a fiscal day and starts a new receipt chain
+func (d *Device) OpenDay(ctx context.Context) (*models.OpenFiscalDayResponse, error) {
+	if err := d.renewOperator(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := d.client.OpenDay(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The opening date is part of the fiscal day signature
+	day, err := d.client.GetFiscalDay(ctx, resp.FiscalDayNo)
+	if err != nil {
+		return nil, err
+	}
+
+	d.chain.OpenDay(resp.FiscalDayNo, day.FiscalDayOpened)
+	return resp, nil
+}
+
+// Counters returns the fiscal counters of the receipts submitted today
+func (d *Device) Counters() []models.FiscalDayCounter { return d.chain.Counters() }
+
+// CloseDay closes the fiscal day with the device's own counters and a
+// signature over them
+func (d *Device) CloseDay(ctx context.Context) (*models.CloseFiscalDayResponse, error) {
+	if err := d.renewOperator(ctx); err != nil {
+		return nil, err
+	}
+	counters, signature, err := d.chain.SignDay(d.key)
+	if err != nil {
+		return nil, err
+	}
+	return d.client.CloseDay(ctx, counters, signature)
+}
+
+// httpClient returns the HTTP client for the server URL, trusting CAFile or
+// skipping verification over https as configured
+func (c Config) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+
+	if strings.HasPrefix(c.ServerURL, "https://") {
+		tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+		if c.CAFile != "" {
+			caPEM, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: c.Timeout, Transport: transport}, nil
+}
@@ -0,0 +1,255 @@
+package devicesim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+)
+
+// Fault is a deliberate mistake injected into a submitted receipt
+type Fault string
+
+const (
+	// FaultGap skips a receipt counter and global number
+	FaultGap Fault = "gap"
+	// FaultBadSignature corrupts the device signature
+	FaultBadSignature Fault = "bad-signature"
+	// FaultWrongTotal sends a receipt total that does not add up
+	FaultWrongTotal Fault = "wrong-total"
+	// FaultWrongTax sends a miscalculated tax amount
+	FaultWrongTax Fault = "wrong-tax"
+)
+
+// expectedCodes are the validation codes each fault must produce
+var expectedCodes = map[Fault][]string{
+	FaultGap:          {"RCPT011", "RCPT012"},
+	FaultBadSignature: {"RCPT020"},
+	FaultWrongTotal:   {"RCPT019", "RCPT038", "RCPT039"},
+	FaultWrongTax:     {"RCPT026"},
+}
+
+// ParseFaults parses a comma-separated list of faults; "" and "none" mean no
+// faults
+func ParseFaults(s string) ([]Fault, error) {
+	var faults []Fault
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		fault := Fault(name)
+		if _, ok := expectedCodes[fault]; !ok {
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+// ExpectedCodes returns the validation codes the server must report for a
+// receipt submitted with faults
+func ExpectedCodes(faults []Fault) []string {
+	var codes []string
+	for _, fault := range faults {
+		codes = append(codes, expectedCodes[fault]...)
+	}
+	return codes
+}
+
+// Item is one line of a simulated sale
+type Item struct {
+	Name     string
+	HSCode   string
+	Price    float64 // tax inclusive
+	Quantity float64
+	Tax      models.Tax
+}
+
+// NewReceipt builds a tax-inclusive receipt paid in full with one money type.
+// Credit note prices and amounts are negated. Counters, numbers, date and
+// signature are filled in by Submit.
+func NewReceipt(receiptType models.ReceiptType, currency string, moneyType models.MoneyType, items []Item) *models.Receipt {
+	sign := 1.0
+	if receiptType == models.ReceiptTypeCreditNote {
+		sign = -1
+	}
+
+	receipt := &models.Receipt{
+		ReceiptType:              receiptType,
+		ReceiptCurrency:          currency,
+		ReceiptLinesTaxInclusive: true,
+	}
+
+	type taxKey struct {
+		taxID   int
+		percent float64
+		exempt  bool
+	}
+	taxes := make(map[taxKey]*models.ReceiptTax)
+	var order []taxKey
+
+	for i, item := range items {
+		price := round2(sign * item.Price)
+		total := round2(price * item.Quantity)
+		hsCode := item.HSCode
+
+		receipt.ReceiptLines = append(receipt.ReceiptLines, models.ReceiptLine{
+			ReceiptLineType:     models.ReceiptLineTypeSale,
+			ReceiptLineNo:       i + 1,
+			ReceiptLineHSCode:   &hsCode,
+			ReceiptLineName:     item.Name,
+			ReceiptLinePrice:    &price,
+			ReceiptLineQuantity: item.Quantity,
+			ReceiptLineTotal:    total,
+			TaxPercent:          item.Tax.TaxPercent,
+			TaxID:               item.Tax.TaxID,
+		})
+		receipt.ReceiptTotal += total
+
+		k := taxKey{taxID: item.Tax.TaxID, exempt: item.Tax.TaxPercent == nil}
+		if item.Tax.TaxPercent != nil {
+			k.percent = *item.Tax.TaxPercent
+		}
+		tax, ok := taxes[k]
+		if !ok {
+			tax = &models.ReceiptTax{TaxID: item.Tax.TaxID, TaxPercent: item.Tax.TaxPercent}
+			taxes[k] = tax
+			order = append(order, k)
+		}
+		tax.SalesAmountWithTax += total
+	}
+
+	for _, k := range order {
+		tax := taxes[k]
+		tax.SalesAmountWithTax = round2(tax.SalesAmountWithTax)
+		if tax.TaxPercent != nil {
+			rate := *tax.TaxPercent / 100
+			tax.TaxAmount = round2(tax.SalesAmountWithTax * rate / (1 + rate))
+		}
+		receipt.ReceiptTaxes = append(receipt.ReceiptTaxes, *tax)
+	}
+
+	receipt.ReceiptTotal = round2(receipt.ReceiptTotal)
+	receipt.ReceiptPayments = []models.Payment{{MoneyTypeCode: moneyType, PaymentAmount: receipt.ReceiptTotal}}
+	return receipt
+}
+
+// NoteFor makes receipt a credit or debit note of the invoice the server
+// accepted as originalID
+func NoteFor(receipt *models.Receipt, original *models.Receipt, originalID int64, notes string) {
+	receipt.CreditDebitNote = &models.CreditDebitNote{
+		ReceiptID:       &originalID,
+		DeviceID:        &original.DeviceID,
+		ReceiptGlobalNo: &original.ReceiptGlobalNo,
+	}
+	receipt.ReceiptNotes = &notes
+}
+
+// Submit numbers, dates, hashes and signs receipt as the next one in the
+// chain, applies faults and sends it. The receipt is only added to the chain
+// and the counters when the server stores it.
+func (d *Device) Submit(ctx context.Context, receipt *models.Receipt, faults ...Fault) (*models.SubmitReceiptResponse, error) {
+	if err := d.renewOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	d.chain.Next(receipt, time.Now())
+	for _, fault := range faults {
+		switch fault {
+		case FaultGap:
+			receipt.ReceiptCounter++
+			receipt.ReceiptGlobalNo++
+		case FaultWrongTotal:
+			receipt.ReceiptTotal = round2(receipt.ReceiptTotal + 1)
+		case FaultWrongTax:
+			receipt.ReceiptTaxes[0].TaxAmount = round2(receipt.ReceiptTaxes[0].TaxAmount + 0.5)
+		}
+	}
+	receipt.InvoiceNo = fmt.Sprintf("SIM-%d-%d", d.cfg.DeviceID, receipt.ReceiptGlobalNo)
+
+	if err := d.chain.Seal(receipt, d.key); err != nil {
+		return nil, err
+	}
+	for _, fault := range faults {
+		if fault == FaultBadSignature {
+			signature := receipt.ReceiptDeviceSignature.Signature
+			signature[len(signature)-1] ^= 0xff
+		}
+	}
+
+	resp, err := d.client.SubmitReceipt(ctx, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt.ReceiptID = resp.ReceiptID
+	receipt.ReceiptHash = receipt.ReceiptDeviceSignature.Hash
+	d.chain.Commit(receipt)
+	return resp, nil
+}
+
+// Basket returns a sale of the given size using the applicable taxes: VAT
+// taxpayers get standard-rated and exempt items, others only items without
+// VAT
+func (d *Device) Basket(size int) ([]Item, error) {
+	if d.config == nil {
+		return nil, fmt.Errorf("device config has not been fetched")
+	}
+
+	// Zero-rated and exempt lines fall under the same key in the server's
+	// tax amount checks, so at most one of them is used
+	var vat, other *models.Tax
+	for i, tax := range d.config.ApplicableTaxes {
+		switch {
+		case tax.TaxPercent != nil && *tax.TaxPercent > 0:
+			if vat == nil && d.config.VATNumber != "" {
+				vat = &d.config.ApplicableTaxes[i]
+			}
+		case other == nil || (other.TaxPercent != nil && tax.TaxPercent == nil):
+			other = &d.config.ApplicableTaxes[i]
+		}
+	}
+
+	var taxes []models.Tax
+	for _, tax := range []*models.Tax{vat, other} {
+		if tax != nil {
+			taxes = append(taxes, *tax)
+		}
+	}
+	if len(taxes) == 0 {
+		return nil, fmt.Errorf("no applicable taxes for this taxpayer")
+	}
+
+	items := make([]Item, size)
+	for i := range items {
+		product := products[(d.GlobalNo()+i)%len(products)]
+		items[i] = Item{
+			Name:     product.name,
+			HSCode:   product.hsCode,
+			Price:    product.price,
+			Quantity: float64(1 + (d.GlobalNo()+i)%3),
+			Tax:      taxes[i%len(taxes)],
+		}
+	}
+	return items, nil
+}
+
+var products = []struct {
+	name   string
+	hsCode string
+	price  float64
+}{
+	{"Fresh milk 1L", "04011000", 1.35},
+	{"Rice 2kg", "10063000", 3.99},
+	{"Mineral water 500ml", "22011000", 0.75},
+	{"Sugar 2kg", "17019900", 2.49},
+	{"Cooking oil 2L", "15079000", 4.20},
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,59 @@
+package devicesim
+
+import (
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/service"
+)
+
+func TestNewReceipt_PassesValidation(t *testing.T) {
+	standard, zero := 15.0, 0.0
+	validFrom := time.Now().AddDate(-1, 0, 0)
+	taxes := []models.Tax{
+		{TaxID: 1, TaxName: "Exempt", TaxValidFrom: validFrom},
+		{TaxID: 2, TaxPercent: &zero, TaxName: "Zero rated", TaxValidFrom: validFrom},
+		{TaxID: 3, TaxPercent: &standard, TaxName: "Standard rated", TaxValidFrom: validFrom},
+	}
+	vatNumber := "220000001"
+	taxpayer := &models.Taxpayer{VATNumber: &vatNumber}
+
+	items := []Item{
+		{Name: "Rice 2kg", HSCode: "10063000", Price: 3.99, Quantity: 3, Tax: taxes[2]},
+		{Name: "Fresh milk 1L", HSCode: "04011000", Price: 1.35, Quantity: 2, Tax: taxes[0]},
+		{Name: "Sugar 2kg", HSCode: "17019900", Price: 2.49, Quantity: 1, Tax: taxes[2]},
+	}
+
+	tests := []struct {
+		name        string
+		receiptType models.ReceiptType
+		wantTotal   float64
+	}{
+		{"Invoice", models.ReceiptTypeFiscalInvoice, 17.16},
+		{"Credit note", models.ReceiptTypeCreditNote, -17.16},
+		{"Debit note", models.ReceiptTypeDebitNote, 17.16},
+	}
+
+	validator := service.NewValidationService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := NewReceipt(tt.receiptType, "USD", models.MoneyTypeCash, items)
+			receipt.ReceiptCounter = 1
+			receipt.ReceiptGlobalNo = 1
+			receipt.ReceiptDate = time.Now()
+			if tt.receiptType != models.ReceiptTypeFiscalInvoice {
+				NoteFor(receipt, &models.Receipt{DeviceID: 1001, ReceiptGlobalNo: 1}, 1, "Correction")
+			}
+
+			if receipt.ReceiptTotal != tt.wantTotal {
+				t.Errorf("ReceiptTotal = %v, want %v", receipt.ReceiptTotal, tt.wantTotal)
+			}
+
+			result := validator.ValidateReceipt(receipt, nil, taxpayer, taxes, receipt.ReceiptDate.Add(-time.Hour), 24)
+			if !result.IsValid {
+				t.Errorf("ValidateReceipt() errors = %v", result.Errors)
+			}
+		})
+	}
+}
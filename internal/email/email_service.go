@@ -1,9 +1,15 @@
 package email
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -14,6 +20,7 @@ type EmailService struct {
 	smtpUsername string
 	smtpPassword string
 	fromAddress  string
+	templates    *Templates
 	logger       *zap.Logger
 }
 
@@ -25,107 +32,89 @@ type EmailConfig struct {
 	FromAddress  string
 }
 
-func NewEmailService(cfg EmailConfig, logger *zap.Logger) *EmailService {
+func NewEmailService(cfg EmailConfig, templates *Templates, logger *zap.Logger) *EmailService {
 	return &EmailService{
 		smtpHost:     cfg.SMTPHost,
 		smtpPort:     cfg.SMTPPort,
 		smtpUsername: cfg.SMTPUsername,
 		smtpPassword: cfg.SMTPPassword,
 		fromAddress:  cfg.FromAddress,
+		templates:    templates,
 		logger:       logger,
 	}
 }
 
-// SendSecurityCode sends a security code via email
-func (s *EmailService) SendSecurityCode(to, code, username string) error {
-	subject := "ZIMRA Fiscalization - Security Code"
-	body := fmt.Sprintf(`
-Dear %s,
-
-Your security code for ZIMRA Fiscalization system is:
-
-%s
-
-This code will expire in 15 minutes.
-
-If you did not request this code, please ignore this email.
-
-Best regards,
-ZIMRA Fiscalization System
-`, username, code)
+// Recipient is who an email is for: their address, username and language,
+// and the taxpayer whose name the email carries
+type Recipient struct {
+	Address  string
+	Username string
+	Language string
+	Taxpayer Taxpayer
+}
 
-	return s.sendEmail(to, subject, body)
+// SendSecurityCode sends a security code via email
+func (s *EmailService) SendSecurityCode(to Recipient, code string) error {
+	return s.send(to, TemplateSecurityCode, TemplateData{Code: code})
 }
 
 // SendPasswordReset sends password reset code via email
-func (s *EmailService) SendPasswordReset(to, code, username string) error {
-	subject := "ZIMRA Fiscalization - Password Reset"
-	body := fmt.Sprintf(`
-Dear %s,
-
-You have requested to reset your password.
-
-Your password reset code is:
-
-%s
-
-This code will expire in 15 minutes.
-
-If you did not request this, please ignore this email and your password will remain unchanged.
-
-Best regards,
-ZIMRA Fiscalization System
-`, username, code)
-
-	return s.sendEmail(to, subject, body)
+func (s *EmailService) SendPasswordReset(to Recipient, code string) error {
+	return s.send(to, TemplatePasswordReset, TemplateData{Code: code})
 }
 
 // SendWelcomeEmail sends welcome email to new user
-func (s *EmailService) SendWelcomeEmail(to, username string) error {
-	subject := "Welcome to ZIMRA Fiscalization System"
-	body := fmt.Sprintf(`
-Dear %s,
-
-Welcome to the ZIMRA Fiscalization System!
-
-Your account has been successfully created and activated.
-
-You can now log in using your username and password.
-
-If you have any questions, please contact support.
-
-Best regards,
-ZIMRA Fiscalization System
-`, username)
+func (s *EmailService) SendWelcomeEmail(to Recipient) error {
+	return s.send(to, TemplateWelcome, TemplateData{})
+}
 
-	return s.sendEmail(to, subject, body)
+// SendContactChangedNotification tells a user at their old email address
+// that their email address was changed
+func (s *EmailService) SendContactChangedNotification(to Recipient) error {
+	return s.send(to, TemplateContactChanged, TemplateData{})
 }
 
 // SendFiscalDayCloseNotification sends notification when fiscal day is about to close
-func (s *EmailService) SendFiscalDayCloseNotification(to, deviceName string, hoursLeft int) error {
-	subject := "ZIMRA Fiscalization - Fiscal Day Closing Soon"
-	body := fmt.Sprintf(`
-Dear User,
+func (s *EmailService) SendFiscalDayCloseNotification(to Recipient, deviceName string, hoursLeft int) error {
+	return s.send(to, TemplateFiscalDayEnding, TemplateData{DeviceName: deviceName, HoursLeft: hoursLeft})
+}
 
-This is a reminder that the fiscal day for device "%s" will close in %d hour(s).
+// SendFiscalDayAutoClosedNotification informs the taxpayer that the server closed a fiscal day
+func (s *EmailService) SendFiscalDayAutoClosedNotification(to Recipient, deviceName string, fiscalDayNo int) error {
+	return s.send(to, TemplateFiscalDayAutoClosed, TemplateData{DeviceName: deviceName, FiscalDayNo: fiscalDayNo})
+}
 
-Please ensure all receipts are submitted before the fiscal day closes.
+// send renders the named template for the recipient and delivers it, or
+// only logs it when no SMTP server is configured
+func (s *EmailService) send(to Recipient, name string, data TemplateData) error {
+	data.Username = to.Username
+	data.Taxpayer = to.Taxpayer
 
-Best regards,
-ZIMRA Fiscalization System
-`, deviceName, hoursLeft)
+	msg, err := s.templates.Render(name, to.Language, data)
+	if err != nil {
+		s.logger.Error("Failed to render email", zap.String("template", name), zap.Error(err))
+		return err
+	}
 
-	return s.sendEmail(to, subject, body)
+	if s.smtpHost == "" {
+		s.logger.Info("MOCK EMAIL",
+			zap.String("to", to.Address),
+			zap.String("language", to.Language),
+			zap.String("subject", msg.Subject),
+			zap.String("text", msg.Text),
+		)
+		return nil
+	}
+	return s.sendEmail(to.Address, msg)
 }
 
 // sendEmail sends an email using SMTP
-func (s *EmailService) sendEmail(to, subject, body string) error {
-	// Build message
-	message := fmt.Sprintf("From: %s\r\n", s.fromAddress)
-	message += fmt.Sprintf("To: %s\r\n", to)
-	message += fmt.Sprintf("Subject: %s\r\n", subject)
-	message += "\r\n"
-	message += body
+func (s *EmailService) sendEmail(to string, msg *Message) error {
+	message, err := buildMessage(s.fromAddress, to, msg, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to build email", zap.Error(err))
+		return err
+	}
 
 	// Setup authentication
 	auth := smtp.PlainAuth("", s.smtpUsername, s.smtpPassword, s.smtpHost)
@@ -181,7 +170,7 @@ func (s *EmailService) sendEmail(to, subject, body string) error {
 		return err
 	}
 
-	_, err = w.Write([]byte(message))
+	_, err = w.Write(message)
 	if err != nil {
 		s.logger.Error("Failed to write message", zap.Error(err))
 		return err
@@ -195,52 +184,53 @@ func (s *EmailService) sendEmail(to, subject, body string) error {
 
 	s.logger.Info("Email sent successfully",
 		zap.String("to", to),
-		zap.String("subject", subject),
+		zap.String("subject", msg.Subject),
 	)
 
 	return nil
 }
 
-// MockEmailService for testing/development
-type MockEmailService struct {
-	logger *zap.Logger
-}
-
-func NewMockEmailService(logger *zap.Logger) *MockEmailService {
-	return &MockEmailService{logger: logger}
-}
-
-func (s *MockEmailService) SendSecurityCode(to, code, username string) error {
-	s.logger.Info("MOCK EMAIL: Security Code",
-		zap.String("to", to),
-		zap.String("code", code),
-		zap.String("username", username),
-	)
-	return nil
-}
-
-func (s *MockEmailService) SendPasswordReset(to, code, username string) error {
-	s.logger.Info("MOCK EMAIL: Password Reset",
-		zap.String("to", to),
-		zap.String("code", code),
-		zap.String("username", username),
-	)
-	return nil
+// NewMockEmailService returns an email service that renders emails and logs
+// them instead of sending them, for testing and development
+func NewMockEmailService(templates *Templates, logger *zap.Logger) *EmailService {
+	return &EmailService{templates: templates, logger: logger}
 }
 
-func (s *MockEmailService) SendWelcomeEmail(to, username string) error {
-	s.logger.Info("MOCK EMAIL: Welcome",
-		zap.String("to", to),
-		zap.String("username", username),
-	)
-	return nil
-}
+// buildMessage encodes msg as a multipart/alternative email with a plain
+// text and an HTML part
+func buildMessage(from, to string, msg *Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
 
-func (s *MockEmailService) SendFiscalDayCloseNotification(to, deviceName string, hoursLeft int) error {
-	s.logger.Info("MOCK EMAIL: Fiscal Day Closing",
-		zap.String("to", to),
-		zap.String("device", deviceName),
-		zap.Int("hoursLeft", hoursLeft),
-	)
-	return nil
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
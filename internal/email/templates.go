@@ -0,0 +1,191 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Names of the email templates. Each is a file <language>/<name>.tmpl that
+// defines a "subject", a "text" and an "html" template; the html template is
+// rendered inside layout.html.
+const (
+	TemplateSecurityCode        = "security_code"
+	TemplatePasswordReset       = "password_reset"
+	TemplateWelcome             = "welcome"
+	TemplateContactChanged      = "contact_changed"
+	TemplateFiscalDayEnding     = "fiscal_day_ending"
+	TemplateFiscalDayAutoClosed = "fiscal_day_auto_closed"
+)
+
+// Languages are the languages templates exist in. The first is used for
+// recipients without a known language.
+var Languages = []string{"en", "sn", "nd"}
+
+var templateNames = []string{
+	TemplateSecurityCode,
+	TemplatePasswordReset,
+	TemplateWelcome,
+	TemplateContactChanged,
+	TemplateFiscalDayEnding,
+	TemplateFiscalDayAutoClosed,
+}
+
+// codeValidMinutes is how long the security codes sent by email are valid
+const codeValidMinutes = 15
+
+// Branding is the look of every email: the product name shown in the
+// header and signature, an optional logo, the header colour and the
+// address recipients can write to for help
+type Branding struct {
+	ProductName  string
+	LogoURL      string
+	Color        string
+	SupportEmail string
+}
+
+// Taxpayer is the taxpayer an email is sent on behalf of
+type Taxpayer struct {
+	Name string
+	TIN  string
+}
+
+// TemplateData is what templates are rendered with. Fields a message has no
+// use for are left empty.
+type TemplateData struct {
+	Brand       Branding
+	Taxpayer    Taxpayer
+	Language    string
+	Username    string
+	Code        string
+	CodeMinutes int
+	DeviceName  string
+	HoursLeft   int
+	FiscalDayNo int
+}
+
+// Message is a rendered email
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type messageTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders the emails. The embedded templates can be replaced
+// file by file from an override directory with the same layout.
+type Templates struct {
+	brand     Branding
+	templates map[string]messageTemplate
+}
+
+// NewTemplates parses every template, preferring the files in dir when dir
+// is set, so that a broken override is found at startup
+func NewTemplates(dir string, brand Branding) (*Templates, error) {
+	if brand.ProductName == "" {
+		brand.ProductName = "ZIMRA Fiscalization System"
+	}
+	if brand.Color == "" {
+		brand.Color = "#00703c"
+	}
+
+	layout, err := readTemplate(dir, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{brand: brand, templates: make(map[string]messageTemplate)}
+	for _, lang := range Languages {
+		for _, name := range templateNames {
+			path := lang + "/" + name + ".tmpl"
+			src, err := readTemplate(dir, path)
+			if err != nil {
+				return nil, err
+			}
+
+			text, err := texttemplate.New(path).Option("missingkey=error").Parse(src)
+			if err != nil {
+				return nil, fmt.Errorf("email template %s: %w", path, err)
+			}
+			html, err := htmltemplate.New("layout.html").Parse(layout)
+			if err == nil {
+				_, err = html.New(path).Parse(src)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("email template %s: %w", path, err)
+			}
+
+			t.templates[lang+"/"+name] = messageTemplate{text: text, html: html}
+		}
+	}
+	return t, nil
+}
+
+// Render renders the named message in language, or in English when there
+// are no templates in that language
+func (t *Templates) Render(name, language string, data TemplateData) (*Message, error) {
+	tmpl, ok := t.templates[language+"/"+name]
+	if !ok {
+		language = Languages[0]
+		if tmpl, ok = t.templates[language+"/"+name]; !ok {
+			return nil, fmt.Errorf("unknown email template %q", name)
+		}
+	}
+
+	data.Brand = t.brand
+	data.Language = language
+	if data.CodeMinutes == 0 {
+		data.CodeMinutes = codeValidMinutes
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// readTemplate reads a template from dir, or from the embedded templates
+// when dir is not set or has no such file
+func readTemplate(dir, path string) (string, error) {
+	if dir != "" {
+		src, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err == nil {
+			return string(src), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("email template %s: %w", path, err)
+		}
+	}
+
+	src, err := defaultTemplates.ReadFile("templates/" + path)
+	if err != nil {
+		return "", fmt.Errorf("email template %s: %w", path, err)
+	}
+	return string(src), nil
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplates_RenderEveryLanguage(t *testing.T) {
+	templates, err := NewTemplates("", Branding{SupportEmail: "help@example.com"})
+	if err != nil {
+		t.Fatalf("NewTemplates() error = %v", err)
+	}
+
+	data := TemplateData{
+		Taxpayer:    Taxpayer{Name: "Test Retail", TIN: "2000000001"},
+		Username:    "cashier",
+		Code:        "123456",
+		DeviceName:  `Till <1>`,
+		HoursLeft:   2,
+		FiscalDayNo: 7,
+	}
+	subjects := make(map[string]bool)
+	for _, lang := range Languages {
+		for _, name := range templateNames {
+			msg, err := templates.Render(name, lang, data)
+			if err != nil {
+				t.Fatalf("Render(%s, %s) error = %v", name, lang, err)
+			}
+			if msg.Subject == "" || msg.Text == "" || subjects[msg.Subject] {
+				t.Errorf("Render(%s, %s) subject %q is empty or not translated", name, lang, msg.Subject)
+			}
+			subjects[msg.Subject] = true
+
+			if !strings.Contains(msg.HTML, "Test Retail") || !strings.Contains(msg.HTML, "help@example.com") {
+				t.Errorf("Render(%s, %s) HTML is missing the branding", name, lang)
+			}
+			if strings.Contains(msg.HTML, "<1>") {
+				t.Errorf("Render(%s, %s) HTML does not escape variables", name, lang)
+			}
+		}
+	}
+
+	msg, _ := templates.Render(TemplateSecurityCode, "sn", data)
+	if !strings.Contains(msg.Text, "123456") || !strings.Contains(msg.Text, "Mhoro cashier") {
+		t.Errorf("Shona security code text = %q", msg.Text)
+	}
+}
+
+func TestTemplates_FallbackAndOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	override := `{{define "subject"}}Code for {{.Taxpayer.Name}}{{end}}{{define "text"}}{{.Code}}{{end}}{{define "html"}}<b>{{.Code}}</b>{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "en", "security_code.tmpl"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := NewTemplates(dir, Branding{})
+	if err != nil {
+		t.Fatalf("NewTemplates() error = %v", err)
+	}
+
+	data := TemplateData{Taxpayer: Taxpayer{Name: "Test Retail"}, Code: "654321"}
+	msg, err := templates.Render(TemplateSecurityCode, "fr", data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if msg.Subject != "Code for Test Retail" || msg.Text != "654321\n" {
+		t.Errorf("overridden template rendered %q / %q", msg.Subject, msg.Text)
+	}
+
+	// Templates that are not overridden are the built-in ones
+	msg, _ = templates.Render(TemplatePasswordReset, "en", data)
+	if msg.Subject != "ZIMRA Fiscalization - Password Reset" {
+		t.Errorf("built-in subject = %q", msg.Subject)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "en", "welcome.tmpl"), []byte(`{{define "subject"}}{{.Nope`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTemplates(dir, Branding{}); err == nil {
+		t.Error("NewTemplates() should fail on a broken override")
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	msg := &Message{Subject: "Kodhi Yekuchengetedza – ZIMRA", Text: "Kodhi: 123456\n", HTML: "<p>Kodhi: <b>123456</b></p>"}
+	raw, err := buildMessage("noreply@example.com", "cashier@example.com", msg, time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject {
+		t.Errorf("Subject = %q, want %q", subject, msg.Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Type")+": "+strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+
+	want := []string{"text/plain; charset=utf-8: " + msg.Text, "text/html; charset=utf-8: " + msg.HTML}
+	if len(parts) != 2 || parts[0] != want[0] || parts[1] != want[1] {
+		t.Errorf("parts = %q, want %q", parts, want)
+	}
+}
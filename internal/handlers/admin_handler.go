@@ -40,7 +40,7 @@ func (h *AdminHandler) Login(c *gin.Context) {
 
 // GET /api/admin/stats
 func (h *AdminHandler) GetStats(c *gin.Context) {
-	stats, err := h.adminService.GetSystemStats()
+	stats, err := h.adminService.GetSystemStats(c.Request.Context())
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -56,7 +56,7 @@ func (h *AdminHandler) ListCompanies(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	search := c.Query("search")
 
-	resp, err := h.adminService.ListTaxpayers(offset, limit, search)
+	resp, err := h.adminService.ListTaxpayers(c.Request.Context(), offset, limit, search)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -70,7 +70,7 @@ func (h *AdminHandler) CreateCompany(c *gin.Context) {
 	if !api.BindJSON(c, &req) {
 		return
 	}
-	tp, err := h.adminService.CreateTaxpayer(req)
+	tp, err := h.adminService.CreateTaxpayer(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -85,7 +85,7 @@ func (h *AdminHandler) GetCompany(c *gin.Context) {
 		api.ValidationErrorResponse(c, "Invalid company ID")
 		return
 	}
-	tp, err := h.adminService.GetTaxpayer(id)
+	tp, err := h.adminService.GetTaxpayer(c.Request.Context(), id)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -105,7 +105,7 @@ func (h *AdminHandler) UpdateCompany(c *gin.Context) {
 		return
 	}
 	req.ID = id
-	tp, err := h.adminService.UpdateTaxpayer(req)
+	tp, err := h.adminService.UpdateTaxpayer(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -126,7 +126,7 @@ func (h *AdminHandler) SetCompanyStatus(c *gin.Context) {
 	if !api.BindJSON(c, &body) {
 		return
 	}
-	if err := h.adminService.SetTaxpayerStatus(id, body.Status); err != nil {
+	if err := h.adminService.SetTaxpayerStatus(c.Request.Context(), id, body.Status); err != nil {
 		api.ErrorResponse(c, err)
 		return
 	}
@@ -140,7 +140,7 @@ func (h *AdminHandler) ListCompanyDevices(c *gin.Context) {
 		api.ValidationErrorResponse(c, "Invalid company ID")
 		return
 	}
-	devices, err := h.adminService.ListDevicesByTaxpayer(id)
+	devices, err := h.adminService.ListDevicesByTaxpayer(c.Request.Context(), id)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -154,7 +154,7 @@ func (h *AdminHandler) ListCompanyDevices(c *gin.Context) {
 func (h *AdminHandler) ListAllDevices(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	resp, err := h.adminService.ListAllDevices(offset, limit)
+	resp, err := h.adminService.ListAllDevices(c.Request.Context(), offset, limit)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -168,7 +168,7 @@ func (h *AdminHandler) ProvisionDevice(c *gin.Context) {
 	if !api.BindJSON(c, &req) {
 		return
 	}
-	device, err := h.adminService.CreateDevice(req)
+	device, err := h.adminService.CreateDevice(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -189,7 +189,7 @@ func (h *AdminHandler) SetDeviceStatus(c *gin.Context) {
 	if !api.BindJSON(c, &body) {
 		return
 	}
-	if err := h.adminService.UpdateDeviceStatus(deviceID, body.Status); err != nil {
+	if err := h.adminService.UpdateDeviceStatus(c.Request.Context(), deviceID, body.Status); err != nil {
 		api.ErrorResponse(c, err)
 		return
 	}
@@ -209,7 +209,7 @@ func (h *AdminHandler) SetDeviceMode(c *gin.Context) {
 	if !api.BindJSON(c, &body) {
 		return
 	}
-	if err := h.adminService.UpdateDeviceMode(deviceID, body.Mode); err != nil {
+	if err := h.adminService.UpdateDeviceMode(c.Request.Context(), deviceID, body.Mode); err != nil {
 		api.ErrorResponse(c, err)
 		return
 	}
@@ -235,7 +235,7 @@ func (h *AdminHandler) ListFiscalDays(c *gin.Context) {
 		deviceID = &id
 	}
 
-	resp, err := h.adminService.ListFiscalDays(taxpayerID, deviceID, offset, limit)
+	resp, err := h.adminService.ListFiscalDays(c.Request.Context(), taxpayerID, deviceID, offset, limit)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -243,6 +243,63 @@ func (h *AdminHandler) ListFiscalDays(c *gin.Context) {
 	api.SuccessResponse(c, resp)
 }
 
+// POST /api/admin/devices/:deviceID/fiscal-days/:fiscalDayNo/force-close
+func (h *AdminHandler) ForceCloseFiscalDay(c *gin.Context) {
+	deviceID, fiscalDayNo, ok := fiscalDayParams(c)
+	if !ok {
+		return
+	}
+	var req models.AdminForceCloseFiscalDayRequest
+	if !api.BindJSON(c, &req) {
+		return
+	}
+	fiscalDay, err := h.adminService.ForceCloseFiscalDay(c.Request.Context(), adminActor(c), deviceID, fiscalDayNo, req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+	api.SuccessResponse(c, fiscalDay)
+}
+
+// POST /api/admin/devices/:deviceID/fiscal-days/:fiscalDayNo/reset
+func (h *AdminHandler) ResetFiscalDay(c *gin.Context) {
+	deviceID, fiscalDayNo, ok := fiscalDayParams(c)
+	if !ok {
+		return
+	}
+	var req models.AdminResetFiscalDayRequest
+	if !api.BindJSON(c, &req) {
+		return
+	}
+	fiscalDay, err := h.adminService.ResetFiscalDay(c.Request.Context(), adminActor(c), deviceID, fiscalDayNo, req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+	api.SuccessResponse(c, fiscalDay)
+}
+
+func fiscalDayParams(c *gin.Context) (int, int, bool) {
+	deviceID, err := strconv.Atoi(c.Param("deviceID"))
+	if err != nil {
+		api.ValidationErrorResponse(c, "Invalid device ID")
+		return 0, 0, false
+	}
+	fiscalDayNo, err := strconv.Atoi(c.Param("fiscalDayNo"))
+	if err != nil || fiscalDayNo < 1 {
+		api.ValidationErrorResponse(c, "Invalid fiscal day number")
+		return 0, 0, false
+	}
+	return deviceID, fiscalDayNo, true
+}
+
+// adminActor returns the authenticated administrator set by AdminAuthMiddleware
+func adminActor(c *gin.Context) models.AdminActor {
+	username, _ := c.Get("admin_username")
+	name, _ := username.(string)
+	return models.AdminActor{Username: name, IPAddress: c.ClientIP()}
+}
+
 // ─── Receipts (cross-tenant) ──────────────────────────────────────────────────
 
 // GET /api/admin/receipts
@@ -271,7 +328,7 @@ func (h *AdminHandler) ListReceipts(c *gin.Context) {
 		to = &t
 	}
 
-	resp, err := h.adminService.ListReceipts(taxpayerID, deviceID, from, to, offset, limit)
+	resp, err := h.adminService.ListReceipts(c.Request.Context(), taxpayerID, deviceID, from, to, offset, limit)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -293,7 +350,7 @@ func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
 		entityID = &id
 	}
 
-	resp, err := h.adminService.ListAuditLogs(entityType, entityID, offset, limit)
+	resp, err := h.adminService.ListAuditLogs(c.Request.Context(), entityType, entityID, offset, limit)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -301,6 +358,60 @@ func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
 	api.SuccessResponse(c, resp)
 }
 
+// ─── Notifications ────────────────────────────────────────────────────────────
+
+// GET /api/admin/notifications
+func (h *AdminHandler) ListNotifications(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	status := models.NotificationStatus(c.Query("status"))
+	switch status {
+	case "", models.NotificationStatusPending, models.NotificationStatusSending,
+		models.NotificationStatusSent, models.NotificationStatusDead:
+	default:
+		api.ValidationErrorResponse(c, "Invalid status, expected pending, sending, sent or dead")
+		return
+	}
+
+	resp, err := h.adminService.ListNotifications(c.Request.Context(), status, offset, limit)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+	api.SuccessResponse(c, resp)
+}
+
+// GET /api/admin/notifications/:id
+func (h *AdminHandler) GetNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		api.ValidationErrorResponse(c, "Invalid notification ID")
+		return
+	}
+	n, err := h.adminService.GetNotification(c.Request.Context(), id)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+	api.SuccessResponse(c, n)
+}
+
+// POST /api/admin/notifications/:id/resend
+func (h *AdminHandler) ResendNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		api.ValidationErrorResponse(c, "Invalid notification ID")
+		return
+	}
+	n, err := h.adminService.ResendNotification(c.Request.Context(), adminActor(c), id)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+	api.SuccessResponse(c, n)
+}
+
 
 //adding admin
 // GET /api/admin/companies/:id/users
@@ -310,7 +421,7 @@ func (h *AdminHandler) ListCompanyUsers(c *gin.Context) {
 		api.ValidationErrorResponse(c, "Invalid company ID")
 		return
 	}
-	rows, err := h.adminService.ListCompanyUsers(id)
+	rows, err := h.adminService.ListCompanyUsers(c.Request.Context(), id)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -330,7 +441,7 @@ func (h *AdminHandler) CreateCompanyUser(c *gin.Context) {
 		return
 	}
 	req.TaxpayerID = id
-	user, err := h.adminService.CreateCompanyUser(req)
+	user, err := h.adminService.CreateCompanyUser(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -21,20 +21,17 @@ func NewDeviceHandler(deviceService *service.DeviceService) *DeviceHandler {
 // VerifyTaxpayer handles POST /api/v1/device/verify-taxpayer
 func (h *DeviceHandler) VerifyTaxpayer(c *gin.Context) {
 	var req models.VerifyTaxpayerRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	// Get headers
-	modelName := c.GetHeader("DeviceModelName")
-	modelVersion := c.GetHeader("DeviceModelVersionNo")
-
+	modelName, modelVersion := api.GetDeviceModel(c)
 	if modelName == "" || modelVersion == "" {
-		api.ValidationErrorResponse(c, "DeviceModelName and DeviceModelVersionNo headers required")
+		api.ValidationErrorResponse(c, "DeviceModelName and DeviceModelVersion headers required")
 		return
 	}
 
-	resp, err := h.deviceService.VerifyTaxpayer(req, modelName, modelVersion)
+	resp, err := h.deviceService.VerifyTaxpayer(c.Request.Context(), req, modelName, modelVersion)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -46,20 +43,17 @@ func (h *DeviceHandler) VerifyTaxpayer(c *gin.Context) {
 // RegisterDevice handles POST /api/v1/device/register
 func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
 	var req models.DeviceRegistrationRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	// Get headers
-	modelName := c.GetHeader("DeviceModelName")
-	modelVersion := c.GetHeader("DeviceModelVersionNo")
-
+	modelName, modelVersion := api.GetDeviceModel(c)
 	if modelName == "" || modelVersion == "" {
-		api.ValidationErrorResponse(c, "DeviceModelName and DeviceModelVersionNo headers required")
+		api.ValidationErrorResponse(c, "DeviceModelName and DeviceModelVersion headers required")
 		return
 	}
 
-	resp, err := h.deviceService.RegisterDevice(req, modelName, modelVersion)
+	resp, err := h.deviceService.RegisterDevice(c.Request.Context(), req, modelName, modelVersion)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -70,20 +64,17 @@ func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
 
 // IssueCertificate handles POST /api/v1/device/issue-certificate
 func (h *DeviceHandler) IssueCertificate(c *gin.Context) {
-	deviceID, exists := api.GetDeviceIDFromContext(c)
-	if !exists {
+	if _, exists := api.GetDeviceIDFromContext(c); !exists {
 		api.UnauthorizedResponse(c, "Device ID not found in context")
 		return
 	}
 
 	var req models.IssueCertificateRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	req.DeviceID = deviceID
-
-	resp, err := h.deviceService.IssueCertificate(req)
+	resp, err := h.deviceService.IssueCertificate(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -100,7 +91,7 @@ func (h *DeviceHandler) GetConfig(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.deviceService.GetConfig(deviceID)
+	resp, err := h.deviceService.GetConfig(c.Request.Context(), deviceID)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -117,7 +108,7 @@ func (h *DeviceHandler) GetStatus(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.deviceService.GetStatus(deviceID)
+	resp, err := h.deviceService.GetStatus(c.Request.Context(), deviceID)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -134,7 +125,7 @@ func (h *DeviceHandler) Ping(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.deviceService.Ping(deviceID)
+	resp, err := h.deviceService.Ping(c.Request.Context(), deviceID)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -196,7 +187,7 @@ func (h *DeviceHandler) GetStockList(c *gin.Context) {
 		req.Operator = &operator
 	}
 
-	resp, err := h.deviceService.GetStockList(req)
+	resp, err := h.deviceService.GetStockList(c.Request.Context(), req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fiscalization-api/internal/models"
+	"fiscalization-api/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler serves the catalogue of error codes the API returns
+type ErrorHandler struct{}
+
+func NewErrorHandler() *ErrorHandler {
+	return &ErrorHandler{}
+}
+
+// ListErrorCodes handles GET /api/v1/errors
+func (h *ErrorHandler) ListErrorCodes(c *gin.Context) {
+	api.SuccessResponse(c, gin.H{"total": len(models.ErrorCatalog), "rows": models.ErrorCatalog})
+}
+
+// GetErrorCode handles GET /api/v1/errors/:code, the type URI of problems
+// that carry an error code
+func (h *ErrorHandler) GetErrorCode(c *gin.Context) {
+	info, ok := models.LookupErrorCode(c.Param("code"))
+	if !ok {
+		api.NotFoundResponse(c, "Unknown error code")
+		return
+	}
+	api.SuccessResponse(c, info)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strconv"
+
 	"fiscalization-api/internal/models"
 	"fiscalization-api/internal/service"
 	"fiscalization-api/pkg/api"
@@ -29,8 +31,17 @@ func (h *FiscalDayHandler) OpenFiscalDay(c *gin.Context) {
 	req := models.OpenFiscalDayRequest{
 		DeviceID: deviceID,
 	}
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
 
-	resp, err := h.fiscalDayService.OpenFiscalDay(req)
+	resp, err := h.fiscalDayService.OpenFiscalDay(c.Request.Context(), operator, req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -41,20 +52,23 @@ func (h *FiscalDayHandler) OpenFiscalDay(c *gin.Context) {
 
 // CloseFiscalDay handles POST /api/v1/fiscal-day/close
 func (h *FiscalDayHandler) CloseFiscalDay(c *gin.Context) {
-	deviceID, exists := api.GetDeviceIDFromContext(c)
-	if !exists {
+	if _, exists := api.GetDeviceIDFromContext(c); !exists {
 		api.UnauthorizedResponse(c, "Device ID not found in context")
 		return
 	}
 
 	var req models.CloseFiscalDayRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	req.DeviceID = deviceID
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
 
-	resp, err := h.fiscalDayService.CloseFiscalDay(req)
+	resp, err := h.fiscalDayService.CloseFiscalDay(c.Request.Context(), operator, req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -71,7 +85,30 @@ func (h *FiscalDayHandler) GetStatus(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.fiscalDayService.GetFiscalDayStatus(deviceID)
+	resp, err := h.fiscalDayService.GetFiscalDayStatus(c.Request.Context(), deviceID)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// GetFiscalDay handles GET /api/v1/fiscal-day/:fiscalDayNo
+func (h *FiscalDayHandler) GetFiscalDay(c *gin.Context) {
+	deviceID, exists := api.GetDeviceIDFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Device ID not found in context")
+		return
+	}
+
+	fiscalDayNo, err := strconv.Atoi(c.Param("fiscalDayNo"))
+	if err != nil || fiscalDayNo < 1 {
+		api.ValidationErrorResponse(c, "Invalid fiscal day number")
+		return
+	}
+
+	resp, err := h.fiscalDayService.GetFiscalDay(c.Request.Context(), deviceID, fiscalDayNo)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/middleware"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository/memory"
+	"fiscalization-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func newTestFiscalDayRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore()
+	devices, err := memory.SeedDemo(context.Background(), store)
+	if err != nil {
+		t.Fatalf("SeedDemo() error = %v", err)
+	}
+	repos := store.Repositories()
+	day := &models.FiscalDay{DeviceID: devices[0].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now(), Status: models.FiscalDayStatusOpened}
+	if err := repos.FiscalDays.Create(context.Background(), day); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	svc := service.NewFiscalDayService(repos.FiscalDays, repos.Receipts, repos.Devices, memory.NewTxManager(store), nil, zap.NewNop())
+	handler := NewFiscalDayHandler(svc)
+
+	router := gin.New()
+	router.GET("/fiscal-day/:fiscalDayNo", func(c *gin.Context) {
+		c.Set(middleware.DeviceIDContextKey, devices[0].DeviceID)
+	}, handler.GetFiscalDay)
+	return router
+}
+
+func TestFiscalDayHandler_GetFiscalDay(t *testing.T) {
+	router := newTestFiscalDayRouter(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"Found", "/fiscal-day/1", http.StatusOK, ""},
+		{"Unknown day", "/fiscal-day/2", http.StatusNotFound, models.ErrCodeFISC05},
+		{"Invalid number", "/fiscal-day/abc", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != models.ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, models.ProblemContentType)
+			}
+			var problem models.APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if problem.Status != tt.wantStatus || (tt.wantCode != "" && problem.ErrorCode != tt.wantCode) || problem.OperationID == "" {
+				t.Errorf("problem = %+v, want %d %s", problem, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -20,20 +20,23 @@ func NewReceiptHandler(receiptService *service.ReceiptService) *ReceiptHandler {
 
 // SubmitReceipt handles POST /api/v1/receipt/submit
 func (h *ReceiptHandler) SubmitReceipt(c *gin.Context) {
-	deviceID, exists := api.GetDeviceIDFromContext(c)
-	if !exists {
+	if _, exists := api.GetDeviceIDFromContext(c); !exists {
 		api.UnauthorizedResponse(c, "Device ID not found in context")
 		return
 	}
 
 	var req models.SubmitReceiptRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	req.DeviceID = deviceID
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
 
-	resp, err := h.receiptService.SubmitReceipt(req)
+	resp, err := h.receiptService.SubmitReceipt(c.Request.Context(), operator, req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
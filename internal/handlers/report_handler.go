@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/service"
+	"fiscalization-api/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	reportService *service.ReportService
+}
+
+func NewReportHandler(reportService *service.ReportService) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+	}
+}
+
+// GetXReport handles GET /api/v1/reports/x
+func (h *ReportHandler) GetXReport(c *gin.Context) {
+	h.report(c, h.reportService.GetXReport)
+}
+
+// GetZReport handles GET /api/v1/reports/z
+func (h *ReportHandler) GetZReport(c *gin.Context) {
+	h.report(c, h.reportService.GetZReport)
+}
+
+// report resolves the optional fiscalDayNo query parameter and renders the
+// report as JSON, or as plain text when format=text is requested
+func (h *ReportHandler) report(c *gin.Context, get func(context.Context, int, *int) (*models.FiscalDayReport, error)) {
+	deviceID, exists := api.GetDeviceIDFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Device ID not found in context")
+		return
+	}
+
+	var fiscalDayNo *int
+	if raw := c.Query("fiscalDayNo"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			api.ValidationErrorResponse(c, "Invalid fiscal day number")
+			return
+		}
+		fiscalDayNo = &n
+	}
+
+	report, err := get(c.Request.Context(), deviceID, fiscalDayNo)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	if c.Query("format") == "text" {
+		c.String(http.StatusOK, report.Text)
+		return
+	}
+
+	api.SuccessResponse(c, report)
+}
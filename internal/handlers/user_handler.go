@@ -21,11 +21,72 @@ func NewUserHandler(userService *service.UserService) *UserHandler {
 // Login handles POST /api/v1/users/login
 func (h *UserHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.Login(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// RefreshToken handles POST /api/v1/users/refresh
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.RefreshSession(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// Logout handles POST /api/v1/users/logout
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
 		return
 	}
 
-	resp, err := h.userService.Login(req)
+	resp, err := h.userService.Logout(c.Request.Context(), operator, req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// ListSessions handles GET /api/v1/users/sessions
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	deviceID, exists := api.GetDeviceIDFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Device ID not found in context")
+		return
+	}
+
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
+
+	resp, err := h.userService.ListSessions(c.Request.Context(), operator, deviceID, c.Query("userName"))
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -44,7 +105,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 	pagination := api.GetPaginationParams(c, 20, 100)
 
-	resp, err := h.userService.ListUsers(deviceID, pagination.Offset, pagination.Limit)
+	resp, err := h.userService.ListUsers(c.Request.Context(), deviceID, pagination.Offset, pagination.Limit)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -55,20 +116,23 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 // CreateUserBegin handles POST /api/v1/users/create-begin
 func (h *UserHandler) CreateUserBegin(c *gin.Context) {
-	deviceID, exists := api.GetDeviceIDFromContext(c)
-	if !exists {
+	if _, exists := api.GetDeviceIDFromContext(c); !exists {
 		api.UnauthorizedResponse(c, "Device ID not found in context")
 		return
 	}
 
 	var req models.CreateUserBeginRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	req.DeviceID = deviceID
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
 
-	resp, err := h.userService.CreateUserBegin(req)
+	resp, err := h.userService.CreateUserBegin(c.Request.Context(), operator, req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -80,11 +144,11 @@ func (h *UserHandler) CreateUserBegin(c *gin.Context) {
 // CreateUserConfirm handles POST /api/v1/users/create-confirm
 func (h *UserHandler) CreateUserConfirm(c *gin.Context) {
 	var req models.CreateUserConfirmRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	resp, err := h.userService.CreateUserConfirm(req)
+	resp, err := h.userService.CreateUserConfirm(c.Request.Context(), req, c.ClientIP())
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -96,11 +160,17 @@ func (h *UserHandler) CreateUserConfirm(c *gin.Context) {
 // UpdateUser handles PUT /api/v1/users/update
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var req models.UpdateUserRequest
-	if !api.BindJSON(c, &req) {
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
 		return
 	}
 
-	resp, err := h.userService.UpdateUser(req)
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
+
+	resp, err := h.userService.UpdateUser(c.Request.Context(), operator, req)
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -116,7 +186,77 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.userService.ChangePassword(req)
+	operator, exists := api.GetOperatorFromContext(c)
+	if !exists {
+		api.UnauthorizedResponse(c, "Operator not found in context")
+		return
+	}
+
+	resp, err := h.userService.ChangePassword(c.Request.Context(), operator, req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// ResetPasswordBegin handles POST /api/v1/users/reset-password-begin
+func (h *UserHandler) ResetPasswordBegin(c *gin.Context) {
+	var req models.ResetUserPasswordBeginRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.ResetPasswordBegin(c.Request.Context(), req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// ResetPasswordConfirm handles POST /api/v1/users/reset-password-confirm
+func (h *UserHandler) ResetPasswordConfirm(c *gin.Context) {
+	var req models.ResetUserPasswordConfirmRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.ResetPasswordConfirm(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// ContactChangeBegin handles POST /api/v1/users/contact-change-begin
+func (h *UserHandler) ContactChangeBegin(c *gin.Context) {
+	var req models.SendSecurityCodeContactChangeRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.ContactChangeBegin(c.Request.Context(), req)
+	if err != nil {
+		api.ErrorResponse(c, err)
+		return
+	}
+
+	api.SuccessResponse(c, resp)
+}
+
+// ContactChangeConfirm handles POST /api/v1/users/contact-change-confirm
+func (h *UserHandler) ContactChangeConfirm(c *gin.Context) {
+	var req models.ConfirmUserContactChangeRequest
+	if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+		return
+	}
+
+	resp, err := h.userService.ContactChangeConfirm(c.Request.Context(), req, c.ClientIP())
 	if err != nil {
 		api.ErrorResponse(c, err)
 		return
@@ -3,6 +3,7 @@ package middleware
 import (
 	"strings"
 
+	"fiscalization-api/internal/models"
 	"fiscalization-api/pkg/api"
 
 	"github.com/gin-gonic/gin"
@@ -49,7 +50,7 @@ func AdminAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		role, _ := claims["role"].(string)
 		if role != "superadmin" {
-			api.UnauthorizedResponse(c, "Insufficient permissions")
+			api.ProblemResponse(c, models.NewAPIError(403, "Insufficient permissions", models.ErrCodeADM02))
 			c.Abort()
 			return
 		}
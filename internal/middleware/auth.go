@@ -1,12 +1,19 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"fiscalization-api/internal/models"
+	"fiscalization-api/pkg/api"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -15,6 +22,7 @@ import (
 const (
 	DeviceIDContextKey = "deviceID"
 	UserIDContextKey   = "userID"
+	OperatorContextKey = "operator"
 )
 
 // CertificateAuthMiddleware validates client certificates
@@ -28,7 +36,7 @@ func CertificateAuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
 				cert, err := parseCertFromHeader(certHeader)
 				if err != nil {
 					logger.Error("Failed to parse certificate from header", zap.Error(err))
-					c.JSON(401, models.NewAPIError(401, "Invalid client certificate", ""))
+					api.ProblemResponse(c, models.NewAPIError(401, "Invalid client certificate", models.ErrCodeDEV08))
 					c.Abort()
 					return
 				}
@@ -37,7 +45,7 @@ func CertificateAuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
 				deviceID, err := extractDeviceIDFromCert(cert)
 				if err != nil {
 					logger.Error("Failed to extract device ID from certificate", zap.Error(err))
-					c.JSON(401, models.NewAPIError(401, "Invalid certificate format", ""))
+					api.ProblemResponse(c, models.NewAPIError(401, "Invalid certificate format", models.ErrCodeDEV08))
 					c.Abort()
 					return
 				}
@@ -50,7 +58,7 @@ func CertificateAuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
 
 			// No certificate provided
 			logger.Warn("No client certificate provided")
-			c.JSON(401, models.NewAPIError(401, "Client certificate required", ""))
+			api.ProblemResponse(c, models.NewAPIError(401, "Client certificate required", models.ErrCodeDEV08))
 			c.Abort()
 			return
 		}
@@ -72,7 +80,7 @@ func CertificateAuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		deviceID, err := extractDeviceIDFromCert(cert)
 		if err != nil {
 			logger.Error("Failed to extract device ID from certificate", zap.Error(err))
-			c.JSON(401, models.NewAPIError(401, "Invalid certificate format", ""))
+			api.ProblemResponse(c, models.NewAPIError(401, "Invalid certificate format", models.ErrCodeDEV08))
 			c.Abort()
 			return
 		}
@@ -89,42 +97,67 @@ func CertificateAuthMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// DevicePathMiddleware checks that the {deviceID} in an FDMS path is the
+// device authenticated by CertificateAuthMiddleware, so a device cannot act
+// for another one by changing the URL
+func DevicePathMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pathID, err := strconv.Atoi(c.Param("deviceID"))
+		if err != nil || pathID <= 0 {
+			api.ProblemResponse(c, models.NewAPIError(400, "Invalid device ID in path", models.ErrCodeREQ03))
+			c.Abort()
+			return
+		}
+
+		certID, _ := GetDeviceIDFromContext(c)
+		if pathID != certID {
+			logger.Warn("Device ID in path does not match certificate",
+				zap.Int("pathDeviceID", pathID),
+				zap.Int("certDeviceID", certID),
+			)
+			api.ProblemResponse(c, models.NewAPIError(403, "Device ID does not match the client certificate", models.ErrCodeDEV01))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // extractDeviceIDFromCert extracts device ID from certificate CN
 // Expected format: ZIMRA-{serialNo}-{deviceID}
 func extractDeviceIDFromCert(cert *x509.Certificate) (int, error) {
 	cn := cert.Subject.CommonName
 
-	// Parse CN format: ZIMRA-{serialNo}-{deviceID}
-	// For now, we'll extract the last part after the last hyphen
-	// In production, implement proper parsing
-	
-	// Simple implementation: assume CN contains device ID somewhere
-	// This should be enhanced based on actual certificate format
-	
-	// For testing purposes, we'll accept any valid integer in the CN
-	// or default to 1001 if no valid integer found
-	
-	// Try to parse the entire CN as integer (for simple test certificates)
+	// Plain integer CNs are accepted for simple test certificates
 	if deviceID, err := strconv.Atoi(cn); err == nil {
 		return deviceID, nil
 	}
 
-	// Try to extract from ZIMRA format
-	// Expected: ZIMRA-{serialNo}-{deviceID}
-	// Example: ZIMRA-DEV001-1001
-	var deviceID int
-	_, err := fmt.Scanf(cn, "ZIMRA-%*s-%d", &deviceID)
-	if err == nil {
-		return deviceID, nil
+	// The serial number may itself contain hyphens, so the device ID is
+	// whatever follows the last one
+	idx := strings.LastIndex(cn, "-")
+	if !strings.HasPrefix(cn, "ZIMRA-") || idx < len("ZIMRA-") {
+		return 0, fmt.Errorf("unexpected certificate CN %q", cn)
+	}
+
+	deviceID, err := strconv.Atoi(cn[idx+1:])
+	if err != nil || deviceID <= 0 {
+		return 0, fmt.Errorf("invalid device ID in certificate CN %q", cn)
 	}
 
-	// For development: default to 1001 if we can't parse
-	// In production, this should return an error
-	return 1001, nil
+	return deviceID, nil
 }
 
-// parseCertFromHeader parses PEM-encoded certificate from header
+// parseCertFromHeader parses PEM-encoded certificate from header. nginx's
+// $ssl_client_escaped_cert sends the PEM URL-encoded, so that is accepted too.
 func parseCertFromHeader(certPEM string) (*x509.Certificate, error) {
+	if strings.Contains(certPEM, "%") {
+		if unescaped, err := url.PathUnescape(certPEM); err == nil {
+			certPEM = unescaped
+		}
+	}
+
 	block, _ := pem.Decode([]byte(certPEM))
 	if block == nil {
 		// Try without PEM encoding
@@ -134,43 +167,105 @@ func parseCertFromHeader(certPEM string) (*x509.Certificate, error) {
 	return x509.ParseCertificate(block.Bytes)
 }
 
-// JWTAuthMiddleware validates JWT tokens (for user authentication)
-func JWTAuthMiddleware(jwtSecret string, logger *zap.Logger) gin.HandlerFunc {
+// OperatorAuthenticator resolves an operator token presented by a device to
+// the user it was issued to and the session it belongs to
+type OperatorAuthenticator interface {
+	AuthenticateOperator(ctx context.Context, deviceID int, token string) (*models.User, *models.UserSession, error)
+}
+
+// JWTAuthMiddleware requires the operator signed in on the device to send
+// their token as "Authorization: Bearer {token}" or, as FDMS user requests
+// do, in the "token" field of the JSON body. It must run after
+// CertificateAuthMiddleware: the user has to belong to the taxpayer of the
+// device the certificate was issued to.
+func JWTAuthMiddleware(auth OperatorAuthenticator, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			logger.Warn("No authorization header provided")
-			c.JSON(401, models.NewAPIError(401, "Authorization required", ""))
+		token, ok := operatorToken(c)
+		if !ok {
+			logger.Warn("No operator token provided")
+			api.ProblemResponse(c, models.NewAPIError(401, "Operator token required", models.ErrCodeDEV12))
 			c.Abort()
 			return
 		}
 
-		// Extract token (format: "Bearer {token}")
-		var token string
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			token = authHeader[7:]
-		} else {
-			logger.Warn("Invalid authorization header format")
-			c.JSON(401, models.NewAPIError(401, "Invalid authorization format", ""))
+		deviceID, _ := GetDeviceIDFromContext(c)
+		user, session, err := auth.AuthenticateOperator(c.Request.Context(), deviceID, token)
+		if err != nil {
+			logger.Warn("Operator authentication failed", zap.Int("deviceID", deviceID), zap.Error(err))
+			api.ErrorResponse(c, err)
 			c.Abort()
 			return
 		}
 
-		// Validate token
-		// TODO: Implement JWT validation using jwtSecret
-		// For now, we'll accept any token for development
-		_ = token
-
-		// Extract user ID from token and store in context
-		// TODO: Parse JWT and extract user ID
-		// For development, use a default user ID
-		c.Set(UserIDContextKey, int64(1))
+		c.Set(UserIDContextKey, user.ID)
+		c.Set(OperatorContextKey, models.Operator{
+			UserID:    user.ID,
+			SessionID: session.ID,
+			Username:  user.Username,
+			Name:      strings.TrimSpace(user.PersonName + " " + user.PersonSurname),
+			Role:      user.UserRole,
+			IPAddress: c.ClientIP(),
+		})
+		c.Next()
+	}
+}
 
+// RequirePermission lets only operators whose role has permission through.
+// It must run after JWTAuthMiddleware.
+func RequirePermission(permission models.Permission, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		operator, _ := GetOperatorFromContext(c)
+		if !operator.Role.Can(permission) {
+			logger.Warn("Operator role lacks permission",
+				zap.String("username", operator.Username),
+				zap.String("role", string(operator.Role)),
+				zap.String("permission", string(permission)),
+			)
+			api.ProblemResponse(c, models.NewAPIError(403, fmt.Sprintf("Role %s is not allowed to perform this operation", operator.Role), models.ErrCodeUSER10))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// maxTokenBodySize bounds how much of a request body is read looking for a
+// token
+const maxTokenBodySize = 1 << 20
+
+// readCloser reads a body that was partly read already and closes the
+// original
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// operatorToken returns the bearer token of the request, or the "token"
+// field of its JSON body. The body is left in place for the handler.
+func operatorToken(c *gin.Context) (string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		return token, ok && token != ""
+	}
+
+	if c.Request.Body == nil || c.ContentType() != "application/json" {
+		return "", false
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTokenBodySize))
+	if err != nil {
+		return "", false
+	}
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+
+	var fields struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", false
+	}
+	return fields.Token, fields.Token != ""
+}
+
 // GetDeviceIDFromContext retrieves device ID from context
 func GetDeviceIDFromContext(c *gin.Context) (int, bool) {
 	deviceID, exists := c.Get(DeviceIDContextKey)
@@ -188,3 +283,13 @@ func GetUserIDFromContext(c *gin.Context) (int64, bool) {
 	}
 	return userID.(int64), true
 }
+
+// GetOperatorFromContext retrieves the operator authenticated by
+// JWTAuthMiddleware from context
+func GetOperatorFromContext(c *gin.Context) (models.Operator, bool) {
+	operator, exists := c.Get(OperatorContextKey)
+	if !exists {
+		return models.Operator{}, false
+	}
+	return operator.(models.Operator), true
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/pkg/api"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// testCertPEM returns a self-signed client certificate issued to commonName
+func testCertPEM(t *testing.T, commonName string) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// newTestFDMSRouter mounts the FDMS device and user groups, and a public
+// route without a certificate, on handlers that echo the device ID the
+// request body was bound to
+func newTestFDMSRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	echo := func(c *gin.Context) {
+		var req struct {
+			DeviceID int `json:"deviceID"`
+		}
+		if !api.BindDeviceJSON(c, &req, &req.DeviceID) {
+			return
+		}
+		c.JSON(http.StatusOK, req)
+	}
+
+	router := gin.New()
+	device := router.Group("/Device/v1/:deviceID")
+	device.Use(CertificateAuthMiddleware(logger), DevicePathMiddleware(logger))
+	device.POST("/Ping", echo)
+
+	user := router.Group("/User/v1/:deviceID")
+	user.Use(CertificateAuthMiddleware(logger), DevicePathMiddleware(logger))
+	user.POST("/Login", echo)
+
+	router.POST("/Public/v1/:deviceID/RegisterDevice", echo)
+	return router
+}
+
+func TestDevicePathMiddleware(t *testing.T) {
+	router := newTestFDMSRouter()
+	cert := testCertPEM(t, "ZIMRA-SN-0001-1001")
+
+	tests := []struct {
+		name         string
+		path         string
+		cert         string
+		wantStatus   int
+		wantCode     string
+		wantDeviceID int
+	}{
+		{"Device route", "/Device/v1/1001/Ping", cert, http.StatusOK, "", 1001},
+		{"User route", "/User/v1/1001/Login", cert, http.StatusOK, "", 1001},
+		{"Path of another device", "/Device/v1/1002/Ping", cert, http.StatusForbidden, models.ErrCodeDEV01, 0},
+		{"User path of another device", "/User/v1/1002/Login", cert, http.StatusForbidden, models.ErrCodeDEV01, 0},
+		{"Non-numeric device ID", "/Device/v1/abc/Ping", cert, http.StatusBadRequest, models.ErrCodeREQ03, 0},
+		{"Negative device ID", "/User/v1/-1/Login", cert, http.StatusBadRequest, models.ErrCodeREQ03, 0},
+		{"No certificate", "/Device/v1/1001/Ping", "", http.StatusUnauthorized, models.ErrCodeDEV08, 0},
+		{"Public route takes the path device ID", "/Public/v1/1003/RegisterDevice", "", http.StatusOK, "", 1003},
+		{"Public route with invalid device ID", "/Public/v1/abc/RegisterDevice", "", http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The body names another device, which must never be used
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"deviceID":9999}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.cert != "" {
+				req.Header.Set("X-SSL-Client-Cert", tt.cert)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var resp struct {
+					DeviceID int `json:"deviceID"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("response is not JSON: %v", err)
+				}
+				if resp.DeviceID != tt.wantDeviceID {
+					t.Errorf("bound deviceID = %d, want %d", resp.DeviceID, tt.wantDeviceID)
+				}
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != models.ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, models.ProblemContentType)
+			}
+			var problem models.APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if tt.wantCode != "" && problem.ErrorCode != tt.wantCode {
+				t.Errorf("ErrorCode = %q, want %q", problem.ErrorCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExtractDeviceIDFromCert(t *testing.T) {
+	tests := []struct {
+		cn      string
+		want    int
+		wantErr bool
+	}{
+		{"ZIMRA-SN-0001-1001", 1001, false},
+		{"ZIMRA-SN0001-42", 42, false},
+		{"1001", 1001, false},
+		{"ZIMRA-SN-0001-abc", 0, true},
+		{"ZIMRA-SN-0001-0", 0, true},
+		{"OTHER-SN-0001-1001", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cn, func(t *testing.T) {
+			got, err := extractDeviceIDFromCert(&x509.Certificate{Subject: pkix.Name{CommonName: tt.cn}})
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("extractDeviceIDFromCert(%q) = %d, %v, want %d", tt.cn, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"time"
 
+	"fiscalization-api/pkg/api"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -21,7 +23,7 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		duration := time.Since(start)
 
 		// Log request
-		logger.Info("HTTP Request",
+		fields := []zap.Field{
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
@@ -30,7 +32,12 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("client_ip", c.ClientIP()),
 			zap.String("user_agent", c.Request.UserAgent()),
 			zap.Int("body_size", c.Writer.Size()),
-		)
+		}
+		// Error responses carry an operation ID to match them with this entry
+		if operationID := c.GetString(api.OperationIDContextKey); operationID != "" {
+			fields = append(fields, zap.String("operationID", operationID))
+		}
+		logger.Info("HTTP Request", fields...)
 
 		// Log errors if any
 		if len(c.Errors) > 0 {
@@ -106,6 +106,8 @@ type AdminReceiptRow struct {
 	ReceiptTotal    float64   `json:"receiptTotal" db:"receipt_total"`
 	ValidationColor *string   `json:"validationColor,omitempty" db:"validation_color"`
 	ServerDate      *time.Time `json:"serverDate,omitempty" db:"server_date"`
+	Username        *string   `json:"username,omitempty" db:"username"`
+	UserNameSurname *string   `json:"userNameSurname,omitempty" db:"user_name_surname"`
 }
 
 type ListReceiptsResponse struct {
@@ -118,6 +120,33 @@ type ListAuditLogsResponse struct {
 	Rows  []AuditLog `json:"rows"`
 }
 
+// ─── Admin Fiscal Day Requests ────────────────────────────────────────────────
+
+type AdminForceCloseFiscalDayRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type AdminResetFiscalDayRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AdminActor identifies the administrator performing an action, for auditing
+type AdminActor struct {
+	Username  string
+	IPAddress string
+}
+
+// Operator is the user signed in on a device, for stamping receipts and
+// auditing fiscal day operations
+type Operator struct {
+	UserID    int64
+	SessionID int64
+	Username  string
+	Name      string
+	Role      UserRole
+	IPAddress string
+}
+
 // ─── Admin Auth ───────────────────────────────────────────────────────────────
 
 type AdminLoginRequest struct {
@@ -138,9 +167,10 @@ type AdminCreateUserRequest struct {
 	Password      string `json:"password" binding:"required,max=100"`
 	PersonName    string `json:"personName" binding:"required,max=100"`
 	PersonSurname string `json:"personSurname" binding:"required,max=100"`
-	UserRole      string `json:"userRole" binding:"required,max=100"`
+	UserRole      UserRole `json:"userRole" binding:"required,oneof=Owner Manager Cashier Accountant"`
 	Email         string `json:"email" binding:"required,max=100"`
 	PhoneNo       string `json:"phoneNo" binding:"required,max=20"`
+	Language      Language `json:"language,omitempty" binding:"omitempty,oneof=en sn nd"`
 }
 
 type AdminUserRow struct {
@@ -148,7 +178,7 @@ type AdminUserRow struct {
 	Username      string    `json:"username" db:"username"`
 	PersonName    string    `json:"personName" db:"person_name"`
 	PersonSurname string    `json:"personSurname" db:"person_surname"`
-	UserRole      string    `json:"userRole" db:"user_role"`
+	UserRole      UserRole  `json:"userRole" db:"user_role"`
 	Email         string    `json:"email" db:"email"`
 	PhoneNo       string    `json:"phoneNo" db:"phone_no"`
 	Status        int       `json:"status" db:"status"`
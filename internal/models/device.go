@@ -166,18 +166,18 @@ type GetStatusResponse struct {
 
 // FiscalDayCounter is imported from fiscal_day package
 type FiscalDayCounter struct {
-	FiscalCounterType       int      `json:"fiscalCounterType"`
-	FiscalCounterCurrency   string   `json:"fiscalCounterCurrency"`
-	FiscalCounterTaxID      *int     `json:"fiscalCounterTaxID,omitempty"`
-	FiscalCounterTaxPercent *float64 `json:"fiscalCounterTaxPercent,omitempty"`
-	FiscalCounterMoneyType  *int     `json:"fiscalCounterMoneyType,omitempty"`
-	FiscalCounterValue      float64  `json:"fiscalCounterValue"`
+	FiscalCounterType       int      `json:"fiscalCounterType" db:"fiscal_counter_type"`
+	FiscalCounterCurrency   string   `json:"fiscalCounterCurrency" db:"fiscal_counter_currency"`
+	FiscalCounterTaxID      *int     `json:"fiscalCounterTaxID,omitempty" db:"fiscal_counter_tax_id"`
+	FiscalCounterTaxPercent *float64 `json:"fiscalCounterTaxPercent,omitempty" db:"fiscal_counter_tax_percent"`
+	FiscalCounterMoneyType  *int     `json:"fiscalCounterMoneyType,omitempty" db:"fiscal_counter_money_type"`
+	FiscalCounterValue      float64  `json:"fiscalCounterValue" db:"fiscal_counter_value"`
 }
 
 // FiscalDayDocumentQuantity is imported from fiscal_day package
 type FiscalDayDocumentQuantity struct {
-	ReceiptType        int     `json:"receiptType"`
-	ReceiptCurrency    string  `json:"receiptCurrency"`
-	ReceiptQuantity    int     `json:"receiptQuantity"`
-	ReceiptTotalAmount float64 `json:"receiptTotalAmount"`
+	ReceiptType        int     `json:"receiptType" db:"receipt_type"`
+	ReceiptCurrency    string  `json:"receiptCurrency" db:"receipt_currency"`
+	ReceiptQuantity    int     `json:"receiptQuantity" db:"receipt_quantity"`
+	ReceiptTotalAmount float64 `json:"receiptTotalAmount" db:"receipt_total_amount"`
 }
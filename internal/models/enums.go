@@ -38,6 +38,22 @@ func (m FiscalDayReconciliationMode) String() string {
 	return [...]string{"Auto", "Manual"}[m]
 }
 
+// FiscalDayClosure records why the server closed a fiscal day without the
+// device. Such days are reconciled manually; the closure is not signed.
+type FiscalDayClosure int
+
+const (
+	// FiscalDayClosureAutoClosed marks days closed after exceeding
+	// TaxPayerDayMaxHrs
+	FiscalDayClosureAutoClosed FiscalDayClosure = iota
+	// FiscalDayClosureForced marks days closed by an administrator
+	FiscalDayClosureForced
+)
+
+func (c FiscalDayClosure) String() string {
+	return [...]string{"AutoClosed", "Forced"}[c]
+}
+
 // FiscalCounterType represents type of fiscal counter
 type FiscalCounterType int
 
@@ -193,6 +209,23 @@ func (s UserStatus) String() string {
 	return [...]string{"Active", "Blocked", "NotConfirmed"}[s]
 }
 
+// Language is the language a user receives notifications in
+type Language string
+
+const (
+	LanguageEnglish Language = "en"
+	LanguageShona   Language = "sn"
+	LanguageNdebele Language = "nd"
+)
+
+// OrDefault returns the language, or English when it is not set
+func (l Language) OrDefault() Language {
+	if l == "" {
+		return LanguageEnglish
+	}
+	return l
+}
+
 // SendSecurityCodeTo represents channels for security code
 type SendSecurityCodeTo int
 
@@ -0,0 +1,102 @@
+// Code generated by gen_error_catalog.go; DO NOT EDIT.
+
+package models
+
+// ErrorCatalog lists every error code the API returns, in the order they
+// are declared
+var ErrorCatalog = []ErrorCodeInfo{
+	{Code: ErrCodeDEV01, Category: "Device errors", Description: "Device not found or invalid"},
+	{Code: ErrCodeDEV02, Category: "Device errors", Description: "Activation key incorrect"},
+	{Code: ErrCodeDEV03, Category: "Device errors", Description: "Certificate request invalid"},
+	{Code: ErrCodeDEV04, Category: "Device errors", Description: "Device model blacklisted"},
+	{Code: ErrCodeDEV05, Category: "Device errors", Description: "Taxpayer not active"},
+	{Code: ErrCodeDEV06, Category: "Device errors", Description: "Device already registered"},
+	{Code: ErrCodeDEV07, Category: "Device errors", Description: "Certificate expired"},
+	{Code: ErrCodeDEV08, Category: "Device errors", Description: "Invalid certificate"},
+	{Code: ErrCodeDEV09, Category: "Device errors", Description: "Device blocked"},
+	{Code: ErrCodeDEV10, Category: "Device errors", Description: "Operating mode invalid"},
+	{Code: ErrCodeFISC01, Category: "Fiscal day errors", Description: "Fiscal day already opened"},
+	{Code: ErrCodeFISC02, Category: "Fiscal day errors", Description: "Previous fiscal day not closed"},
+	{Code: ErrCodeFISC03, Category: "Fiscal day errors", Description: "No fiscal day to close"},
+	{Code: ErrCodeFISC04, Category: "Fiscal day errors", Description: "Fiscal day has validation errors"},
+	{Code: ErrCodeFISC05, Category: "Fiscal day errors", Description: "Fiscal day not found"},
+	{Code: ErrCodeRCPT01, Category: "Receipt errors", Description: "No fiscal day opened"},
+	{Code: ErrCodeRCPT02, Category: "Receipt errors", Description: "Receipt validation failed"},
+	{Code: ErrCodeRCPT03, Category: "Receipt errors", Description: "Invalid signature"},
+	{Code: ErrCodeRCPT04, Category: "Receipt errors", Description: "Duplicate receipt"},
+	{Code: ErrCodeRCPT05, Category: "Receipt errors", Description: "Invalid receipt type"},
+	{Code: ErrCodeRCPT06, Category: "Receipt errors", Description: "Invalid currency"},
+	{Code: ErrCodeRCPT07, Category: "Receipt errors", Description: "Invalid total"},
+	{Code: ErrCodeRCPT08, Category: "Receipt errors", Description: "Invalid tax"},
+	{Code: ErrCodeRCPT09, Category: "Receipt errors", Description: "Invalid line item"},
+	{Code: ErrCodeRCPT10, Category: "Receipt errors", Description: "Invalid payment"},
+	{Code: ErrCodeRCPT11, Category: "Receipt errors", Description: "Receipt chain changed during submission"},
+	{Code: ErrCodeRCPT010, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Currency code not valid"},
+	{Code: ErrCodeRCPT011, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt counter not sequential"},
+	{Code: ErrCodeRCPT012, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt global number not sequential"},
+	{Code: ErrCodeRCPT013, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Date too old"},
+	{Code: ErrCodeRCPT014, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt date earlier than fiscal day opening"},
+	{Code: ErrCodeRCPT015, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credited/debited invoice data not provided"},
+	{Code: ErrCodeRCPT016, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "No receipt lines provided"},
+	{Code: ErrCodeRCPT017, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Taxes information not provided"},
+	{Code: ErrCodeRCPT018, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Payment information not provided"},
+	{Code: ErrCodeRCPT019, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice total not equal to sum of invoice lines"},
+	{Code: ErrCodeRCPT020, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice signature not valid"},
+	{Code: ErrCodeRCPT021, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "VAT tax used by a taxpayer not registered for VAT"},
+	{Code: ErrCodeRCPT022, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt line price has the wrong sign"},
+	{Code: ErrCodeRCPT023, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt line quantity not positive"},
+	{Code: ErrCodeRCPT024, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt line total not equal to unit price times quantity"},
+	{Code: ErrCodeRCPT025, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Tax not valid or not valid on the receipt date"},
+	{Code: ErrCodeRCPT026, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Incorrectly calculated tax amount"},
+	{Code: ErrCodeRCPT027, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Incorrectly calculated total sales amount"},
+	{Code: ErrCodeRCPT028, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Payment amount has the wrong sign"},
+	{Code: ErrCodeRCPT029, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credited/debited invoice provided for a regular invoice"},
+	{Code: ErrCodeRCPT030, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt date earlier than the previous receipt"},
+	{Code: ErrCodeRCPT031, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt date in the future"},
+	{Code: ErrCodeRCPT032, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credit/debit note refers to an unknown invoice"},
+	{Code: ErrCodeRCPT033, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credited/debited invoice issued more than 12 months ago"},
+	{Code: ErrCodeRCPT034, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credit/debit note reason not provided"},
+	{Code: ErrCodeRCPT035, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credit note total exceeds the original invoice"},
+	{Code: ErrCodeRCPT036, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credit/debit note uses taxes not on the original invoice"},
+	{Code: ErrCodeRCPT037, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice total not equal to sum of invoice lines and taxes"},
+	{Code: ErrCodeRCPT038, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice total not equal to sum of sales amounts including tax"},
+	{Code: ErrCodeRCPT039, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice total not equal to sum of payments"},
+	{Code: ErrCodeRCPT040, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Invoice total has the wrong sign"},
+	{Code: ErrCodeRCPT041, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt issued after fiscal day end"},
+	{Code: ErrCodeRCPT042, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Credit/debit note currency differs from the original invoice"},
+	{Code: ErrCodeRCPT043, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Mandatory buyer data not provided"},
+	{Code: ErrCodeRCPT044, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Receipt type invalid for operation"},
+	{Code: ErrCodeRCPT045, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Line sequence invalid"},
+	{Code: ErrCodeRCPT046, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "Payment method invalid"},
+	{Code: ErrCodeRCPT047, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "HS code required for VAT taxpayers"},
+	{Code: ErrCodeRCPT048, Category: "Receipt validation codes (RCPT010-RCPT048)", Description: "HS code length not valid"},
+	{Code: ErrCodeFILE01, Category: "File errors", Description: "File format invalid"},
+	{Code: ErrCodeFILE02, Category: "File errors", Description: "File too large"},
+	{Code: ErrCodeFILE03, Category: "File errors", Description: "File processing failed"},
+	{Code: ErrCodeFILE04, Category: "File errors", Description: "File sent for closed day"},
+	{Code: ErrCodeFILE05, Category: "File errors", Description: "File exceeded waiting time"},
+	{Code: ErrCodeUSER01, Category: "User errors", Description: "User not found"},
+	{Code: ErrCodeUSER02, Category: "User errors", Description: "Invalid credentials"},
+	{Code: ErrCodeUSER03, Category: "User errors", Description: "User not active"},
+	{Code: ErrCodeUSER04, Category: "User errors", Description: "Security code invalid"},
+	{Code: ErrCodeUSER05, Category: "User errors", Description: "Security code expired"},
+	{Code: ErrCodeUSER06, Category: "User errors", Description: "Username already exists"},
+	{Code: ErrCodeUSER07, Category: "User errors", Description: "Password too weak"},
+	{Code: ErrCodeUSER08, Category: "User errors", Description: "Token invalid"},
+	{Code: ErrCodeUSER09, Category: "User errors", Description: "Token expired"},
+	{Code: ErrCodeUSER10, Category: "User errors", Description: "Insufficient permissions"},
+	{Code: ErrCodeDEV11, Category: "Device user errors", Description: "User credentials are incorrect"},
+	{Code: ErrCodeDEV12, Category: "Device user errors", Description: "Token is not valid"},
+	{Code: ErrCodeDEV13, Category: "Device user errors", Description: "User is not confirmed"},
+	{Code: ErrCodeDEV14, Category: "Device user errors", Description: "Email or phone number is not valid or doesn't exist"},
+	{Code: ErrCodeDEV15, Category: "Device user errors", Description: "Email or phone number already confirmed"},
+	{Code: ErrCodeREQ01, Category: "Request errors", Description: "Request deadline exceeded"},
+	{Code: ErrCodeREQ02, Category: "Request errors", Description: "Request cancelled by client"},
+	{Code: ErrCodeREQ03, Category: "Request errors", Description: "Request body or parameters invalid"},
+	{Code: ErrCodeADM01, Category: "Admin errors", Description: "Admin credentials incorrect"},
+	{Code: ErrCodeADM02, Category: "Admin errors", Description: "Admin permission required"},
+	{Code: ErrCodeADM03, Category: "Admin errors", Description: "Taxpayer not found"},
+	{Code: ErrCodeADM04, Category: "Admin errors", Description: "Notification not found"},
+	{Code: ErrCodeADM05, Category: "Admin errors", Description: "Notification is not dead-lettered"},
+	{Code: ErrCodeADM06, Category: "Admin errors", Description: "Taxpayer TIN already registered"},
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 )
 
-// Error codes constants
+//go:generate go run gen_error_catalog.go
+
+// Error codes constants. Each code's comment is its description in the
+// error code catalogue, and the comment above a group is its category; run
+// go generate after changing them.
 const (
 	// Device errors
 	ErrCodeDEV01 = "DEV01" // Device not found or invalid
@@ -23,6 +27,7 @@ const (
 	ErrCodeFISC02 = "FISC02" // Previous fiscal day not closed
 	ErrCodeFISC03 = "FISC03" // No fiscal day to close
 	ErrCodeFISC04 = "FISC04" // Fiscal day has validation errors
+	ErrCodeFISC05 = "FISC05" // Fiscal day not found
 
 	// Receipt errors
 	ErrCodeRCPT01 = "RCPT01" // No fiscal day opened
@@ -35,47 +40,48 @@ const (
 	ErrCodeRCPT08 = "RCPT08" // Invalid tax
 	ErrCodeRCPT09 = "RCPT09" // Invalid line item
 	ErrCodeRCPT10 = "RCPT10" // Invalid payment
+	ErrCodeRCPT11 = "RCPT11" // Receipt chain changed during submission
 
 	// Receipt validation codes (RCPT010-RCPT048)
-	ErrCodeRCPT010 = "RCPT010" // Currency not valid
-	ErrCodeRCPT011 = "RCPT011" // Counter incorrect
-	ErrCodeRCPT012 = "RCPT012" // Date in future
+	ErrCodeRCPT010 = "RCPT010" // Currency code not valid
+	ErrCodeRCPT011 = "RCPT011" // Receipt counter not sequential
+	ErrCodeRCPT012 = "RCPT012" // Receipt global number not sequential
 	ErrCodeRCPT013 = "RCPT013" // Date too old
-	ErrCodeRCPT014 = "RCPT014" // Total calculation incorrect
-	ErrCodeRCPT015 = "RCPT015" // Tax calculation incorrect
-	ErrCodeRCPT016 = "RCPT016" // Payment total mismatch
-	ErrCodeRCPT017 = "RCPT017" // Invalid buyer data
-	ErrCodeRCPT018 = "RCPT018" // Missing receipt lines
-	ErrCodeRCPT019 = "RCPT019" // Invalid HS code
-	ErrCodeRCPT020 = "RCPT020" // Invalid tax ID
-	ErrCodeRCPT021 = "RCPT021" // Tax not applicable
-	ErrCodeRCPT022 = "RCPT022" // Line price invalid
-	ErrCodeRCPT023 = "RCPT023" // Line quantity invalid
-	ErrCodeRCPT024 = "RCPT024" // Line total incorrect
-	ErrCodeRCPT025 = "RCPT025" // Discount exceeds total
-	ErrCodeRCPT026 = "RCPT026" // VAT taxpayer required
-	ErrCodeRCPT027 = "RCPT027" // Invoice number duplicate
-	ErrCodeRCPT028 = "RCPT028" // Credit note amount exceeds original
-	ErrCodeRCPT029 = "RCPT029" // Debit note amount exceeds limit
-	ErrCodeRCPT030 = "RCPT030" // Original invoice not found
-	ErrCodeRCPT031 = "RCPT031" // Original invoice invalid type
-	ErrCodeRCPT032 = "RCPT032" // Credit/debit note currency mismatch
-	ErrCodeRCPT033 = "RCPT033" // Previous hash missing
-	ErrCodeRCPT034 = "RCPT034" // Previous hash invalid
-	ErrCodeRCPT035 = "RCPT035" // Signature verification failed
-	ErrCodeRCPT036 = "RCPT036" // Missing required field
-	ErrCodeRCPT037 = "RCPT037" // Field length exceeded
-	ErrCodeRCPT038 = "RCPT038" // Invalid character in field
-	ErrCodeRCPT039 = "RCPT039" // Tax percent mismatch
-	ErrCodeRCPT040 = "RCPT040" // Tax amount calculation error
-	ErrCodeRCPT041 = "RCPT041" // Multiple tax rates on line
-	ErrCodeRCPT042 = "RCPT042" // Tax valid from date invalid
-	ErrCodeRCPT043 = "RCPT043" // Tax valid till date invalid
+	ErrCodeRCPT014 = "RCPT014" // Receipt date earlier than fiscal day opening
+	ErrCodeRCPT015 = "RCPT015" // Credited/debited invoice data not provided
+	ErrCodeRCPT016 = "RCPT016" // No receipt lines provided
+	ErrCodeRCPT017 = "RCPT017" // Taxes information not provided
+	ErrCodeRCPT018 = "RCPT018" // Payment information not provided
+	ErrCodeRCPT019 = "RCPT019" // Invoice total not equal to sum of invoice lines
+	ErrCodeRCPT020 = "RCPT020" // Invoice signature not valid
+	ErrCodeRCPT021 = "RCPT021" // VAT tax used by a taxpayer not registered for VAT
+	ErrCodeRCPT022 = "RCPT022" // Receipt line price has the wrong sign
+	ErrCodeRCPT023 = "RCPT023" // Receipt line quantity not positive
+	ErrCodeRCPT024 = "RCPT024" // Receipt line total not equal to unit price times quantity
+	ErrCodeRCPT025 = "RCPT025" // Tax not valid or not valid on the receipt date
+	ErrCodeRCPT026 = "RCPT026" // Incorrectly calculated tax amount
+	ErrCodeRCPT027 = "RCPT027" // Incorrectly calculated total sales amount
+	ErrCodeRCPT028 = "RCPT028" // Payment amount has the wrong sign
+	ErrCodeRCPT029 = "RCPT029" // Credited/debited invoice provided for a regular invoice
+	ErrCodeRCPT030 = "RCPT030" // Receipt date earlier than the previous receipt
+	ErrCodeRCPT031 = "RCPT031" // Receipt date in the future
+	ErrCodeRCPT032 = "RCPT032" // Credit/debit note refers to an unknown invoice
+	ErrCodeRCPT033 = "RCPT033" // Credited/debited invoice issued more than 12 months ago
+	ErrCodeRCPT034 = "RCPT034" // Credit/debit note reason not provided
+	ErrCodeRCPT035 = "RCPT035" // Credit note total exceeds the original invoice
+	ErrCodeRCPT036 = "RCPT036" // Credit/debit note uses taxes not on the original invoice
+	ErrCodeRCPT037 = "RCPT037" // Invoice total not equal to sum of invoice lines and taxes
+	ErrCodeRCPT038 = "RCPT038" // Invoice total not equal to sum of sales amounts including tax
+	ErrCodeRCPT039 = "RCPT039" // Invoice total not equal to sum of payments
+	ErrCodeRCPT040 = "RCPT040" // Invoice total has the wrong sign
+	ErrCodeRCPT041 = "RCPT041" // Receipt issued after fiscal day end
+	ErrCodeRCPT042 = "RCPT042" // Credit/debit note currency differs from the original invoice
+	ErrCodeRCPT043 = "RCPT043" // Mandatory buyer data not provided
 	ErrCodeRCPT044 = "RCPT044" // Receipt type invalid for operation
 	ErrCodeRCPT045 = "RCPT045" // Line sequence invalid
 	ErrCodeRCPT046 = "RCPT046" // Payment method invalid
-	ErrCodeRCPT047 = "RCPT047" // Receipt counter sequence broken
-	ErrCodeRCPT048 = "RCPT048" // Global counter sequence broken
+	ErrCodeRCPT047 = "RCPT047" // HS code required for VAT taxpayers
+	ErrCodeRCPT048 = "RCPT048" // HS code length not valid
 
 	// File errors
 	ErrCodeFILE01 = "FILE01" // File format invalid
@@ -96,28 +102,80 @@ const (
 	ErrCodeUSER09 = "USER09" // Token expired
 	ErrCodeUSER10 = "USER10" // Insufficient permissions
 
-
+	// Device user errors
 	ErrCodeDEV11 = "DEV11" // User credentials are incorrect
 	ErrCodeDEV12 = "DEV12" // Token is not valid
 	ErrCodeDEV13 = "DEV13" // User is not confirmed
 	ErrCodeDEV14 = "DEV14" // Email or phone number is not valid or doesn't exist
 	ErrCodeDEV15 = "DEV15" // Email or phone number already confirmed
 
+	// Request errors
+	ErrCodeREQ01 = "REQ01" // Request deadline exceeded
+	ErrCodeREQ02 = "REQ02" // Request cancelled by client
+	ErrCodeREQ03 = "REQ03" // Request body or parameters invalid
+
+	// Admin errors
+	ErrCodeADM01 = "ADM01" // Admin credentials incorrect
+	ErrCodeADM02 = "ADM02" // Admin permission required
+	ErrCodeADM03 = "ADM03" // Taxpayer not found
+	ErrCodeADM04 = "ADM04" // Notification not found
+	ErrCodeADM05 = "ADM05" // Notification is not dead-lettered
+	ErrCodeADM06 = "ADM06" // Taxpayer TIN already registered
 )
 
-// APIError represents an API error response
+// ProblemContentType is the media type of error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// ErrorCodeInfo describes an error code in the catalogue
+type ErrorCodeInfo struct {
+	Code        string `json:"code"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+// LookupErrorCode returns the catalogue entry of an error code
+func LookupErrorCode(code string) (ErrorCodeInfo, bool) {
+	for _, info := range ErrorCatalog {
+		if info.Code == code {
+			return info, true
+		}
+	}
+	return ErrorCodeInfo{}, false
+}
+
+// ProblemType returns the problem type URI of an error code: its entry in
+// the error code catalogue, or about:blank for errors without a code
+func ProblemType(errorCode string) string {
+	if errorCode == "" {
+		return "about:blank"
+	}
+	return "/api/v1/errors/" + errorCode
+}
+
+// APIError is the problem details (RFC 7807) body of every error response.
+// OperationID identifies the request in the server logs; Errors lists the
+// fields of the request that failed validation.
 type APIError struct {
-	Type      string `json:"type"`
-	Title     string `json:"title"`
-	Status    int    `json:"status"`
-	ErrorCode string `json:"errorCode,omitempty"`
-	Detail    string `json:"detail,omitempty"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Status      int          `json:"status"`
+	ErrorCode   string       `json:"errorCode,omitempty"`
+	Detail      string       `json:"detail,omitempty"`
+	OperationID string       `json:"operationID,omitempty"`
+	Errors      []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a validation problem with one field of a request. Field is
+// the JSON path of the field, e.g. receipt.receiptLines[0].receiptLinePrice.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // NewAPIError creates a new API error
 func NewAPIError(status int, title string, errorCode string) *APIError {
 	return &APIError{
-		Type:      "about:blank",
+		Type:      ProblemType(errorCode),
 		Title:     title,
 		Status:    status,
 		ErrorCode: errorCode,
@@ -137,3 +195,9 @@ func (e *APIError) WithDetail(detail string) *APIError {
 	e.Detail = detail
 	return e
 }
+
+// WithFieldErrors adds field-level validation problems to the error
+func (e *APIError) WithFieldErrors(errs ...FieldError) *APIError {
+	e.Errors = append(e.Errors, errs...)
+	return e
+}
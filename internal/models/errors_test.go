@@ -0,0 +1,42 @@
+package models
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// TestErrorCatalog_UpToDate fails when an ErrCode constant was added or
+// removed without running go generate
+func TestErrorCatalog_UpToDate(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	var declared []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if strings.HasPrefix(name.Name, "ErrCode") {
+					declared = append(declared, name.Name)
+				}
+			}
+		}
+	}
+
+	if len(declared) != len(ErrorCatalog) {
+		t.Fatalf("errors.go declares %d error codes, ErrorCatalog has %d; run go generate", len(declared), len(ErrorCatalog))
+	}
+	for i, name := range declared {
+		if "ErrCode"+ErrorCatalog[i].Code != name {
+			t.Errorf("ErrorCatalog[%d] = %s, want %s; run go generate", i, ErrorCatalog[i].Code, name)
+		}
+	}
+}
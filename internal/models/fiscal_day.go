@@ -13,6 +13,7 @@ type FiscalDay struct {
 	FiscalDayClosed          *time.Time                  `json:"fiscalDayClosed,omitempty" db:"fiscal_day_closed"`
 	Status                   FiscalDayStatus             `json:"status" db:"status"`
 	ReconciliationMode       *FiscalDayReconciliationMode `json:"reconciliationMode,omitempty" db:"reconciliation_mode"`
+	Closure                  *FiscalDayClosure            `json:"closure,omitempty" db:"closure"`
 	FiscalDayDeviceSignature *SignatureData              `json:"fiscalDayDeviceSignature,omitempty" db:"fiscal_day_device_signature"`
 	FiscalDayServerSignature *SignatureDataEx            `json:"fiscalDayServerSignature,omitempty" db:"fiscal_day_server_signature"`
 	ClosingErrorCode         *FiscalDayProcessingError   `json:"closingErrorCode,omitempty" db:"closing_error_code"`
@@ -89,9 +90,12 @@ type GetStatusRequest struct {
 	DeviceID int `json:"deviceID" binding:"required"`
 }
 
-// OpenFiscalDayRequest represents opening fiscal day request (simplified)
+// OpenFiscalDayRequest represents opening fiscal day request. FDMS devices
+// send the number and opening time of the day; both are optional.
 type OpenFiscalDayRequest struct {
-	DeviceID int `json:"deviceID" binding:"required"`
+	DeviceID        int        `json:"deviceID" binding:"required"`
+	FiscalDayNo     *int       `json:"fiscalDayNo,omitempty"`
+	FiscalDayOpened *time.Time `json:"fiscalDayOpened,omitempty"`
 }
 
 // OpenFiscalDayResponse represents opening fiscal day response (simplified)
@@ -103,6 +107,7 @@ type OpenFiscalDayResponse struct {
 // CloseFiscalDayRequest represents closing fiscal day request (simplified)
 type CloseFiscalDayRequest struct {
 	DeviceID                 int                 `json:"deviceID" binding:"required"`
+	FiscalDayNo              *int                `json:"fiscalDayNo,omitempty"`
 	FiscalDayDeviceSignature *SignatureData      `json:"fiscalDayDeviceSignature,omitempty"`
 	FiscalDayCounters        []FiscalDayCounter  `json:"fiscalDayCounters,omitempty"`
 }
@@ -115,5 +120,21 @@ type CloseFiscalDayResponse struct {
 	FiscalDayDocumentQuantities []FiscalDayDocumentQuantity `json:"fiscalDayDocumentQuantities"`
 }
 
+// GetFiscalDayResponse represents a historical fiscal day of a device
+type GetFiscalDayResponse struct {
+	OperationID                 string                      `json:"operationID"`
+	FiscalDayNo                 int                         `json:"fiscalDayNo"`
+	FiscalDayStatus             string                      `json:"fiscalDayStatus"`
+	FiscalDayOpened             time.Time                   `json:"fiscalDayOpened"`
+	FiscalDayClosed             *time.Time                  `json:"fiscalDayClosed,omitempty"`
+	FiscalDayReconciliationMode *string                     `json:"fiscalDayReconciliationMode,omitempty"`
+	FiscalDayDeviceSignature    *SignatureData              `json:"fiscalDayDeviceSignature,omitempty"`
+	FiscalDayServerSignature    *SignatureDataEx            `json:"fiscalDayServerSignature,omitempty"`
+	FiscalDayClosingErrorCode   *string                     `json:"fiscalDayClosingErrorCode,omitempty"`
+	LastReceiptGlobalNo         *int                        `json:"lastReceiptGlobalNo,omitempty"`
+	FiscalDayCounters           []FiscalDayCounter          `json:"fiscalDayCounters"`
+	FiscalDayDocumentQuantities []FiscalDayDocumentQuantity `json:"fiscalDayDocumentQuantities"`
+}
+
 // GetFiscalDayStatusResponse is an alias for GetStatusResponse
 type GetFiscalDayStatusResponse = GetStatusResponse
@@ -0,0 +1,82 @@
+//go:build ignore
+
+// gen_error_catalog generates error_catalog.go from the ErrCode* constants in
+// errors.go: the trailing comment of each constant is its description and
+// the comment above its group is its category.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "errors.go", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_error_catalog.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package models\n\n")
+	buf.WriteString("// ErrorCatalog lists every error code the API returns, in the order they\n")
+	buf.WriteString("// are declared\n")
+	buf.WriteString("var ErrorCatalog = []ErrorCodeInfo{\n")
+
+	count := 0
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		category := ""
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			if value.Doc != nil {
+				category = strings.TrimSpace(value.Doc.Text())
+			}
+			if len(value.Names) != 1 || !strings.HasPrefix(value.Names[0].Name, "ErrCode") {
+				continue
+			}
+			if value.Comment == nil {
+				log.Fatalf("%s has no description comment", value.Names[0].Name)
+			}
+
+			lit, ok := value.Values[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				log.Fatalf("%s is not a string literal", value.Names[0].Name)
+			}
+			code, _ := strconv.Unquote(lit.Value)
+			if value.Names[0].Name != "ErrCode"+code {
+				log.Fatalf("%s does not match its code %q", value.Names[0].Name, code)
+			}
+
+			fmt.Fprintf(&buf, "\t{Code: %s, Category: %q, Description: %q},\n",
+				value.Names[0].Name, category, strings.TrimSpace(value.Comment.Text()))
+			count++
+		}
+	}
+	buf.WriteString("}\n")
+
+	if count == 0 {
+		log.Fatal("no ErrCode constants found")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("error_catalog.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
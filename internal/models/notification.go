@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// NotificationChannel is how a notification is delivered
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "email"
+	NotificationChannelSMS   NotificationChannel = "sms"
+)
+
+// NotificationStatus is the delivery state of a notification in the outbox
+type NotificationStatus string
+
+const (
+	// NotificationStatusPending notifications wait for NextAttemptAt
+	NotificationStatusPending NotificationStatus = "pending"
+	// NotificationStatusSending notifications are claimed by a worker until
+	// NextAttemptAt, after which a claim left by a crashed worker is retried
+	NotificationStatusSending NotificationStatus = "sending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	// NotificationStatusDead notifications ran out of attempts and are only
+	// sent again when an administrator resends them
+	NotificationStatusDead NotificationStatus = "dead"
+)
+
+// NotificationKind selects the message a notification is delivered as
+type NotificationKind string
+
+const (
+	NotificationKindContactChanged      NotificationKind = "contact_changed"
+	NotificationKindFiscalDayEnding     NotificationKind = "fiscal_day_ending"
+	NotificationKindFiscalDayAutoClosed NotificationKind = "fiscal_day_auto_closed"
+)
+
+// Notification is a message in the notification outbox. Payload holds the
+// JSON parameters of its kind; LastError is the error of the last failed
+// attempt.
+type Notification struct {
+	ID            int64               `json:"id" db:"id"`
+	Channel       NotificationChannel `json:"channel" db:"channel"`
+	Recipient     string              `json:"recipient" db:"recipient"`
+	Kind          NotificationKind    `json:"kind" db:"kind"`
+	Payload       string              `json:"payload" db:"payload"`
+	Status        NotificationStatus  `json:"status" db:"status"`
+	Attempts      int                 `json:"attempts" db:"attempts"`
+	LastError     string              `json:"lastError,omitempty" db:"last_error"`
+	NextAttemptAt time.Time           `json:"nextAttemptAt" db:"next_attempt_at"`
+	SentAt        *time.Time          `json:"sentAt,omitempty" db:"sent_at"`
+	CreatedAt     time.Time           `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time           `json:"updatedAt" db:"updated_at"`
+}
+
+// ListNotificationsResponse is a page of the notification outbox
+type ListNotificationsResponse struct {
+	Total int            `json:"total"`
+	Rows  []Notification `json:"rows"`
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // Receipt represents a fiscal receipt (invoice, credit note, or debit note)
@@ -34,7 +36,7 @@ type Receipt struct {
 	Username                *string          `json:"username,omitempty" db:"username"`
 	UserNameSurname         *string          `json:"userNameSurname,omitempty" db:"user_name_surname"`
 	ValidationColor         *ValidationColor `json:"-" db:"validation_color"`
-	ValidationErrors        []string         `json:"-" db:"validation_errors"`
+	ValidationErrors        pq.StringArray   `json:"-" db:"validation_errors"`
 	ServerDate              *time.Time       `json:"serverDate,omitempty" db:"server_date"`
 	CreatedAt               time.Time        `json:"-" db:"created_at"`
 	UpdatedAt               time.Time        `json:"-" db:"updated_at"`
@@ -139,6 +141,8 @@ type SubmitReceiptResponse struct {
 	ReceiptID              int64            `json:"receiptID"`
 	ServerDate             time.Time        `json:"serverDate"`
 	ReceiptServerSignature SignatureDataEx  `json:"receiptServerSignature"`
+	ValidationColor        *ValidationColor `json:"validationColor,omitempty"`
+	ValidationErrors       []string         `json:"validationErrors,omitempty"`
 }
 
 // SubmitFileRequest represents file submission request
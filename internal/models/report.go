@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportType distinguishes intra-day X-reports from end-of-day Z-reports
+type ReportType string
+
+const (
+	ReportTypeX ReportType = "X"
+	ReportTypeZ ReportType = "Z"
+)
+
+// FiscalDayReport holds the totals of a fiscal day for X- and Z-reports
+type FiscalDayReport struct {
+	OperationID              string                      `json:"operationID"`
+	ReportType               ReportType                  `json:"reportType"`
+	GeneratedAt              time.Time                   `json:"generatedAt"`
+	TaxPayerName             string                      `json:"taxPayerName"`
+	TaxPayerTIN              string                      `json:"taxPayerTIN"`
+	VATNumber                string                      `json:"vatNumber,omitempty"`
+	DeviceID                 int                         `json:"deviceID"`
+	DeviceSerialNo           string                      `json:"deviceSerialNo"`
+	DeviceBranchName         string                      `json:"deviceBranchName"`
+	FiscalDayNo              int                         `json:"fiscalDayNo"`
+	FiscalDayStatus          string                      `json:"fiscalDayStatus"`
+	FiscalDayOpened          time.Time                   `json:"fiscalDayOpened"`
+	FiscalDayClosed          *time.Time                  `json:"fiscalDayClosed,omitempty"`
+	ReconciliationMode       *string                     `json:"fiscalDayReconciliationMode,omitempty"`
+	TaxTotals                []ReportTaxTotal            `json:"taxTotals"`
+	CurrencyTotals           []ReportCurrencyTotal       `json:"currencyTotals"`
+	MoneyTypeTotals          []ReportMoneyTypeTotal      `json:"moneyTypeTotals"`
+	DocumentQuantities       []FiscalDayDocumentQuantity `json:"documentQuantities"`
+	FiscalDayServerSignature *SignatureDataEx            `json:"fiscalDayServerSignature,omitempty"`
+	Text                     string                      `json:"text"` // 48-column printable version
+}
+
+// ReportTaxTotal holds the totals of one tax in one currency
+type ReportTaxTotal struct {
+	Currency         string   `json:"currency"`
+	TaxID            *int     `json:"taxID,omitempty"`
+	TaxPercent       *float64 `json:"taxPercent,omitempty"`
+	SalesAmount      float64  `json:"salesAmount"`
+	SalesTax         float64  `json:"salesTax"`
+	CreditNoteAmount float64  `json:"creditNoteAmount"`
+	CreditNoteTax    float64  `json:"creditNoteTax"`
+	DebitNoteAmount  float64  `json:"debitNoteAmount"`
+	DebitNoteTax     float64  `json:"debitNoteTax"`
+}
+
+// ReportCurrencyTotal holds the totals of all taxes in one currency
+type ReportCurrencyTotal struct {
+	Currency         string  `json:"currency"`
+	SalesAmount      float64 `json:"salesAmount"`
+	CreditNoteAmount float64 `json:"creditNoteAmount"`
+	DebitNoteAmount  float64 `json:"debitNoteAmount"`
+	TaxAmount        float64 `json:"taxAmount"`
+	NetAmount        float64 `json:"netAmount"`
+}
+
+// ReportMoneyTypeTotal holds payments received with one money type in one currency
+type ReportMoneyTypeTotal struct {
+	Currency  string  `json:"currency"`
+	MoneyType string  `json:"moneyType"`
+	Amount    float64 `json:"amount"`
+}
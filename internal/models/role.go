@@ -0,0 +1,71 @@
+package models
+
+// UserRole is the role of a taxpayer user, which decides what they may do on
+// the taxpayer's devices
+type UserRole string
+
+const (
+	UserRoleOwner      UserRole = "Owner"
+	UserRoleManager    UserRole = "Manager"
+	UserRoleCashier    UserRole = "Cashier"
+	UserRoleAccountant UserRole = "Accountant"
+)
+
+// UserRoles lists every role, most privileged first
+var UserRoles = []UserRole{UserRoleOwner, UserRoleManager, UserRoleCashier, UserRoleAccountant}
+
+// Permission is a device operation restricted to some roles
+type Permission string
+
+const (
+	PermissionManageUsers    Permission = "users.manage"
+	PermissionOpenFiscalDay  Permission = "fiscal_day.open"
+	PermissionCloseFiscalDay Permission = "fiscal_day.close"
+	PermissionSubmitReceipt  Permission = "receipt.submit"
+	PermissionViewStock      Permission = "stock.view"
+	PermissionViewReports    Permission = "reports.view"
+)
+
+// rolePermissions is the permission matrix
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleOwner: {
+		PermissionManageUsers, PermissionOpenFiscalDay, PermissionCloseFiscalDay,
+		PermissionSubmitReceipt, PermissionViewStock, PermissionViewReports,
+	},
+	UserRoleManager: {
+		PermissionManageUsers, PermissionOpenFiscalDay, PermissionCloseFiscalDay,
+		PermissionSubmitReceipt, PermissionViewStock, PermissionViewReports,
+	},
+	UserRoleCashier: {
+		PermissionOpenFiscalDay, PermissionSubmitReceipt, PermissionViewStock,
+	},
+	UserRoleAccountant: {
+		PermissionViewStock, PermissionViewReports,
+	},
+}
+
+// Valid reports whether r is one of UserRoles
+func (r UserRole) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether users with role r have permission p
+func (r UserRole) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManage reports whether a user with role r may create or change users
+// with role target. Owners manage everyone; managers manage cashiers and
+// accountants.
+func (r UserRole) CanManage(target UserRole) bool {
+	if !r.Can(PermissionManageUsers) {
+		return false
+	}
+	return r == UserRoleOwner || target == UserRoleCashier || target == UserRoleAccountant
+}
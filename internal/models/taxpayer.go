@@ -122,32 +122,7 @@ func (s *SignatureDataEx) Scan(value interface{}) error {
 
 	return json.Unmarshal(bytes, s)
 }
-/*
-// APIError represents an API error response
-type APIError struct {
-	Type      string `json:"type"`
-	Title     string `json:"title"`
-	Status    int    `json:"status"`
-	ErrorCode string `json:"errorCode,omitempty"`
-}
-
-// Error implements the error interface
-func (e APIError) Error() string {
-	return e.Title
-}
 
-// Common error codes
-
-// NewAPIError creates a new API error
-func NewAPIError(status int, title string, errorCode string) *APIError {
-	return &APIError{
-		Type:      "about:blank",
-		Title:     title,
-		Status:    status,
-		ErrorCode: errorCode,
-	}
-}
-*/
 // OperationResponse represents a standard operation response
 type OperationResponse struct {
 	OperationID string `json:"operationID"`
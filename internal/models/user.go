@@ -12,16 +12,84 @@ type User struct {
 	PasswordHash  string     `json:"-" db:"password_hash"`
 	PersonName    string     `json:"personName" db:"person_name"`
 	PersonSurname string     `json:"personSurname" db:"person_surname"`
-	UserRole      string     `json:"userRole" db:"user_role"`
+	UserRole      UserRole   `json:"userRole" db:"user_role"`
 	Email         string     `json:"email" db:"email"`
 	PhoneNo       string     `json:"phoneNo" db:"phone_no"`
 	Status        UserStatus `json:"userStatus" db:"status"`
+	Language      Language   `json:"language" db:"language"`
 	SecurityCode  *string    `json:"-" db:"security_code"`
 	SecurityCodeExpiry *time.Time `json:"-" db:"security_code_expiry"`
 	CreatedAt     time.Time  `json:"-" db:"created_at"`
 	UpdatedAt     time.Time  `json:"-" db:"updated_at"`
 }
 
+// SecurityCodePurpose is the operation a security code confirms. A code
+// sent for one operation cannot confirm another.
+type SecurityCodePurpose string
+
+const (
+	SecurityCodePurposeCreateUser    SecurityCodePurpose = "create_user"
+	SecurityCodePurposeResetPassword SecurityCodePurpose = "reset_password"
+	SecurityCodePurposeChangeEmail   SecurityCodePurpose = "change_email"
+	SecurityCodePurposeChangePhone   SecurityCodePurpose = "change_phone"
+)
+
+// SecurityCode is a one-time code sent to a user. Only a hash of the code is
+// stored; Attempts counts the confirmations tried with it. Target is the new
+// email address or phone number a contact change code was sent to.
+type SecurityCode struct {
+	UserID    int64               `db:"user_id"`
+	Purpose   SecurityCodePurpose `db:"purpose"`
+	CodeHash  string              `db:"code_hash"`
+	Target    string              `db:"target"`
+	Attempts  int                 `db:"attempts"`
+	ExpiresAt time.Time           `db:"expires_at"`
+	CreatedAt time.Time           `db:"created_at"`
+}
+
+// SessionRevokeReason records why a user session was ended
+type SessionRevokeReason string
+
+const (
+	SessionRevokeReasonLogout         SessionRevokeReason = "logout"
+	SessionRevokeReasonPasswordChange SessionRevokeReason = "password_change"
+	SessionRevokeReasonPasswordReset  SessionRevokeReason = "password_reset"
+	SessionRevokeReasonUserBlocked    SessionRevokeReason = "user_blocked"
+	SessionRevokeReasonTokenReuse     SessionRevokeReason = "refresh_token_reuse"
+)
+
+// UserSession is a login of a user on a device. Its refresh token is rotated
+// on every use; only hashes of the current and the previous one are stored.
+// Current marks the session of the token a listing was requested with.
+type UserSession struct {
+	ID                int64               `json:"sessionID" db:"id"`
+	UserID            int64               `json:"-" db:"user_id"`
+	DeviceID          int                 `json:"deviceID" db:"device_id"`
+	RefreshTokenHash  string              `json:"-" db:"refresh_token_hash"`
+	PreviousTokenHash string              `json:"-" db:"previous_token_hash"`
+	IPAddress         string              `json:"ipAddress" db:"ip_address"`
+	CreatedAt         time.Time           `json:"createdAt" db:"created_at"`
+	LastUsedAt        time.Time           `json:"lastUsedAt" db:"last_used_at"`
+	ExpiresAt         time.Time           `json:"expiresAt" db:"expires_at"`
+	RevokedAt         *time.Time          `json:"-" db:"revoked_at"`
+	RevokeReason      SessionRevokeReason `json:"-" db:"revoke_reason"`
+	Current           bool                `json:"current" db:"-"`
+}
+
+// Active reports whether the session is neither revoked nor expired at now
+func (s *UserSession) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+// SessionTokens are the tokens of a user session: a short-lived access token
+// sent with requests, and the refresh token that replaces it
+type SessionTokens struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
 // GetUsersListRequest represents users list request
 type GetUsersListRequest struct {
 	DeviceID int `json:"deviceID" binding:"required"`
@@ -43,10 +111,43 @@ type LoginRequest struct {
 
 // LoginResponse represents login response
 type LoginResponse struct {
-	User        User      `json:"user"`
-	Token       string    `json:"token"`
-	ExpiresAt   time.Time `json:"expiresAt"`
-	OperationID string    `json:"operationID"`
+	User User `json:"user"`
+	SessionTokens
+	OperationID string `json:"operationID"`
+}
+
+// RefreshTokenRequest represents access token refresh request
+type RefreshTokenRequest struct {
+	DeviceID     int    `json:"deviceID" binding:"required"`
+	RefreshToken string `json:"refreshToken" binding:"required,max=200"`
+}
+
+// RefreshTokenResponse represents access token refresh response; the refresh
+// token sent is no longer valid and is replaced by the one returned
+type RefreshTokenResponse struct {
+	SessionTokens
+	OperationID string `json:"operationID"`
+}
+
+// LogoutRequest represents logout request. All ends every session of the
+// user instead of the current one.
+type LogoutRequest struct {
+	DeviceID int    `json:"deviceID" binding:"required"`
+	Token    string `json:"token,omitempty" binding:"max=1000"`
+	All      bool   `json:"all"`
+}
+
+// LogoutResponse represents logout response
+type LogoutResponse struct {
+	Revoked     int    `json:"revoked"`
+	OperationID string `json:"operationID"`
+}
+
+// ListSessionsResponse represents user sessions list response
+type ListSessionsResponse struct {
+	Username    string        `json:"userName"`
+	Sessions    []UserSession `json:"sessions"`
+	OperationID string        `json:"operationID"`
 }
 
 // CreateUserBeginRequest represents user creation start request
@@ -55,7 +156,11 @@ type CreateUserBeginRequest struct {
 	Username      string `json:"userName" binding:"required,max=100"`
 	PersonName    string `json:"personName" binding:"required,max=100"`
 	PersonSurname string `json:"personSurname" binding:"required,max=100"`
-	UserRole      string `json:"userRole" binding:"required,max=100"`
+	UserRole      UserRole `json:"userRole" binding:"required,oneof=Owner Manager Cashier Accountant"`
+	UserEmail     string `json:"userEmail,omitempty" binding:"max=100"`
+	PhoneNo       string `json:"phoneNo,omitempty" binding:"max=20"`
+	Channel       SendSecurityCodeTo `json:"channel" binding:"oneof=0 1"`
+	Language      Language `json:"language,omitempty" binding:"omitempty,oneof=en sn nd"`
 }
 
 // CreateUserBeginResponse represents user creation start response
@@ -74,8 +179,9 @@ type CreateUserConfirmRequest struct {
 // CreateUserConfirmResponse represents user creation confirmation response
 type CreateUserConfirmResponse struct {
 	User        User   `json:"user"`
-	JWTToken    string `json:"jwtToken"`
-	OperationID string `json:"operationID"`
+	JWTToken     string `json:"jwtToken"`
+	RefreshToken string `json:"refreshToken"`
+	OperationID  string `json:"operationID"`
 }
 
 // SendSecurityCodeRequest represents security code sending request
@@ -105,7 +211,7 @@ type SendSecurityCodeContactChangeResponse struct {
 // ConfirmUserContactChangeRequest represents contact change confirmation request
 type ConfirmUserContactChangeRequest struct {
 	DeviceID     int                `json:"deviceID" binding:"required"`
-	Channel      SendSecurityCodeTo `json:"channel" binding:"required"`
+	Channel      SendSecurityCodeTo `json:"channel" binding:"oneof=0 1"`
 	SecurityCode string             `json:"securityCode" binding:"required,max=10"`
 	Token        string             `json:"token" binding:"required,max=1000"`
 }
@@ -122,9 +228,10 @@ type UpdateUserRequest struct {
 	Username      string     `json:"userName" binding:"required,max=100"`
 	PersonName    string     `json:"personName" binding:"required,max=100"`
 	PersonSurname string     `json:"personSurname" binding:"required,max=100"`
-	UserRole      string     `json:"userRole" binding:"required,max=100"`
-	UserStatus    UserStatus `json:"userStatus" binding:"required"`
-	Token         string     `json:"token" binding:"required,max=1000"`
+	UserRole      UserRole   `json:"userRole" binding:"required,oneof=Owner Manager Cashier Accountant"`
+	UserStatus    UserStatus `json:"userStatus" binding:"oneof=0 1"`
+	Language      Language   `json:"language,omitempty" binding:"omitempty,oneof=en sn nd"`
+	Token         string     `json:"token,omitempty" binding:"max=1000"`
 }
 
 // UpdateUserResponse represents user update response
@@ -151,7 +258,7 @@ type ChangeUserPasswordResponse struct {
 type ResetUserPasswordBeginRequest struct {
 	DeviceID int                `json:"deviceID" binding:"required"`
 	Username string             `json:"userName" binding:"required,max=100"`
-	Channel  SendSecurityCodeTo `json:"channel" binding:"required"`
+	Channel  SendSecurityCodeTo `json:"channel" binding:"oneof=0 1"`
 }
 
 // ResetUserPasswordBeginResponse represents password reset start response
@@ -169,14 +276,15 @@ type ResetUserPasswordConfirmRequest struct {
 
 // ResetUserPasswordConfirmResponse represents password reset confirmation response
 type ResetUserPasswordConfirmResponse struct {
-	OperationID string `json:"operationID"`
-	User        User   `json:"user"`
-	Token       string `json:"token"`
+	OperationID  string `json:"operationID"`
+	User         User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
 }
 
-// ChangePasswordRequest represents password change request (simplified)
+// ChangePasswordRequest changes the password of the operator signed in on
+// the device
 type ChangePasswordRequest struct {
-	UserID      int64  `json:"userID" binding:"required"`
 	OldPassword string `json:"oldPassword" binding:"required"`
 	NewPassword string `json:"newPassword" binding:"required"`
 }
@@ -201,6 +309,6 @@ type UserInfo struct {
 	Surname  string  `json:"surname"`
 	Email    *string `json:"email,omitempty"`
 	Phone    *string `json:"phone,omitempty"`
-	Role     string  `json:"role"`
+	Role     UserRole `json:"role"`
 	Status   string  `json:"status"`
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -13,41 +14,41 @@ import (
 // AdminRepository handles all system-owner operations across all tenants
 type AdminRepository interface {
 	// Taxpayer (company) management
-	CreateTaxpayer(tp *models.Taxpayer) error
-	GetTaxpayerByID(id int64) (*models.Taxpayer, error)
-	GetTaxpayerByTIN(tin string) (*models.Taxpayer, error)
-	ListTaxpayers(offset, limit int, search string) (int, []models.Taxpayer, error)
-	UpdateTaxpayer(tp *models.Taxpayer) error
-	SetTaxpayerStatus(id int64, status string) error
+	CreateTaxpayer(ctx context.Context, tp *models.Taxpayer) error
+	GetTaxpayerByID(ctx context.Context, id int64) (*models.Taxpayer, error)
+	GetTaxpayerByTIN(ctx context.Context, tin string) (*models.Taxpayer, error)
+	ListTaxpayers(ctx context.Context, offset, limit int, search string) (int, []models.Taxpayer, error)
+	UpdateTaxpayer(ctx context.Context, tp *models.Taxpayer) error
+	SetTaxpayerStatus(ctx context.Context, id int64, status string) error
 
 	// Device management across tenants
-	CreateDevice(device *models.Device) error
-	ListDevicesByTaxpayer(taxpayerID int64) ([]models.Device, error)
-	ListAllDevices(offset, limit int) (int, []models.Device, error)
-	GetDeviceByID(deviceID int) (*models.Device, error)
-	UpdateDeviceStatus(deviceID int, status string) error
-	UpdateDeviceMode(deviceID int, mode int) error
+	CreateDevice(ctx context.Context, device *models.Device) error
+	ListDevicesByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.Device, error)
+	ListAllDevices(ctx context.Context, offset, limit int) (int, []models.Device, error)
+	GetDeviceByID(ctx context.Context, deviceID int) (*models.Device, error)
+	UpdateDeviceStatus(ctx context.Context, deviceID int, status string) error
+	UpdateDeviceMode(ctx context.Context, deviceID int, mode int) error
 
 	// Cross-tenant fiscal day overview
-	ListFiscalDays(taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error)
+	ListFiscalDays(ctx context.Context, taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error)
 
 	// Cross-tenant receipt overview
-	ListReceipts(taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error)
+	ListReceipts(ctx context.Context, taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error)
 
 	// Audit logs
-	ListAuditLogs(entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error)
-	InsertAuditLog(entityType, action string, entityID *int64, deviceID *int, ipAddress, details string) error
+	ListAuditLogs(ctx context.Context, entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error)
+	InsertAuditLog(ctx context.Context, entityType, action string, entityID, userID *int64, deviceID *int, ipAddress, details string) error
 
 	// System stats
-	GetSystemStats() (*models.SystemStats, error)
+	GetSystemStats(ctx context.Context) (*models.SystemStats, error)
 
 	//Admin
-	CreateUser(user *models.User) error
-	ListUsersByTaxpayer(taxpayerID int64) ([]models.AdminUserRow, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	ListUsersByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.AdminUserRow, error)
 }
 
 type adminRepository struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewAdminRepository(db *sqlx.DB) AdminRepository {
@@ -56,36 +57,36 @@ func NewAdminRepository(db *sqlx.DB) AdminRepository {
 
 // ─── Taxpayer ─────────────────────────────────────────────────────────────────
 
-func (r *adminRepository) CreateTaxpayer(tp *models.Taxpayer) error {
+func (r *adminRepository) CreateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
 	query := `
 		INSERT INTO taxpayers (tin, name, vat_number, status, taxpayer_day_max_hrs, taxpayer_day_end_notification_hrs, qr_url)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at`
-	return r.db.QueryRow(query,
+	return r.db.QueryRowContext(ctx, query,
 		tp.TIN, tp.Name, tp.VATNumber, tp.Status,
 		tp.TaxPayerDayMaxHrs, tp.TaxpayerDayEndNotificationHrs, tp.QrURL,
 	).Scan(&tp.ID, &tp.CreatedAt, &tp.UpdatedAt)
 }
 
-func (r *adminRepository) GetTaxpayerByID(id int64) (*models.Taxpayer, error) {
+func (r *adminRepository) GetTaxpayerByID(ctx context.Context, id int64) (*models.Taxpayer, error) {
 	var tp models.Taxpayer
-	err := r.db.Get(&tp, `SELECT * FROM taxpayers WHERE id = $1`, id)
+	err := r.db.GetContext(ctx, &tp, `SELECT * FROM taxpayers WHERE id = $1`, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &tp, err
 }
 
-func (r *adminRepository) GetTaxpayerByTIN(tin string) (*models.Taxpayer, error) {
+func (r *adminRepository) GetTaxpayerByTIN(ctx context.Context, tin string) (*models.Taxpayer, error) {
 	var tp models.Taxpayer
-	err := r.db.Get(&tp, `SELECT * FROM taxpayers WHERE tin = $1`, tin)
+	err := r.db.GetContext(ctx, &tp, `SELECT * FROM taxpayers WHERE tin = $1`, tin)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &tp, err
 }
 
-func (r *adminRepository) ListTaxpayers(offset, limit int, search string) (int, []models.Taxpayer, error) {
+func (r *adminRepository) ListTaxpayers(ctx context.Context, offset, limit int, search string) (int, []models.Taxpayer, error) {
 	where := "WHERE 1=1"
 	args := []interface{}{}
 	argc := 0
@@ -97,7 +98,7 @@ func (r *adminRepository) ListTaxpayers(offset, limit int, search string) (int,
 	}
 
 	var total int
-	if err := r.db.Get(&total, "SELECT COUNT(*) FROM taxpayers "+where, args...); err != nil {
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM taxpayers "+where, args...); err != nil {
 		return 0, nil, err
 	}
 
@@ -105,14 +106,14 @@ func (r *adminRepository) ListTaxpayers(offset, limit int, search string) (int,
 	argc2 := argc + 1
 	args = append(args, limit, offset)
 	var rows []models.Taxpayer
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		fmt.Sprintf("SELECT * FROM taxpayers %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d", where, argc, argc2),
 		args...)
 	return total, rows, err
 }
 
-func (r *adminRepository) UpdateTaxpayer(tp *models.Taxpayer) error {
-	_, err := r.db.Exec(`
+func (r *adminRepository) UpdateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE taxpayers SET
 			name = $1, vat_number = $2, status = $3,
 			taxpayer_day_max_hrs = $4, taxpayer_day_end_notification_hrs = $5, qr_url = $6
@@ -122,14 +123,14 @@ func (r *adminRepository) UpdateTaxpayer(tp *models.Taxpayer) error {
 	return err
 }
 
-func (r *adminRepository) SetTaxpayerStatus(id int64, status string) error {
-	_, err := r.db.Exec(`UPDATE taxpayers SET status = $1 WHERE id = $2`, status, id)
+func (r *adminRepository) SetTaxpayerStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE taxpayers SET status = $1 WHERE id = $2`, status, id)
 	return err
 }
 
 // ─── Device ───────────────────────────────────────────────────────────────────
 
-func (r *adminRepository) CreateDevice(device *models.Device) error {
+func (r *adminRepository) CreateDevice(ctx context.Context, device *models.Device) error {
 	query := `
 		INSERT INTO devices (
 			device_id, taxpayer_id, device_serial_no, device_model_name,
@@ -137,7 +138,7 @@ func (r *adminRepository) CreateDevice(device *models.Device) error {
 			branch_name, branch_address, branch_contacts
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, created_at, updated_at`
-	return r.db.QueryRow(query,
+	return r.db.QueryRowContext(ctx, query,
 		device.DeviceID, device.TaxpayerID, device.DeviceSerialNo,
 		device.DeviceModelName, device.DeviceModelVersion, device.ActivationKey,
 		device.OperatingMode, device.Status, device.BranchName,
@@ -145,46 +146,46 @@ func (r *adminRepository) CreateDevice(device *models.Device) error {
 	).Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
 }
 
-func (r *adminRepository) ListDevicesByTaxpayer(taxpayerID int64) ([]models.Device, error) {
+func (r *adminRepository) ListDevicesByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.Device, error) {
 	var rows []models.Device
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		`SELECT * FROM devices WHERE taxpayer_id = $1 ORDER BY device_id`, taxpayerID)
 	return rows, err
 }
 
-func (r *adminRepository) ListAllDevices(offset, limit int) (int, []models.Device, error) {
+func (r *adminRepository) ListAllDevices(ctx context.Context, offset, limit int) (int, []models.Device, error) {
 	var total int
-	if err := r.db.Get(&total, `SELECT COUNT(*) FROM devices`); err != nil {
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM devices`); err != nil {
 		return 0, nil, err
 	}
 	var rows []models.Device
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		`SELECT * FROM devices ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
 	return total, rows, err
 }
 
-func (r *adminRepository) GetDeviceByID(deviceID int) (*models.Device, error) {
+func (r *adminRepository) GetDeviceByID(ctx context.Context, deviceID int) (*models.Device, error) {
 	var d models.Device
-	err := r.db.Get(&d, `SELECT * FROM devices WHERE device_id = $1`, deviceID)
+	err := r.db.GetContext(ctx, &d, `SELECT * FROM devices WHERE device_id = $1`, deviceID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &d, err
 }
 
-func (r *adminRepository) UpdateDeviceStatus(deviceID int, status string) error {
-	_, err := r.db.Exec(`UPDATE devices SET status = $1 WHERE device_id = $2`, status, deviceID)
+func (r *adminRepository) UpdateDeviceStatus(ctx context.Context, deviceID int, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET status = $1 WHERE device_id = $2`, status, deviceID)
 	return err
 }
 
-func (r *adminRepository) UpdateDeviceMode(deviceID int, mode int) error {
-	_, err := r.db.Exec(`UPDATE devices SET operating_mode = $1 WHERE device_id = $2`, mode, deviceID)
+func (r *adminRepository) UpdateDeviceMode(ctx context.Context, deviceID int, mode int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET operating_mode = $1 WHERE device_id = $2`, mode, deviceID)
 	return err
 }
 
 // ─── Fiscal Days ──────────────────────────────────────────────────────────────
 
-func (r *adminRepository) ListFiscalDays(taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error) {
+func (r *adminRepository) ListFiscalDays(ctx context.Context, taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error) {
 	where := "WHERE 1=1"
 	args := []interface{}{}
 	argc := 0
@@ -203,7 +204,7 @@ func (r *adminRepository) ListFiscalDays(taxpayerID *int64, deviceID *int, offse
 	joinQ := `FROM fiscal_days f JOIN devices d ON f.device_id = d.device_id ` + where
 
 	var total int
-	if err := r.db.Get(&total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
 		return 0, nil, err
 	}
 
@@ -211,7 +212,7 @@ func (r *adminRepository) ListFiscalDays(taxpayerID *int64, deviceID *int, offse
 	argc2 := argc + 1
 	args = append(args, limit, offset)
 	var rows []models.FiscalDay
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		fmt.Sprintf("SELECT f.* "+joinQ+" ORDER BY f.fiscal_day_opened DESC LIMIT $%d OFFSET $%d", argc, argc2),
 		args...)
 	return total, rows, err
@@ -219,7 +220,7 @@ func (r *adminRepository) ListFiscalDays(taxpayerID *int64, deviceID *int, offse
 
 // ─── Receipts ─────────────────────────────────────────────────────────────────
 
-func (r *adminRepository) ListReceipts(taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error) {
+func (r *adminRepository) ListReceipts(ctx context.Context, taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error) {
 	where := "WHERE 1=1"
 	args := []interface{}{}
 	argc := 0
@@ -248,7 +249,7 @@ func (r *adminRepository) ListReceipts(taxpayerID *int64, deviceID *int, from, t
 	joinQ := `FROM receipts r JOIN devices d ON r.device_id = d.device_id ` + where
 
 	var total int
-	if err := r.db.Get(&total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
 		return 0, nil, err
 	}
 
@@ -259,17 +260,18 @@ func (r *adminRepository) ListReceipts(taxpayerID *int64, deviceID *int, from, t
 	// Use explicit column list so validation_color is included (models.Receipt has json:"-" on it)
 	selectQ := fmt.Sprintf(`
 		SELECT r.id, r.receipt_id, r.device_id, r.receipt_type, r.receipt_currency,
-		       r.invoice_no, r.receipt_date, r.receipt_total, r.validation_color, r.server_date
+		       r.invoice_no, r.receipt_date, r.receipt_total, r.validation_color, r.server_date,
+		       r.username, r.user_name_surname
 		`+joinQ+` ORDER BY r.receipt_date DESC LIMIT $%d OFFSET $%d`, argc, argc2)
 
 	var rows []models.AdminReceiptRow
-	err := r.db.Select(&rows, selectQ, args...)
+	err := r.db.SelectContext(ctx, &rows, selectQ, args...)
 	return total, rows, err
 }
 
 // ─── Audit Logs ───────────────────────────────────────────────────────────────
 
-func (r *adminRepository) ListAuditLogs(entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error) {
+func (r *adminRepository) ListAuditLogs(ctx context.Context, entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error) {
 	where := "WHERE 1=1"
 	args := []interface{}{}
 	argc := 0
@@ -286,7 +288,7 @@ func (r *adminRepository) ListAuditLogs(entityType string, entityID *int64, offs
 	}
 
 	var total int
-	if err := r.db.Get(&total, "SELECT COUNT(*) FROM audit_logs "+where, args...); err != nil {
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM audit_logs "+where, args...); err != nil {
 		return 0, nil, err
 	}
 
@@ -294,22 +296,22 @@ func (r *adminRepository) ListAuditLogs(entityType string, entityID *int64, offs
 	argc2 := argc + 1
 	args = append(args, limit, offset)
 	var rows []models.AuditLog
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		fmt.Sprintf("SELECT * FROM audit_logs %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d",
 			where, argc, argc2), args...)
 	return total, rows, err
 }
 
-func (r *adminRepository) InsertAuditLog(entityType, action string, entityID *int64, deviceID *int, ipAddress, details string) error {
-	_, err := r.db.Exec(`
-		INSERT INTO audit_logs (entity_type, action, entity_id, device_id, ip_address, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		entityType, action, entityID, deviceID, ipAddress, details)
+func (r *adminRepository) InsertAuditLog(ctx context.Context, entityType, action string, entityID, userID *int64, deviceID *int, ipAddress, details string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO audit_logs (entity_type, action, entity_id, user_id, device_id, ip_address, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entityType, action, entityID, userID, deviceID, ipAddress, details)
 	return err
 }
 // ─── System Stats ─────────────────────────────────────────────────────────────
 
-func (r *adminRepository) GetSystemStats() (*models.SystemStats, error) {
+func (r *adminRepository) GetSystemStats(ctx context.Context) (*models.SystemStats, error) {
 	stats := &models.SystemStats{}
 
 	queries := []struct {
@@ -326,13 +328,13 @@ func (r *adminRepository) GetSystemStats() (*models.SystemStats, error) {
 	}
 
 	for _, q := range queries {
-		if err := r.db.Get(q.dest, q.query); err != nil {
+		if err := r.db.GetContext(ctx, q.dest, q.query); err != nil {
 			*q.dest = 0
 		}
 	}
 
 	// Today's revenue
-	r.db.Get(&stats.TodayRevenue,
+	r.db.GetContext(ctx, &stats.TodayRevenue,
 		`SELECT COALESCE(SUM(receipt_total), 0) FROM receipts WHERE receipt_date >= CURRENT_DATE AND receipt_type = 0`)
 
 	return stats, nil
@@ -341,20 +343,20 @@ func (r *adminRepository) GetSystemStats() (*models.SystemStats, error) {
 
 //admin
 
-func (r *adminRepository) CreateUser(user *models.User) error {
-	return r.db.QueryRow(`
-		INSERT INTO users (taxpayer_id, username, password_hash, person_name, person_surname, email, phone_no, user_role, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+func (r *adminRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO users (taxpayer_id, username, password_hash, person_name, person_surname, email, phone_no, user_role, status, language)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at`,
 		user.TaxpayerID, user.Username, user.PasswordHash,
 		user.PersonName, user.PersonSurname,
-		user.Email, user.PhoneNo, user.UserRole, user.Status,
+		user.Email, user.PhoneNo, user.UserRole, user.Status, user.Language.OrDefault(),
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 }
 
-func (r *adminRepository) ListUsersByTaxpayer(taxpayerID int64) ([]models.AdminUserRow, error) {
+func (r *adminRepository) ListUsersByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.AdminUserRow, error) {
 	var rows []models.AdminUserRow
-	err := r.db.Select(&rows,
+	err := r.db.SelectContext(ctx, &rows,
 		`SELECT id, username, person_name, person_surname, user_role, email, phone_no, status, created_at
 		 FROM users WHERE taxpayer_id = $1 ORDER BY created_at DESC`, taxpayerID)
 	return rows, err
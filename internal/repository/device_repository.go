@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -13,30 +14,31 @@ import (
 
 type DeviceRepository interface {
 	// Device operations
-	Create(device *models.Device) error
-	GetByDeviceID(deviceID int) (*models.Device, error)
-	GetBySerialNo(serialNo string) (*models.Device, error)
-	Update(device *models.Device) error
-	UpdateCertificate(deviceID int, cert string, thumbprint []byte, validTill time.Time) error
-	UpdateLastPing(deviceID int, lastPing time.Time) error
-	IsBlacklisted(modelName, modelVersion string) (bool, error)
+	Create(ctx context.Context, device *models.Device) error
+	GetByDeviceID(ctx context.Context, deviceID int) (*models.Device, error)
+	GetBySerialNo(ctx context.Context, serialNo string) (*models.Device, error)
+	Update(ctx context.Context, device *models.Device) error
+	UpdateCertificate(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error
+	UpdateLastPing(ctx context.Context, deviceID int, lastPing time.Time) error
+	IsBlacklisted(ctx context.Context, modelName, modelVersion string) (bool, error)
 	
 	// Taxpayer operations
-	GetTaxpayer(taxpayerID int64) (*models.Taxpayer, error)
+	GetTaxpayer(ctx context.Context, taxpayerID int64) (*models.Taxpayer, error)
 	
 	// Tax operations
-	GetApplicableTaxes() ([]models.Tax, error)
+	GetApplicableTaxes(ctx context.Context) ([]models.Tax, error)
 	
 	// Fiscal day operations
-	GetCurrentFiscalDay(deviceID int) (*models.FiscalDay, error)
-	GetFiscalDayCounters(fiscalDayID int64) ([]models.FiscalDayCounter, error)
-	GetFiscalDayDocumentQuantities(fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error)
+	GetCurrentFiscalDay(ctx context.Context, deviceID int) (*models.FiscalDay, error)
+	GetFiscalDayCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error)
+	GetFiscalDayDocumentQuantities(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error)
 	
 	// Certificate history
-	SaveCertificateHistory(deviceID int, cert string, thumbprint []byte, validTill time.Time) error
+	SaveCertificateHistory(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error
 	
 	// Stock operations
 	GetStockList(
+		ctx context.Context,
 		taxpayerID int64,
 		branchID int64,
 		hsCode *string,
@@ -50,14 +52,14 @@ type DeviceRepository interface {
 }
 
 type deviceRepository struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewDeviceRepository(db *sqlx.DB) DeviceRepository {
 	return &deviceRepository{db: db}
 }
 
-func (r *deviceRepository) Create(device *models.Device) error {
+func (r *deviceRepository) Create(ctx context.Context, device *models.Device) error {
 	query := `
 		INSERT INTO devices (
 			device_id, taxpayer_id, device_serial_no, device_model_name, device_model_version,
@@ -66,7 +68,7 @@ func (r *deviceRepository) Create(device *models.Device) error {
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
 		) RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(ctx,
 		query,
 		device.DeviceID,
 		device.TaxpayerID,
@@ -82,11 +84,11 @@ func (r *deviceRepository) Create(device *models.Device) error {
 	).Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
 }
 
-func (r *deviceRepository) GetByDeviceID(deviceID int) (*models.Device, error) {
+func (r *deviceRepository) GetByDeviceID(ctx context.Context, deviceID int) (*models.Device, error) {
 	var device models.Device
 	query := `SELECT * FROM devices WHERE device_id = $1`
 	
-	err := r.db.Get(&device, query, deviceID)
+	err := r.db.GetContext(ctx, &device, query, deviceID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -97,11 +99,11 @@ func (r *deviceRepository) GetByDeviceID(deviceID int) (*models.Device, error) {
 	return &device, nil
 }
 
-func (r *deviceRepository) GetBySerialNo(serialNo string) (*models.Device, error) {
+func (r *deviceRepository) GetBySerialNo(ctx context.Context, serialNo string) (*models.Device, error) {
 	var device models.Device
 	query := `SELECT * FROM devices WHERE device_serial_no = $1`
 	
-	err := r.db.Get(&device, query, serialNo)
+	err := r.db.GetContext(ctx, &device, query, serialNo)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -112,7 +114,7 @@ func (r *deviceRepository) GetBySerialNo(serialNo string) (*models.Device, error
 	return &device, nil
 }
 
-func (r *deviceRepository) Update(device *models.Device) error {
+func (r *deviceRepository) Update(ctx context.Context, device *models.Device) error {
 	query := `
 		UPDATE devices SET
 			device_serial_no = $1,
@@ -125,7 +127,7 @@ func (r *deviceRepository) Update(device *models.Device) error {
 			branch_contacts = $8
 		WHERE device_id = $9`
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(ctx,
 		query,
 		device.DeviceSerialNo,
 		device.DeviceModelName,
@@ -141,7 +143,7 @@ func (r *deviceRepository) Update(device *models.Device) error {
 	return err
 }
 
-func (r *deviceRepository) UpdateCertificate(deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+func (r *deviceRepository) UpdateCertificate(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
 	query := `
 		UPDATE devices SET
 			certificate = $1,
@@ -149,27 +151,27 @@ func (r *deviceRepository) UpdateCertificate(deviceID int, cert string, thumbpri
 			certificate_valid_till = $3
 		WHERE device_id = $4`
 
-	_, err := r.db.Exec(query, cert, thumbprint, validTill, deviceID)
+	_, err := r.db.ExecContext(ctx, query, cert, thumbprint, validTill, deviceID)
 	return err
 }
 
-func (r *deviceRepository) UpdateLastPing(deviceID int, lastPing time.Time) error {
+func (r *deviceRepository) UpdateLastPing(ctx context.Context, deviceID int, lastPing time.Time) error {
 	query := `UPDATE devices SET updated_at = $1 WHERE device_id = $2`
-	_, err := r.db.Exec(query, lastPing, deviceID)
+	_, err := r.db.ExecContext(ctx, query, lastPing, deviceID)
 	return err
 }
 
-func (r *deviceRepository) IsBlacklisted(modelName, modelVersion string) (bool, error) {
+func (r *deviceRepository) IsBlacklisted(ctx context.Context, modelName, modelVersion string) (bool, error) {
 	// This would check against a blacklist table
 	// For now, return false
 	return false, nil
 }
 
-func (r *deviceRepository) GetTaxpayer(taxpayerID int64) (*models.Taxpayer, error) {
+func (r *deviceRepository) GetTaxpayer(ctx context.Context, taxpayerID int64) (*models.Taxpayer, error) {
 	var taxpayer models.Taxpayer
 	query := `SELECT * FROM taxpayers WHERE id = $1`
 	
-	err := r.db.Get(&taxpayer, query, taxpayerID)
+	err := r.db.GetContext(ctx, &taxpayer, query, taxpayerID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -180,7 +182,7 @@ func (r *deviceRepository) GetTaxpayer(taxpayerID int64) (*models.Taxpayer, erro
 	return &taxpayer, nil
 }
 
-func (r *deviceRepository) GetApplicableTaxes() ([]models.Tax, error) {
+func (r *deviceRepository) GetApplicableTaxes(ctx context.Context) ([]models.Tax, error) {
 	var taxes []models.Tax
 	query := `
 		SELECT tax_id, tax_name, tax_percent, tax_valid_from, tax_valid_till
@@ -189,7 +191,7 @@ func (r *deviceRepository) GetApplicableTaxes() ([]models.Tax, error) {
 		  AND (tax_valid_till IS NULL OR tax_valid_till >= CURRENT_DATE)
 		ORDER BY tax_id`
 	
-	err := r.db.Select(&taxes, query)
+	err := r.db.SelectContext(ctx, &taxes, query)
 	if err != nil {
 		return nil, err
 	}
@@ -197,7 +199,7 @@ func (r *deviceRepository) GetApplicableTaxes() ([]models.Tax, error) {
 	return taxes, nil
 }
 
-func (r *deviceRepository) GetCurrentFiscalDay(deviceID int) (*models.FiscalDay, error) {
+func (r *deviceRepository) GetCurrentFiscalDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
 	var fiscalDay models.FiscalDay
 	query := `
 		SELECT * FROM fiscal_days
@@ -205,7 +207,7 @@ func (r *deviceRepository) GetCurrentFiscalDay(deviceID int) (*models.FiscalDay,
 		ORDER BY fiscal_day_no DESC
 		LIMIT 1`
 	
-	err := r.db.Get(&fiscalDay, query, deviceID)
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -216,15 +218,18 @@ func (r *deviceRepository) GetCurrentFiscalDay(deviceID int) (*models.FiscalDay,
 	return &fiscalDay, nil
 }
 
-func (r *deviceRepository) GetFiscalDayCounters(fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+func (r *deviceRepository) GetFiscalDayCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
 	var counters []models.FiscalDayCounter
 	query := `
-		SELECT * FROM fiscal_counters
+		SELECT
+			fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id,
+			fiscal_counter_tax_percent, fiscal_counter_money_type, fiscal_counter_value
+		FROM fiscal_counters
 		WHERE fiscal_day_id = $1
 		  AND fiscal_counter_value != 0
 		ORDER BY fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id`
 	
-	err := r.db.Select(&counters, query, fiscalDayID)
+	err := r.db.SelectContext(ctx, &counters, query, fiscalDayID)
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +237,7 @@ func (r *deviceRepository) GetFiscalDayCounters(fiscalDayID int64) ([]models.Fis
 	return counters, nil
 }
 
-func (r *deviceRepository) GetFiscalDayDocumentQuantities(fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error) {
+func (r *deviceRepository) GetFiscalDayDocumentQuantities(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error) {
 	var quantities []models.FiscalDayDocumentQuantity
 	query := `
 		SELECT
@@ -245,7 +250,7 @@ func (r *deviceRepository) GetFiscalDayDocumentQuantities(fiscalDayID int64) ([]
 		GROUP BY receipt_type, receipt_currency
 		ORDER BY receipt_type, receipt_currency`
 	
-	err := r.db.Select(&quantities, query, fiscalDayID)
+	err := r.db.SelectContext(ctx, &quantities, query, fiscalDayID)
 	if err != nil {
 		return nil, err
 	}
@@ -253,17 +258,18 @@ func (r *deviceRepository) GetFiscalDayDocumentQuantities(fiscalDayID int64) ([]
 	return quantities, nil
 }
 
-func (r *deviceRepository) SaveCertificateHistory(deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+func (r *deviceRepository) SaveCertificateHistory(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
 	query := `
 		INSERT INTO certificates_history (
 			device_id, certificate, certificate_thumbprint, issued_at, valid_till
 		) VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := r.db.Exec(query, deviceID, cert, thumbprint, time.Now(), validTill)
+	_, err := r.db.ExecContext(ctx, query, deviceID, cert, thumbprint, time.Now(), validTill)
 	return err
 }
 
 func (r *deviceRepository) GetStockList(
+	ctx context.Context,
 	taxpayerID int64,
 	branchID int64,
 	hsCode *string,
@@ -299,7 +305,7 @@ func (r *deviceRepository) GetStockList(
 	// Count total
 	var total int
 	countQuery := "SELECT COUNT(*) " + baseQuery
-	err := r.db.Get(&total, countQuery, args...)
+	err := r.db.GetContext(ctx, &total, countQuery, args...)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -331,7 +337,7 @@ func (r *deviceRepository) GetStockList(
 	selectQuery += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
 	var items []models.Good
-	err = r.db.Select(&items, selectQuery, args...)
+	err = r.db.SelectContext(ctx, &items, selectQuery, args...)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,40 +13,48 @@ import (
 
 type FiscalDayRepository interface {
 	// Fiscal day operations
-	Create(fiscalDay *models.FiscalDay) error
-	GetByID(id int64) (*models.FiscalDay, error)
-	GetCurrent(deviceID int) (*models.FiscalDay, error)
-	GetByDayNo(deviceID, fiscalDayNo int) (*models.FiscalDay, error)
-	Update(fiscalDay *models.FiscalDay) error
-	UpdateStatus(id int64, status models.FiscalDayStatus) error
-	Close(id int64, closedAt time.Time, signature *models.SignatureData) error
+	Create(ctx context.Context, fiscalDay *models.FiscalDay) error
+	GetByID(ctx context.Context, id int64) (*models.FiscalDay, error)
+	GetByIDForUpdate(ctx context.Context, id int64) (*models.FiscalDay, error)
+	GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error)
+	GetByDayNo(ctx context.Context, deviceID, fiscalDayNo int) (*models.FiscalDay, error)
+	Update(ctx context.Context, fiscalDay *models.FiscalDay) error
+	UpdateStatus(ctx context.Context, id int64, status models.FiscalDayStatus) error
+	Close(ctx context.Context, id int64, closedAt time.Time, signature *models.SignatureData) error
 	
 	// Counter operations
-	CreateCounters(fiscalDayID int64, counters []models.FiscalDayCounter) error
-	GetCounters(fiscalDayID int64) ([]models.FiscalDayCounter, error)
-	UpdateCounters(fiscalDayID int64, counters []models.FiscalDayCounter) error
+	CreateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error
+	GetCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error)
+	UpdateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error
+	
+	CalculateCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error)
 	
 	// Validation
-	ValidateCounters(fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error)
-	GetLastClosedDay(deviceID int) (*models.FiscalDay, error)
+	ValidateCounters(ctx context.Context, fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error)
+	GetLastClosedDay(ctx context.Context, deviceID int) (*models.FiscalDay, error)
+
+	// Scheduling
+	ListExceedingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error)
+	ListApproachingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error)
+	MarkEndNotificationSent(ctx context.Context, fiscalDayID int64, thresholdHrs int) (bool, error)
 }
 
 type fiscalDayRepository struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewFiscalDayRepository(db *sqlx.DB) FiscalDayRepository {
 	return &fiscalDayRepository{db: db}
 }
 
-func (r *fiscalDayRepository) Create(fiscalDay *models.FiscalDay) error {
+func (r *fiscalDayRepository) Create(ctx context.Context, fiscalDay *models.FiscalDay) error {
 	query := `
 		INSERT INTO fiscal_days (
 			device_id, fiscal_day_no, fiscal_day_opened, status
 		) VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(ctx,
 		query,
 		fiscalDay.DeviceID,
 		fiscalDay.FiscalDayNo,
@@ -54,11 +63,29 @@ func (r *fiscalDayRepository) Create(fiscalDay *models.FiscalDay) error {
 	).Scan(&fiscalDay.ID, &fiscalDay.CreatedAt, &fiscalDay.UpdatedAt)
 }
 
-func (r *fiscalDayRepository) GetByID(id int64) (*models.FiscalDay, error) {
+func (r *fiscalDayRepository) GetByID(ctx context.Context, id int64) (*models.FiscalDay, error) {
 	var fiscalDay models.FiscalDay
 	query := `SELECT * FROM fiscal_days WHERE id = $1`
 
-	err := r.db.Get(&fiscalDay, query, id)
+	err := r.db.GetContext(ctx, &fiscalDay, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+// GetByIDForUpdate gets a fiscal day and locks its row until the enclosing
+// transaction ends. Receipt submission takes the same lock, so no receipt can
+// be added to the day while it is held.
+func (r *fiscalDayRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `SELECT * FROM fiscal_days WHERE id = $1 FOR UPDATE`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -69,7 +96,7 @@ func (r *fiscalDayRepository) GetByID(id int64) (*models.FiscalDay, error) {
 	return &fiscalDay, nil
 }
 
-func (r *fiscalDayRepository) GetCurrent(deviceID int) (*models.FiscalDay, error) {
+func (r *fiscalDayRepository) GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
 	var fiscalDay models.FiscalDay
 	query := `
 		SELECT * FROM fiscal_days
@@ -77,7 +104,7 @@ func (r *fiscalDayRepository) GetCurrent(deviceID int) (*models.FiscalDay, error
 		ORDER BY fiscal_day_no DESC
 		LIMIT 1`
 
-	err := r.db.Get(&fiscalDay, query, deviceID)
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -88,13 +115,13 @@ func (r *fiscalDayRepository) GetCurrent(deviceID int) (*models.FiscalDay, error
 	return &fiscalDay, nil
 }
 
-func (r *fiscalDayRepository) GetByDayNo(deviceID, fiscalDayNo int) (*models.FiscalDay, error) {
+func (r *fiscalDayRepository) GetByDayNo(ctx context.Context, deviceID, fiscalDayNo int) (*models.FiscalDay, error) {
 	var fiscalDay models.FiscalDay
 	query := `
 		SELECT * FROM fiscal_days
 		WHERE device_id = $1 AND fiscal_day_no = $2`
 
-	err := r.db.Get(&fiscalDay, query, deviceID, fiscalDayNo)
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID, fiscalDayNo)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -105,7 +132,7 @@ func (r *fiscalDayRepository) GetByDayNo(deviceID, fiscalDayNo int) (*models.Fis
 	return &fiscalDay, nil
 }
 
-func (r *fiscalDayRepository) Update(fiscalDay *models.FiscalDay) error {
+func (r *fiscalDayRepository) Update(ctx context.Context, fiscalDay *models.FiscalDay) error {
 	query := `
 		UPDATE fiscal_days SET
 			fiscal_day_closed = $1,
@@ -114,10 +141,11 @@ func (r *fiscalDayRepository) Update(fiscalDay *models.FiscalDay) error {
 			fiscal_day_device_signature = $4,
 			fiscal_day_server_signature = $5,
 			closing_error_code = $6,
-			last_receipt_global_no = $7
-		WHERE id = $8`
+			last_receipt_global_no = $7,
+			closure = $8
+		WHERE id = $9`
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(ctx,
 		query,
 		fiscalDay.FiscalDayClosed,
 		fiscalDay.Status,
@@ -126,19 +154,20 @@ func (r *fiscalDayRepository) Update(fiscalDay *models.FiscalDay) error {
 		fiscalDay.FiscalDayServerSignature,
 		fiscalDay.ClosingErrorCode,
 		fiscalDay.LastReceiptGlobalNo,
+		fiscalDay.Closure,
 		fiscalDay.ID,
 	)
 
 	return err
 }
 
-func (r *fiscalDayRepository) UpdateStatus(id int64, status models.FiscalDayStatus) error {
+func (r *fiscalDayRepository) UpdateStatus(ctx context.Context, id int64, status models.FiscalDayStatus) error {
 	query := `UPDATE fiscal_days SET status = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, status, id)
+	_, err := r.db.ExecContext(ctx, query, status, id)
 	return err
 }
 
-func (r *fiscalDayRepository) Close(id int64, closedAt time.Time, signature *models.SignatureData) error {
+func (r *fiscalDayRepository) Close(ctx context.Context, id int64, closedAt time.Time, signature *models.SignatureData) error {
 	query := `
 		UPDATE fiscal_days SET
 			fiscal_day_closed = $1,
@@ -146,58 +175,64 @@ func (r *fiscalDayRepository) Close(id int64, closedAt time.Time, signature *mod
 			fiscal_day_device_signature = $3
 		WHERE id = $4`
 
-	_, err := r.db.Exec(query, closedAt, models.FiscalDayStatusCloseInitiated, signature, id)
+	_, err := r.db.ExecContext(ctx, query, closedAt, models.FiscalDayStatusCloseInitiated, signature, id)
 	return err
 }
 
-func (r *fiscalDayRepository) CreateCounters(fiscalDayID int64, counters []models.FiscalDayCounter) error {
-	// Delete existing counters first
-	deleteQuery := `DELETE FROM fiscal_counters WHERE fiscal_day_id = $1`
-	_, err := r.db.Exec(deleteQuery, fiscalDayID)
-	if err != nil {
-		return err
-	}
-
-	// Insert new counters (only non-zero values)
-	for _, counter := range counters {
-		if counter.FiscalCounterValue == 0 {
-			continue
-		}
-
-		query := `
-			INSERT INTO fiscal_counters (
-				fiscal_day_id, fiscal_counter_type, fiscal_counter_currency,
-				fiscal_counter_tax_id, fiscal_counter_tax_percent,
-				fiscal_counter_money_type, fiscal_counter_value
-			) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-
-		_, err := r.db.Exec(
-			query,
-			fiscalDayID,
-			counter.FiscalCounterType,
-			counter.FiscalCounterCurrency,
-			counter.FiscalCounterTaxID,
-			counter.FiscalCounterTaxPercent,
-			counter.FiscalCounterMoneyType,
-			counter.FiscalCounterValue,
-		)
+func (r *fiscalDayRepository) CreateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	// Replace existing counters in one transaction
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		// Delete existing counters first
+		deleteQuery := `DELETE FROM fiscal_counters WHERE fiscal_day_id = $1`
+		_, err := tx.ExecContext(ctx, deleteQuery, fiscalDayID)
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		// Insert new counters (only non-zero values)
+		for _, counter := range counters {
+			if counter.FiscalCounterValue == 0 {
+				continue
+			}
+
+			query := `
+				INSERT INTO fiscal_counters (
+					fiscal_day_id, fiscal_counter_type, fiscal_counter_currency,
+					fiscal_counter_tax_id, fiscal_counter_tax_percent,
+					fiscal_counter_money_type, fiscal_counter_value
+				) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+			_, err := tx.ExecContext(ctx,
+				query,
+				fiscalDayID,
+				counter.FiscalCounterType,
+				counter.FiscalCounterCurrency,
+				counter.FiscalCounterTaxID,
+				counter.FiscalCounterTaxPercent,
+				counter.FiscalCounterMoneyType,
+				counter.FiscalCounterValue,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
-func (r *fiscalDayRepository) GetCounters(fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+func (r *fiscalDayRepository) GetCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
 	var counters []models.FiscalDayCounter
 	query := `
-		SELECT * FROM fiscal_counters
+		SELECT
+			fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id,
+			fiscal_counter_tax_percent, fiscal_counter_money_type, fiscal_counter_value
+		FROM fiscal_counters
 		WHERE fiscal_day_id = $1
 		  AND fiscal_counter_value != 0
 		ORDER BY fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id`
 
-	err := r.db.Select(&counters, query, fiscalDayID)
+	err := r.db.SelectContext(ctx, &counters, query, fiscalDayID)
 	if err != nil {
 		return nil, err
 	}
@@ -205,22 +240,27 @@ func (r *fiscalDayRepository) GetCounters(fiscalDayID int64) ([]models.FiscalDay
 	return counters, nil
 }
 
-func (r *fiscalDayRepository) UpdateCounters(fiscalDayID int64, counters []models.FiscalDayCounter) error {
-	return r.CreateCounters(fiscalDayID, counters)
+// CalculateCounters aggregates the fiscal counters of a day from its stored receipts
+func (r *fiscalDayRepository) CalculateCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	return r.calculateActualCounters(ctx, fiscalDayID)
 }
 
-func (r *fiscalDayRepository) ValidateCounters(fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error) {
+func (r *fiscalDayRepository) UpdateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	return r.CreateCounters(ctx, fiscalDayID, counters)
+}
+
+func (r *fiscalDayRepository) ValidateCounters(ctx context.Context, fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error) {
 	// Calculate actual counters from receipts
-	actualCounters, err := r.calculateActualCounters(fiscalDayID)
+	actualCounters, err := r.calculateActualCounters(ctx, fiscalDayID)
 	if err != nil {
 		return false, err
 	}
 
 	// Compare submitted vs actual
-	return r.compareCounters(submittedCounters, actualCounters), nil
+	return CompareCounters(submittedCounters, actualCounters), nil
 }
 
-func (r *fiscalDayRepository) GetLastClosedDay(deviceID int) (*models.FiscalDay, error) {
+func (r *fiscalDayRepository) GetLastClosedDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
 	var fiscalDay models.FiscalDay
 	query := `
 		SELECT * FROM fiscal_days
@@ -229,7 +269,7 @@ func (r *fiscalDayRepository) GetLastClosedDay(deviceID int) (*models.FiscalDay,
 		ORDER BY fiscal_day_no DESC
 		LIMIT 1`
 
-	err := r.db.Get(&fiscalDay, query, deviceID, models.FiscalDayStatusClosed)
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID, models.FiscalDayStatusClosed)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -240,9 +280,80 @@ func (r *fiscalDayRepository) GetLastClosedDay(deviceID int) (*models.FiscalDay,
 	return &fiscalDay, nil
 }
 
+// ListExceedingMaxHours returns open or close-failed days that have been open
+// longer than their taxpayer's TaxPayerDayMaxHrs at the given time
+func (r *fiscalDayRepository) ListExceedingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	query := `
+		SELECT f.* FROM fiscal_days f
+		JOIN devices d ON f.device_id = d.device_id
+		JOIN taxpayers t ON d.taxpayer_id = t.id
+		WHERE f.status IN ($1, $2)
+		  AND f.fiscal_day_opened + (t.taxpayer_day_max_hrs * INTERVAL '1 hour') < $3
+		ORDER BY f.fiscal_day_opened`
+
+	err := r.db.SelectContext(ctx, &fiscalDays, query,
+		models.FiscalDayStatusOpened, models.FiscalDayStatusCloseFailed, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiscalDays, nil
+}
+
+// ListApproachingMaxHours returns open days that are within their taxpayer's
+// TaxpayerDayEndNotificationHrs of TaxPayerDayMaxHrs and have not yet been
+// reminded for that threshold
+func (r *fiscalDayRepository) ListApproachingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	query := `
+		SELECT f.* FROM fiscal_days f
+		JOIN devices d ON f.device_id = d.device_id
+		JOIN taxpayers t ON d.taxpayer_id = t.id
+		WHERE f.status = $1
+		  AND t.taxpayer_day_end_notification_hrs > 0
+		  AND f.fiscal_day_opened + ((t.taxpayer_day_max_hrs - t.taxpayer_day_end_notification_hrs) * INTERVAL '1 hour') <= $2
+		  AND f.fiscal_day_opened + (t.taxpayer_day_max_hrs * INTERVAL '1 hour') > $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM fiscal_day_notifications n
+			WHERE n.fiscal_day_id = f.id
+			  AND n.threshold_hrs = t.taxpayer_day_end_notification_hrs
+		  )
+		ORDER BY f.fiscal_day_opened`
+
+	err := r.db.SelectContext(ctx, &fiscalDays, query, models.FiscalDayStatusOpened, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiscalDays, nil
+}
+
+// MarkEndNotificationSent records a reminder for the given threshold. It
+// returns false if one was already recorded, so concurrent schedulers never
+// send the same reminder twice.
+func (r *fiscalDayRepository) MarkEndNotificationSent(ctx context.Context, fiscalDayID int64, thresholdHrs int) (bool, error) {
+	query := `
+		INSERT INTO fiscal_day_notifications (fiscal_day_id, threshold_hrs)
+		VALUES ($1, $2)
+		ON CONFLICT (fiscal_day_id, threshold_hrs) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, fiscalDayID, thresholdHrs)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
 // Helper methods
 
-func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+func (r *fiscalDayRepository) calculateActualCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
 	counters := make([]models.FiscalDayCounter, 0)
 
 	// Calculate SaleByTax counters
@@ -260,7 +371,7 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 		GROUP BY rt.tax_id, rt.tax_percent, r.receipt_currency`
 
 	var saleCounters []models.FiscalDayCounter
-	err := r.db.Select(&saleCounters, saleByTaxQuery, fiscalDayID, models.ReceiptTypeFiscalInvoice)
+	err := r.db.SelectContext(ctx, &saleCounters, saleByTaxQuery, fiscalDayID, models.ReceiptTypeFiscalInvoice)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -281,7 +392,7 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 		GROUP BY rt.tax_id, rt.tax_percent, r.receipt_currency`
 
 	var saleTaxCounters []models.FiscalDayCounter
-	err = r.db.Select(&saleTaxCounters, saleTaxQuery, fiscalDayID, models.ReceiptTypeFiscalInvoice)
+	err = r.db.SelectContext(ctx, &saleTaxCounters, saleTaxQuery, fiscalDayID, models.ReceiptTypeFiscalInvoice)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -302,7 +413,7 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 		GROUP BY rt.tax_id, rt.tax_percent, r.receipt_currency`
 
 	var creditCounters []models.FiscalDayCounter
-	err = r.db.Select(&creditCounters, creditQuery, fiscalDayID, models.ReceiptTypeCreditNote)
+	err = r.db.SelectContext(ctx, &creditCounters, creditQuery, fiscalDayID, models.ReceiptTypeCreditNote)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -323,7 +434,7 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 		GROUP BY rt.tax_id, rt.tax_percent, r.receipt_currency`
 
 	var debitCounters []models.FiscalDayCounter
-	err = r.db.Select(&debitCounters, debitQuery, fiscalDayID, models.ReceiptTypeDebitNote)
+	err = r.db.SelectContext(ctx, &debitCounters, debitQuery, fiscalDayID, models.ReceiptTypeDebitNote)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -342,7 +453,7 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 		GROUP BY rp.money_type_code, r.receipt_currency`
 
 	var balanceCounters []models.FiscalDayCounter
-	err = r.db.Select(&balanceCounters, balanceQuery, fiscalDayID)
+	err = r.db.SelectContext(ctx, &balanceCounters, balanceQuery, fiscalDayID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -351,18 +462,20 @@ func (r *fiscalDayRepository) calculateActualCounters(fiscalDayID int64) ([]mode
 	return counters, nil
 }
 
-func (r *fiscalDayRepository) compareCounters(submitted, actual []models.FiscalDayCounter) bool {
+// CompareCounters reports whether submitted counters match the actual ones,
+// allowing a 0.01 rounding difference per counter
+func CompareCounters(submitted, actual []models.FiscalDayCounter) bool {
 	// Create maps for easy comparison
 	submittedMap := make(map[string]float64)
 	actualMap := make(map[string]float64)
 
 	for _, c := range submitted {
-		key := r.counterKey(c)
+		key := counterKey(c)
 		submittedMap[key] = c.FiscalCounterValue
 	}
 
 	for _, c := range actual {
-		key := r.counterKey(c)
+		key := counterKey(c)
 		actualMap[key] = c.FiscalCounterValue
 	}
 
@@ -390,7 +503,7 @@ func (r *fiscalDayRepository) compareCounters(submitted, actual []models.FiscalD
 	return true
 }
 
-func (r *fiscalDayRepository) counterKey(c models.FiscalDayCounter) string {
+func counterKey(c models.FiscalDayCounter) string {
 	key := string(rune(c.FiscalCounterType)) + "_" + c.FiscalCounterCurrency + "_"
 
 	if c.FiscalCounterTaxID != nil {
@@ -0,0 +1,406 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type adminRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewAdminRepository(store *Store) repository.AdminRepository {
+	return &adminRepository{store: store}
+}
+
+// ─── Taxpayer ─────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		for _, stored := range d.taxpayers {
+			if stored.TIN == tp.TIN {
+				return fmt.Errorf("taxpayer %s: %w", tp.TIN, ErrDuplicateKey)
+			}
+		}
+
+		now := time.Now()
+		tp.ID = d.nextID("taxpayers")
+		tp.CreatedAt = now
+		tp.UpdatedAt = now
+		d.taxpayers[tp.ID] = *tp
+		return nil
+	})
+}
+
+func (r *adminRepository) GetTaxpayerByID(ctx context.Context, id int64) (*models.Taxpayer, error) {
+	var tp *models.Taxpayer
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.taxpayers[id]; ok {
+			tp = &found
+		}
+		return nil
+	})
+	return tp, err
+}
+
+func (r *adminRepository) GetTaxpayerByTIN(ctx context.Context, tin string) (*models.Taxpayer, error) {
+	var tp *models.Taxpayer
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.taxpayers {
+			if found.TIN == tin {
+				tp = &found
+				return nil
+			}
+		}
+		return nil
+	})
+	return tp, err
+}
+
+func (r *adminRepository) ListTaxpayers(ctx context.Context, offset, limit int, search string) (int, []models.Taxpayer, error) {
+	var rows []models.Taxpayer
+	err := r.store.read(ctx, func(d *data) error {
+		for _, tp := range d.taxpayers {
+			if search == "" || containsFold(tp.Name, search) || containsFold(tp.TIN, search) {
+				rows = append(rows, tp)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return newerFirst(rows[i].CreatedAt, rows[j].CreatedAt, rows[i].ID, rows[j].ID) })
+	return len(rows), page(rows, offset, limit), nil
+}
+
+func (r *adminRepository) UpdateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.taxpayers[tp.ID]
+		if !ok {
+			return nil
+		}
+		stored.Name = tp.Name
+		stored.VATNumber = tp.VATNumber
+		stored.Status = tp.Status
+		stored.TaxPayerDayMaxHrs = tp.TaxPayerDayMaxHrs
+		stored.TaxpayerDayEndNotificationHrs = tp.TaxpayerDayEndNotificationHrs
+		stored.QrURL = tp.QrURL
+		stored.UpdatedAt = time.Now()
+		d.taxpayers[tp.ID] = stored
+		return nil
+	})
+}
+
+func (r *adminRepository) SetTaxpayerStatus(ctx context.Context, id int64, status string) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.taxpayers[id]
+		if !ok {
+			return nil
+		}
+		stored.Status = status
+		stored.UpdatedAt = time.Now()
+		d.taxpayers[id] = stored
+		return nil
+	})
+}
+
+// ─── Device ───────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateDevice(ctx context.Context, device *models.Device) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		return d.createDevice(device)
+	})
+}
+
+func (r *adminRepository) ListDevicesByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.Device, error) {
+	var rows []models.Device
+	err := r.store.read(ctx, func(d *data) error {
+		for _, device := range d.devices {
+			if device.TaxpayerID == taxpayerID {
+				rows = append(rows, device)
+			}
+		}
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].DeviceID < rows[j].DeviceID })
+	return rows, err
+}
+
+func (r *adminRepository) ListAllDevices(ctx context.Context, offset, limit int) (int, []models.Device, error) {
+	var rows []models.Device
+	err := r.store.read(ctx, func(d *data) error {
+		for _, device := range d.devices {
+			rows = append(rows, device)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return newerFirst(rows[i].CreatedAt, rows[j].CreatedAt, rows[i].ID, rows[j].ID) })
+	return len(rows), page(rows, offset, limit), nil
+}
+
+func (r *adminRepository) GetDeviceByID(ctx context.Context, deviceID int) (*models.Device, error) {
+	var device *models.Device
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.devices[deviceID]; ok {
+			device = &found
+		}
+		return nil
+	})
+	return device, err
+}
+
+func (r *adminRepository) UpdateDeviceStatus(ctx context.Context, deviceID int, status string) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.devices[deviceID]
+		if !ok {
+			return nil
+		}
+		stored.Status = status
+		d.devices[deviceID] = stored
+		return nil
+	})
+}
+
+func (r *adminRepository) UpdateDeviceMode(ctx context.Context, deviceID int, mode int) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.devices[deviceID]
+		if !ok {
+			return nil
+		}
+		stored.OperatingMode = models.DeviceOperatingMode(mode)
+		d.devices[deviceID] = stored
+		return nil
+	})
+}
+
+// ─── Fiscal Days ──────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListFiscalDays(ctx context.Context, taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error) {
+	var rows []models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		for _, fiscalDay := range d.fiscalDays {
+			if d.matchesDevice(fiscalDay.DeviceID, taxpayerID, deviceID) {
+				rows = append(rows, fiscalDay)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return newerFirst(rows[i].FiscalDayOpened, rows[j].FiscalDayOpened, rows[i].ID, rows[j].ID)
+	})
+	return len(rows), page(rows, offset, limit), nil
+}
+
+// ─── Receipts ─────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListReceipts(ctx context.Context, taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error) {
+	var rows []models.AdminReceiptRow
+	err := r.store.read(ctx, func(d *data) error {
+		for _, receipt := range d.receipts {
+			if !d.matchesDevice(receipt.DeviceID, taxpayerID, deviceID) {
+				continue
+			}
+			if from != nil && receipt.ReceiptDate.Before(*from) {
+				continue
+			}
+			if to != nil && receipt.ReceiptDate.After(*to) {
+				continue
+			}
+
+			row := models.AdminReceiptRow{
+				ID:              receipt.ID,
+				ReceiptID:       receipt.ReceiptID,
+				DeviceID:        receipt.DeviceID,
+				ReceiptType:     int(receipt.ReceiptType),
+				ReceiptCurrency: receipt.ReceiptCurrency,
+				InvoiceNo:       receipt.InvoiceNo,
+				ReceiptDate:     receipt.ReceiptDate,
+				ReceiptTotal:    receipt.ReceiptTotal,
+				ServerDate:      receipt.ServerDate,
+				Username:        receipt.Username,
+				UserNameSurname: receipt.UserNameSurname,
+			}
+			if receipt.ValidationColor != nil {
+				color := string(*receipt.ValidationColor)
+				row.ValidationColor = &color
+			}
+			rows = append(rows, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return newerFirst(rows[i].ReceiptDate, rows[j].ReceiptDate, rows[i].ID, rows[j].ID)
+	})
+	return len(rows), page(rows, offset, limit), nil
+}
+
+// ─── Audit Logs ───────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListAuditLogs(ctx context.Context, entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error) {
+	var rows []models.AuditLog
+	err := r.store.read(ctx, func(d *data) error {
+		for _, log := range d.auditLogs {
+			if entityType != "" && log.EntityType != entityType {
+				continue
+			}
+			if entityID != nil && (log.EntityID == nil || *log.EntityID != *entityID) {
+				continue
+			}
+			rows = append(rows, log)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return newerFirst(rows[i].CreatedAt, rows[j].CreatedAt, rows[i].ID, rows[j].ID) })
+	return len(rows), page(rows, offset, limit), nil
+}
+
+func (r *adminRepository) InsertAuditLog(ctx context.Context, entityType, action string, entityID, userID *int64, deviceID *int, ipAddress, details string) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		d.auditLogs = append(d.auditLogs, models.AuditLog{
+			ID:         d.nextID("audit_logs"),
+			EntityType: entityType,
+			EntityID:   entityID,
+			Action:     action,
+			UserID:     userID,
+			DeviceID:   deviceID,
+			IPAddress:  ipAddress,
+			Details:    &details,
+			CreatedAt:  time.Now(),
+		})
+		return nil
+	})
+}
+
+// ─── System Stats ─────────────────────────────────────────────────────────────
+
+func (r *adminRepository) GetSystemStats(ctx context.Context) (*models.SystemStats, error) {
+	stats := &models.SystemStats{}
+	err := r.store.read(ctx, func(d *data) error {
+		day := today()
+
+		stats.TotalCompanies = len(d.taxpayers)
+		for _, tp := range d.taxpayers {
+			if tp.Status == "Active" {
+				stats.ActiveCompanies++
+			}
+		}
+
+		stats.TotalDevices = len(d.devices)
+		for _, device := range d.devices {
+			if device.Status == "Active" {
+				stats.ActiveDevices++
+			}
+		}
+
+		for _, fiscalDay := range d.fiscalDays {
+			if fiscalDay.Status == models.FiscalDayStatusOpened {
+				stats.OpenFiscalDays++
+			}
+		}
+
+		for _, receipt := range d.receipts {
+			if receipt.ReceiptDate.Before(day) {
+				continue
+			}
+			stats.TodayReceipts++
+			if receipt.ValidationColor != nil && *receipt.ValidationColor != "" {
+				stats.ValidationErrors++
+			}
+			if receipt.ReceiptType == models.ReceiptTypeFiscalInvoice {
+				stats.TodayRevenue += receipt.ReceiptTotal
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ─── Users ────────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		return d.createUser(user)
+	})
+}
+
+func (r *adminRepository) ListUsersByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.AdminUserRow, error) {
+	var users []models.User
+	err := r.store.read(ctx, func(d *data) error {
+		for _, user := range d.users {
+			if user.TaxpayerID == taxpayerID {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return newerFirst(users[i].CreatedAt, users[j].CreatedAt, users[i].ID, users[j].ID)
+	})
+	rows := make([]models.AdminUserRow, len(users))
+	for i, user := range users {
+		rows[i] = models.AdminUserRow{
+			ID:            user.ID,
+			Username:      user.Username,
+			PersonName:    user.PersonName,
+			PersonSurname: user.PersonSurname,
+			UserRole:      user.UserRole,
+			Email:         user.Email,
+			PhoneNo:       user.PhoneNo,
+			Status:        int(user.Status),
+			CreatedAt:     user.CreatedAt,
+		}
+	}
+	return rows, nil
+}
+
+// matchesDevice applies the taxpayer and device filters of the admin lists
+func (d *data) matchesDevice(id int, taxpayerID *int64, deviceID *int) bool {
+	device, ok := d.devices[id]
+	if !ok {
+		return false
+	}
+	if taxpayerID != nil && device.TaxpayerID != *taxpayerID {
+		return false
+	}
+	return deviceID == nil || id == *deviceID
+}
+
+// newerFirst orders rows by a timestamp descending, falling back to the ID for
+// rows created within the same clock tick
+func newerFirst(a, b time.Time, aID, bID int64) bool {
+	if !a.Equal(b) {
+		return a.After(b)
+	}
+	return aID > bID
+}
@@ -0,0 +1,291 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type deviceRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewDeviceRepository(store *Store) repository.DeviceRepository {
+	return &deviceRepository{store: store}
+}
+
+func (r *deviceRepository) Create(ctx context.Context, device *models.Device) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		return d.createDevice(device)
+	})
+}
+
+func (r *deviceRepository) GetByDeviceID(ctx context.Context, deviceID int) (*models.Device, error) {
+	var device *models.Device
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.devices[deviceID]; ok {
+			device = &found
+		}
+		return nil
+	})
+	return device, err
+}
+
+func (r *deviceRepository) GetBySerialNo(ctx context.Context, serialNo string) (*models.Device, error) {
+	var device *models.Device
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.devices {
+			if found.DeviceSerialNo == serialNo {
+				device = &found
+				return nil
+			}
+		}
+		return nil
+	})
+	return device, err
+}
+
+func (r *deviceRepository) Update(ctx context.Context, device *models.Device) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.devices[device.DeviceID]
+		if !ok {
+			return nil
+		}
+		stored.DeviceSerialNo = device.DeviceSerialNo
+		stored.DeviceModelName = device.DeviceModelName
+		stored.DeviceModelVersion = device.DeviceModelVersion
+		stored.OperatingMode = device.OperatingMode
+		stored.Status = device.Status
+		stored.BranchName = device.BranchName
+		stored.BranchAddress = device.BranchAddress
+		stored.BranchContacts = device.BranchContacts
+		d.devices[device.DeviceID] = stored
+		return nil
+	})
+}
+
+func (r *deviceRepository) UpdateCertificate(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.devices[deviceID]
+		if !ok {
+			return nil
+		}
+		stored.Certificate = &cert
+		stored.CertificateThumbprint = thumbprint
+		stored.CertificateValidTill = &validTill
+		d.devices[deviceID] = stored
+		return nil
+	})
+}
+
+func (r *deviceRepository) UpdateLastPing(ctx context.Context, deviceID int, lastPing time.Time) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.devices[deviceID]
+		if !ok {
+			return nil
+		}
+		stored.UpdatedAt = lastPing
+		d.devices[deviceID] = stored
+		return nil
+	})
+}
+
+func (r *deviceRepository) IsBlacklisted(ctx context.Context, modelName, modelVersion string) (bool, error) {
+	// No blacklist is kept, as in the PostgreSQL repository
+	return false, nil
+}
+
+func (r *deviceRepository) GetTaxpayer(ctx context.Context, taxpayerID int64) (*models.Taxpayer, error) {
+	var taxpayer *models.Taxpayer
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.taxpayers[taxpayerID]; ok {
+			taxpayer = &found
+		}
+		return nil
+	})
+	return taxpayer, err
+}
+
+func (r *deviceRepository) GetApplicableTaxes(ctx context.Context) ([]models.Tax, error) {
+	var taxes []models.Tax
+	err := r.store.read(ctx, func(d *data) error {
+		day := today()
+		for _, tax := range d.taxes {
+			if tax.TaxValidFrom.After(day) {
+				continue
+			}
+			if tax.TaxValidTill != nil && tax.TaxValidTill.Before(day) {
+				continue
+			}
+			taxes = append(taxes, tax)
+		}
+		return nil
+	})
+	sort.Slice(taxes, func(i, j int) bool { return taxes[i].TaxID < taxes[j].TaxID })
+	return taxes, err
+}
+
+func (r *deviceRepository) GetCurrentFiscalDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay *models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		fiscalDay = d.currentFiscalDay(deviceID)
+		return nil
+	})
+	return fiscalDay, err
+}
+
+func (r *deviceRepository) GetFiscalDayCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	var counters []models.FiscalDayCounter
+	err := r.store.read(ctx, func(d *data) error {
+		counters = d.storedCounters(fiscalDayID)
+		return nil
+	})
+	return counters, err
+}
+
+func (r *deviceRepository) GetFiscalDayDocumentQuantities(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error) {
+	type key struct {
+		receiptType int
+		currency    string
+	}
+
+	totals := make(map[key]*models.FiscalDayDocumentQuantity)
+	err := r.store.read(ctx, func(d *data) error {
+		for _, receipt := range d.receipts {
+			if receipt.FiscalDayID != fiscalDayID {
+				continue
+			}
+			k := key{int(receipt.ReceiptType), receipt.ReceiptCurrency}
+			if totals[k] == nil {
+				totals[k] = &models.FiscalDayDocumentQuantity{ReceiptType: k.receiptType, ReceiptCurrency: k.currency}
+			}
+			totals[k].ReceiptQuantity++
+			totals[k].ReceiptTotalAmount += receipt.ReceiptTotal
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var quantities []models.FiscalDayDocumentQuantity
+	for _, q := range totals {
+		quantities = append(quantities, *q)
+	}
+	sort.Slice(quantities, func(i, j int) bool {
+		if quantities[i].ReceiptType != quantities[j].ReceiptType {
+			return quantities[i].ReceiptType < quantities[j].ReceiptType
+		}
+		return quantities[i].ReceiptCurrency < quantities[j].ReceiptCurrency
+	})
+	return quantities, nil
+}
+
+func (r *deviceRepository) SaveCertificateHistory(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		d.certificates = append(d.certificates, certificateRecord{
+			DeviceID:   deviceID,
+			Cert:       cert,
+			Thumbprint: thumbprint,
+			IssuedAt:   time.Now(),
+			ValidTill:  validTill,
+		})
+		return nil
+	})
+}
+
+func (r *deviceRepository) GetStockList(
+	ctx context.Context,
+	taxpayerID int64,
+	branchID int64,
+	hsCode *string,
+	goodName *string,
+	sortField *string,
+	order *string,
+	offset int,
+	limit int,
+	operator *string,
+) (int, []models.Good, error) {
+	var goods []models.Good
+	err := r.store.read(ctx, func(d *data) error {
+		taxpayer, ok := d.taxpayers[taxpayerID]
+		if !ok {
+			return nil
+		}
+
+		for _, item := range d.stock {
+			if item.TaxpayerID != taxpayerID {
+				continue
+			}
+			if hsCode != nil && *hsCode != "" && item.HSCode != *hsCode {
+				continue
+			}
+			if goodName != nil && *goodName != "" && !containsFold(item.GoodName, *goodName) {
+				continue
+			}
+
+			good := models.Good{
+				HSCode:       item.HSCode,
+				GoodName:     item.GoodName,
+				Quantity:     item.Quantity,
+				TaxPayerID:   taxpayer.ID,
+				TaxPayerName: taxpayer.Name,
+			}
+			if item.BranchID != nil {
+				for _, device := range d.devices {
+					if device.ID == *item.BranchID {
+						id, name := device.ID, device.BranchName
+						good.BranchID = &id
+						good.BranchName = &name
+						break
+					}
+				}
+			}
+			goods = append(goods, good)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	less := func(i, j int) bool { return goods[i].HSCode < goods[j].HSCode }
+	if sortField != nil {
+		switch *sortField {
+		case "goodName", "good_name", "s.good_name":
+			less = func(i, j int) bool { return goods[i].GoodName < goods[j].GoodName }
+		case "quantity", "s.quantity":
+			less = func(i, j int) bool { return goods[i].Quantity < goods[j].Quantity }
+		}
+	}
+	if order != nil && strings.ToUpper(*order) == "DESC" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+	sort.SliceStable(goods, less)
+
+	return len(goods), page(goods, offset, limit), nil
+}
+
+func (d *data) createDevice(device *models.Device) error {
+	if _, exists := d.devices[device.DeviceID]; exists {
+		return fmt.Errorf("device %d: %w", device.DeviceID, ErrDuplicateKey)
+	}
+
+	now := time.Now()
+	device.ID = d.nextID("devices")
+	device.CreatedAt = now
+	device.UpdatedAt = now
+	d.devices[device.DeviceID] = *device
+	return nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
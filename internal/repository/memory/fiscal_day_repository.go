@@ -0,0 +1,420 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type fiscalDayRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewFiscalDayRepository(store *Store) repository.FiscalDayRepository {
+	return &fiscalDayRepository{store: store}
+}
+
+func (r *fiscalDayRepository) Create(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		for _, existing := range d.fiscalDays {
+			if existing.DeviceID == fiscalDay.DeviceID && existing.FiscalDayNo == fiscalDay.FiscalDayNo {
+				return fmt.Errorf("fiscal day %d of device %d: %w", fiscalDay.FiscalDayNo, fiscalDay.DeviceID, ErrDuplicateKey)
+			}
+		}
+
+		now := time.Now()
+		fiscalDay.ID = d.nextID("fiscal_days")
+		fiscalDay.CreatedAt = now
+		fiscalDay.UpdatedAt = now
+		d.fiscalDays[fiscalDay.ID] = models.FiscalDay{
+			ID:              fiscalDay.ID,
+			DeviceID:        fiscalDay.DeviceID,
+			FiscalDayNo:     fiscalDay.FiscalDayNo,
+			FiscalDayOpened: fiscalDay.FiscalDayOpened,
+			Status:          fiscalDay.Status,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) GetByID(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	var fiscalDay *models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.fiscalDays[id]; ok {
+			fiscalDay = &found
+		}
+		return nil
+	})
+	return fiscalDay, err
+}
+
+// GetByIDForUpdate gets a fiscal day. Writes to the store are serialised, so
+// holding a transaction already keeps receipts from being added to the day.
+func (r *fiscalDayRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *fiscalDayRepository) GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay *models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		fiscalDay = d.currentFiscalDay(deviceID)
+		return nil
+	})
+	return fiscalDay, err
+}
+
+func (r *fiscalDayRepository) GetByDayNo(ctx context.Context, deviceID, fiscalDayNo int) (*models.FiscalDay, error) {
+	var fiscalDay *models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.fiscalDays {
+			if found.DeviceID == deviceID && found.FiscalDayNo == fiscalDayNo {
+				fiscalDay = &found
+				return nil
+			}
+		}
+		return nil
+	})
+	return fiscalDay, err
+}
+
+func (r *fiscalDayRepository) Update(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.fiscalDays[fiscalDay.ID]
+		if !ok {
+			return nil
+		}
+		stored.FiscalDayClosed = fiscalDay.FiscalDayClosed
+		stored.Status = fiscalDay.Status
+		stored.ReconciliationMode = fiscalDay.ReconciliationMode
+		stored.FiscalDayDeviceSignature = fiscalDay.FiscalDayDeviceSignature
+		stored.FiscalDayServerSignature = fiscalDay.FiscalDayServerSignature
+		stored.ClosingErrorCode = fiscalDay.ClosingErrorCode
+		stored.LastReceiptGlobalNo = fiscalDay.LastReceiptGlobalNo
+		stored.Closure = fiscalDay.Closure
+		d.fiscalDays[fiscalDay.ID] = stored
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) UpdateStatus(ctx context.Context, id int64, status models.FiscalDayStatus) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.fiscalDays[id]
+		if !ok {
+			return nil
+		}
+		stored.Status = status
+		d.fiscalDays[id] = stored
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) Close(ctx context.Context, id int64, closedAt time.Time, signature *models.SignatureData) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.fiscalDays[id]
+		if !ok {
+			return nil
+		}
+		stored.FiscalDayClosed = &closedAt
+		stored.Status = models.FiscalDayStatusCloseInitiated
+		stored.FiscalDayDeviceSignature = signature
+		d.fiscalDays[id] = stored
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) CreateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		// Replace existing counters, keeping only non-zero values
+		var stored []models.FiscalDayCounter
+		for _, counter := range counters {
+			if counter.FiscalCounterValue != 0 {
+				stored = append(stored, counter)
+			}
+		}
+		d.counters[fiscalDayID] = stored
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) GetCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	var counters []models.FiscalDayCounter
+	err := r.store.read(ctx, func(d *data) error {
+		counters = d.storedCounters(fiscalDayID)
+		return nil
+	})
+	return counters, err
+}
+
+func (r *fiscalDayRepository) UpdateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	return r.CreateCounters(ctx, fiscalDayID, counters)
+}
+
+// CalculateCounters aggregates the fiscal counters of a day from its stored receipts
+func (r *fiscalDayRepository) CalculateCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	var counters []models.FiscalDayCounter
+	err := r.store.read(ctx, func(d *data) error {
+		counters = d.calculateCounters(fiscalDayID)
+		return nil
+	})
+	return counters, err
+}
+
+func (r *fiscalDayRepository) ValidateCounters(ctx context.Context, fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error) {
+	actualCounters, err := r.CalculateCounters(ctx, fiscalDayID)
+	if err != nil {
+		return false, err
+	}
+	return repository.CompareCounters(submittedCounters, actualCounters), nil
+}
+
+func (r *fiscalDayRepository) GetLastClosedDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay *models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.fiscalDays {
+			if found.DeviceID != deviceID || found.Status != models.FiscalDayStatusClosed {
+				continue
+			}
+			if fiscalDay == nil || found.FiscalDayNo > fiscalDay.FiscalDayNo {
+				fiscalDay = &found
+			}
+		}
+		return nil
+	})
+	return fiscalDay, err
+}
+
+// ListExceedingMaxHours returns open or close-failed days that have been open
+// longer than their taxpayer's TaxPayerDayMaxHrs at the given time
+func (r *fiscalDayRepository) ListExceedingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		for _, fiscalDay := range d.fiscalDays {
+			if fiscalDay.Status != models.FiscalDayStatusOpened && fiscalDay.Status != models.FiscalDayStatusCloseFailed {
+				continue
+			}
+			taxpayer, ok := d.deviceTaxpayer(fiscalDay.DeviceID)
+			if !ok {
+				continue
+			}
+			if fiscalDay.FiscalDayOpened.Add(hours(taxpayer.TaxPayerDayMaxHrs)).Before(now) {
+				fiscalDays = append(fiscalDays, fiscalDay)
+			}
+		}
+		return nil
+	})
+	sortByOpened(fiscalDays)
+	return fiscalDays, err
+}
+
+// ListApproachingMaxHours returns open days that are within their taxpayer's
+// TaxpayerDayEndNotificationHrs of TaxPayerDayMaxHrs and have not yet been
+// reminded for that threshold
+func (r *fiscalDayRepository) ListApproachingMaxHours(ctx context.Context, now time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	err := r.store.read(ctx, func(d *data) error {
+		for _, fiscalDay := range d.fiscalDays {
+			if fiscalDay.Status != models.FiscalDayStatusOpened {
+				continue
+			}
+			taxpayer, ok := d.deviceTaxpayer(fiscalDay.DeviceID)
+			if !ok || taxpayer.TaxpayerDayEndNotificationHrs <= 0 {
+				continue
+			}
+			remindAt := fiscalDay.FiscalDayOpened.Add(hours(taxpayer.TaxPayerDayMaxHrs - taxpayer.TaxpayerDayEndNotificationHrs))
+			closesAt := fiscalDay.FiscalDayOpened.Add(hours(taxpayer.TaxPayerDayMaxHrs))
+			if remindAt.After(now) || !closesAt.After(now) {
+				continue
+			}
+			if _, sent := d.notifications[notificationKey{fiscalDay.ID, taxpayer.TaxpayerDayEndNotificationHrs}]; sent {
+				continue
+			}
+			fiscalDays = append(fiscalDays, fiscalDay)
+		}
+		return nil
+	})
+	sortByOpened(fiscalDays)
+	return fiscalDays, err
+}
+
+// MarkEndNotificationSent records a reminder for the given threshold. It
+// returns false if one was already recorded.
+func (r *fiscalDayRepository) MarkEndNotificationSent(ctx context.Context, fiscalDayID int64, thresholdHrs int) (bool, error) {
+	claimed := false
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		key := notificationKey{fiscalDayID, thresholdHrs}
+		if _, sent := d.notifications[key]; sent {
+			return nil
+		}
+		d.notifications[key] = time.Now()
+		claimed = true
+		return nil
+	})
+	return claimed, err
+}
+
+func (d *data) currentFiscalDay(deviceID int) *models.FiscalDay {
+	var current *models.FiscalDay
+	for _, fiscalDay := range d.fiscalDays {
+		if fiscalDay.DeviceID != deviceID {
+			continue
+		}
+		if current == nil || fiscalDay.FiscalDayNo > current.FiscalDayNo {
+			current = &fiscalDay
+		}
+	}
+	return current
+}
+
+func (d *data) deviceTaxpayer(deviceID int) (models.Taxpayer, bool) {
+	device, ok := d.devices[deviceID]
+	if !ok {
+		return models.Taxpayer{}, false
+	}
+	taxpayer, ok := d.taxpayers[device.TaxpayerID]
+	return taxpayer, ok
+}
+
+func (d *data) storedCounters(fiscalDayID int64) []models.FiscalDayCounter {
+	counters := append([]models.FiscalDayCounter(nil), d.counters[fiscalDayID]...)
+	sort.SliceStable(counters, func(i, j int) bool {
+		a, b := counters[i], counters[j]
+		if a.FiscalCounterType != b.FiscalCounterType {
+			return a.FiscalCounterType < b.FiscalCounterType
+		}
+		if a.FiscalCounterCurrency != b.FiscalCounterCurrency {
+			return a.FiscalCounterCurrency < b.FiscalCounterCurrency
+		}
+		return derefInt(a.FiscalCounterTaxID) < derefInt(b.FiscalCounterTaxID)
+	})
+	return counters
+}
+
+// calculateCounters aggregates counters the same way as the PostgreSQL
+// repository: sales and tax by tax for invoices, sales by tax for credit and
+// debit notes, and payments by money type for all receipts
+func (d *data) calculateCounters(fiscalDayID int64) []models.FiscalDayCounter {
+	type taxKey struct {
+		taxID    int
+		percent  float64
+		exempt   bool
+		currency string
+	}
+	type moneyKey struct {
+		moneyType int
+		currency  string
+	}
+
+	byTax := map[models.FiscalCounterType]map[taxKey]float64{
+		models.FiscalCounterTypeSaleByTax:       {},
+		models.FiscalCounterTypeSaleTaxByTax:    {},
+		models.FiscalCounterTypeCreditNoteByTax: {},
+		models.FiscalCounterTypeDebitNoteByTax:  {},
+	}
+	byMoneyType := make(map[moneyKey]float64)
+
+	for _, receipt := range d.receipts {
+		if receipt.FiscalDayID != fiscalDayID {
+			continue
+		}
+
+		for _, tax := range receipt.ReceiptTaxes {
+			k := taxKey{taxID: tax.TaxID, exempt: tax.TaxPercent == nil, currency: receipt.ReceiptCurrency}
+			if tax.TaxPercent != nil {
+				k.percent = *tax.TaxPercent
+			}
+			switch receipt.ReceiptType {
+			case models.ReceiptTypeFiscalInvoice:
+				byTax[models.FiscalCounterTypeSaleByTax][k] += tax.SalesAmountWithTax
+				byTax[models.FiscalCounterTypeSaleTaxByTax][k] += tax.TaxAmount
+			case models.ReceiptTypeCreditNote:
+				byTax[models.FiscalCounterTypeCreditNoteByTax][k] += tax.SalesAmountWithTax
+			case models.ReceiptTypeDebitNote:
+				byTax[models.FiscalCounterTypeDebitNoteByTax][k] += tax.SalesAmountWithTax
+			}
+		}
+
+		for _, payment := range receipt.ReceiptPayments {
+			byMoneyType[moneyKey{int(payment.MoneyTypeCode), receipt.ReceiptCurrency}] += payment.PaymentAmount
+		}
+	}
+
+	counters := make([]models.FiscalDayCounter, 0)
+	for _, counterType := range []models.FiscalCounterType{
+		models.FiscalCounterTypeSaleByTax,
+		models.FiscalCounterTypeSaleTaxByTax,
+		models.FiscalCounterTypeCreditNoteByTax,
+		models.FiscalCounterTypeDebitNoteByTax,
+	} {
+		keys := make([]taxKey, 0, len(byTax[counterType]))
+		for k := range byTax[counterType] {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].currency != keys[j].currency {
+				return keys[i].currency < keys[j].currency
+			}
+			return keys[i].taxID < keys[j].taxID
+		})
+
+		for _, k := range keys {
+			taxID := k.taxID
+			counter := models.FiscalDayCounter{
+				FiscalCounterType:     int(counterType),
+				FiscalCounterCurrency: k.currency,
+				FiscalCounterTaxID:    &taxID,
+				FiscalCounterValue:    byTax[counterType][k],
+			}
+			if !k.exempt {
+				percent := k.percent
+				counter.FiscalCounterTaxPercent = &percent
+			}
+			counters = append(counters, counter)
+		}
+	}
+
+	keys := make([]moneyKey, 0, len(byMoneyType))
+	for k := range byMoneyType {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].currency != keys[j].currency {
+			return keys[i].currency < keys[j].currency
+		}
+		return keys[i].moneyType < keys[j].moneyType
+	})
+	for _, k := range keys {
+		moneyType := k.moneyType
+		counters = append(counters, models.FiscalDayCounter{
+			FiscalCounterType:      int(models.FiscalCounterTypeBalanceByMoneyType),
+			FiscalCounterCurrency:  k.currency,
+			FiscalCounterMoneyType: &moneyType,
+			FiscalCounterValue:     byMoneyType[k],
+		})
+	}
+
+	return counters
+}
+
+func sortByOpened(fiscalDays []models.FiscalDay) {
+	sort.Slice(fiscalDays, func(i, j int) bool {
+		return fiscalDays[i].FiscalDayOpened.Before(fiscalDays[j].FiscalDayOpened)
+	})
+}
+
+func hours(n int) time.Duration {
+	return time.Duration(n) * time.Hour
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/repositorytest"
+)
+
+func seededStore(t *testing.T) (*Store, []models.Device) {
+	t.Helper()
+	store := NewStore()
+	devices, err := SeedDemo(context.Background(), store)
+	if err != nil {
+		t.Fatalf("SeedDemo() error = %v", err)
+	}
+	return store, devices
+}
+
+func TestFiscalDayRepository_CalculateCounters(t *testing.T) {
+	ctx := context.Background()
+	store, devices := seededStore(t)
+	repos := store.Repositories()
+
+	day := &models.FiscalDay{DeviceID: devices[0].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now(), Status: models.FiscalDayStatusOpened}
+	if err := repos.FiscalDays.Create(ctx, day); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	standard := 15.0
+	receipts := []models.Receipt{
+		{ReceiptType: models.ReceiptTypeFiscalInvoice, ReceiptTotal: 115,
+			ReceiptTaxes:    []models.ReceiptTax{{TaxID: 3, TaxPercent: &standard, TaxAmount: 15, SalesAmountWithTax: 115}},
+			ReceiptPayments: []models.Payment{{MoneyTypeCode: 0, PaymentAmount: 115}}},
+		{ReceiptType: models.ReceiptTypeFiscalInvoice, ReceiptTotal: 50,
+			ReceiptTaxes:    []models.ReceiptTax{{TaxID: 1, SalesAmountWithTax: 50}},
+			ReceiptPayments: []models.Payment{{MoneyTypeCode: 0, PaymentAmount: 50}}},
+		{ReceiptType: models.ReceiptTypeCreditNote, ReceiptTotal: -23,
+			ReceiptTaxes:    []models.ReceiptTax{{TaxID: 3, TaxPercent: &standard, TaxAmount: -3, SalesAmountWithTax: -23}},
+			ReceiptPayments: []models.Payment{{MoneyTypeCode: 0, PaymentAmount: -23}}},
+	}
+	for i := range receipts {
+		receipts[i].DeviceID = day.DeviceID
+		receipts[i].FiscalDayID = day.ID
+		receipts[i].ReceiptCurrency = "USD"
+		receipts[i].ReceiptGlobalNo = i + 1
+		if err := repos.Receipts.CreateWithLines(ctx, &receipts[i]); err != nil {
+			t.Fatalf("CreateWithLines() error = %v", err)
+		}
+	}
+
+	counters, err := repos.FiscalDays.CalculateCounters(ctx, day.ID)
+	if err != nil {
+		t.Fatalf("CalculateCounters() error = %v", err)
+	}
+
+	want := map[models.FiscalCounterType]float64{
+		models.FiscalCounterTypeSaleByTax:          165,
+		models.FiscalCounterTypeSaleTaxByTax:       15,
+		models.FiscalCounterTypeCreditNoteByTax:    -23,
+		models.FiscalCounterTypeBalanceByMoneyType: 142,
+	}
+	got := make(map[models.FiscalCounterType]float64)
+	for _, counter := range counters {
+		got[models.FiscalCounterType(counter.FiscalCounterType)] += counter.FiscalCounterValue
+	}
+	for counterType, value := range want {
+		if got[counterType] != value {
+			t.Errorf("counter type %d = %v, want %v", counterType, got[counterType], value)
+		}
+	}
+
+	valid, err := repos.FiscalDays.ValidateCounters(ctx, day.ID, counters)
+	if err != nil || !valid {
+		t.Errorf("ValidateCounters() = %v, %v, want true, nil", valid, err)
+	}
+}
+
+func TestFiscalDayRepository_Deadlines(t *testing.T) {
+	repositorytest.FiscalDayDeadlines(t, func(t *testing.T) repository.Repositories {
+		return NewStore().Repositories()
+	})
+}
+
+func TestFiscalDayRepository_Closure(t *testing.T) {
+	repositorytest.FiscalDayClosure(t, func(t *testing.T) repository.Repositories {
+		return NewStore().Repositories()
+	})
+}
+
+func TestAdminRepository_ListTaxpayers(t *testing.T) {
+	store, _ := seededStore(t)
+	admin := store.Repositories().Admin
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		search        string
+		wantTotal     int
+		wantRows      int
+	}{
+		{"All", 0, 10, "", 2, 2},
+		{"Second page", 1, 1, "", 2, 1},
+		{"Past the end", 5, 10, "", 2, 0},
+		{"Search by name", 0, 10, "wholesale", 1, 1},
+		{"Search by TIN", 0, 10, "0000001", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, rows, err := admin.ListTaxpayers(context.Background(), tt.offset, tt.limit, tt.search)
+			if err != nil {
+				t.Fatalf("ListTaxpayers() error = %v", err)
+			}
+			if total != tt.wantTotal || len(rows) != tt.wantRows {
+				t.Errorf("ListTaxpayers() = %d, %d rows, want %d, %d rows", total, len(rows), tt.wantTotal, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestTxManager_RollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	store, devices := seededStore(t)
+	failed := errors.New("failed")
+
+	err := NewTxManager(store).WithinTx(ctx, func(repos repository.Repositories) error {
+		day := &models.FiscalDay{DeviceID: devices[0].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now()}
+		if err := repos.FiscalDays.Create(ctx, day); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithinTx() error = %v, want %v", err, failed)
+	}
+
+	day, err := store.Repositories().FiscalDays.GetCurrent(ctx, devices[0].DeviceID)
+	if err != nil {
+		t.Fatalf("GetCurrent() error = %v", err)
+	}
+	if day != nil {
+		t.Errorf("GetCurrent() = %+v, want nil after rollback", day)
+	}
+}
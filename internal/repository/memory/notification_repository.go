@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type notificationRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewNotificationRepository(store *Store) repository.NotificationRepository {
+	return &notificationRepository{store: store}
+}
+
+func (r *notificationRepository) Enqueue(ctx context.Context, n *models.Notification) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		now := time.Now()
+		if n.NextAttemptAt.IsZero() {
+			n.NextAttemptAt = now
+		}
+		n.ID = d.nextID("notification_outbox")
+		n.Status = models.NotificationStatusPending
+		n.CreatedAt, n.UpdatedAt = now, now
+		d.outbox[n.ID] = *n
+		return nil
+	})
+}
+
+func (r *notificationRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error) {
+	var claimed []models.Notification
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		for _, n := range d.outbox {
+			due := n.Status == models.NotificationStatusPending || n.Status == models.NotificationStatusSending
+			if due && !n.NextAttemptAt.After(now) {
+				claimed = append(claimed, n)
+			}
+		}
+		sort.Slice(claimed, func(i, j int) bool {
+			if !claimed[i].NextAttemptAt.Equal(claimed[j].NextAttemptAt) {
+				return claimed[i].NextAttemptAt.Before(claimed[j].NextAttemptAt)
+			}
+			return claimed[i].ID < claimed[j].ID
+		})
+		if len(claimed) > limit {
+			claimed = claimed[:limit]
+		}
+
+		for i := range claimed {
+			claimed[i].Status = models.NotificationStatusSending
+			claimed[i].NextAttemptAt = leaseUntil
+			claimed[i].UpdatedAt = time.Now()
+			d.outbox[claimed[i].ID] = claimed[i]
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *notificationRepository) MarkSent(ctx context.Context, id int64) error {
+	return r.update(ctx, id, func(n *models.Notification) {
+		sentAt := time.Now()
+		n.Status = models.NotificationStatusSent
+		n.Attempts++
+		n.SentAt = &sentAt
+	})
+}
+
+func (r *notificationRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	return r.update(ctx, id, func(n *models.Notification) {
+		n.Status = models.NotificationStatusDead
+		n.NextAttemptAt = time.Now()
+		if retryAt != nil {
+			n.Status = models.NotificationStatusPending
+			n.NextAttemptAt = *retryAt
+		}
+		n.Attempts++
+		n.LastError = lastError
+	})
+}
+
+func (r *notificationRepository) GetByID(ctx context.Context, id int64) (*models.Notification, error) {
+	var n *models.Notification
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.outbox[id]; ok {
+			n = &found
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (r *notificationRepository) List(ctx context.Context, status models.NotificationStatus, offset, limit int) (int, []models.Notification, error) {
+	var rows []models.Notification
+	err := r.store.read(ctx, func(d *data) error {
+		for _, n := range d.outbox {
+			if status == "" || n.Status == status {
+				rows = append(rows, n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return newerFirst(rows[i].CreatedAt, rows[j].CreatedAt, rows[i].ID, rows[j].ID) })
+	return len(rows), page(rows, offset, limit), nil
+}
+
+func (r *notificationRepository) Requeue(ctx context.Context, id int64) (bool, error) {
+	requeued := false
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		n, ok := d.outbox[id]
+		if !ok || n.Status != models.NotificationStatusDead {
+			return nil
+		}
+		n.Status = models.NotificationStatusPending
+		n.Attempts = 0
+		n.NextAttemptAt = time.Now()
+		n.UpdatedAt = n.NextAttemptAt
+		d.outbox[id] = n
+		requeued = true
+		return nil
+	})
+	return requeued, err
+}
+
+// update applies fn to a stored notification; unknown IDs are ignored like
+// an UPDATE matching no rows
+func (r *notificationRepository) update(ctx context.Context, id int64, fn func(n *models.Notification)) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		n, ok := d.outbox[id]
+		if !ok {
+			return nil
+		}
+		fn(&n)
+		n.UpdatedAt = time.Now()
+		d.outbox[id] = n
+		return nil
+	})
+}
@@ -0,0 +1,411 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type receiptRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewReceiptRepository(store *Store) repository.ReceiptRepository {
+	return &receiptRepository{store: store}
+}
+
+func (r *receiptRepository) Create(ctx context.Context, receipt *models.Receipt) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored := *receipt
+		stored.ReceiptLines, stored.ReceiptTaxes, stored.ReceiptPayments = nil, nil, nil
+		if err := d.insertReceipt(&stored); err != nil {
+			return err
+		}
+		receipt.ID, receipt.ReceiptID = stored.ID, stored.ReceiptID
+		receipt.CreatedAt, receipt.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+		return nil
+	})
+}
+
+func (r *receiptRepository) CreateWithLines(ctx context.Context, receipt *models.Receipt) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		return d.insertWithLines(receipt)
+	})
+}
+
+// CreateChained stores a receipt and advances the fiscal day's last receipt
+// number, with the same checks as the PostgreSQL repository.
+func (r *receiptRepository) CreateChained(ctx context.Context, receipt *models.Receipt, previousGlobalNo *int, sign repository.ReceiptSigner) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		fiscalDay, ok := d.fiscalDays[receipt.FiscalDayID]
+		if !ok {
+			return repository.ErrFiscalDayNotOpen
+		}
+		if fiscalDay.Status != models.FiscalDayStatusOpened && fiscalDay.Status != models.FiscalDayStatusCloseFailed {
+			return repository.ErrFiscalDayNotOpen
+		}
+
+		var latest *int
+		for _, stored := range d.receipts {
+			if stored.DeviceID == receipt.DeviceID && stored.ReceiptGlobalNo == receipt.ReceiptGlobalNo {
+				return repository.ErrDuplicateReceipt
+			}
+			if stored.FiscalDayID == receipt.FiscalDayID && stored.ReceiptGlobalNo < receipt.ReceiptGlobalNo {
+				if latest == nil || stored.ReceiptGlobalNo > *latest {
+					globalNo := stored.ReceiptGlobalNo
+					latest = &globalNo
+				}
+			}
+		}
+		if (latest != nil) != (previousGlobalNo != nil) || (latest != nil && *latest != *previousGlobalNo) {
+			return repository.ErrReceiptChainChanged
+		}
+
+		if err := d.insertWithLines(receipt); err != nil {
+			return err
+		}
+
+		if sign != nil {
+			if err := sign(receipt); err != nil {
+				delete(d.receipts, receipt.ID)
+				return err
+			}
+			stored := d.receipts[receipt.ID]
+			stored.ReceiptServerSignature = receipt.ReceiptServerSignature
+			d.receipts[receipt.ID] = stored
+		}
+
+		if fiscalDay.LastReceiptGlobalNo == nil || *fiscalDay.LastReceiptGlobalNo < receipt.ReceiptGlobalNo {
+			globalNo := receipt.ReceiptGlobalNo
+			fiscalDay.LastReceiptGlobalNo = &globalNo
+			d.fiscalDays[fiscalDay.ID] = fiscalDay
+		}
+		return nil
+	})
+}
+
+func (r *receiptRepository) GetByID(ctx context.Context, id int64) (*models.Receipt, error) {
+	return r.find(ctx, func(receipt *models.Receipt) bool { return receipt.ID == id })
+}
+
+func (r *receiptRepository) GetByReceiptID(ctx context.Context, receiptID int64) (*models.Receipt, error) {
+	return r.find(ctx, func(receipt *models.Receipt) bool { return receipt.ReceiptID == receiptID })
+}
+
+func (r *receiptRepository) GetByGlobalNo(ctx context.Context, deviceID, globalNo int) (*models.Receipt, error) {
+	return r.find(ctx, func(receipt *models.Receipt) bool {
+		return receipt.DeviceID == deviceID && receipt.ReceiptGlobalNo == globalNo
+	})
+}
+
+func (r *receiptRepository) GetPreviousReceipt(ctx context.Context, deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error) {
+	var receipt *models.Receipt
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if stored.DeviceID != deviceID || stored.FiscalDayID != fiscalDayID || stored.ReceiptGlobalNo >= globalNo {
+				continue
+			}
+			if receipt == nil || stored.ReceiptGlobalNo > receipt.ReceiptGlobalNo {
+				receipt = copyReceipt(stored)
+			}
+		}
+		return nil
+	})
+	return receipt, err
+}
+
+func (r *receiptRepository) Update(ctx context.Context, receipt *models.Receipt) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.receipts[receipt.ID]
+		if !ok {
+			return nil
+		}
+		stored.ReceiptServerSignature = receipt.ReceiptServerSignature
+		stored.ServerDate = receipt.ServerDate
+		stored.ValidationColor = receipt.ValidationColor
+		stored.ValidationErrors = append([]string(nil), receipt.ValidationErrors...)
+		stored.UpdatedAt = time.Now()
+		d.receipts[receipt.ID] = stored
+		return nil
+	})
+}
+
+func (r *receiptRepository) UpdateValidation(ctx context.Context, receiptID int64, color *models.ValidationColor, errors []string) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.receipts[receiptID]
+		if !ok {
+			return nil
+		}
+		stored.ValidationColor = color
+		stored.ValidationErrors = append([]string(nil), errors...)
+		stored.UpdatedAt = time.Now()
+		d.receipts[receiptID] = stored
+		return nil
+	})
+}
+
+func (r *receiptRepository) CreateReceiptLines(ctx context.Context, receiptID int64, lines []models.ReceiptLine) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.receipts[receiptID]
+		if !ok {
+			return fmt.Errorf("receipt %d not found", receiptID)
+		}
+		stored.ReceiptLines = append(append([]models.ReceiptLine(nil), stored.ReceiptLines...), d.newLines(receiptID, lines)...)
+		d.receipts[receiptID] = stored
+		return nil
+	})
+}
+
+func (r *receiptRepository) GetReceiptLines(ctx context.Context, receiptID int64) ([]models.ReceiptLine, error) {
+	var lines []models.ReceiptLine
+	err := r.store.read(ctx, func(d *data) error {
+		if stored, ok := d.receipts[receiptID]; ok {
+			lines = copyReceipt(stored).ReceiptLines
+		}
+		return nil
+	})
+	return lines, err
+}
+
+func (r *receiptRepository) CreateReceiptTaxes(ctx context.Context, receiptID int64, taxes []models.ReceiptTax) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.receipts[receiptID]
+		if !ok {
+			return fmt.Errorf("receipt %d not found", receiptID)
+		}
+		stored.ReceiptTaxes = append(append([]models.ReceiptTax(nil), stored.ReceiptTaxes...), d.newTaxes(receiptID, taxes)...)
+		d.receipts[receiptID] = stored
+		return nil
+	})
+}
+
+func (r *receiptRepository) GetReceiptTaxes(ctx context.Context, receiptID int64) ([]models.ReceiptTax, error) {
+	var taxes []models.ReceiptTax
+	err := r.store.read(ctx, func(d *data) error {
+		if stored, ok := d.receipts[receiptID]; ok {
+			taxes = copyReceipt(stored).ReceiptTaxes
+		}
+		return nil
+	})
+	return taxes, err
+}
+
+func (r *receiptRepository) CreateReceiptPayments(ctx context.Context, receiptID int64, payments []models.Payment) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.receipts[receiptID]
+		if !ok {
+			return fmt.Errorf("receipt %d not found", receiptID)
+		}
+		stored.ReceiptPayments = append(append([]models.Payment(nil), stored.ReceiptPayments...), d.newPayments(receiptID, payments)...)
+		d.receipts[receiptID] = stored
+		return nil
+	})
+}
+
+func (r *receiptRepository) GetReceiptPayments(ctx context.Context, receiptID int64) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.store.read(ctx, func(d *data) error {
+		if stored, ok := d.receipts[receiptID]; ok {
+			payments = copyReceipt(stored).ReceiptPayments
+		}
+		return nil
+	})
+	return payments, err
+}
+
+func (r *receiptRepository) CheckInvoiceNoUnique(ctx context.Context, taxpayerID int64, invoiceNo string) (bool, error) {
+	unique := true
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if stored.InvoiceNo != invoiceNo {
+				continue
+			}
+			if device, ok := d.devices[stored.DeviceID]; ok && device.TaxpayerID == taxpayerID {
+				unique = false
+				return nil
+			}
+		}
+		return nil
+	})
+	return unique, err
+}
+
+// GetMissingReceipts reports the first missing global number of each gap in
+// the day's receipts
+func (r *receiptRepository) GetMissingReceipts(ctx context.Context, deviceID int, fiscalDayID int64) ([]int, error) {
+	var globalNos []int
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if stored.DeviceID == deviceID && stored.FiscalDayID == fiscalDayID {
+				globalNos = append(globalNos, stored.ReceiptGlobalNo)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(globalNos)
+	var missing []int
+	for i := 1; i < len(globalNos); i++ {
+		if globalNos[i]-globalNos[i-1] > 1 {
+			missing = append(missing, globalNos[i-1]+1)
+		}
+	}
+	return missing, nil
+}
+
+func (r *receiptRepository) GetReceiptsWithValidationErrors(ctx context.Context, fiscalDayID int64) ([]models.Receipt, error) {
+	var receipts []models.Receipt
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if stored.FiscalDayID != fiscalDayID || stored.ValidationColor == nil {
+				continue
+			}
+			if *stored.ValidationColor == models.ValidationColorRed || *stored.ValidationColor == models.ValidationColorGrey {
+				stored.ReceiptLines, stored.ReceiptTaxes, stored.ReceiptPayments = nil, nil, nil
+				receipts = append(receipts, stored)
+			}
+		}
+		return nil
+	})
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].ReceiptGlobalNo < receipts[j].ReceiptGlobalNo })
+	return receipts, err
+}
+
+func (r *receiptRepository) GetCreditDebitNotes(ctx context.Context, originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error) {
+	var creditNotes []*models.Receipt
+	var debitNotes []*models.Receipt
+
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if stored.CreditDebitNote == nil || stored.CreditDebitNote.ReceiptID == nil || *stored.CreditDebitNote.ReceiptID != originalReceiptID {
+				continue
+			}
+			note := stored
+			note.ReceiptLines, note.ReceiptTaxes, note.ReceiptPayments = nil, nil, nil
+			switch stored.ReceiptType {
+			case models.ReceiptTypeCreditNote:
+				creditNotes = append(creditNotes, &note)
+			case models.ReceiptTypeDebitNote:
+				debitNotes = append(debitNotes, &note)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byDate := func(notes []*models.Receipt) {
+		sort.Slice(notes, func(i, j int) bool { return notes[i].ReceiptDate.Before(notes[j].ReceiptDate) })
+	}
+	byDate(creditNotes)
+	byDate(debitNotes)
+	return creditNotes, debitNotes, nil
+}
+
+// find returns a copy of the first receipt matching fn, with its relations
+func (r *receiptRepository) find(ctx context.Context, fn func(receipt *models.Receipt) bool) (*models.Receipt, error) {
+	var receipt *models.Receipt
+	err := r.store.read(ctx, func(d *data) error {
+		for _, stored := range d.receipts {
+			if fn(&stored) {
+				receipt = copyReceipt(stored)
+				return nil
+			}
+		}
+		return nil
+	})
+	return receipt, err
+}
+
+// insertReceipt stores a receipt, assigning its IDs and timestamps
+func (d *data) insertReceipt(receipt *models.Receipt) error {
+	for _, stored := range d.receipts {
+		if stored.DeviceID == receipt.DeviceID && stored.ReceiptGlobalNo == receipt.ReceiptGlobalNo {
+			return fmt.Errorf("receipt %d of device %d: %w", receipt.ReceiptGlobalNo, receipt.DeviceID, ErrDuplicateKey)
+		}
+	}
+
+	now := time.Now()
+	receipt.ID = d.nextID("receipts")
+	receipt.ReceiptID = d.nextID("receipt_ids")
+	receipt.CreatedAt = now
+	receipt.UpdatedAt = now
+	d.receipts[receipt.ID] = *receipt
+	return nil
+}
+
+func (d *data) insertWithLines(receipt *models.Receipt) error {
+	stored := *receipt
+	stored.ReceiptLines, stored.ReceiptTaxes, stored.ReceiptPayments = nil, nil, nil
+	stored.ValidationErrors = append([]string(nil), receipt.ValidationErrors...)
+	if err := d.insertReceipt(&stored); err != nil {
+		return err
+	}
+
+	stored.ReceiptLines = d.newLines(stored.ID, receipt.ReceiptLines)
+	stored.ReceiptTaxes = d.newTaxes(stored.ID, receipt.ReceiptTaxes)
+	stored.ReceiptPayments = d.newPayments(stored.ID, receipt.ReceiptPayments)
+	d.receipts[stored.ID] = stored
+
+	receipt.ID, receipt.ReceiptID = stored.ID, stored.ReceiptID
+	receipt.CreatedAt, receipt.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	return nil
+}
+
+func (d *data) newLines(receiptID int64, lines []models.ReceiptLine) []models.ReceiptLine {
+	stored := make([]models.ReceiptLine, len(lines))
+	for i, line := range lines {
+		line.ID = d.nextID("receipt_lines")
+		line.ReceiptID = receiptID
+		stored[i] = line
+	}
+	return stored
+}
+
+func (d *data) newTaxes(receiptID int64, taxes []models.ReceiptTax) []models.ReceiptTax {
+	stored := make([]models.ReceiptTax, len(taxes))
+	for i, tax := range taxes {
+		tax.ID = d.nextID("receipt_taxes")
+		tax.ReceiptID = receiptID
+		stored[i] = tax
+	}
+	return stored
+}
+
+func (d *data) newPayments(receiptID int64, payments []models.Payment) []models.Payment {
+	stored := make([]models.Payment, len(payments))
+	for i, payment := range payments {
+		payment.ID = d.nextID("receipt_payments")
+		payment.ReceiptID = receiptID
+		stored[i] = payment
+	}
+	return stored
+}
+
+// copyReceipt copies a stored receipt so callers cannot modify the store.
+// Lines are ordered by line number and taxes by tax ID, as the PostgreSQL
+// repository loads them.
+func copyReceipt(stored models.Receipt) *models.Receipt {
+	receipt := stored
+	receipt.ReceiptLines = append([]models.ReceiptLine(nil), stored.ReceiptLines...)
+	receipt.ReceiptTaxes = append([]models.ReceiptTax(nil), stored.ReceiptTaxes...)
+	receipt.ReceiptPayments = append([]models.Payment(nil), stored.ReceiptPayments...)
+	receipt.ValidationErrors = append([]string(nil), stored.ValidationErrors...)
+
+	sort.SliceStable(receipt.ReceiptLines, func(i, j int) bool {
+		return receipt.ReceiptLines[i].ReceiptLineNo < receipt.ReceiptLines[j].ReceiptLineNo
+	})
+	sort.SliceStable(receipt.ReceiptTaxes, func(i, j int) bool {
+		return receipt.ReceiptTaxes[i].TaxID < receipt.ReceiptTaxes[j].TaxID
+	})
+	return &receipt
+}
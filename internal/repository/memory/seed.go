@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fiscalization-api/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DemoPassword is the password of every user created by SeedDemo
+const DemoPassword = "Demo@12345"
+
+// SeedDemo fills the store with taxes, taxpayers, devices, users and stock for
+// trying the API locally. It returns the seeded devices so their IDs and
+// activation keys can be shown to the integrator.
+func SeedDemo(ctx context.Context, store *Store) ([]models.Device, error) {
+	validFrom := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	zero, standard := 0.0, 15.0
+	store.AddTax(models.Tax{TaxID: 1, TaxName: "Exempt", TaxValidFrom: validFrom})
+	store.AddTax(models.Tax{TaxID: 2, TaxName: "Zero rated 0%", TaxPercent: &zero, TaxValidFrom: validFrom})
+	store.AddTax(models.Tax{TaxID: 3, TaxName: "Standard rated 15%", TaxPercent: &standard, TaxValidFrom: validFrom})
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(DemoPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash demo password: %w", err)
+	}
+
+	admin := NewAdminRepository(store)
+	seeds := []struct {
+		taxpayer models.Taxpayer
+		devices  []models.Device
+		username string
+		stock    []StockItem
+	}{
+		{
+			taxpayer: models.Taxpayer{TIN: "2000000001", Name: "Demo Retail (Pvt) Ltd"},
+			devices: []models.Device{
+				{DeviceID: 1001, DeviceSerialNo: "DEMO-0001", ActivationKey: "DEMO1001", BranchName: "Harare CBD"},
+				{DeviceID: 1002, DeviceSerialNo: "DEMO-0002", ActivationKey: "DEMO1002", BranchName: "Borrowdale"},
+			},
+			username: "retail.admin",
+			stock: []StockItem{
+				{HSCode: "04011000", GoodName: "Fresh milk 1L", Quantity: 240},
+				{HSCode: "10063000", GoodName: "Rice 2kg", Quantity: 120},
+				{HSCode: "22011000", GoodName: "Mineral water 500ml", Quantity: 600},
+			},
+		},
+		{
+			taxpayer: models.Taxpayer{TIN: "2000000002", Name: "Demo Wholesale (Pvt) Ltd"},
+			devices: []models.Device{
+				{DeviceID: 2001, DeviceSerialNo: "DEMO-0003", ActivationKey: "DEMO2001", BranchName: "Bulawayo Depot"},
+			},
+			username: "wholesale.admin",
+			stock: []StockItem{
+				{HSCode: "17019900", GoodName: "Sugar 50kg", Quantity: 80},
+				{HSCode: "15079000", GoodName: "Cooking oil 20L", Quantity: 45},
+			},
+		},
+	}
+
+	var devices []models.Device
+	for _, seed := range seeds {
+		taxpayer := seed.taxpayer
+		taxpayer.Status = "Active"
+		taxpayer.TaxPayerDayMaxHrs = 24
+		taxpayer.TaxpayerDayEndNotificationHrs = 2
+		taxpayer.QrURL = "http://localhost:8080/verify"
+		if err := admin.CreateTaxpayer(ctx, &taxpayer); err != nil {
+			return nil, err
+		}
+
+		for _, device := range seed.devices {
+			device.TaxpayerID = taxpayer.ID
+			device.DeviceModelName = "DemoPOS"
+			device.DeviceModelVersion = "1.0"
+			device.OperatingMode = models.DeviceOperatingModeOnline
+			device.Status = "Active"
+			device.BranchAddress = models.Address{Province: "Harare", City: "Harare", Street: "Samora Machel Ave", HouseNo: "1"}
+			if err := admin.CreateDevice(ctx, &device); err != nil {
+				return nil, err
+			}
+			devices = append(devices, device)
+		}
+
+		user := models.User{
+			TaxpayerID:    taxpayer.ID,
+			Username:      seed.username,
+			PasswordHash:  string(passwordHash),
+			PersonName:    "Demo",
+			PersonSurname: "Administrator",
+			UserRole:      models.UserRoleOwner,
+			Email:         seed.username + "@example.com",
+			PhoneNo:       "+263771000000",
+			Status:        models.UserStatusActive,
+		}
+		if err := admin.CreateUser(ctx, &user); err != nil {
+			return nil, err
+		}
+
+		for _, item := range seed.stock {
+			item.TaxpayerID = taxpayer.ID
+			store.AddStock(item)
+		}
+	}
+
+	return devices, nil
+}
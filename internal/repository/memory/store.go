@@ -0,0 +1,229 @@
+// Package memory implements the repository interfaces in memory, for tests
+// and for running the API without a database.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+// Store holds the data shared by the in-memory repositories. Like the
+// PostgreSQL tables they replace, every repository created from the same
+// store sees the same data.
+type Store struct {
+	// txMu serialises writes. A transaction holds it until it commits or
+	// rolls back, so rolling back never discards anyone else's writes.
+	txMu sync.Mutex
+	mu   sync.RWMutex
+	data *data
+}
+
+// StockItem is a stock entry returned by GetStockList
+type StockItem struct {
+	TaxpayerID int64
+	BranchID   *int64 // devices.id of the branch, nil for taxpayer-wide stock
+	HSCode     string
+	GoodName   string
+	Quantity   float64
+}
+
+type certificateRecord struct {
+	DeviceID   int
+	Cert       string
+	Thumbprint []byte
+	IssuedAt   time.Time
+	ValidTill  time.Time
+}
+
+type notificationKey struct {
+	FiscalDayID  int64
+	ThresholdHrs int
+}
+
+type securityCodeKey struct {
+	UserID  int64
+	Purpose models.SecurityCodePurpose
+}
+
+type data struct {
+	sequences     map[string]int64
+	taxpayers     map[int64]models.Taxpayer
+	devices       map[int]models.Device // by device_id
+	taxes         []models.Tax
+	stock         []StockItem
+	certificates  []certificateRecord
+	fiscalDays    map[int64]models.FiscalDay
+	counters      map[int64][]models.FiscalDayCounter // by fiscal day id
+	notifications map[notificationKey]time.Time
+	receipts      map[int64]models.Receipt // with lines, taxes and payments
+	users         map[int64]models.User
+	securityCodes map[securityCodeKey]models.SecurityCode
+	sessions      map[int64]models.UserSession
+	auditLogs     []models.AuditLog
+	outbox        map[int64]models.Notification
+}
+
+func NewStore() *Store {
+	return &Store{data: newData()}
+}
+
+func newData() *data {
+	return &data{
+		sequences:     make(map[string]int64),
+		taxpayers:     make(map[int64]models.Taxpayer),
+		devices:       make(map[int]models.Device),
+		fiscalDays:    make(map[int64]models.FiscalDay),
+		counters:      make(map[int64][]models.FiscalDayCounter),
+		notifications: make(map[notificationKey]time.Time),
+		receipts:      make(map[int64]models.Receipt),
+		users:         make(map[int64]models.User),
+		securityCodes: make(map[securityCodeKey]models.SecurityCode),
+		sessions:      make(map[int64]models.UserSession),
+		outbox:        make(map[int64]models.Notification),
+	}
+}
+
+// Repositories returns the full set of repositories backed by the store
+func (s *Store) Repositories() repository.Repositories {
+	return s.repositories(false)
+}
+
+func (s *Store) repositories(inTx bool) repository.Repositories {
+	return repository.Repositories{
+		Devices:       &deviceRepository{store: s, inTx: inTx},
+		Receipts:      &receiptRepository{store: s, inTx: inTx},
+		FiscalDays:    &fiscalDayRepository{store: s, inTx: inTx},
+		Users:         &userRepository{store: s, inTx: inTx},
+		Admin:         &adminRepository{store: s, inTx: inTx},
+		Notifications: &notificationRepository{store: s, inTx: inTx},
+	}
+}
+
+// AddTax adds a tax to the applicable taxes table
+func (s *Store) AddTax(tax models.Tax) {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.taxes = append(s.data.taxes, tax)
+}
+
+// AddStock adds an item to the stock table
+func (s *Store) AddStock(item StockItem) {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.stock = append(s.data.stock, item)
+}
+
+// read runs fn with shared access to the data
+func (s *Store) read(ctx context.Context, fn func(d *data) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.data)
+}
+
+// write runs fn with exclusive access to the data. Outside a transaction it
+// also takes the write lock a transaction would hold.
+func (s *Store) write(ctx context.Context, inTx bool, fn func(d *data) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !inTx {
+		s.txMu.Lock()
+		defer s.txMu.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+func (d *data) nextID(table string) int64 {
+	d.sequences[table]++
+	return d.sequences[table]
+}
+
+// clone copies the data for rolling back a transaction. Stored values are
+// never modified in place, so copying the containers is enough.
+func (d *data) clone() *data {
+	c := &data{
+		sequences:     make(map[string]int64, len(d.sequences)),
+		taxpayers:     make(map[int64]models.Taxpayer, len(d.taxpayers)),
+		devices:       make(map[int]models.Device, len(d.devices)),
+		taxes:         append([]models.Tax(nil), d.taxes...),
+		stock:         append([]StockItem(nil), d.stock...),
+		certificates:  append([]certificateRecord(nil), d.certificates...),
+		fiscalDays:    make(map[int64]models.FiscalDay, len(d.fiscalDays)),
+		counters:      make(map[int64][]models.FiscalDayCounter, len(d.counters)),
+		notifications: make(map[notificationKey]time.Time, len(d.notifications)),
+		receipts:      make(map[int64]models.Receipt, len(d.receipts)),
+		users:         make(map[int64]models.User, len(d.users)),
+		securityCodes: make(map[securityCodeKey]models.SecurityCode, len(d.securityCodes)),
+		sessions:      make(map[int64]models.UserSession, len(d.sessions)),
+		auditLogs:     append([]models.AuditLog(nil), d.auditLogs...),
+		outbox:        make(map[int64]models.Notification, len(d.outbox)),
+	}
+	for k, v := range d.sequences {
+		c.sequences[k] = v
+	}
+	for k, v := range d.taxpayers {
+		c.taxpayers[k] = v
+	}
+	for k, v := range d.devices {
+		c.devices[k] = v
+	}
+	for k, v := range d.fiscalDays {
+		c.fiscalDays[k] = v
+	}
+	for k, v := range d.counters {
+		c.counters[k] = v
+	}
+	for k, v := range d.notifications {
+		c.notifications[k] = v
+	}
+	for k, v := range d.receipts {
+		c.receipts[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.securityCodes {
+		c.securityCodes[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.outbox {
+		c.outbox[k] = v
+	}
+	return c
+}
+
+// page applies OFFSET and LIMIT to rows
+func page[T any](rows []T, offset, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(rows) || limit <= 0 {
+		return nil
+	}
+	end := offset + limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[offset:end]
+}
+
+// today is the start of the current day, like CURRENT_DATE
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
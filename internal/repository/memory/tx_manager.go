@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"errors"
+
+	"fiscalization-api/internal/repository"
+)
+
+// ErrDuplicateKey is returned where PostgreSQL would report a unique
+// constraint violation
+var ErrDuplicateKey = errors.New("duplicate key value violates unique constraint")
+
+type txManager struct {
+	store *Store
+}
+
+// NewTxManager returns a transaction manager for the store. Transactions are
+// serialised with each other and with writes made outside them; reads are not
+// isolated from a running transaction.
+func NewTxManager(store *Store) repository.TxManager {
+	return &txManager{store: store}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.store.txMu.Lock()
+	defer m.store.txMu.Unlock()
+
+	m.store.mu.RLock()
+	snapshot := m.store.data.clone()
+	m.store.mu.RUnlock()
+
+	committed := false
+	defer func() {
+		if !committed {
+			m.store.mu.Lock()
+			m.store.data = snapshot
+			m.store.mu.Unlock()
+		}
+	}()
+
+	if err := fn(m.store.repositories(true)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+type userRepository struct {
+	store *Store
+	inTx  bool
+}
+
+func NewUserRepository(store *Store) repository.UserRepository {
+	return &userRepository{store: store}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		return d.createUser(user)
+	})
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	var user *models.User
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.users[id]; ok {
+			user = &found
+		}
+		return nil
+	})
+	return user, err
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user *models.User
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.users {
+			if found.Username != username {
+				continue
+			}
+			if user == nil || found.ID < user.ID {
+				user = &found
+			}
+		}
+		return nil
+	})
+	return user, err
+}
+
+func (r *userRepository) GetByTaxpayerID(ctx context.Context, taxpayerID int64) ([]models.User, error) {
+	var users []models.User
+	err := r.store.read(ctx, func(d *data) error {
+		users = d.taxpayerUsers(taxpayerID)
+		return nil
+	})
+	return users, err
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.users[user.ID]
+		if !ok {
+			return nil
+		}
+		stored.PersonName = user.PersonName
+		stored.PersonSurname = user.PersonSurname
+		stored.Email = user.Email
+		stored.PhoneNo = user.PhoneNo
+		stored.UserRole = user.UserRole
+		stored.Status = user.Status
+		stored.Language = user.Language.OrDefault()
+		stored.UpdatedAt = time.Now()
+		d.users[user.ID] = stored
+		return nil
+	})
+}
+
+func (r *userRepository) Delete(ctx context.Context, id int64) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		delete(d.users, id)
+		for key := range d.securityCodes {
+			if key.UserID == id {
+				delete(d.securityCodes, key)
+			}
+		}
+		for sessionID, session := range d.sessions {
+			if session.UserID == id {
+				delete(d.sessions, sessionID)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *userRepository) SaveSecurityCode(ctx context.Context, code *models.SecurityCode) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		saved := *code
+		saved.Attempts = 0
+		saved.CreatedAt = time.Now()
+		d.securityCodes[securityCodeKey{code.UserID, code.Purpose}] = saved
+		return nil
+	})
+}
+
+func (r *userRepository) GetSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) (*models.SecurityCode, error) {
+	var code *models.SecurityCode
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.securityCodes[securityCodeKey{userID, purpose}]; ok && found.ExpiresAt.After(time.Now()) {
+			code = &found
+		}
+		return nil
+	})
+	return code, err
+}
+
+func (r *userRepository) ClaimSecurityCodeAttempt(ctx context.Context, userID int64, purpose models.SecurityCodePurpose, maxAttempts int) (*models.SecurityCode, error) {
+	var code *models.SecurityCode
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		key := securityCodeKey{userID, purpose}
+		found, ok := d.securityCodes[key]
+		if !ok || found.Attempts >= maxAttempts || !found.ExpiresAt.After(time.Now()) {
+			return nil
+		}
+		found.Attempts++
+		d.securityCodes[key] = found
+		code = &found
+		return nil
+	})
+	return code, err
+}
+
+func (r *userRepository) DeleteSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		delete(d.securityCodes, securityCodeKey{userID, purpose})
+		return nil
+	})
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		stored, ok := d.users[userID]
+		if !ok {
+			return nil
+		}
+		stored.PasswordHash = passwordHash
+		stored.UpdatedAt = time.Now()
+		d.users[userID] = stored
+		return nil
+	})
+}
+
+func (r *userRepository) List(ctx context.Context, taxpayerID int64, offset, limit int) ([]models.User, int, error) {
+	var users []models.User
+	err := r.store.read(ctx, func(d *data) error {
+		users = d.taxpayerUsers(taxpayerID)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return page(users, offset, limit), len(users), nil
+}
+
+func (r *userRepository) CreateSession(ctx context.Context, session *models.UserSession) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		now := time.Now()
+		for id, stored := range d.sessions {
+			if stored.UserID == session.UserID && !stored.Active(now) {
+				delete(d.sessions, id)
+			}
+		}
+
+		session.ID = d.nextID("user_sessions")
+		session.CreatedAt = now
+		session.LastUsedAt = now
+		d.sessions[session.ID] = *session
+		return nil
+	})
+}
+
+func (r *userRepository) GetSession(ctx context.Context, id int64) (*models.UserSession, error) {
+	var session *models.UserSession
+	err := r.store.read(ctx, func(d *data) error {
+		if found, ok := d.sessions[id]; ok {
+			session = &found
+		}
+		return nil
+	})
+	return session, err
+}
+
+func (r *userRepository) GetSessionByRefreshHash(ctx context.Context, hash string) (*models.UserSession, error) {
+	var session *models.UserSession
+	err := r.store.read(ctx, func(d *data) error {
+		for _, found := range d.sessions {
+			if found.RefreshTokenHash == hash || found.PreviousTokenHash == hash {
+				session = &found
+				break
+			}
+		}
+		return nil
+	})
+	return session, err
+}
+
+func (r *userRepository) RotateSession(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time, ipAddress string) (bool, error) {
+	rotated := false
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		now := time.Now()
+		stored, ok := d.sessions[id]
+		if !ok || stored.RefreshTokenHash != oldHash || !stored.Active(now) {
+			return nil
+		}
+		stored.PreviousTokenHash = stored.RefreshTokenHash
+		stored.RefreshTokenHash = newHash
+		stored.IPAddress = ipAddress
+		stored.ExpiresAt = expiresAt
+		stored.LastUsedAt = now
+		d.sessions[id] = stored
+		rotated = true
+		return nil
+	})
+	return rotated, err
+}
+
+func (r *userRepository) RevokeSession(ctx context.Context, id int64, reason models.SessionRevokeReason) error {
+	return r.store.write(ctx, r.inTx, func(d *data) error {
+		if stored, ok := d.sessions[id]; ok && stored.RevokedAt == nil {
+			d.revokeSession(stored, reason)
+		}
+		return nil
+	})
+}
+
+func (r *userRepository) RevokeSessions(ctx context.Context, userID int64, reason models.SessionRevokeReason) (int, error) {
+	revoked := 0
+	err := r.store.write(ctx, r.inTx, func(d *data) error {
+		now := time.Now()
+		for _, stored := range d.sessions {
+			if stored.UserID == userID && stored.Active(now) {
+				d.revokeSession(stored, reason)
+				revoked++
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+func (r *userRepository) ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := r.store.read(ctx, func(d *data) error {
+		now := time.Now()
+		for _, stored := range d.sessions {
+			if stored.UserID == userID && stored.Active(now) {
+				sessions = append(sessions, stored)
+			}
+		}
+		return nil
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, err
+}
+
+// revokeSession stores session as revoked now for reason
+func (d *data) revokeSession(session models.UserSession, reason models.SessionRevokeReason) {
+	revokedAt := time.Now()
+	session.RevokedAt = &revokedAt
+	session.RevokeReason = reason
+	d.sessions[session.ID] = session
+}
+
+func (d *data) createUser(user *models.User) error {
+	for _, stored := range d.users {
+		if stored.TaxpayerID == user.TaxpayerID && stored.Username == user.Username {
+			return fmt.Errorf("user %q of taxpayer %d: %w", user.Username, user.TaxpayerID, ErrDuplicateKey)
+		}
+	}
+
+	now := time.Now()
+	user.ID = d.nextID("users")
+	user.Language = user.Language.OrDefault()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	d.users[user.ID] = *user
+	return nil
+}
+
+// taxpayerUsers returns the taxpayer's users ordered by username
+func (d *data) taxpayerUsers(taxpayerID int64) []models.User {
+	var users []models.User
+	for _, user := range d.users {
+		if user.TaxpayerID == taxpayerID {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
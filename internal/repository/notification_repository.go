@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fiscalization-api/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NotificationRepository stores the notification outbox. Notifications are
+// enqueued with the repositories of the transaction making the change they
+// report, so they are sent only if that change is committed.
+type NotificationRepository interface {
+	// Enqueue adds a pending notification due at n.NextAttemptAt, or
+	// immediately when it is zero
+	Enqueue(ctx context.Context, n *models.Notification) error
+
+	// ClaimDue marks up to limit due notifications as sending until
+	// leaseUntil and returns them. Notifications claimed by a worker that
+	// stopped before recording the outcome are due again once the lease ends.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error)
+	MarkSent(ctx context.Context, id int64) error
+
+	// MarkFailed records a failed attempt. The notification is retried at
+	// retryAt, or dead-lettered when retryAt is nil.
+	MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error
+
+	GetByID(ctx context.Context, id int64) (*models.Notification, error)
+	List(ctx context.Context, status models.NotificationStatus, offset, limit int) (int, []models.Notification, error)
+
+	// Requeue makes a dead notification pending again with no attempts used.
+	// It returns false when the notification is not dead.
+	Requeue(ctx context.Context, id int64) (bool, error)
+}
+
+type notificationRepository struct {
+	db dbtx
+}
+
+func NewNotificationRepository(db *sqlx.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Enqueue(ctx context.Context, n *models.Notification) error {
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = time.Now()
+	}
+	n.Status = models.NotificationStatusPending
+
+	query := `
+		INSERT INTO notification_outbox (channel, recipient, kind, payload, status, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		n.Channel, n.Recipient, n.Kind, n.Payload, n.Status, n.NextAttemptAt,
+	).Scan(&n.ID, &n.CreatedAt, &n.UpdatedAt)
+}
+
+func (r *notificationRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error) {
+	// SKIP LOCKED lets several workers claim different rows at once
+	query := `
+		UPDATE notification_outbox SET
+			status = 'sending',
+			next_attempt_at = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status IN ('pending', 'sending') AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+
+	var notifications []models.Notification
+	err := r.db.SelectContext(ctx, &notifications, query, now, leaseUntil, limit)
+	return notifications, err
+}
+
+func (r *notificationRepository) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE notification_outbox SET
+			status = 'sent',
+			attempts = attempts + 1,
+			sent_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *notificationRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	status := models.NotificationStatusDead
+	nextAttemptAt := time.Now()
+	if retryAt != nil {
+		status = models.NotificationStatusPending
+		nextAttemptAt = *retryAt
+	}
+
+	query := `
+		UPDATE notification_outbox SET
+			status = $1,
+			attempts = attempts + 1,
+			last_error = $2,
+			next_attempt_at = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, query, status, lastError, nextAttemptAt, id)
+	return err
+}
+
+func (r *notificationRepository) GetByID(ctx context.Context, id int64) (*models.Notification, error) {
+	var n models.Notification
+	err := r.db.GetContext(ctx, &n, `SELECT * FROM notification_outbox WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (r *notificationRepository) List(ctx context.Context, status models.NotificationStatus, offset, limit int) (int, []models.Notification, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+	if status != "" {
+		where += " AND status = $1"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM notification_outbox "+where, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+	var rows []models.Notification
+	err := r.db.SelectContext(ctx, &rows,
+		fmt.Sprintf("SELECT * FROM notification_outbox %s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d",
+			where, len(args)-1, len(args)), args...)
+	return total, rows, err
+}
+
+func (r *notificationRepository) Requeue(ctx context.Context, id int64) (bool, error) {
+	query := `
+		UPDATE notification_outbox SET
+			status = 'pending',
+			attempts = 0,
+			next_attempt_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'dead'`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,181 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/config"
+	"fiscalization-api/internal/database"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/repositorytest"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// openTestDB connects to the PostgreSQL database named by TEST_POSTGRES_DSN,
+// applies the embedded migrations and empties it. The database is wiped, so
+// point it at one that exists only for tests.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db, config.DatabaseConfig{Driver: config.DatabaseDriverPostgres})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	if _, err := db.Exec(`TRUNCATE taxpayers RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("emptying database: %v", err)
+	}
+	return db
+}
+
+func newRepositories(db *sqlx.DB) repository.Repositories {
+	return repository.Repositories{
+		Devices:       repository.NewDeviceRepository(db),
+		Receipts:      repository.NewReceiptRepository(db),
+		FiscalDays:    repository.NewFiscalDayRepository(db),
+		Users:         repository.NewUserRepository(db),
+		Admin:         repository.NewAdminRepository(db),
+		Notifications: repository.NewNotificationRepository(db),
+	}
+}
+
+func seedDevices(t *testing.T, repos repository.Repositories, deviceIDs ...int) []*models.Device {
+	t.Helper()
+	ctx := context.Background()
+
+	tp := &models.Taxpayer{TIN: "2000000001", Name: "Test Retail", Status: "Active", TaxPayerDayMaxHrs: 24,
+		TaxpayerDayEndNotificationHrs: 2, QrURL: "https://example.com"}
+	if err := repos.Admin.CreateTaxpayer(ctx, tp); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+
+	var devices []*models.Device
+	for _, deviceID := range deviceIDs {
+		device := &models.Device{DeviceID: deviceID, TaxpayerID: tp.ID, DeviceSerialNo: fmt.Sprintf("SN-%d", deviceID),
+			DeviceModelName: "Model", DeviceModelVersion: "1.0", ActivationKey: "ABCD1234", Status: "Active", BranchName: "Main"}
+		if err := repos.Admin.CreateDevice(ctx, device); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+func TestFiscalDayRepository_Deadlines(t *testing.T) {
+	repositorytest.FiscalDayDeadlines(t, func(t *testing.T) repository.Repositories {
+		return newRepositories(openTestDB(t))
+	})
+}
+
+func TestFiscalDayRepository_Closure(t *testing.T) {
+	repositorytest.FiscalDayClosure(t, func(t *testing.T) repository.Repositories {
+		return newRepositories(openTestDB(t))
+	})
+}
+
+func TestReceiptRepository_CreateChainedConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repos := newRepositories(openTestDB(t))
+	device := seedDevices(t, repos, 1001)[0]
+
+	day := &models.FiscalDay{DeviceID: device.DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now(), Status: models.FiscalDayStatusOpened}
+	if err := repos.FiscalDays.Create(ctx, day); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	newReceipt := func(counter, globalNo int) *models.Receipt {
+		return &models.Receipt{DeviceID: device.DeviceID, FiscalDayID: day.ID, ReceiptType: models.ReceiptTypeFiscalInvoice,
+			ReceiptCurrency: "USD", ReceiptCounter: counter, ReceiptGlobalNo: globalNo, InvoiceNo: "INV", ReceiptDate: time.Now(), ReceiptTotal: 10}
+	}
+	// submit stores a receipt the way SubmitReceipt does, reading the chain
+	// again whenever another submission changed it in between
+	submit := func(receipt *models.Receipt) error {
+		for {
+			previous, err := repos.Receipts.GetPreviousReceipt(ctx, receipt.DeviceID, receipt.FiscalDayID, receipt.ReceiptGlobalNo)
+			if err != nil {
+				return err
+			}
+			var previousGlobalNo *int
+			if previous != nil {
+				previousGlobalNo = &previous.ReceiptGlobalNo
+			}
+			err = repos.Receipts.CreateChained(ctx, receipt, previousGlobalNo, nil)
+			if !errors.Is(err, repository.ErrReceiptChainChanged) {
+				return err
+			}
+		}
+	}
+
+	// Only one of several submissions of the same receipt is stored
+	const duplicates = 10
+	errs := make(chan error, duplicates)
+	var wg sync.WaitGroup
+	for i := 0; i < duplicates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repos.Receipts.CreateChained(ctx, newReceipt(1, 1), nil, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	stored := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			stored++
+		case !errors.Is(err, repository.ErrDuplicateReceipt):
+			t.Errorf("CreateChained() error = %v, want %v", err, repository.ErrDuplicateReceipt)
+		}
+	}
+	if stored != 1 {
+		t.Fatalf("stored duplicates = %d, want 1", stored)
+	}
+
+	// Receipts racing each other all end up in the chain
+	const receipts = 20
+	errs = make(chan error, receipts)
+	for globalNo := 2; globalNo <= receipts+1; globalNo++ {
+		wg.Add(1)
+		go func(globalNo int) {
+			defer wg.Done()
+			errs <- submit(newReceipt(globalNo, globalNo))
+		}(globalNo)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("CreateChained() error = %v", err)
+		}
+	}
+
+	// A counter reset mid-day still chains onto the previous receipt
+	if err := submit(newReceipt(1, receipts+2)); err != nil {
+		t.Errorf("CreateChained() with a reset counter error = %v", err)
+	}
+
+	current, err := repos.FiscalDays.GetByID(ctx, day.ID)
+	if err != nil || current.LastReceiptGlobalNo == nil || *current.LastReceiptGlobalNo != receipts+2 {
+		t.Errorf("last receipt global no = %v, %v, want %d", current, err, receipts+2)
+	}
+}
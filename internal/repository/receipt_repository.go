@@ -1,53 +1,70 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"fiscalization-api/internal/models"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ReceiptRepository interface {
 	// Receipt operations
-	Create(receipt *models.Receipt) error
-	CreateWithLines(receipt *models.Receipt) error
-	GetByID(id int64) (*models.Receipt, error)
-	GetByReceiptID(receiptID int64) (*models.Receipt, error)
-	GetByGlobalNo(deviceID, globalNo int) (*models.Receipt, error)
-	GetPreviousReceipt(deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error)
-	Update(receipt *models.Receipt) error
-	UpdateValidation(receiptID int64, color *models.ValidationColor, errors []string) error
+	Create(ctx context.Context, receipt *models.Receipt) error
+	CreateWithLines(ctx context.Context, receipt *models.Receipt) error
+	CreateChained(ctx context.Context, receipt *models.Receipt, previousGlobalNo *int, sign ReceiptSigner) error
+	GetByID(ctx context.Context, id int64) (*models.Receipt, error)
+	GetByReceiptID(ctx context.Context, receiptID int64) (*models.Receipt, error)
+	GetByGlobalNo(ctx context.Context, deviceID, globalNo int) (*models.Receipt, error)
+	GetPreviousReceipt(ctx context.Context, deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error)
+	Update(ctx context.Context, receipt *models.Receipt) error
+	UpdateValidation(ctx context.Context, receiptID int64, color *models.ValidationColor, errors []string) error
 	
 	// Receipt lines
-	CreateReceiptLines(receiptID int64, lines []models.ReceiptLine) error
-	GetReceiptLines(receiptID int64) ([]models.ReceiptLine, error)
+	CreateReceiptLines(ctx context.Context, receiptID int64, lines []models.ReceiptLine) error
+	GetReceiptLines(ctx context.Context, receiptID int64) ([]models.ReceiptLine, error)
 	
 	// Receipt taxes
-	CreateReceiptTaxes(receiptID int64, taxes []models.ReceiptTax) error
-	GetReceiptTaxes(receiptID int64) ([]models.ReceiptTax, error)
+	CreateReceiptTaxes(ctx context.Context, receiptID int64, taxes []models.ReceiptTax) error
+	GetReceiptTaxes(ctx context.Context, receiptID int64) ([]models.ReceiptTax, error)
 	
 	// Receipt payments
-	CreateReceiptPayments(receiptID int64, payments []models.Payment) error
-	GetReceiptPayments(receiptID int64) ([]models.Payment, error)
+	CreateReceiptPayments(ctx context.Context, receiptID int64, payments []models.Payment) error
+	GetReceiptPayments(ctx context.Context, receiptID int64) ([]models.Payment, error)
 	
 	// Validation and queries
-	CheckInvoiceNoUnique(taxpayerID int64, invoiceNo string) (bool, error)
-	GetMissingReceipts(deviceID int, fiscalDayID int64) ([]int, error)
-	GetReceiptsWithValidationErrors(fiscalDayID int64) ([]models.Receipt, error)
-	GetCreditDebitNotes(originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error)
+	CheckInvoiceNoUnique(ctx context.Context, taxpayerID int64, invoiceNo string) (bool, error)
+	GetMissingReceipts(ctx context.Context, deviceID int, fiscalDayID int64) ([]int, error)
+	GetReceiptsWithValidationErrors(ctx context.Context, fiscalDayID int64) ([]models.Receipt, error)
+	GetCreditDebitNotes(ctx context.Context, originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error)
 }
 
+// ReceiptSigner sets the server signature on a receipt that has been stored
+// and given its ID but not yet committed. An error rolls the receipt back.
+type ReceiptSigner func(receipt *models.Receipt) error
+
+var (
+	// ErrDuplicateReceipt is returned when the device already has a receipt with the same global number
+	ErrDuplicateReceipt = errors.New("duplicate receipt")
+	// ErrReceiptChainChanged is returned when another receipt was stored ahead of the one being created
+	ErrReceiptChainChanged = errors.New("receipt chain changed")
+	// ErrFiscalDayNotOpen is returned when the receipt's fiscal day no longer accepts receipts
+	ErrFiscalDayNotOpen = errors.New("fiscal day is not open")
+)
+
 type receiptRepository struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewReceiptRepository(db *sqlx.DB) ReceiptRepository {
 	return &receiptRepository{db: db}
 }
 
-func (r *receiptRepository) Create(receipt *models.Receipt) error {
+func (r *receiptRepository) Create(ctx context.Context, receipt *models.Receipt) error {
 	query := `
 		INSERT INTO receipts (
 			device_id, fiscal_day_id, receipt_type, receipt_currency, receipt_counter,
@@ -59,7 +76,7 @@ func (r *receiptRepository) Create(receipt *models.Receipt) error {
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
 		) RETURNING id, receipt_id, created_at, updated_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(ctx,
 		query,
 		receipt.DeviceID,
 		receipt.FiscalDayID,
@@ -82,14 +99,83 @@ func (r *receiptRepository) Create(receipt *models.Receipt) error {
 	).Scan(&receipt.ID, &receipt.ReceiptID, &receipt.CreatedAt, &receipt.UpdatedAt)
 }
 
-func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
-	// Start transaction
-	tx, err := r.db.Beginx()
-	if err != nil {
+func (r *receiptRepository) CreateWithLines(ctx context.Context, receipt *models.Receipt) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		return r.insertWithLines(ctx, tx, receipt)
+	})
+}
+
+// CreateChained stores a receipt and advances the fiscal day's last receipt
+// number in one transaction. The fiscal day row is locked for the duration, so
+// submissions for the same day are serialised across server instances.
+// previousGlobalNo is the global number of the receipt the new one was
+// validated against (nil if none); if another receipt has since been stored
+// in between, ErrReceiptChainChanged is returned and nothing is written.
+// sign, if not nil, is called before commit and the signature it sets is
+// stored with the receipt.
+func (r *receiptRepository) CreateChained(ctx context.Context, receipt *models.Receipt, previousGlobalNo *int, sign ReceiptSigner) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		var status models.FiscalDayStatus
+		err := tx.GetContext(ctx, &status, `SELECT status FROM fiscal_days WHERE id = $1 FOR UPDATE`, receipt.FiscalDayID)
+		if err == sql.ErrNoRows {
+			return ErrFiscalDayNotOpen
+		}
+		if err != nil {
+			return err
+		}
+		if status != models.FiscalDayStatusOpened && status != models.FiscalDayStatusCloseFailed {
+			return ErrFiscalDayNotOpen
+		}
+
+		var exists bool
+		err = tx.GetContext(ctx, &exists,
+			`SELECT EXISTS(SELECT 1 FROM receipts WHERE device_id = $1 AND receipt_global_no = $2)`,
+			receipt.DeviceID, receipt.ReceiptGlobalNo)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateReceipt
+		}
+
+		var latest sql.NullInt64
+		err = tx.GetContext(ctx, &latest, `
+			SELECT MAX(receipt_global_no) FROM receipts
+			WHERE fiscal_day_id = $1 AND receipt_global_no < $2`,
+			receipt.FiscalDayID, receipt.ReceiptGlobalNo)
+		if err != nil {
+			return err
+		}
+		if latest.Valid != (previousGlobalNo != nil) || (latest.Valid && int(latest.Int64) != *previousGlobalNo) {
+			return ErrReceiptChainChanged
+		}
+
+		if err := r.insertWithLines(ctx, tx, receipt); err != nil {
+			return err
+		}
+
+		if sign != nil {
+			if err := sign(receipt); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx,
+				`UPDATE receipts SET receipt_server_signature = $1 WHERE id = $2`,
+				receipt.ReceiptServerSignature, receipt.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE fiscal_days
+			SET last_receipt_global_no = GREATEST(COALESCE(last_receipt_global_no, 0), $1)
+			WHERE id = $2`,
+			receipt.ReceiptGlobalNo, receipt.FiscalDayID)
 		return err
-	}
-	defer tx.Rollback()
+	})
+}
 
+func (r *receiptRepository) insertWithLines(ctx context.Context, tx *sqlx.Tx, receipt *models.Receipt) error {
 	// Create receipt
 	query := `
 		INSERT INTO receipts (
@@ -97,12 +183,14 @@ func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
 			receipt_global_no, invoice_no, buyer_data, receipt_notes, receipt_date,
 			credit_debit_note, receipt_lines_tax_inclusive, receipt_total,
 			receipt_print_form, receipt_device_signature, receipt_hash,
-			username, user_name_surname
+			username, user_name_surname, receipt_server_signature,
+			validation_color, validation_errors, server_date
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18,
+			$19, $20, $21, $22
 		) RETURNING id, receipt_id, created_at, updated_at`
 
-	err = tx.QueryRow(
+	err := tx.QueryRowContext(ctx,
 		query,
 		receipt.DeviceID,
 		receipt.FiscalDayID,
@@ -122,6 +210,10 @@ func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
 		receipt.ReceiptHash,
 		receipt.Username,
 		receipt.UserNameSurname,
+		receipt.ReceiptServerSignature,
+		receipt.ValidationColor,
+		receipt.ValidationErrors,
+		receipt.ServerDate,
 	).Scan(&receipt.ID, &receipt.ReceiptID, &receipt.CreatedAt, &receipt.UpdatedAt)
 	if err != nil {
 		return err
@@ -136,7 +228,7 @@ func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
 				receipt_line_total, tax_code, tax_percent, tax_id
 			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			lineQuery,
 			receipt.ID,
 			line.ReceiptLineType,
@@ -162,7 +254,7 @@ func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
 				receipt_id, tax_code, tax_percent, tax_id, tax_amount, sales_amount_with_tax
 			) VALUES ($1, $2, $3, $4, $5, $6)`
 
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			taxQuery,
 			receipt.ID,
 			tax.TaxCode,
@@ -183,20 +275,20 @@ func (r *receiptRepository) CreateWithLines(receipt *models.Receipt) error {
 				receipt_id, money_type_code, payment_amount
 			) VALUES ($1, $2, $3)`
 
-		_, err = tx.Exec(paymentQuery, receipt.ID, payment.MoneyTypeCode, payment.PaymentAmount)
+		_, err = tx.ExecContext(ctx, paymentQuery, receipt.ID, payment.MoneyTypeCode, payment.PaymentAmount)
 		if err != nil {
 			return err
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
-func (r *receiptRepository) GetByID(id int64) (*models.Receipt, error) {
+func (r *receiptRepository) GetByID(ctx context.Context, id int64) (*models.Receipt, error) {
 	var receipt models.Receipt
 	query := `SELECT * FROM receipts WHERE id = $1`
 
-	err := r.db.Get(&receipt, query, id)
+	err := r.db.GetContext(ctx, &receipt, query, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -205,18 +297,18 @@ func (r *receiptRepository) GetByID(id int64) (*models.Receipt, error) {
 	}
 
 	// Load related data
-	if err := r.loadReceiptRelations(&receipt); err != nil {
+	if err := r.loadReceiptRelations(ctx, &receipt); err != nil {
 		return nil, err
 	}
 
 	return &receipt, nil
 }
 
-func (r *receiptRepository) GetByReceiptID(receiptID int64) (*models.Receipt, error) {
+func (r *receiptRepository) GetByReceiptID(ctx context.Context, receiptID int64) (*models.Receipt, error) {
 	var receipt models.Receipt
 	query := `SELECT * FROM receipts WHERE receipt_id = $1`
 
-	err := r.db.Get(&receipt, query, receiptID)
+	err := r.db.GetContext(ctx, &receipt, query, receiptID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -224,18 +316,18 @@ func (r *receiptRepository) GetByReceiptID(receiptID int64) (*models.Receipt, er
 		return nil, err
 	}
 
-	if err := r.loadReceiptRelations(&receipt); err != nil {
+	if err := r.loadReceiptRelations(ctx, &receipt); err != nil {
 		return nil, err
 	}
 
 	return &receipt, nil
 }
 
-func (r *receiptRepository) GetByGlobalNo(deviceID, globalNo int) (*models.Receipt, error) {
+func (r *receiptRepository) GetByGlobalNo(ctx context.Context, deviceID, globalNo int) (*models.Receipt, error) {
 	var receipt models.Receipt
 	query := `SELECT * FROM receipts WHERE device_id = $1 AND receipt_global_no = $2`
 
-	err := r.db.Get(&receipt, query, deviceID, globalNo)
+	err := r.db.GetContext(ctx, &receipt, query, deviceID, globalNo)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -243,14 +335,14 @@ func (r *receiptRepository) GetByGlobalNo(deviceID, globalNo int) (*models.Recei
 		return nil, err
 	}
 
-	if err := r.loadReceiptRelations(&receipt); err != nil {
+	if err := r.loadReceiptRelations(ctx, &receipt); err != nil {
 		return nil, err
 	}
 
 	return &receipt, nil
 }
 
-func (r *receiptRepository) GetPreviousReceipt(deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error) {
+func (r *receiptRepository) GetPreviousReceipt(ctx context.Context, deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error) {
 	var receipt models.Receipt
 	query := `
 		SELECT * FROM receipts
@@ -258,7 +350,7 @@ func (r *receiptRepository) GetPreviousReceipt(deviceID int, fiscalDayID int64,
 		ORDER BY receipt_global_no DESC
 		LIMIT 1`
 
-	err := r.db.Get(&receipt, query, deviceID, fiscalDayID, globalNo)
+	err := r.db.GetContext(ctx, &receipt, query, deviceID, fiscalDayID, globalNo)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -266,14 +358,14 @@ func (r *receiptRepository) GetPreviousReceipt(deviceID int, fiscalDayID int64,
 		return nil, err
 	}
 
-	if err := r.loadReceiptRelations(&receipt); err != nil {
+	if err := r.loadReceiptRelations(ctx, &receipt); err != nil {
 		return nil, err
 	}
 
 	return &receipt, nil
 }
 
-func (r *receiptRepository) Update(receipt *models.Receipt) error {
+func (r *receiptRepository) Update(ctx context.Context, receipt *models.Receipt) error {
 	query := `
 		UPDATE receipts SET
 			receipt_server_signature = $1,
@@ -282,7 +374,7 @@ func (r *receiptRepository) Update(receipt *models.Receipt) error {
 			validation_errors = $4
 		WHERE id = $5`
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(ctx,
 		query,
 		receipt.ReceiptServerSignature,
 		receipt.ServerDate,
@@ -293,18 +385,18 @@ func (r *receiptRepository) Update(receipt *models.Receipt) error {
 	return err
 }
 
-func (r *receiptRepository) UpdateValidation(receiptID int64, color *models.ValidationColor, errors []string) error {
+func (r *receiptRepository) UpdateValidation(ctx context.Context, receiptID int64, color *models.ValidationColor, errors []string) error {
 	query := `
 		UPDATE receipts SET
 			validation_color = $1,
 			validation_errors = $2
 		WHERE id = $3`
 
-	_, err := r.db.Exec(query, color, errors, receiptID)
+	_, err := r.db.ExecContext(ctx, query, color, pq.Array(errors), receiptID)
 	return err
 }
 
-func (r *receiptRepository) CreateReceiptLines(receiptID int64, lines []models.ReceiptLine) error {
+func (r *receiptRepository) CreateReceiptLines(ctx context.Context, receiptID int64, lines []models.ReceiptLine) error {
 	for _, line := range lines {
 		query := `
 			INSERT INTO receipt_lines (
@@ -313,7 +405,7 @@ func (r *receiptRepository) CreateReceiptLines(receiptID int64, lines []models.R
 				receipt_line_total, tax_code, tax_percent, tax_id
 			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-		_, err := r.db.Exec(
+		_, err := r.db.ExecContext(ctx,
 			query,
 			receiptID,
 			line.ReceiptLineType,
@@ -334,22 +426,22 @@ func (r *receiptRepository) CreateReceiptLines(receiptID int64, lines []models.R
 	return nil
 }
 
-func (r *receiptRepository) GetReceiptLines(receiptID int64) ([]models.ReceiptLine, error) {
+func (r *receiptRepository) GetReceiptLines(ctx context.Context, receiptID int64) ([]models.ReceiptLine, error) {
 	var lines []models.ReceiptLine
 	query := `SELECT * FROM receipt_lines WHERE receipt_id = $1 ORDER BY receipt_line_no`
 
-	err := r.db.Select(&lines, query, receiptID)
+	err := r.db.SelectContext(ctx, &lines, query, receiptID)
 	return lines, err
 }
 
-func (r *receiptRepository) CreateReceiptTaxes(receiptID int64, taxes []models.ReceiptTax) error {
+func (r *receiptRepository) CreateReceiptTaxes(ctx context.Context, receiptID int64, taxes []models.ReceiptTax) error {
 	for _, tax := range taxes {
 		query := `
 			INSERT INTO receipt_taxes (
 				receipt_id, tax_code, tax_percent, tax_id, tax_amount, sales_amount_with_tax
 			) VALUES ($1, $2, $3, $4, $5, $6)`
 
-		_, err := r.db.Exec(
+		_, err := r.db.ExecContext(ctx,
 			query,
 			receiptID,
 			tax.TaxCode,
@@ -365,21 +457,21 @@ func (r *receiptRepository) CreateReceiptTaxes(receiptID int64, taxes []models.R
 	return nil
 }
 
-func (r *receiptRepository) GetReceiptTaxes(receiptID int64) ([]models.ReceiptTax, error) {
+func (r *receiptRepository) GetReceiptTaxes(ctx context.Context, receiptID int64) ([]models.ReceiptTax, error) {
 	var taxes []models.ReceiptTax
 	query := `SELECT * FROM receipt_taxes WHERE receipt_id = $1 ORDER BY tax_id`
 
-	err := r.db.Select(&taxes, query, receiptID)
+	err := r.db.SelectContext(ctx, &taxes, query, receiptID)
 	return taxes, err
 }
 
-func (r *receiptRepository) CreateReceiptPayments(receiptID int64, payments []models.Payment) error {
+func (r *receiptRepository) CreateReceiptPayments(ctx context.Context, receiptID int64, payments []models.Payment) error {
 	for _, payment := range payments {
 		query := `
 			INSERT INTO receipt_payments (receipt_id, money_type_code, payment_amount)
 			VALUES ($1, $2, $3)`
 
-		_, err := r.db.Exec(query, receiptID, payment.MoneyTypeCode, payment.PaymentAmount)
+		_, err := r.db.ExecContext(ctx, query, receiptID, payment.MoneyTypeCode, payment.PaymentAmount)
 		if err != nil {
 			return err
 		}
@@ -387,15 +479,15 @@ func (r *receiptRepository) CreateReceiptPayments(receiptID int64, payments []mo
 	return nil
 }
 
-func (r *receiptRepository) GetReceiptPayments(receiptID int64) ([]models.Payment, error) {
+func (r *receiptRepository) GetReceiptPayments(ctx context.Context, receiptID int64) ([]models.Payment, error) {
 	var payments []models.Payment
 	query := `SELECT * FROM receipt_payments WHERE receipt_id = $1`
 
-	err := r.db.Select(&payments, query, receiptID)
+	err := r.db.SelectContext(ctx, &payments, query, receiptID)
 	return payments, err
 }
 
-func (r *receiptRepository) CheckInvoiceNoUnique(taxpayerID int64, invoiceNo string) (bool, error) {
+func (r *receiptRepository) CheckInvoiceNoUnique(ctx context.Context, taxpayerID int64, invoiceNo string) (bool, error) {
 	var count int
 	query := `
 		SELECT COUNT(*)
@@ -403,7 +495,7 @@ func (r *receiptRepository) CheckInvoiceNoUnique(taxpayerID int64, invoiceNo str
 		JOIN devices d ON r.device_id = d.device_id
 		WHERE d.taxpayer_id = $1 AND r.invoice_no = $2`
 
-	err := r.db.Get(&count, query, taxpayerID, invoiceNo)
+	err := r.db.GetContext(ctx, &count, query, taxpayerID, invoiceNo)
 	if err != nil {
 		return false, err
 	}
@@ -411,7 +503,7 @@ func (r *receiptRepository) CheckInvoiceNoUnique(taxpayerID int64, invoiceNo str
 	return count == 0, nil
 }
 
-func (r *receiptRepository) GetMissingReceipts(deviceID int, fiscalDayID int64) ([]int, error) {
+func (r *receiptRepository) GetMissingReceipts(ctx context.Context, deviceID int, fiscalDayID int64) ([]int, error) {
 	// Find gaps in receipt_global_no sequence
 	query := `
 		WITH receipt_sequence AS (
@@ -427,11 +519,11 @@ func (r *receiptRepository) GetMissingReceipts(deviceID int, fiscalDayID int64)
 		WHERE receipt_global_no - prev_no > 1`
 
 	var missing []int
-	err := r.db.Select(&missing, query, deviceID, fiscalDayID)
+	err := r.db.SelectContext(ctx, &missing, query, deviceID, fiscalDayID)
 	return missing, err
 }
 
-func (r *receiptRepository) GetReceiptsWithValidationErrors(fiscalDayID int64) ([]models.Receipt, error) {
+func (r *receiptRepository) GetReceiptsWithValidationErrors(ctx context.Context, fiscalDayID int64) ([]models.Receipt, error) {
 	var receipts []models.Receipt
 	query := `
 		SELECT * FROM receipts
@@ -439,11 +531,11 @@ func (r *receiptRepository) GetReceiptsWithValidationErrors(fiscalDayID int64) (
 		  AND (validation_color = 'Red' OR validation_color = 'Grey')
 		ORDER BY receipt_global_no`
 
-	err := r.db.Select(&receipts, query, fiscalDayID)
+	err := r.db.SelectContext(ctx, &receipts, query, fiscalDayID)
 	return receipts, err
 }
 
-func (r *receiptRepository) GetCreditDebitNotes(originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error) {
+func (r *receiptRepository) GetCreditDebitNotes(ctx context.Context, originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error) {
 	var creditNotes []*models.Receipt
 	var debitNotes []*models.Receipt
 
@@ -454,7 +546,7 @@ func (r *receiptRepository) GetCreditDebitNotes(originalReceiptID int64) ([]*mod
 		  AND credit_debit_note->>'receiptID' = $2
 		ORDER BY receipt_date`
 
-	err := r.db.Select(&creditNotes, creditQuery, models.ReceiptTypeCreditNote, fmt.Sprintf("%d", originalReceiptID))
+	err := r.db.SelectContext(ctx, &creditNotes, creditQuery, models.ReceiptTypeCreditNote, fmt.Sprintf("%d", originalReceiptID))
 	if err != nil && err != sql.ErrNoRows {
 		return nil, nil, err
 	}
@@ -466,7 +558,7 @@ func (r *receiptRepository) GetCreditDebitNotes(originalReceiptID int64) ([]*mod
 		  AND credit_debit_note->>'receiptID' = $2
 		ORDER BY receipt_date`
 
-	err = r.db.Select(&debitNotes, debitQuery, models.ReceiptTypeDebitNote, fmt.Sprintf("%d", originalReceiptID))
+	err = r.db.SelectContext(ctx, &debitNotes, debitQuery, models.ReceiptTypeDebitNote, fmt.Sprintf("%d", originalReceiptID))
 	if err != nil && err != sql.ErrNoRows {
 		return nil, nil, err
 	}
@@ -475,23 +567,23 @@ func (r *receiptRepository) GetCreditDebitNotes(originalReceiptID int64) ([]*mod
 }
 
 // Helper method to load all related data for a receipt
-func (r *receiptRepository) loadReceiptRelations(receipt *models.Receipt) error {
+func (r *receiptRepository) loadReceiptRelations(ctx context.Context, receipt *models.Receipt) error {
 	// Load lines
-	lines, err := r.GetReceiptLines(receipt.ID)
+	lines, err := r.GetReceiptLines(ctx, receipt.ID)
 	if err != nil {
 		return err
 	}
 	receipt.ReceiptLines = lines
 
 	// Load taxes
-	taxes, err := r.GetReceiptTaxes(receipt.ID)
+	taxes, err := r.GetReceiptTaxes(ctx, receipt.ID)
 	if err != nil {
 		return err
 	}
 	receipt.ReceiptTaxes = taxes
 
 	// Load payments
-	payments, err := r.GetReceiptPayments(receipt.ID)
+	payments, err := r.GetReceiptPayments(ctx, receipt.ID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,159 @@
+// Package repositorytest holds tests shared by every repository backend.
+// Each backend calls them from its own tests with a factory for empty
+// repositories.
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+)
+
+// Factory returns empty repositories of one backend
+type Factory func(t *testing.T) repository.Repositories
+
+// deadlineCases are fiscal days of a taxpayer that allows 24 hours per day
+// and reminds 2 hours before the end, oldest first, with the monitor
+// queries expected to return them
+var deadlineCases = []struct {
+	name        string
+	age         time.Duration
+	status      models.FiscalDayStatus
+	exceeding   bool
+	approaching bool
+}{
+	{"Closed past the limit", 50 * time.Hour, models.FiscalDayStatusClosed, false, false},
+	{"Close-failed past the limit", 30 * time.Hour, models.FiscalDayStatusCloseFailed, true, false},
+	{"Open past the limit", 25 * time.Hour, models.FiscalDayStatusOpened, true, false},
+	{"Closed in its last 2 hours", 23*time.Hour + 30*time.Minute, models.FiscalDayStatusClosed, false, false},
+	{"Open in its last 2 hours", 23 * time.Hour, models.FiscalDayStatusOpened, false, true},
+	{"Open within the limit", time.Hour, models.FiscalDayStatusOpened, false, false},
+}
+
+// FiscalDayDeadlines tests the queries FiscalDayMonitor uses to auto-close
+// days past TaxPayerDayMaxHrs and to remind devices before then
+func FiscalDayDeadlines(t *testing.T, newRepositories Factory) {
+	t.Run("ListExceedingMaxHours", func(t *testing.T) {
+		repos := newRepositories(t)
+		days, now := createDeadlineDays(t, repos)
+
+		got, err := repos.FiscalDays.ListExceedingMaxHours(context.Background(), now)
+		if err != nil {
+			t.Fatalf("ListExceedingMaxHours() error = %v", err)
+		}
+		checkDays(t, "ListExceedingMaxHours()", got, days, func(i int) bool { return deadlineCases[i].exceeding })
+	})
+
+	t.Run("ListApproachingMaxHours", func(t *testing.T) {
+		ctx := context.Background()
+		repos := newRepositories(t)
+		days, now := createDeadlineDays(t, repos)
+
+		got, err := repos.FiscalDays.ListApproachingMaxHours(ctx, now)
+		if err != nil {
+			t.Fatalf("ListApproachingMaxHours() error = %v", err)
+		}
+		checkDays(t, "ListApproachingMaxHours()", got, days, func(i int) bool { return deadlineCases[i].approaching })
+
+		// Only the first claim of each reminder succeeds, so repeated runs of
+		// the monitor send it once, and a reminded day is not listed again
+		for _, day := range got {
+			for run, want := range []bool{true, false} {
+				claimed, err := repos.FiscalDays.MarkEndNotificationSent(ctx, day.ID, 2)
+				if err != nil || claimed != want {
+					t.Errorf("MarkEndNotificationSent(%d) run %d = %v, %v, want %v", day.ID, run+1, claimed, err, want)
+				}
+			}
+		}
+		if again, err := repos.FiscalDays.ListApproachingMaxHours(ctx, now); err != nil || len(again) != 0 {
+			t.Errorf("ListApproachingMaxHours() after the reminders = %+v, %v, want none", again, err)
+		}
+	})
+}
+
+// createDeadlineDays stores one fiscal day per deadline case, each on its
+// own device
+func createDeadlineDays(t *testing.T, repos repository.Repositories) ([]*models.FiscalDay, time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	tp := &models.Taxpayer{TIN: "2000000001", Name: "Test Retail", Status: "Active", TaxPayerDayMaxHrs: 24,
+		TaxpayerDayEndNotificationHrs: 2, QrURL: "https://example.com"}
+	if err := repos.Admin.CreateTaxpayer(ctx, tp); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+
+	now := time.Now()
+	var days []*models.FiscalDay
+	for i, tc := range deadlineCases {
+		device := &models.Device{DeviceID: 1001 + i, TaxpayerID: tp.ID, DeviceSerialNo: fmt.Sprintf("SN-%d", 1001+i),
+			DeviceModelName: "Model", DeviceModelVersion: "1.0", ActivationKey: "ABCD1234", Status: "Active", BranchName: "Main"}
+		if err := repos.Admin.CreateDevice(ctx, device); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+
+		day := &models.FiscalDay{DeviceID: device.DeviceID, FiscalDayNo: 1, FiscalDayOpened: now.Add(-tc.age), Status: tc.status}
+		if err := repos.FiscalDays.Create(ctx, day); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		days = append(days, day)
+	}
+	return days, now
+}
+
+// checkDays fails unless got holds, oldest first, the days whose case
+// matches
+func checkDays(t *testing.T, call string, got []models.FiscalDay, days []*models.FiscalDay, match func(i int) bool) {
+	t.Helper()
+
+	var want []string
+	for i := range deadlineCases {
+		if match(i) {
+			want = append(want, deadlineCases[i].name)
+		}
+	}
+	names := make(map[int64]string, len(days))
+	for i, day := range days {
+		names[day.ID] = deadlineCases[i].name
+	}
+	var gotNames []string
+	for _, day := range got {
+		gotNames = append(gotNames, names[day.ID])
+	}
+
+	if fmt.Sprint(gotNames) != fmt.Sprint(want) {
+		t.Errorf("%s = %q, want %q", call, gotNames, want)
+	}
+}
+
+// FiscalDayClosure tests that Update stores the closure of a day closed by
+// the server next to its reconciliation mode
+func FiscalDayClosure(t *testing.T, newRepositories Factory) {
+	ctx := context.Background()
+	repos := newRepositories(t)
+	days, _ := createDeadlineDays(t, repos)
+	day := days[len(days)-1]
+
+	closedAt := time.Now()
+	mode := models.FiscalDayReconciliationModeManual
+	closure := models.FiscalDayClosureForced
+	day.FiscalDayClosed = &closedAt
+	day.Status = models.FiscalDayStatusClosed
+	day.ReconciliationMode = &mode
+	day.Closure = &closure
+	if err := repos.FiscalDays.Update(ctx, day); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	stored, err := repos.FiscalDays.GetByID(ctx, day.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByID() = %v, %v", stored, err)
+	}
+	if stored.ReconciliationMode == nil || *stored.ReconciliationMode != mode || stored.Closure == nil || *stored.Closure != closure {
+		t.Errorf("stored ReconciliationMode = %v, Closure = %v, want %v and %v", stored.ReconciliationMode, stored.Closure, mode, closure)
+	}
+}
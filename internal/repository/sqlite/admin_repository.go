@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type adminRepository struct {
+	db dbtx
+}
+
+func NewAdminRepository(db *sqlx.DB) repository.AdminRepository {
+	return &adminRepository{db: db}
+}
+
+// ─── Taxpayer ─────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
+	query := `
+		INSERT INTO taxpayers (tin, name, vat_number, status, taxpayer_day_max_hrs, taxpayer_day_end_notification_hrs, qr_url, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, r.db, query,
+		tp.TIN, tp.Name, tp.VATNumber, tp.Status,
+		tp.TaxPayerDayMaxHrs, tp.TaxpayerDayEndNotificationHrs, tp.QrURL,
+		createdAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	tp.ID, tp.CreatedAt, tp.UpdatedAt = id, createdAt, createdAt
+	return nil
+}
+
+func (r *adminRepository) GetTaxpayerByID(ctx context.Context, id int64) (*models.Taxpayer, error) {
+	var tp models.Taxpayer
+	err := r.db.GetContext(ctx, &tp, `SELECT * FROM taxpayers WHERE id = ?`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &tp, err
+}
+
+func (r *adminRepository) GetTaxpayerByTIN(ctx context.Context, tin string) (*models.Taxpayer, error) {
+	var tp models.Taxpayer
+	err := r.db.GetContext(ctx, &tp, `SELECT * FROM taxpayers WHERE tin = ?`, tin)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &tp, err
+}
+
+func (r *adminRepository) ListTaxpayers(ctx context.Context, offset, limit int, search string) (int, []models.Taxpayer, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+
+	if search != "" {
+		where += " AND (name LIKE ? OR tin LIKE ?)"
+		args = append(args, "%"+search+"%", "%"+search+"%")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM taxpayers "+where, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+	var rows []models.Taxpayer
+	err := r.db.SelectContext(ctx, &rows,
+		"SELECT * FROM taxpayers "+where+" ORDER BY julianday(created_at) DESC, id DESC LIMIT ? OFFSET ?",
+		args...)
+	return total, rows, err
+}
+
+func (r *adminRepository) UpdateTaxpayer(ctx context.Context, tp *models.Taxpayer) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE taxpayers SET
+			name = ?, vat_number = ?, status = ?,
+			taxpayer_day_max_hrs = ?, taxpayer_day_end_notification_hrs = ?, qr_url = ?
+		WHERE id = ?`,
+		tp.Name, tp.VATNumber, tp.Status,
+		tp.TaxPayerDayMaxHrs, tp.TaxpayerDayEndNotificationHrs, tp.QrURL, tp.ID)
+	return err
+}
+
+func (r *adminRepository) SetTaxpayerStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE taxpayers SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// ─── Device ───────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateDevice(ctx context.Context, device *models.Device) error {
+	return createDevice(ctx, r.db, device)
+}
+
+func (r *adminRepository) ListDevicesByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.Device, error) {
+	var rows []models.Device
+	err := r.db.SelectContext(ctx, &rows,
+		`SELECT * FROM devices WHERE taxpayer_id = ? ORDER BY device_id`, taxpayerID)
+	return rows, err
+}
+
+func (r *adminRepository) ListAllDevices(ctx context.Context, offset, limit int) (int, []models.Device, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM devices`); err != nil {
+		return 0, nil, err
+	}
+	var rows []models.Device
+	err := r.db.SelectContext(ctx, &rows,
+		`SELECT * FROM devices ORDER BY julianday(created_at) DESC, id DESC LIMIT ? OFFSET ?`, limit, offset)
+	return total, rows, err
+}
+
+func (r *adminRepository) GetDeviceByID(ctx context.Context, deviceID int) (*models.Device, error) {
+	var d models.Device
+	err := r.db.GetContext(ctx, &d, `SELECT * FROM devices WHERE device_id = ?`, deviceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &d, err
+}
+
+func (r *adminRepository) UpdateDeviceStatus(ctx context.Context, deviceID int, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET status = ? WHERE device_id = ?`, status, deviceID)
+	return err
+}
+
+func (r *adminRepository) UpdateDeviceMode(ctx context.Context, deviceID int, mode int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET operating_mode = ? WHERE device_id = ?`, mode, deviceID)
+	return err
+}
+
+// ─── Fiscal Days ──────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListFiscalDays(ctx context.Context, taxpayerID *int64, deviceID *int, offset, limit int) (int, []models.FiscalDay, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+
+	if taxpayerID != nil {
+		where += " AND d.taxpayer_id = ?"
+		args = append(args, *taxpayerID)
+	}
+	if deviceID != nil {
+		where += " AND f.device_id = ?"
+		args = append(args, *deviceID)
+	}
+
+	joinQ := `FROM fiscal_days f JOIN devices d ON f.device_id = d.device_id ` + where
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+	var rows []models.FiscalDay
+	err := r.db.SelectContext(ctx, &rows,
+		"SELECT f.* "+joinQ+" ORDER BY julianday(f.fiscal_day_opened) DESC, f.id DESC LIMIT ? OFFSET ?",
+		args...)
+	return total, rows, err
+}
+
+// ─── Receipts ─────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListReceipts(ctx context.Context, taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (int, []models.AdminReceiptRow, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+
+	if taxpayerID != nil {
+		where += " AND d.taxpayer_id = ?"
+		args = append(args, *taxpayerID)
+	}
+	if deviceID != nil {
+		where += " AND r.device_id = ?"
+		args = append(args, *deviceID)
+	}
+	if from != nil {
+		where += " AND julianday(r.receipt_date) >= julianday(?)"
+		args = append(args, from.UTC())
+	}
+	if to != nil {
+		where += " AND julianday(r.receipt_date) <= julianday(?)"
+		args = append(args, to.UTC())
+	}
+
+	joinQ := `FROM receipts r JOIN devices d ON r.device_id = d.device_id ` + where
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) "+joinQ, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+
+	// Use explicit column list so validation_color is included (models.Receipt has json:"-" on it)
+	selectQ := `
+		SELECT r.id, r.receipt_id, r.device_id, r.receipt_type, r.receipt_currency,
+		       r.invoice_no, r.receipt_date, r.receipt_total, r.validation_color, r.server_date,
+		       r.username, r.user_name_surname
+		` + joinQ + ` ORDER BY julianday(r.receipt_date) DESC, r.id DESC LIMIT ? OFFSET ?`
+
+	var rows []models.AdminReceiptRow
+	err := r.db.SelectContext(ctx, &rows, selectQ, args...)
+	return total, rows, err
+}
+
+// ─── Audit Logs ───────────────────────────────────────────────────────────────
+
+func (r *adminRepository) ListAuditLogs(ctx context.Context, entityType string, entityID *int64, offset, limit int) (int, []models.AuditLog, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+
+	if entityType != "" {
+		where += " AND entity_type = ?"
+		args = append(args, entityType)
+	}
+	if entityID != nil {
+		where += " AND entity_id = ?"
+		args = append(args, *entityID)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM audit_logs "+where, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+	var rows []models.AuditLog
+	err := r.db.SelectContext(ctx, &rows,
+		"SELECT * FROM audit_logs "+where+" ORDER BY julianday(created_at) DESC, id DESC LIMIT ? OFFSET ?",
+		args...)
+	return total, rows, err
+}
+
+func (r *adminRepository) InsertAuditLog(ctx context.Context, entityType, action string, entityID, userID *int64, deviceID *int, ipAddress, details string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO audit_logs (entity_type, action, entity_id, user_id, device_id, ip_address, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entityType, action, entityID, userID, deviceID, ipAddress, details, now())
+	return err
+}
+
+// ─── System Stats ─────────────────────────────────────────────────────────────
+
+func (r *adminRepository) GetSystemStats(ctx context.Context) (*models.SystemStats, error) {
+	stats := &models.SystemStats{}
+
+	today := `julianday(receipt_date) >= julianday('now', 'start of day')`
+	queries := []struct {
+		dest  *int
+		query string
+	}{
+		{&stats.TotalCompanies, `SELECT COUNT(*) FROM taxpayers`},
+		{&stats.ActiveCompanies, `SELECT COUNT(*) FROM taxpayers WHERE status = 'Active'`},
+		{&stats.TotalDevices, `SELECT COUNT(*) FROM devices`},
+		{&stats.ActiveDevices, `SELECT COUNT(*) FROM devices WHERE status = 'Active'`},
+		{&stats.TodayReceipts, `SELECT COUNT(*) FROM receipts WHERE ` + today},
+		{&stats.OpenFiscalDays, `SELECT COUNT(*) FROM fiscal_days WHERE status = 1`},
+		{&stats.ValidationErrors, `SELECT COUNT(*) FROM receipts WHERE validation_color IS NOT NULL AND validation_color != '' AND ` + today},
+	}
+
+	for _, q := range queries {
+		if err := r.db.GetContext(ctx, q.dest, q.query); err != nil {
+			*q.dest = 0
+		}
+	}
+
+	// Today's revenue
+	r.db.GetContext(ctx, &stats.TodayRevenue,
+		`SELECT COALESCE(SUM(receipt_total), 0) FROM receipts WHERE `+today+` AND receipt_type = 0`)
+
+	return stats, nil
+}
+
+// ─── Users ────────────────────────────────────────────────────────────────────
+
+func (r *adminRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return createUser(ctx, r.db, user)
+}
+
+func (r *adminRepository) ListUsersByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.AdminUserRow, error) {
+	var rows []models.AdminUserRow
+	err := r.db.SelectContext(ctx, &rows,
+		`SELECT id, username, person_name, person_surname, user_role, email, phone_no, status, created_at
+		 FROM users WHERE taxpayer_id = ? ORDER BY julianday(created_at) DESC, id DESC`, taxpayerID)
+	return rows, err
+}
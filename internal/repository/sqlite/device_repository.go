@@ -0,0 +1,313 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type deviceRepository struct {
+	db dbtx
+}
+
+func NewDeviceRepository(db *sqlx.DB) repository.DeviceRepository {
+	return &deviceRepository{db: db}
+}
+
+func (r *deviceRepository) Create(ctx context.Context, device *models.Device) error {
+	return createDevice(ctx, r.db, device)
+}
+
+func (r *deviceRepository) GetByDeviceID(ctx context.Context, deviceID int) (*models.Device, error) {
+	var device models.Device
+	query := `SELECT * FROM devices WHERE device_id = ?`
+
+	err := r.db.GetContext(ctx, &device, query, deviceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+func (r *deviceRepository) GetBySerialNo(ctx context.Context, serialNo string) (*models.Device, error) {
+	var device models.Device
+	query := `SELECT * FROM devices WHERE device_serial_no = ?`
+
+	err := r.db.GetContext(ctx, &device, query, serialNo)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+func (r *deviceRepository) Update(ctx context.Context, device *models.Device) error {
+	query := `
+		UPDATE devices SET
+			device_serial_no = ?,
+			device_model_name = ?,
+			device_model_version = ?,
+			operating_mode = ?,
+			status = ?,
+			branch_name = ?,
+			branch_address = ?,
+			branch_contacts = ?
+		WHERE device_id = ?`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		device.DeviceSerialNo,
+		device.DeviceModelName,
+		device.DeviceModelVersion,
+		device.OperatingMode,
+		device.Status,
+		device.BranchName,
+		device.BranchAddress,
+		device.BranchContacts,
+		device.DeviceID,
+	)
+
+	return err
+}
+
+func (r *deviceRepository) UpdateCertificate(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+	query := `
+		UPDATE devices SET
+			certificate = ?,
+			certificate_thumbprint = ?,
+			certificate_valid_till = ?
+		WHERE device_id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, cert, thumbprint, validTill.UTC(), deviceID)
+	return err
+}
+
+func (r *deviceRepository) UpdateLastPing(ctx context.Context, deviceID int, lastPing time.Time) error {
+	query := `UPDATE devices SET updated_at = ? WHERE device_id = ?`
+	_, err := r.db.ExecContext(ctx, query, lastPing.UTC(), deviceID)
+	return err
+}
+
+func (r *deviceRepository) IsBlacklisted(ctx context.Context, modelName, modelVersion string) (bool, error) {
+	// No blacklist is kept, as in the PostgreSQL repository
+	return false, nil
+}
+
+func (r *deviceRepository) GetTaxpayer(ctx context.Context, taxpayerID int64) (*models.Taxpayer, error) {
+	var taxpayer models.Taxpayer
+	query := `SELECT * FROM taxpayers WHERE id = ?`
+
+	err := r.db.GetContext(ctx, &taxpayer, query, taxpayerID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &taxpayer, nil
+}
+
+func (r *deviceRepository) GetApplicableTaxes(ctx context.Context) ([]models.Tax, error) {
+	var taxes []models.Tax
+	query := `
+		SELECT tax_id, tax_name, tax_percent, tax_valid_from, tax_valid_till
+		FROM taxes
+		WHERE date(tax_valid_from) <= date('now')
+		  AND (tax_valid_till IS NULL OR date(tax_valid_till) >= date('now'))
+		ORDER BY tax_id`
+
+	err := r.db.SelectContext(ctx, &taxes, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return taxes, nil
+}
+
+func (r *deviceRepository) GetCurrentFiscalDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `
+		SELECT * FROM fiscal_days
+		WHERE device_id = ?
+		ORDER BY fiscal_day_no DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+func (r *deviceRepository) GetFiscalDayCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	return getCounters(ctx, r.db, fiscalDayID)
+}
+
+func (r *deviceRepository) GetFiscalDayDocumentQuantities(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error) {
+	var quantities []models.FiscalDayDocumentQuantity
+	query := `
+		SELECT
+			receipt_type,
+			receipt_currency,
+			COUNT(*) as receipt_quantity,
+			SUM(receipt_total) as receipt_total_amount
+		FROM receipts
+		WHERE fiscal_day_id = ?
+		GROUP BY receipt_type, receipt_currency
+		ORDER BY receipt_type, receipt_currency`
+
+	err := r.db.SelectContext(ctx, &quantities, query, fiscalDayID)
+	if err != nil {
+		return nil, err
+	}
+
+	return quantities, nil
+}
+
+func (r *deviceRepository) SaveCertificateHistory(ctx context.Context, deviceID int, cert string, thumbprint []byte, validTill time.Time) error {
+	query := `
+		INSERT INTO certificates_history (
+			device_id, certificate, certificate_thumbprint, issued_at, valid_till
+		) VALUES (?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query, deviceID, cert, thumbprint, now(), validTill.UTC())
+	return err
+}
+
+// stockSortColumns maps the accepted sort fields to columns. The PostgreSQL
+// repository interpolates the field as given; here unknown fields fall back to
+// the HS code.
+var stockSortColumns = map[string]string{
+	"hsCode":      "s.hs_code",
+	"hs_code":     "s.hs_code",
+	"s.hs_code":   "s.hs_code",
+	"goodName":    "s.good_name",
+	"good_name":   "s.good_name",
+	"s.good_name": "s.good_name",
+	"quantity":    "s.quantity",
+	"s.quantity":  "s.quantity",
+}
+
+func (r *deviceRepository) GetStockList(
+	ctx context.Context,
+	taxpayerID int64,
+	branchID int64,
+	hsCode *string,
+	goodName *string,
+	sort *string,
+	order *string,
+	offset int,
+	limit int,
+	operator *string,
+) (int, []models.Good, error) {
+	// Build query with filters
+	baseQuery := `
+		FROM stock s
+		INNER JOIN taxpayers t ON s.taxpayer_id = t.id
+		LEFT JOIN devices d ON s.branch_id = d.id
+		WHERE s.taxpayer_id = ?`
+
+	args := []interface{}{taxpayerID}
+
+	if hsCode != nil && *hsCode != "" {
+		baseQuery += " AND s.hs_code = ?"
+		args = append(args, *hsCode)
+	}
+
+	if goodName != nil && *goodName != "" {
+		// LIKE is case-insensitive for ASCII in SQLite, like ILIKE
+		baseQuery += " AND s.good_name LIKE ?"
+		args = append(args, "%"+*goodName+"%")
+	}
+
+	// Count total
+	var total int
+	err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) "+baseQuery, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Get data
+	selectQuery := `
+		SELECT
+			s.hs_code,
+			s.good_name,
+			s.quantity,
+			t.id as taxpayer_id,
+			t.name as taxpayer_name,
+			d.id as branch_id,
+			d.branch_name
+		` + baseQuery
+
+	sortField := "s.hs_code"
+	if sort != nil {
+		if column, ok := stockSortColumns[*sort]; ok {
+			sortField = column
+		}
+	}
+	sortOrder := "ASC"
+	if order != nil && strings.ToUpper(*order) == "DESC" {
+		sortOrder = "DESC"
+	}
+	selectQuery += " ORDER BY " + sortField + " " + sortOrder + " LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	var items []models.Good
+	err = r.db.SelectContext(ctx, &items, selectQuery, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return total, items, nil
+}
+
+func createDevice(ctx context.Context, db dbtx, device *models.Device) error {
+	query := `
+		INSERT INTO devices (
+			device_id, taxpayer_id, device_serial_no, device_model_name, device_model_version,
+			activation_key, operating_mode, status, branch_name, branch_address, branch_contacts,
+			created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, db,
+		query,
+		device.DeviceID,
+		device.TaxpayerID,
+		device.DeviceSerialNo,
+		device.DeviceModelName,
+		device.DeviceModelVersion,
+		device.ActivationKey,
+		device.OperatingMode,
+		device.Status,
+		device.BranchName,
+		device.BranchAddress,
+		device.BranchContacts,
+		createdAt,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	device.ID, device.CreatedAt, device.UpdatedAt = id, createdAt, createdAt
+	return nil
+}
@@ -0,0 +1,378 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type fiscalDayRepository struct {
+	db dbtx
+}
+
+func NewFiscalDayRepository(db *sqlx.DB) repository.FiscalDayRepository {
+	return &fiscalDayRepository{db: db}
+}
+
+func (r *fiscalDayRepository) Create(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	query := `
+		INSERT INTO fiscal_days (
+			device_id, fiscal_day_no, fiscal_day_opened, status, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, r.db,
+		query,
+		fiscalDay.DeviceID,
+		fiscalDay.FiscalDayNo,
+		fiscalDay.FiscalDayOpened.UTC(),
+		fiscalDay.Status,
+		createdAt,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	fiscalDay.ID, fiscalDay.CreatedAt, fiscalDay.UpdatedAt = id, createdAt, createdAt
+	return nil
+}
+
+func (r *fiscalDayRepository) GetByID(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `SELECT * FROM fiscal_days WHERE id = ?`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+// GetByIDForUpdate gets a fiscal day. SQLite has no row locks; inside a
+// transaction the database write lock is already held, so no receipt can be
+// added to the day until the transaction ends.
+func (r *fiscalDayRepository) GetByIDForUpdate(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *fiscalDayRepository) GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `
+		SELECT * FROM fiscal_days
+		WHERE device_id = ?
+		ORDER BY fiscal_day_no DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+func (r *fiscalDayRepository) GetByDayNo(ctx context.Context, deviceID, fiscalDayNo int) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `
+		SELECT * FROM fiscal_days
+		WHERE device_id = ? AND fiscal_day_no = ?`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID, fiscalDayNo)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+func (r *fiscalDayRepository) Update(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	query := `
+		UPDATE fiscal_days SET
+			fiscal_day_closed = ?,
+			status = ?,
+			reconciliation_mode = ?,
+			fiscal_day_device_signature = ?,
+			fiscal_day_server_signature = ?,
+			closing_error_code = ?,
+			last_receipt_global_no = ?,
+			closure = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		utcPtr(fiscalDay.FiscalDayClosed),
+		fiscalDay.Status,
+		fiscalDay.ReconciliationMode,
+		fiscalDay.FiscalDayDeviceSignature,
+		fiscalDay.FiscalDayServerSignature,
+		fiscalDay.ClosingErrorCode,
+		fiscalDay.LastReceiptGlobalNo,
+		fiscalDay.Closure,
+		fiscalDay.ID,
+	)
+
+	return err
+}
+
+func (r *fiscalDayRepository) UpdateStatus(ctx context.Context, id int64, status models.FiscalDayStatus) error {
+	query := `UPDATE fiscal_days SET status = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	return err
+}
+
+func (r *fiscalDayRepository) Close(ctx context.Context, id int64, closedAt time.Time, signature *models.SignatureData) error {
+	query := `
+		UPDATE fiscal_days SET
+			fiscal_day_closed = ?,
+			status = ?,
+			fiscal_day_device_signature = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, closedAt.UTC(), models.FiscalDayStatusCloseInitiated, signature, id)
+	return err
+}
+
+func (r *fiscalDayRepository) CreateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	// Replace existing counters in one transaction
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM fiscal_counters WHERE fiscal_day_id = ?`, fiscalDayID)
+		if err != nil {
+			return err
+		}
+
+		// Insert new counters (only non-zero values)
+		for _, counter := range counters {
+			if counter.FiscalCounterValue == 0 {
+				continue
+			}
+
+			query := `
+				INSERT INTO fiscal_counters (
+					fiscal_day_id, fiscal_counter_type, fiscal_counter_currency,
+					fiscal_counter_tax_id, fiscal_counter_tax_percent,
+					fiscal_counter_money_type, fiscal_counter_value
+				) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+			_, err := tx.ExecContext(ctx,
+				query,
+				fiscalDayID,
+				counter.FiscalCounterType,
+				counter.FiscalCounterCurrency,
+				counter.FiscalCounterTaxID,
+				counter.FiscalCounterTaxPercent,
+				counter.FiscalCounterMoneyType,
+				counter.FiscalCounterValue,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *fiscalDayRepository) GetCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	return getCounters(ctx, r.db, fiscalDayID)
+}
+
+// CalculateCounters aggregates the fiscal counters of a day from its stored receipts
+func (r *fiscalDayRepository) CalculateCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	counters := make([]models.FiscalDayCounter, 0)
+
+	byTax := []struct {
+		counterType models.FiscalCounterType
+		receiptType models.ReceiptType
+		column      string
+	}{
+		{models.FiscalCounterTypeSaleByTax, models.ReceiptTypeFiscalInvoice, "rt.sales_amount_with_tax"},
+		{models.FiscalCounterTypeSaleTaxByTax, models.ReceiptTypeFiscalInvoice, "rt.tax_amount"},
+		{models.FiscalCounterTypeCreditNoteByTax, models.ReceiptTypeCreditNote, "rt.sales_amount_with_tax"},
+		{models.FiscalCounterTypeDebitNoteByTax, models.ReceiptTypeDebitNote, "rt.sales_amount_with_tax"},
+	}
+	for _, c := range byTax {
+		query := `
+			SELECT
+				? as fiscal_counter_type,
+				rt.tax_id as fiscal_counter_tax_id,
+				rt.tax_percent as fiscal_counter_tax_percent,
+				r.receipt_currency as fiscal_counter_currency,
+				SUM(` + c.column + `) as fiscal_counter_value
+			FROM receipts r
+			JOIN receipt_taxes rt ON r.id = rt.receipt_id
+			WHERE r.fiscal_day_id = ?
+			  AND r.receipt_type = ?
+			GROUP BY rt.tax_id, rt.tax_percent, r.receipt_currency`
+
+		var rows []models.FiscalDayCounter
+		if err := r.db.SelectContext(ctx, &rows, query, c.counterType, fiscalDayID, c.receiptType); err != nil {
+			return nil, err
+		}
+		counters = append(counters, rows...)
+	}
+
+	balanceQuery := `
+		SELECT
+			? as fiscal_counter_type,
+			rp.money_type_code as fiscal_counter_money_type,
+			r.receipt_currency as fiscal_counter_currency,
+			SUM(rp.payment_amount) as fiscal_counter_value
+		FROM receipts r
+		JOIN receipt_payments rp ON r.id = rp.receipt_id
+		WHERE r.fiscal_day_id = ?
+		GROUP BY rp.money_type_code, r.receipt_currency`
+
+	var balanceCounters []models.FiscalDayCounter
+	err := r.db.SelectContext(ctx, &balanceCounters, balanceQuery, models.FiscalCounterTypeBalanceByMoneyType, fiscalDayID)
+	if err != nil {
+		return nil, err
+	}
+	counters = append(counters, balanceCounters...)
+
+	return counters, nil
+}
+
+func (r *fiscalDayRepository) UpdateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	return r.CreateCounters(ctx, fiscalDayID, counters)
+}
+
+func (r *fiscalDayRepository) ValidateCounters(ctx context.Context, fiscalDayID int64, submittedCounters []models.FiscalDayCounter) (bool, error) {
+	actualCounters, err := r.CalculateCounters(ctx, fiscalDayID)
+	if err != nil {
+		return false, err
+	}
+	return repository.CompareCounters(submittedCounters, actualCounters), nil
+}
+
+func (r *fiscalDayRepository) GetLastClosedDay(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	var fiscalDay models.FiscalDay
+	query := `
+		SELECT * FROM fiscal_days
+		WHERE device_id = ?
+		  AND status = ?
+		ORDER BY fiscal_day_no DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &fiscalDay, query, deviceID, models.FiscalDayStatusClosed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fiscalDay, nil
+}
+
+// ListExceedingMaxHours returns open or close-failed days that have been open
+// longer than their taxpayer's TaxPayerDayMaxHrs at the given time
+func (r *fiscalDayRepository) ListExceedingMaxHours(ctx context.Context, at time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	query := `
+		SELECT f.* FROM fiscal_days f
+		JOIN devices d ON f.device_id = d.device_id
+		JOIN taxpayers t ON d.taxpayer_id = t.id
+		WHERE f.status IN (?, ?)
+		  AND julianday(f.fiscal_day_opened) + t.taxpayer_day_max_hrs / 24.0 < julianday(?)
+		ORDER BY julianday(f.fiscal_day_opened)`
+
+	err := r.db.SelectContext(ctx, &fiscalDays, query,
+		models.FiscalDayStatusOpened, models.FiscalDayStatusCloseFailed, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return fiscalDays, nil
+}
+
+// ListApproachingMaxHours returns open days that are within their taxpayer's
+// TaxpayerDayEndNotificationHrs of TaxPayerDayMaxHrs and have not yet been
+// reminded for that threshold
+func (r *fiscalDayRepository) ListApproachingMaxHours(ctx context.Context, at time.Time) ([]models.FiscalDay, error) {
+	var fiscalDays []models.FiscalDay
+	query := `
+		SELECT f.* FROM fiscal_days f
+		JOIN devices d ON f.device_id = d.device_id
+		JOIN taxpayers t ON d.taxpayer_id = t.id
+		WHERE f.status = ?
+		  AND t.taxpayer_day_end_notification_hrs > 0
+		  AND julianday(f.fiscal_day_opened) + (t.taxpayer_day_max_hrs - t.taxpayer_day_end_notification_hrs) / 24.0 <= julianday(?)
+		  AND julianday(f.fiscal_day_opened) + t.taxpayer_day_max_hrs / 24.0 > julianday(?)
+		  AND NOT EXISTS (
+			SELECT 1 FROM fiscal_day_notifications n
+			WHERE n.fiscal_day_id = f.id
+			  AND n.threshold_hrs = t.taxpayer_day_end_notification_hrs
+		  )
+		ORDER BY julianday(f.fiscal_day_opened)`
+
+	err := r.db.SelectContext(ctx, &fiscalDays, query, models.FiscalDayStatusOpened, at.UTC(), at.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return fiscalDays, nil
+}
+
+// MarkEndNotificationSent records a reminder for the given threshold. It
+// returns false if one was already recorded.
+func (r *fiscalDayRepository) MarkEndNotificationSent(ctx context.Context, fiscalDayID int64, thresholdHrs int) (bool, error) {
+	query := `
+		INSERT INTO fiscal_day_notifications (fiscal_day_id, threshold_hrs, sent_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (fiscal_day_id, threshold_hrs) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, fiscalDayID, thresholdHrs, now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func getCounters(ctx context.Context, db dbtx, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	var counters []models.FiscalDayCounter
+	query := `
+		SELECT
+			fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id,
+			fiscal_counter_tax_percent, fiscal_counter_money_type, fiscal_counter_value
+		FROM fiscal_counters
+		WHERE fiscal_day_id = ?
+		  AND fiscal_counter_value != 0
+		ORDER BY fiscal_counter_type, fiscal_counter_currency, fiscal_counter_tax_id`
+
+	err := db.SelectContext(ctx, &counters, query, fiscalDayID)
+	if err != nil {
+		return nil, err
+	}
+
+	return counters, nil
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
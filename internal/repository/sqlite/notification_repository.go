@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type notificationRepository struct {
+	db dbtx
+}
+
+func NewNotificationRepository(db *sqlx.DB) repository.NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Enqueue(ctx context.Context, n *models.Notification) error {
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = time.Now()
+	}
+	n.Status = models.NotificationStatusPending
+
+	query := `
+		INSERT INTO notification_outbox (
+			channel, recipient, kind, payload, status, next_attempt_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, r.db, query,
+		n.Channel, n.Recipient, n.Kind, n.Payload, n.Status, n.NextAttemptAt.UTC(), createdAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	n.ID, n.CreatedAt, n.UpdatedAt = id, createdAt, createdAt
+	return nil
+}
+
+func (r *notificationRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error) {
+	// A single statement, so no other worker can claim the same rows
+	query := `
+		UPDATE notification_outbox SET
+			status = 'sending',
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status IN ('pending', 'sending') AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+		)
+		RETURNING *`
+
+	var notifications []models.Notification
+	err := r.db.SelectContext(ctx, &notifications, query, leaseUntil.UTC(), now.UTC(), now.UTC(), limit)
+	return notifications, err
+}
+
+func (r *notificationRepository) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE notification_outbox SET
+			status = 'sent',
+			attempts = attempts + 1,
+			sent_at = ?,
+			updated_at = ?
+		WHERE id = ?`
+
+	sentAt := now()
+	_, err := r.db.ExecContext(ctx, query, sentAt, sentAt, id)
+	return err
+}
+
+func (r *notificationRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	status := models.NotificationStatusDead
+	nextAttemptAt := now()
+	if retryAt != nil {
+		status = models.NotificationStatusPending
+		nextAttemptAt = retryAt.UTC()
+	}
+
+	query := `
+		UPDATE notification_outbox SET
+			status = ?,
+			attempts = attempts + 1,
+			last_error = ?,
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, status, lastError, nextAttemptAt, now(), id)
+	return err
+}
+
+func (r *notificationRepository) GetByID(ctx context.Context, id int64) (*models.Notification, error) {
+	var n models.Notification
+	err := r.db.GetContext(ctx, &n, `SELECT * FROM notification_outbox WHERE id = ?`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (r *notificationRepository) List(ctx context.Context, status models.NotificationStatus, offset, limit int) (int, []models.Notification, error) {
+	where := "WHERE 1=1"
+	args := []interface{}{}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM notification_outbox "+where, args...); err != nil {
+		return 0, nil, err
+	}
+
+	args = append(args, limit, offset)
+	var rows []models.Notification
+	err := r.db.SelectContext(ctx, &rows,
+		"SELECT * FROM notification_outbox "+where+" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", args...)
+	return total, rows, err
+}
+
+func (r *notificationRepository) Requeue(ctx context.Context, id int64) (bool, error) {
+	query := `
+		UPDATE notification_outbox SET
+			status = 'pending',
+			attempts = 0,
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id = ? AND status = 'dead'`
+
+	requeuedAt := now()
+	result, err := r.db.ExecContext(ctx, query, requeuedAt, requeuedAt, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
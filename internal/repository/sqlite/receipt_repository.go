@@ -0,0 +1,429 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type receiptRepository struct {
+	db dbtx
+}
+
+func NewReceiptRepository(db *sqlx.DB) repository.ReceiptRepository {
+	return &receiptRepository{db: db}
+}
+
+func (r *receiptRepository) Create(ctx context.Context, receipt *models.Receipt) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		return r.insert(ctx, tx, receipt)
+	})
+}
+
+func (r *receiptRepository) CreateWithLines(ctx context.Context, receipt *models.Receipt) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		return r.insertWithLines(ctx, tx, receipt)
+	})
+}
+
+// CreateChained stores a receipt and advances the fiscal day's last receipt
+// number in one transaction, with the same checks as the PostgreSQL
+// repository. The transaction holds the database write lock from its start,
+// so submissions for the same day are serialised.
+func (r *receiptRepository) CreateChained(ctx context.Context, receipt *models.Receipt, previousGlobalNo *int, sign repository.ReceiptSigner) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		var status models.FiscalDayStatus
+		err := tx.GetContext(ctx, &status, `SELECT status FROM fiscal_days WHERE id = ?`, receipt.FiscalDayID)
+		if err == sql.ErrNoRows {
+			return repository.ErrFiscalDayNotOpen
+		}
+		if err != nil {
+			return err
+		}
+		if status != models.FiscalDayStatusOpened && status != models.FiscalDayStatusCloseFailed {
+			return repository.ErrFiscalDayNotOpen
+		}
+
+		var exists bool
+		err = tx.GetContext(ctx, &exists,
+			`SELECT EXISTS(SELECT 1 FROM receipts WHERE device_id = ? AND receipt_global_no = ?)`,
+			receipt.DeviceID, receipt.ReceiptGlobalNo)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return repository.ErrDuplicateReceipt
+		}
+
+		var latest sql.NullInt64
+		err = tx.GetContext(ctx, &latest, `
+			SELECT MAX(receipt_global_no) FROM receipts
+			WHERE fiscal_day_id = ? AND receipt_global_no < ?`,
+			receipt.FiscalDayID, receipt.ReceiptGlobalNo)
+		if err != nil {
+			return err
+		}
+		if latest.Valid != (previousGlobalNo != nil) || (latest.Valid && int(latest.Int64) != *previousGlobalNo) {
+			return repository.ErrReceiptChainChanged
+		}
+
+		if err := r.insertWithLines(ctx, tx, receipt); err != nil {
+			return err
+		}
+
+		if sign != nil {
+			if err := sign(receipt); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx,
+				`UPDATE receipts SET receipt_server_signature = ? WHERE id = ?`,
+				receipt.ReceiptServerSignature, receipt.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE fiscal_days
+			SET last_receipt_global_no = MAX(COALESCE(last_receipt_global_no, 0), ?)
+			WHERE id = ?`,
+			receipt.ReceiptGlobalNo, receipt.FiscalDayID)
+		return err
+	})
+}
+
+// insert stores the receipt row. receipt_id is allocated from the highest one
+// in use, which is safe because the caller holds the write lock.
+func (r *receiptRepository) insert(ctx context.Context, tx *sqlx.Tx, receipt *models.Receipt) error {
+	var receiptID int64
+	if err := tx.GetContext(ctx, &receiptID, `SELECT COALESCE(MAX(receipt_id), 0) + 1 FROM receipts`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO receipts (
+			receipt_id, device_id, fiscal_day_id, receipt_type, receipt_currency, receipt_counter,
+			receipt_global_no, invoice_no, buyer_data, receipt_notes, receipt_date,
+			credit_debit_note, receipt_lines_tax_inclusive, receipt_total,
+			receipt_print_form, receipt_device_signature, receipt_hash,
+			username, user_name_surname, receipt_server_signature,
+			validation_color, validation_errors, server_date, created_at, updated_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)`
+
+	createdAt := now()
+	id, err := insert(ctx, tx,
+		query,
+		receiptID,
+		receipt.DeviceID,
+		receipt.FiscalDayID,
+		receipt.ReceiptType,
+		receipt.ReceiptCurrency,
+		receipt.ReceiptCounter,
+		receipt.ReceiptGlobalNo,
+		receipt.InvoiceNo,
+		receipt.BuyerData,
+		receipt.ReceiptNotes,
+		receipt.ReceiptDate.UTC(),
+		receipt.CreditDebitNote,
+		receipt.ReceiptLinesTaxInclusive,
+		receipt.ReceiptTotal,
+		receipt.ReceiptPrintForm,
+		receipt.ReceiptDeviceSignature,
+		receipt.ReceiptHash,
+		receipt.Username,
+		receipt.UserNameSurname,
+		receipt.ReceiptServerSignature,
+		receipt.ValidationColor,
+		validationErrors(receipt.ValidationErrors),
+		utcPtr(receipt.ServerDate),
+		createdAt,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	receipt.ID, receipt.ReceiptID = id, receiptID
+	receipt.CreatedAt, receipt.UpdatedAt = createdAt, createdAt
+	return nil
+}
+
+func (r *receiptRepository) insertWithLines(ctx context.Context, tx *sqlx.Tx, receipt *models.Receipt) error {
+	if err := r.insert(ctx, tx, receipt); err != nil {
+		return err
+	}
+	if err := createReceiptLines(ctx, tx, receipt.ID, receipt.ReceiptLines); err != nil {
+		return err
+	}
+	if err := createReceiptTaxes(ctx, tx, receipt.ID, receipt.ReceiptTaxes); err != nil {
+		return err
+	}
+	return createReceiptPayments(ctx, tx, receipt.ID, receipt.ReceiptPayments)
+}
+
+func (r *receiptRepository) GetByID(ctx context.Context, id int64) (*models.Receipt, error) {
+	return r.get(ctx, `SELECT * FROM receipts WHERE id = ?`, id)
+}
+
+func (r *receiptRepository) GetByReceiptID(ctx context.Context, receiptID int64) (*models.Receipt, error) {
+	return r.get(ctx, `SELECT * FROM receipts WHERE receipt_id = ?`, receiptID)
+}
+
+func (r *receiptRepository) GetByGlobalNo(ctx context.Context, deviceID, globalNo int) (*models.Receipt, error) {
+	return r.get(ctx, `SELECT * FROM receipts WHERE device_id = ? AND receipt_global_no = ?`, deviceID, globalNo)
+}
+
+func (r *receiptRepository) GetPreviousReceipt(ctx context.Context, deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error) {
+	query := `
+		SELECT * FROM receipts
+		WHERE device_id = ? AND fiscal_day_id = ? AND receipt_global_no < ?
+		ORDER BY receipt_global_no DESC
+		LIMIT 1`
+
+	return r.get(ctx, query, deviceID, fiscalDayID, globalNo)
+}
+
+func (r *receiptRepository) Update(ctx context.Context, receipt *models.Receipt) error {
+	query := `
+		UPDATE receipts SET
+			receipt_server_signature = ?,
+			server_date = ?,
+			validation_color = ?,
+			validation_errors = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		receipt.ReceiptServerSignature,
+		utcPtr(receipt.ServerDate),
+		receipt.ValidationColor,
+		validationErrors(receipt.ValidationErrors),
+		receipt.ID,
+	)
+	return err
+}
+
+func (r *receiptRepository) UpdateValidation(ctx context.Context, receiptID int64, color *models.ValidationColor, errors []string) error {
+	query := `
+		UPDATE receipts SET
+			validation_color = ?,
+			validation_errors = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, color, validationErrors(errors), receiptID)
+	return err
+}
+
+func (r *receiptRepository) CreateReceiptLines(ctx context.Context, receiptID int64, lines []models.ReceiptLine) error {
+	return createReceiptLines(ctx, r.db, receiptID, lines)
+}
+
+func (r *receiptRepository) GetReceiptLines(ctx context.Context, receiptID int64) ([]models.ReceiptLine, error) {
+	var lines []models.ReceiptLine
+	query := `SELECT * FROM receipt_lines WHERE receipt_id = ? ORDER BY receipt_line_no`
+
+	err := r.db.SelectContext(ctx, &lines, query, receiptID)
+	return lines, err
+}
+
+func (r *receiptRepository) CreateReceiptTaxes(ctx context.Context, receiptID int64, taxes []models.ReceiptTax) error {
+	return createReceiptTaxes(ctx, r.db, receiptID, taxes)
+}
+
+func (r *receiptRepository) GetReceiptTaxes(ctx context.Context, receiptID int64) ([]models.ReceiptTax, error) {
+	var taxes []models.ReceiptTax
+	query := `SELECT * FROM receipt_taxes WHERE receipt_id = ? ORDER BY tax_id`
+
+	err := r.db.SelectContext(ctx, &taxes, query, receiptID)
+	return taxes, err
+}
+
+func (r *receiptRepository) CreateReceiptPayments(ctx context.Context, receiptID int64, payments []models.Payment) error {
+	return createReceiptPayments(ctx, r.db, receiptID, payments)
+}
+
+func (r *receiptRepository) GetReceiptPayments(ctx context.Context, receiptID int64) ([]models.Payment, error) {
+	var payments []models.Payment
+	query := `SELECT * FROM receipt_payments WHERE receipt_id = ? ORDER BY id`
+
+	err := r.db.SelectContext(ctx, &payments, query, receiptID)
+	return payments, err
+}
+
+func (r *receiptRepository) CheckInvoiceNoUnique(ctx context.Context, taxpayerID int64, invoiceNo string) (bool, error) {
+	var count int
+	query := `
+		SELECT COUNT(*)
+		FROM receipts r
+		JOIN devices d ON r.device_id = d.device_id
+		WHERE d.taxpayer_id = ? AND r.invoice_no = ?`
+
+	err := r.db.GetContext(ctx, &count, query, taxpayerID, invoiceNo)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
+
+func (r *receiptRepository) GetMissingReceipts(ctx context.Context, deviceID int, fiscalDayID int64) ([]int, error) {
+	// Find gaps in receipt_global_no sequence
+	query := `
+		WITH receipt_sequence AS (
+			SELECT
+				receipt_global_no,
+				LAG(receipt_global_no) OVER (ORDER BY receipt_global_no) as prev_no
+			FROM receipts
+			WHERE device_id = ? AND fiscal_day_id = ?
+		)
+		SELECT prev_no + 1 as missing_no
+		FROM receipt_sequence
+		WHERE receipt_global_no - prev_no > 1
+		ORDER BY receipt_global_no`
+
+	var missing []int
+	err := r.db.SelectContext(ctx, &missing, query, deviceID, fiscalDayID)
+	return missing, err
+}
+
+func (r *receiptRepository) GetReceiptsWithValidationErrors(ctx context.Context, fiscalDayID int64) ([]models.Receipt, error) {
+	var receipts []models.Receipt
+	query := `
+		SELECT * FROM receipts
+		WHERE fiscal_day_id = ?
+		  AND (validation_color = 'Red' OR validation_color = 'Grey')
+		ORDER BY receipt_global_no`
+
+	err := r.db.SelectContext(ctx, &receipts, query, fiscalDayID)
+	return receipts, err
+}
+
+func (r *receiptRepository) GetCreditDebitNotes(ctx context.Context, originalReceiptID int64) ([]*models.Receipt, []*models.Receipt, error) {
+	// credit_debit_note is stored as JSON text, possibly bound as a blob
+	query := `
+		SELECT * FROM receipts
+		WHERE receipt_type = ?
+		  AND json_extract(CAST(credit_debit_note AS TEXT), '$.receiptID') = ?
+		ORDER BY julianday(receipt_date)`
+
+	var creditNotes []*models.Receipt
+	err := r.db.SelectContext(ctx, &creditNotes, query, models.ReceiptTypeCreditNote, originalReceiptID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var debitNotes []*models.Receipt
+	err = r.db.SelectContext(ctx, &debitNotes, query, models.ReceiptTypeDebitNote, originalReceiptID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return creditNotes, debitNotes, nil
+}
+
+// get loads a single receipt with its lines, taxes and payments
+func (r *receiptRepository) get(ctx context.Context, query string, args ...interface{}) (*models.Receipt, error) {
+	var receipt models.Receipt
+	err := r.db.GetContext(ctx, &receipt, query, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt.ReceiptLines, err = r.GetReceiptLines(ctx, receipt.ID); err != nil {
+		return nil, err
+	}
+	if receipt.ReceiptTaxes, err = r.GetReceiptTaxes(ctx, receipt.ID); err != nil {
+		return nil, err
+	}
+	if receipt.ReceiptPayments, err = r.GetReceiptPayments(ctx, receipt.ID); err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+func createReceiptLines(ctx context.Context, db dbtx, receiptID int64, lines []models.ReceiptLine) error {
+	for _, line := range lines {
+		query := `
+			INSERT INTO receipt_lines (
+				receipt_id, receipt_line_type, receipt_line_no, receipt_line_hs_code,
+				receipt_line_name, receipt_line_price, receipt_line_quantity,
+				receipt_line_total, tax_code, tax_percent, tax_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+		_, err := db.ExecContext(ctx,
+			query,
+			receiptID,
+			line.ReceiptLineType,
+			line.ReceiptLineNo,
+			line.ReceiptLineHSCode,
+			line.ReceiptLineName,
+			line.ReceiptLinePrice,
+			line.ReceiptLineQuantity,
+			line.ReceiptLineTotal,
+			line.TaxCode,
+			line.TaxPercent,
+			line.TaxID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createReceiptTaxes(ctx context.Context, db dbtx, receiptID int64, taxes []models.ReceiptTax) error {
+	for _, tax := range taxes {
+		query := `
+			INSERT INTO receipt_taxes (
+				receipt_id, tax_code, tax_percent, tax_id, tax_amount, sales_amount_with_tax
+			) VALUES (?, ?, ?, ?, ?, ?)`
+
+		_, err := db.ExecContext(ctx,
+			query,
+			receiptID,
+			tax.TaxCode,
+			tax.TaxPercent,
+			tax.TaxID,
+			tax.TaxAmount,
+			tax.SalesAmountWithTax,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createReceiptPayments(ctx context.Context, db dbtx, receiptID int64, payments []models.Payment) error {
+	for _, payment := range payments {
+		query := `
+			INSERT INTO receipt_payments (receipt_id, money_type_code, payment_amount)
+			VALUES (?, ?, ?)`
+
+		_, err := db.ExecContext(ctx, query, receiptID, payment.MoneyTypeCode, payment.PaymentAmount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validationErrors stores the errors in PostgreSQL array literal form, which
+// pq.StringArray reads back from text
+func validationErrors(errors []string) interface{} {
+	if errors == nil {
+		return nil
+	}
+	return pq.StringArray(errors)
+}
@@ -0,0 +1,428 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/config"
+	"fiscalization-api/internal/database"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/repositorytest"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// openTestDB creates a database file with the embedded SQLite migrations applied
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_foreign_keys=on&_txlock=immediate"
+	db, err := sqlx.Connect("sqlite3", dsn)
+	if err != nil {
+		t.Skipf("sqlite3 unavailable: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db, config.DatabaseConfig{Driver: config.DatabaseDriverSQLite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	return db
+}
+
+func seedDevice(t *testing.T, repos repository.Repositories) (*models.Device, *models.FiscalDay) {
+	t.Helper()
+	ctx := context.Background()
+
+	tp := &models.Taxpayer{TIN: "2000000001", Name: "Test Retail", Status: "Active", TaxPayerDayMaxHrs: 24, QrURL: "https://example.com"}
+	if err := repos.Admin.CreateTaxpayer(ctx, tp); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+
+	device := &models.Device{DeviceID: 1001, TaxpayerID: tp.ID, DeviceSerialNo: "SN-0001", DeviceModelName: "Model",
+		DeviceModelVersion: "1.0", ActivationKey: "ABCD1234", Status: "Active", BranchName: "Main"}
+	if err := repos.Admin.CreateDevice(ctx, device); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	day := &models.FiscalDay{DeviceID: device.DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now(), Status: models.FiscalDayStatusOpened}
+	if err := repos.FiscalDays.Create(ctx, day); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return device, day
+}
+
+func TestReceiptRepository_CreateChained(t *testing.T) {
+	ctx := context.Background()
+	repos := NewRepositories(openTestDB(t))
+	device, day := seedDevice(t, repos)
+
+	standard := 15.0
+	newReceipt := func(globalNo int, receiptType models.ReceiptType, total float64) *models.Receipt {
+		return &models.Receipt{
+			DeviceID: device.DeviceID, FiscalDayID: day.ID, ReceiptType: receiptType, ReceiptCurrency: "USD",
+			ReceiptCounter: globalNo, ReceiptGlobalNo: globalNo, InvoiceNo: "INV", ReceiptDate: time.Now(), ReceiptTotal: total,
+			ReceiptLines:    []models.ReceiptLine{{ReceiptLineNo: 1, ReceiptLineName: "Item", ReceiptLineQuantity: 1, ReceiptLineTotal: total, TaxID: 3}},
+			ReceiptTaxes:    []models.ReceiptTax{{TaxID: 3, TaxPercent: &standard, TaxAmount: total * 15 / 115, SalesAmountWithTax: total}},
+			ReceiptPayments: []models.Payment{{MoneyTypeCode: 0, PaymentAmount: total}},
+		}
+	}
+
+	first := newReceipt(1, models.ReceiptTypeFiscalInvoice, 115)
+	if err := repos.Receipts.CreateChained(ctx, first, nil, nil); err != nil {
+		t.Fatalf("CreateChained() error = %v", err)
+	}
+
+	previous := 1
+	note := newReceipt(2, models.ReceiptTypeCreditNote, -23)
+	note.CreditDebitNote = &models.CreditDebitNote{ReceiptID: &first.ReceiptID}
+	note.ValidationErrors = []string{"RCPT020"}
+
+	tests := []struct {
+		name     string
+		receipt  *models.Receipt
+		previous *int
+		wantErr  error
+	}{
+		{"duplicate global number", newReceipt(1, models.ReceiptTypeFiscalInvoice, 10), nil, repository.ErrDuplicateReceipt},
+		{"stale previous receipt", newReceipt(2, models.ReceiptTypeFiscalInvoice, 10), nil, repository.ErrReceiptChainChanged},
+		{"next in chain", note, &previous, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repos.Receipts.CreateChained(ctx, tt.receipt, tt.previous, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateChained() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	stored, err := repos.Receipts.GetByGlobalNo(ctx, device.DeviceID, 2)
+	if err != nil || stored == nil {
+		t.Fatalf("GetByGlobalNo() = %v, %v", stored, err)
+	}
+	if stored.ReceiptID != first.ReceiptID+1 || len(stored.ReceiptLines) != 1 || len(stored.ValidationErrors) != 1 {
+		t.Errorf("stored receipt = %+v", stored)
+	}
+
+	credits, _, err := repos.Receipts.GetCreditDebitNotes(ctx, first.ReceiptID)
+	if err != nil || len(credits) != 1 {
+		t.Errorf("GetCreditDebitNotes() = %d credit notes, %v, want 1", len(credits), err)
+	}
+
+	current, err := repos.FiscalDays.GetByID(ctx, day.ID)
+	if err != nil || current.LastReceiptGlobalNo == nil || *current.LastReceiptGlobalNo != 2 {
+		t.Errorf("last receipt global no = %v, %v, want 2", current, err)
+	}
+
+	counters, err := repos.FiscalDays.CalculateCounters(ctx, day.ID)
+	if err != nil {
+		t.Fatalf("CalculateCounters() error = %v", err)
+	}
+	got := make(map[models.FiscalCounterType]float64)
+	for _, counter := range counters {
+		got[models.FiscalCounterType(counter.FiscalCounterType)] += counter.FiscalCounterValue
+	}
+	if got[models.FiscalCounterTypeSaleByTax] != 115 || got[models.FiscalCounterTypeCreditNoteByTax] != -23 ||
+		got[models.FiscalCounterTypeBalanceByMoneyType] != 92 {
+		t.Errorf("counters = %v", got)
+	}
+}
+
+func TestReceiptRepository_CreateChainedSigning(t *testing.T) {
+	ctx := context.Background()
+	repos := NewRepositories(openTestDB(t))
+	device, day := seedDevice(t, repos)
+
+	newReceipt := func() *models.Receipt {
+		return &models.Receipt{DeviceID: device.DeviceID, FiscalDayID: day.ID, ReceiptType: models.ReceiptTypeFiscalInvoice,
+			ReceiptCurrency: "USD", ReceiptCounter: 1, ReceiptGlobalNo: 1, InvoiceNo: "INV", ReceiptDate: time.Now(), ReceiptTotal: 10}
+	}
+
+	errSign := errors.New("signing failed")
+	err := repos.Receipts.CreateChained(ctx, newReceipt(), nil, func(*models.Receipt) error { return errSign })
+	if !errors.Is(err, errSign) {
+		t.Fatalf("CreateChained() error = %v, want %v", err, errSign)
+	}
+	if stored, err := repos.Receipts.GetByGlobalNo(ctx, device.DeviceID, 1); err != nil || stored != nil {
+		t.Fatalf("GetByGlobalNo() = %v, %v, want nothing stored after a signing failure", stored, err)
+	}
+
+	sign := func(receipt *models.Receipt) error {
+		receipt.ReceiptServerSignature = &models.SignatureDataEx{SignatureData: models.SignatureData{Signature: []byte("server")}}
+		return nil
+	}
+	if err := repos.Receipts.CreateChained(ctx, newReceipt(), nil, sign); err != nil {
+		t.Fatalf("CreateChained() retry error = %v", err)
+	}
+	stored, err := repos.Receipts.GetByGlobalNo(ctx, device.DeviceID, 1)
+	if err != nil || stored == nil || stored.ReceiptServerSignature == nil || string(stored.ReceiptServerSignature.Signature) != "server" {
+		t.Errorf("GetByGlobalNo() = %+v, %v, want the signed receipt", stored, err)
+	}
+}
+
+func TestFiscalDayRepository_Deadlines(t *testing.T) {
+	repositorytest.FiscalDayDeadlines(t, func(t *testing.T) repository.Repositories {
+		return NewRepositories(openTestDB(t))
+	})
+}
+
+func TestFiscalDayRepository_Closure(t *testing.T) {
+	repositorytest.FiscalDayClosure(t, func(t *testing.T) repository.Repositories {
+		return NewRepositories(openTestDB(t))
+	})
+}
+
+func TestAdminRepository_InsertAuditLog(t *testing.T) {
+	ctx := context.Background()
+	repos := NewRepositories(openTestDB(t))
+	device, day := seedDevice(t, repos)
+	userID := int64(7)
+
+	if err := repos.Admin.InsertAuditLog(ctx, "fiscal_day", "close", &day.ID, &userID, &device.DeviceID, "10.0.0.5", `{"fiscalDayNo":1}`); err != nil {
+		t.Fatalf("InsertAuditLog() error = %v", err)
+	}
+	if err := repos.Admin.InsertAuditLog(ctx, "fiscal_day", "auto_close", &day.ID, nil, &device.DeviceID, "system", `{}`); err != nil {
+		t.Fatalf("InsertAuditLog() error = %v", err)
+	}
+
+	total, logs, err := repos.Admin.ListAuditLogs(ctx, "fiscal_day", &day.ID, 0, 10)
+	if err != nil || total != 2 {
+		t.Fatalf("ListAuditLogs() = %d, %v, want 2 entries", total, err)
+	}
+	for _, entry := range logs {
+		switch entry.Action {
+		case "close":
+			if entry.UserID == nil || *entry.UserID != userID {
+				t.Errorf("close entry UserID = %v, want %d", entry.UserID, userID)
+			}
+		case "auto_close":
+			if entry.UserID != nil {
+				t.Errorf("auto_close entry UserID = %d, want none", *entry.UserID)
+			}
+		}
+	}
+}
+
+func TestTxManager_RollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repos := NewRepositories(db)
+	_, day := seedDevice(t, repos)
+
+	errAbort := errors.New("abort")
+	err := NewTxManager(db).WithinTx(ctx, func(tx repository.Repositories) error {
+		if err := tx.FiscalDays.UpdateStatus(ctx, day.ID, models.FiscalDayStatusClosed); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx() error = %v, want %v", err, errAbort)
+	}
+
+	stored, err := repos.FiscalDays.GetByID(ctx, day.ID)
+	if err != nil || stored.Status != models.FiscalDayStatusOpened {
+		t.Errorf("status after rollback = %v, %v, want opened", stored, err)
+	}
+}
+
+func TestUserRepository_SecurityCodes(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repos := NewRepositories(db)
+	device, _ := seedDevice(t, repos)
+
+	user := &models.User{TaxpayerID: device.TaxpayerID, Username: "cashier", PasswordHash: "x", PersonName: "Tendai",
+		PersonSurname: "Moyo", UserRole: "Cashier", Email: "cashier@example.com", Status: models.UserStatusActive}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	reset := models.SecurityCodePurposeResetPassword
+	code := &models.SecurityCode{UserID: user.ID, Purpose: reset, CodeHash: "hash", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := repos.Users.SaveSecurityCode(ctx, code); err != nil {
+		t.Fatalf("SaveSecurityCode() error = %v", err)
+	}
+	if claimed, err := repos.Users.ClaimSecurityCodeAttempt(ctx, user.ID, reset, 2); err != nil || claimed == nil || claimed.Attempts != 1 {
+		t.Fatalf("ClaimSecurityCodeAttempt() = %+v, %v, want the code with 1 attempt", claimed, err)
+	}
+
+	saved, err := repos.Users.GetSecurityCode(ctx, user.ID, reset)
+	if err != nil || saved == nil || saved.CodeHash != "hash" || saved.Attempts != 1 {
+		t.Fatalf("GetSecurityCode() = %+v, %v, want hash with 1 attempt", saved, err)
+	}
+	if other, _ := repos.Users.GetSecurityCode(ctx, user.ID, models.SecurityCodePurposeCreateUser); other != nil {
+		t.Error("code was returned for another purpose")
+	}
+
+	// The attempt past the limit is refused and not counted
+	if claimed, _ := repos.Users.ClaimSecurityCodeAttempt(ctx, user.ID, reset, 2); claimed == nil || claimed.Attempts != 2 {
+		t.Fatalf("second ClaimSecurityCodeAttempt() = %+v, want the code with 2 attempts", claimed)
+	}
+	if claimed, err := repos.Users.ClaimSecurityCodeAttempt(ctx, user.ID, reset, 2); err != nil || claimed != nil {
+		t.Errorf("ClaimSecurityCodeAttempt() past the limit = %+v, %v, want nil", claimed, err)
+	}
+	if saved, _ := repos.Users.GetSecurityCode(ctx, user.ID, reset); saved == nil || saved.Attempts != 2 {
+		t.Errorf("GetSecurityCode() after the limit = %+v, want 2 attempts", saved)
+	}
+
+	// Saving again replaces the code and resets its attempts
+	code.CodeHash = "new"
+	if err := repos.Users.SaveSecurityCode(ctx, code); err != nil {
+		t.Fatalf("SaveSecurityCode() error = %v", err)
+	}
+	if saved, _ := repos.Users.GetSecurityCode(ctx, user.ID, reset); saved == nil || saved.CodeHash != "new" || saved.Attempts != 0 {
+		t.Errorf("GetSecurityCode() after replace = %+v", saved)
+	}
+
+	code.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := repos.Users.SaveSecurityCode(ctx, code); err != nil {
+		t.Fatalf("SaveSecurityCode() error = %v", err)
+	}
+	if saved, _ := repos.Users.GetSecurityCode(ctx, user.ID, reset); saved != nil {
+		t.Error("expired code was returned")
+	}
+	if claimed, _ := repos.Users.ClaimSecurityCodeAttempt(ctx, user.ID, reset, 2); claimed != nil {
+		t.Error("attempt at an expired code was counted")
+	}
+}
+
+func TestUserRepository_Sessions(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repos := NewRepositories(db)
+	device, _ := seedDevice(t, repos)
+
+	user := &models.User{TaxpayerID: device.TaxpayerID, Username: "cashier", PasswordHash: "x", PersonName: "Tendai",
+		PersonSurname: "Moyo", UserRole: "Cashier", Email: "cashier@example.com", Status: models.UserStatusActive}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	session := &models.UserSession{UserID: user.ID, DeviceID: device.DeviceID, RefreshTokenHash: "first",
+		IPAddress: "10.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repos.Users.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	// Rotation only succeeds with the current token
+	if rotated, err := repos.Users.RotateSession(ctx, session.ID, "stale", "second", time.Now().Add(2*time.Hour), "10.0.0.2"); err != nil || rotated {
+		t.Fatalf("RotateSession() with stale token = %v, %v, want false", rotated, err)
+	}
+	if rotated, err := repos.Users.RotateSession(ctx, session.ID, "first", "second", time.Now().Add(2*time.Hour), "10.0.0.2"); err != nil || !rotated {
+		t.Fatalf("RotateSession() = %v, %v, want true", rotated, err)
+	}
+	for _, hash := range []string{"first", "second"} {
+		found, err := repos.Users.GetSessionByRefreshHash(ctx, hash)
+		if err != nil || found == nil || found.ID != session.ID || found.RefreshTokenHash != "second" || found.PreviousTokenHash != "first" {
+			t.Errorf("GetSessionByRefreshHash(%q) = %+v, %v", hash, found, err)
+		}
+	}
+
+	other := &models.UserSession{UserID: user.ID, DeviceID: device.DeviceID, RefreshTokenHash: "other", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repos.Users.CreateSession(ctx, other); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if sessions, err := repos.Users.ListSessions(ctx, user.ID); err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions() = %d sessions, %v, want 2", len(sessions), err)
+	}
+
+	if err := repos.Users.RevokeSession(ctx, other.ID, models.SessionRevokeReasonLogout); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	stored, err := repos.Users.GetSession(ctx, other.ID)
+	if err != nil || stored == nil || stored.RevokedAt == nil || stored.RevokeReason != models.SessionRevokeReasonLogout {
+		t.Errorf("GetSession() after revoke = %+v, %v", stored, err)
+	}
+	if rotated, _ := repos.Users.RotateSession(ctx, other.ID, "other", "again", time.Now().Add(time.Hour), ""); rotated {
+		t.Error("revoked session was rotated")
+	}
+
+	if revoked, err := repos.Users.RevokeSessions(ctx, user.ID, models.SessionRevokeReasonPasswordReset); err != nil || revoked != 1 {
+		t.Errorf("RevokeSessions() = %d, %v, want 1", revoked, err)
+	}
+	if sessions, _ := repos.Users.ListSessions(ctx, user.ID); len(sessions) != 0 {
+		t.Errorf("ListSessions() after revoke = %d sessions, want 0", len(sessions))
+	}
+
+	// Revoked sessions are cleared when the user logs in again
+	if err := repos.Users.CreateSession(ctx, &models.UserSession{UserID: user.ID, DeviceID: device.DeviceID, RefreshTokenHash: "third", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if stored, _ := repos.Users.GetSession(ctx, session.ID); stored != nil {
+		t.Error("revoked session was not deleted")
+	}
+}
+
+func TestNotificationRepository_Outbox(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repos := NewRepositories(db)
+
+	// Enqueued in a rolled back transaction, so never sent
+	errAbort := errors.New("abort")
+	err := NewTxManager(db).WithinTx(ctx, func(tx repository.Repositories) error {
+		if err := tx.Notifications.Enqueue(ctx, &models.Notification{Channel: models.NotificationChannelEmail, Recipient: "lost@example.com", Kind: models.NotificationKindContactChanged, Payload: "{}"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx() error = %v, want %v", err, errAbort)
+	}
+
+	n := &models.Notification{Channel: models.NotificationChannelEmail, Recipient: "cashier@example.com", Kind: models.NotificationKindContactChanged, Payload: "{}"}
+	if err := repos.Notifications.Enqueue(ctx, n); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	now := time.Now()
+	claimed, err := repos.Notifications.ClaimDue(ctx, now, now.Add(time.Minute), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != n.ID || claimed[0].Status != models.NotificationStatusSending {
+		t.Fatalf("ClaimDue() = %+v, %v, want the committed notification as sending", claimed, err)
+	}
+	if again, _ := repos.Notifications.ClaimDue(ctx, now, now.Add(time.Minute), 10); len(again) != 0 {
+		t.Errorf("claimed notification was claimed again before its lease ended: %+v", again)
+	}
+
+	retryAt := now.Add(-time.Second)
+	if err := repos.Notifications.MarkFailed(ctx, n.ID, "smtp down", &retryAt); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+	claimed, _ = repos.Notifications.ClaimDue(ctx, now, now.Add(time.Minute), 10)
+	if len(claimed) != 1 || claimed[0].Attempts != 1 || claimed[0].LastError != "smtp down" {
+		t.Fatalf("ClaimDue() after a failure = %+v, want 1 attempt with the error", claimed)
+	}
+
+	if err := repos.Notifications.MarkFailed(ctx, n.ID, "smtp down", nil); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+	if total, dead, err := repos.Notifications.List(ctx, models.NotificationStatusDead, 0, 10); err != nil || total != 1 {
+		t.Fatalf("List(dead) = %d, %+v, %v, want the notification", total, dead, err)
+	}
+	if claimed, _ := repos.Notifications.ClaimDue(ctx, time.Now(), time.Now().Add(time.Minute), 10); len(claimed) != 0 {
+		t.Errorf("dead notification was claimed: %+v", claimed)
+	}
+
+	if ok, err := repos.Notifications.Requeue(ctx, n.ID); err != nil || !ok {
+		t.Fatalf("Requeue() = %v, %v, want true", ok, err)
+	}
+	if err := repos.Notifications.MarkSent(ctx, n.ID); err != nil {
+		t.Fatalf("MarkSent() error = %v", err)
+	}
+	sent, err := repos.Notifications.GetByID(ctx, n.ID)
+	if err != nil || sent.Status != models.NotificationStatusSent || sent.Attempts != 1 || sent.SentAt == nil {
+		t.Errorf("GetByID() after resend = %+v, %v, want sent after 1 attempt", sent, err)
+	}
+	if ok, _ := repos.Notifications.Requeue(ctx, n.ID); ok {
+		t.Error("sent notification was requeued")
+	}
+}
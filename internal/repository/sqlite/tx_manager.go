@@ -0,0 +1,95 @@
+// Package sqlite implements the repository interfaces on SQLite, for
+// single-site deployments where PostgreSQL is not available.
+//
+// Connections are expected to be opened with _txlock=immediate (see
+// database.NewConnection), so every transaction takes SQLite's write lock when
+// it begins. That lock stands in for the row locks (SELECT ... FOR UPDATE) used
+// by the PostgreSQL repositories.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbtx is implemented by both *sqlx.DB and *sqlx.Tx, so a repository can run
+// either directly against the pool or inside a transaction
+type dbtx interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txManager struct {
+	db *sqlx.DB
+}
+
+func NewTxManager(db *sqlx.DB) repository.TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	return inTx(ctx, m.db, func(tx *sqlx.Tx) error {
+		return fn(newRepositories(tx))
+	})
+}
+
+// NewRepositories returns the full set of repositories backed by db
+func NewRepositories(db *sqlx.DB) repository.Repositories {
+	return newRepositories(db)
+}
+
+func newRepositories(db dbtx) repository.Repositories {
+	return repository.Repositories{
+		Devices:       &deviceRepository{db: db},
+		Receipts:      &receiptRepository{db: db},
+		FiscalDays:    &fiscalDayRepository{db: db},
+		Users:         &userRepository{db: db},
+		Admin:         &adminRepository{db: db},
+		Notifications: &notificationRepository{db: db},
+	}
+}
+
+// inTx runs fn in a transaction. When db is already a transaction fn joins
+// it, and committing is left to whoever started it.
+func inTx(ctx context.Context, db dbtx, fn func(tx *sqlx.Tx) error) error {
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		return fn(db)
+	case *sqlx.DB:
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	default:
+		return fmt.Errorf("unsupported database handle %T", db)
+	}
+}
+
+// insert runs an INSERT and returns the rowid of the new row
+func insert(ctx context.Context, db dbtx, query string, args ...interface{}) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// now is the timestamp written to created_at and updated_at. Times are stored
+// in UTC so they sort correctly as text.
+func now() time.Time {
+	return time.Now().UTC()
+}
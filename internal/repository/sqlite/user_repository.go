@@ -0,0 +1,349 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type userRepository struct {
+	db dbtx
+}
+
+func NewUserRepository(db *sqlx.DB) repository.UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return createUser(ctx, r.db, user)
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	var user models.User
+	query := `SELECT * FROM users WHERE id = ?`
+
+	err := r.db.GetContext(ctx, &user, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	query := `SELECT * FROM users WHERE username = ?`
+
+	err := r.db.GetContext(ctx, &user, query, username)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByTaxpayerID(ctx context.Context, taxpayerID int64) ([]models.User, error) {
+	var users []models.User
+	query := `SELECT * FROM users WHERE taxpayer_id = ? ORDER BY username`
+
+	err := r.db.SelectContext(ctx, &users, query, taxpayerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users SET
+			person_name = ?,
+			person_surname = ?,
+			email = ?,
+			phone_no = ?,
+			user_role = ?,
+			status = ?,
+			language = ?,
+			updated_at = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		user.PersonName,
+		user.PersonSurname,
+		user.Email,
+		user.PhoneNo,
+		user.UserRole,
+		user.Status,
+		user.Language.OrDefault(),
+		now(),
+		user.ID,
+	)
+
+	return err
+}
+
+func (r *userRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM users WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *userRepository) SaveSecurityCode(ctx context.Context, code *models.SecurityCode) error {
+	query := `
+		INSERT INTO security_codes (user_id, purpose, code_hash, target, attempts, expires_at, created_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)
+		ON CONFLICT (user_id, purpose) DO UPDATE SET
+			code_hash = excluded.code_hash,
+			target = excluded.target,
+			attempts = 0,
+			expires_at = excluded.expires_at,
+			created_at = excluded.created_at`
+
+	_, err := r.db.ExecContext(ctx, query, code.UserID, code.Purpose, code.CodeHash, code.Target, code.ExpiresAt.UTC(), now())
+	return err
+}
+
+func (r *userRepository) GetSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) (*models.SecurityCode, error) {
+	var code models.SecurityCode
+	query := `
+		SELECT * FROM security_codes
+		WHERE user_id = ? AND purpose = ? AND julianday(expires_at) > julianday('now')`
+
+	err := r.db.GetContext(ctx, &code, query, userID, purpose)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (r *userRepository) ClaimSecurityCodeAttempt(ctx context.Context, userID int64, purpose models.SecurityCodePurpose, maxAttempts int) (*models.SecurityCode, error) {
+	var code models.SecurityCode
+	query := `
+		UPDATE security_codes SET attempts = attempts + 1
+		WHERE user_id = ? AND purpose = ? AND attempts < ? AND julianday(expires_at) > julianday('now')
+		RETURNING *`
+
+	err := r.db.GetContext(ctx, &code, query, userID, purpose, maxAttempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (r *userRepository) DeleteSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) error {
+	query := `DELETE FROM security_codes WHERE user_id = ? AND purpose = ?`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
+	return err
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	query := `
+		UPDATE users SET
+			password_hash = ?,
+			updated_at = ?
+		WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, passwordHash, now(), userID)
+	return err
+}
+
+func (r *userRepository) List(ctx context.Context, taxpayerID int64, offset, limit int) ([]models.User, int, error) {
+	// Get total count
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users WHERE taxpayer_id = ?`
+	err := r.db.GetContext(ctx, &total, countQuery, taxpayerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Get users
+	var users []models.User
+	query := `
+		SELECT * FROM users
+		WHERE taxpayer_id = ?
+		ORDER BY username
+		LIMIT ? OFFSET ?`
+
+	err = r.db.SelectContext(ctx, &users, query, taxpayerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *userRepository) CreateSession(ctx context.Context, session *models.UserSession) error {
+	prune := `
+		DELETE FROM user_sessions
+		WHERE user_id = ? AND (revoked_at IS NOT NULL OR julianday(expires_at) <= julianday('now'))`
+	if _, err := r.db.ExecContext(ctx, prune, session.UserID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_sessions (user_id, device_id, refresh_token_hash, ip_address, expires_at, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, r.db, query,
+		session.UserID,
+		session.DeviceID,
+		session.RefreshTokenHash,
+		session.IPAddress,
+		session.ExpiresAt.UTC(),
+		createdAt,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	session.ID = id
+	session.CreatedAt = createdAt
+	session.LastUsedAt = createdAt
+	return nil
+}
+
+func (r *userRepository) GetSession(ctx context.Context, id int64) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `SELECT * FROM user_sessions WHERE id = ?`
+
+	err := r.db.GetContext(ctx, &session, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *userRepository) GetSessionByRefreshHash(ctx context.Context, hash string) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `
+		SELECT * FROM user_sessions
+		WHERE refresh_token_hash = ? OR previous_token_hash = ?
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &session, query, hash, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *userRepository) RotateSession(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time, ipAddress string) (bool, error) {
+	query := `
+		UPDATE user_sessions SET
+			previous_token_hash = refresh_token_hash,
+			refresh_token_hash = ?,
+			ip_address = ?,
+			expires_at = ?,
+			last_used_at = ?
+		WHERE id = ? AND refresh_token_hash = ?
+			AND revoked_at IS NULL AND julianday(expires_at) > julianday('now')`
+
+	result, err := r.db.ExecContext(ctx, query, newHash, ipAddress, expiresAt.UTC(), now(), id, oldHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (r *userRepository) RevokeSession(ctx context.Context, id int64, reason models.SessionRevokeReason) error {
+	query := `
+		UPDATE user_sessions SET
+			revoked_at = ?,
+			revoke_reason = ?
+		WHERE id = ? AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, now(), reason, id)
+	return err
+}
+
+func (r *userRepository) RevokeSessions(ctx context.Context, userID int64, reason models.SessionRevokeReason) (int, error) {
+	query := `
+		UPDATE user_sessions SET
+			revoked_at = ?,
+			revoke_reason = ?
+		WHERE user_id = ? AND revoked_at IS NULL AND julianday(expires_at) > julianday('now')`
+
+	result, err := r.db.ExecContext(ctx, query, now(), reason, userID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	return int(rows), err
+}
+
+func (r *userRepository) ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	query := `
+		SELECT * FROM user_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND julianday(expires_at) > julianday('now')
+		ORDER BY last_used_at DESC, id DESC`
+
+	err := r.db.SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func createUser(ctx context.Context, db dbtx, user *models.User) error {
+	query := `
+		INSERT INTO users (
+			taxpayer_id, username, password_hash, person_name, person_surname,
+			email, phone_no, user_role, status, language, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	id, err := insert(ctx, db,
+		query,
+		user.TaxpayerID,
+		user.Username,
+		user.PasswordHash,
+		user.PersonName,
+		user.PersonSurname,
+		user.Email,
+		user.PhoneNo,
+		user.UserRole,
+		user.Status,
+		user.Language.OrDefault(),
+		createdAt,
+		createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	user.ID, user.CreatedAt, user.UpdatedAt = id, createdAt, createdAt
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbtx is implemented by both *sqlx.DB and *sqlx.Tx, so a repository can run
+// either directly against the pool or inside a transaction
+type dbtx interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Repositories is a set of repositories sharing one database handle
+type Repositories struct {
+	Devices       DeviceRepository
+	Receipts      ReceiptRepository
+	FiscalDays    FiscalDayRepository
+	Users         UserRepository
+	Admin         AdminRepository
+	Notifications NotificationRepository
+}
+
+// TxManager runs units of work in a database transaction
+type TxManager interface {
+	// WithinTx calls fn with repositories bound to a new transaction. The
+	// transaction is committed if fn returns nil and rolled back otherwise.
+	WithinTx(ctx context.Context, fn func(repos Repositories) error) error
+}
+
+type txManager struct {
+	db *sqlx.DB
+}
+
+func NewTxManager(db *sqlx.DB) TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(repos Repositories) error) error {
+	return inTx(ctx, m.db, func(tx *sqlx.Tx) error {
+		return fn(newRepositories(tx))
+	})
+}
+
+func newRepositories(db dbtx) Repositories {
+	return Repositories{
+		Devices:       &deviceRepository{db: db},
+		Receipts:      &receiptRepository{db: db},
+		FiscalDays:    &fiscalDayRepository{db: db},
+		Users:         &userRepository{db: db},
+		Admin:         &adminRepository{db: db},
+		Notifications: &notificationRepository{db: db},
+	}
+}
+
+// inTx runs fn in a transaction. When db is already a transaction fn joins
+// it, and committing is left to whoever started it.
+func inTx(ctx context.Context, db dbtx, fn func(tx *sqlx.Tx) error) error {
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		return fn(db)
+	case *sqlx.DB:
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	default:
+		return fmt.Errorf("unsupported database handle %T", db)
+	}
+}
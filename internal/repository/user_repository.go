@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -11,42 +12,65 @@ import (
 
 type UserRepository interface {
 	// User operations
-	Create(user *models.User) error
-	GetByID(id int64) (*models.User, error)
-	GetByUsername(username string) (*models.User, error)
-	GetByTaxpayerID(taxpayerID int64) ([]models.User, error)
-	Update(user *models.User) error
-	Delete(id int64) error
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByTaxpayerID(ctx context.Context, taxpayerID int64) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id int64) error
 	
-	// Security code operations
-	SaveSecurityCode(userID int64, code string, expiresAt time.Time) error
-	GetSecurityCode(userID int64) (string, time.Time, error)
-	DeleteSecurityCode(userID int64) error
+	// Security code operations. Saving a code replaces the user's pending
+	// code for the same purpose and resets its attempts; expired codes are
+	// not returned.
+	SaveSecurityCode(ctx context.Context, code *models.SecurityCode) error
+	GetSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) (*models.SecurityCode, error)
+	// ClaimSecurityCodeAttempt counts a guess against the user's code, unless
+	// it has already had maxAttempts, and returns the code with the guess
+	// counted. It returns nil when no code can be guessed.
+	ClaimSecurityCodeAttempt(ctx context.Context, userID int64, purpose models.SecurityCodePurpose, maxAttempts int) (*models.SecurityCode, error)
+	DeleteSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) error
 	
 	// Password operations
-	UpdatePassword(userID int64, passwordHash string) error
+	UpdatePassword(ctx context.Context, userID int64, passwordHash string) error
+
+	// Session operations. Creating a session deletes the user's sessions
+	// that have expired or were revoked; only active sessions are listed.
+	CreateSession(ctx context.Context, session *models.UserSession) error
+	GetSession(ctx context.Context, id int64) (*models.UserSession, error)
+	// GetSessionByRefreshHash returns the session whose current or previous
+	// refresh token has the hash, active or not
+	GetSessionByRefreshHash(ctx context.Context, hash string) (*models.UserSession, error)
+	// RotateSession replaces the refresh token of an active session and
+	// extends it, provided its current token is still oldHash. It reports
+	// whether the session was rotated.
+	RotateSession(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time, ipAddress string) (bool, error)
+	RevokeSession(ctx context.Context, id int64, reason models.SessionRevokeReason) error
+	// RevokeSessions ends every active session of the user and returns how
+	// many there were
+	RevokeSessions(ctx context.Context, userID int64, reason models.SessionRevokeReason) (int, error)
+	ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error)
 	
 	// List operations
-	List(taxpayerID int64, offset, limit int) ([]models.User, int, error)
+	List(ctx context.Context, taxpayerID int64, offset, limit int) ([]models.User, int, error)
 }
 
 type userRepository struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewUserRepository(db *sqlx.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
 		INSERT INTO users (
 			taxpayer_id, username, password_hash, person_name, person_surname,
-			email, phone_no, user_role, status
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			email, phone_no, user_role, status, language
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(
+	return r.db.QueryRowContext(ctx,
 		query,
 		user.TaxpayerID,
 		user.Username,
@@ -57,14 +81,15 @@ func (r *userRepository) Create(user *models.User) error {
 		user.PhoneNo,
 		user.UserRole,
 		user.Status,
+		user.Language.OrDefault(),
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 }
 
-func (r *userRepository) GetByID(id int64) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	var user models.User
 	query := `SELECT * FROM users WHERE id = $1`
 
-	err := r.db.Get(&user, query, id)
+	err := r.db.GetContext(ctx, &user, query, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -75,11 +100,11 @@ func (r *userRepository) GetByID(id int64) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) GetByUsername(username string) (*models.User, error) {
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
 	query := `SELECT * FROM users WHERE username = $1`
 
-	err := r.db.Get(&user, query, username)
+	err := r.db.GetContext(ctx, &user, query, username)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -90,11 +115,11 @@ func (r *userRepository) GetByUsername(username string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) GetByTaxpayerID(taxpayerID int64) ([]models.User, error) {
+func (r *userRepository) GetByTaxpayerID(ctx context.Context, taxpayerID int64) ([]models.User, error) {
 	var users []models.User
 	query := `SELECT * FROM users WHERE taxpayer_id = $1 ORDER BY username`
 
-	err := r.db.Select(&users, query, taxpayerID)
+	err := r.db.SelectContext(ctx, &users, query, taxpayerID)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +127,7 @@ func (r *userRepository) GetByTaxpayerID(taxpayerID int64) ([]models.User, error
 	return users, nil
 }
 
-func (r *userRepository) Update(user *models.User) error {
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users SET
 			person_name = $1,
@@ -111,10 +136,11 @@ func (r *userRepository) Update(user *models.User) error {
 			phone_no = $4,
 			user_role = $5,
 			status = $6,
+			language = $7,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7`
+		WHERE id = $8`
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(ctx,
 		query,
 		user.PersonName,
 		user.PersonSurname,
@@ -122,73 +148,91 @@ func (r *userRepository) Update(user *models.User) error {
 		user.PhoneNo,
 		user.UserRole,
 		user.Status,
+		user.Language.OrDefault(),
 		user.ID,
 	)
 
 	return err
 }
 
-func (r *userRepository) Delete(id int64) error {
+func (r *userRepository) Delete(ctx context.Context, id int64) error {
 	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
-func (r *userRepository) SaveSecurityCode(userID int64, code string, expiresAt time.Time) error {
+func (r *userRepository) SaveSecurityCode(ctx context.Context, code *models.SecurityCode) error {
 	query := `
-		INSERT INTO security_codes (user_id, code, expires_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id) DO UPDATE SET
-			code = EXCLUDED.code,
+		INSERT INTO security_codes (user_id, purpose, code_hash, target, attempts, expires_at)
+		VALUES ($1, $2, $3, $4, 0, $5)
+		ON CONFLICT (user_id, purpose) DO UPDATE SET
+			code_hash = EXCLUDED.code_hash,
+			target = EXCLUDED.target,
+			attempts = 0,
 			expires_at = EXCLUDED.expires_at,
 			created_at = CURRENT_TIMESTAMP`
 
-	_, err := r.db.Exec(query, userID, code, expiresAt)
+	_, err := r.db.ExecContext(ctx, query, code.UserID, code.Purpose, code.CodeHash, code.Target, code.ExpiresAt)
 	return err
 }
 
-func (r *userRepository) GetSecurityCode(userID int64) (string, time.Time, error) {
-	var code string
-	var expiresAt time.Time
+func (r *userRepository) GetSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) (*models.SecurityCode, error) {
+	var code models.SecurityCode
+	query := `
+		SELECT * FROM security_codes
+		WHERE user_id = $1 AND purpose = $2 AND expires_at > CURRENT_TIMESTAMP`
+
+	err := r.db.GetContext(ctx, &code, query, userID, purpose)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	return &code, nil
+}
+
+func (r *userRepository) ClaimSecurityCodeAttempt(ctx context.Context, userID int64, purpose models.SecurityCodePurpose, maxAttempts int) (*models.SecurityCode, error) {
+	var code models.SecurityCode
 	query := `
-		SELECT code, expires_at
-		FROM security_codes
-		WHERE user_id = $1 AND expires_at > CURRENT_TIMESTAMP`
+		UPDATE security_codes SET attempts = attempts + 1
+		WHERE user_id = $1 AND purpose = $2 AND attempts < $3 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING *`
 
-	err := r.db.QueryRow(query, userID).Scan(&code, &expiresAt)
+	err := r.db.GetContext(ctx, &code, query, userID, purpose, maxAttempts)
 	if err == sql.ErrNoRows {
-		return "", time.Time{}, nil
+		return nil, nil
 	}
 	if err != nil {
-		return "", time.Time{}, err
+		return nil, err
 	}
 
-	return code, expiresAt, nil
+	return &code, nil
 }
 
-func (r *userRepository) DeleteSecurityCode(userID int64) error {
-	query := `DELETE FROM security_codes WHERE user_id = $1`
-	_, err := r.db.Exec(query, userID)
+func (r *userRepository) DeleteSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose) error {
+	query := `DELETE FROM security_codes WHERE user_id = $1 AND purpose = $2`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
 	return err
 }
 
-func (r *userRepository) UpdatePassword(userID int64, passwordHash string) error {
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
 	query := `
 		UPDATE users SET
 			password_hash = $1,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $2`
 
-	_, err := r.db.Exec(query, passwordHash, userID)
+	_, err := r.db.ExecContext(ctx, query, passwordHash, userID)
 	return err
 }
 
-func (r *userRepository) List(taxpayerID int64, offset, limit int) ([]models.User, int, error) {
+func (r *userRepository) List(ctx context.Context, taxpayerID int64, offset, limit int) ([]models.User, int, error) {
 	// Get total count
 	var total int
 	countQuery := `SELECT COUNT(*) FROM users WHERE taxpayer_id = $1`
-	err := r.db.Get(&total, countQuery, taxpayerID)
+	err := r.db.GetContext(ctx, &total, countQuery, taxpayerID)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -201,10 +245,126 @@ func (r *userRepository) List(taxpayerID int64, offset, limit int) ([]models.Use
 		ORDER BY username
 		LIMIT $2 OFFSET $3`
 
-	err = r.db.Select(&users, query, taxpayerID, limit, offset)
+	err = r.db.SelectContext(ctx, &users, query, taxpayerID, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	return users, total, nil
 }
+
+func (r *userRepository) CreateSession(ctx context.Context, session *models.UserSession) error {
+	prune := `
+		DELETE FROM user_sessions
+		WHERE user_id = $1 AND (revoked_at IS NOT NULL OR expires_at <= CURRENT_TIMESTAMP)`
+	if _, err := r.db.ExecContext(ctx, prune, session.UserID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_sessions (user_id, device_id, refresh_token_hash, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, last_used_at`
+
+	return r.db.QueryRowContext(ctx,
+		query,
+		session.UserID,
+		session.DeviceID,
+		session.RefreshTokenHash,
+		session.IPAddress,
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+}
+
+func (r *userRepository) GetSession(ctx context.Context, id int64) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `SELECT * FROM user_sessions WHERE id = $1`
+
+	err := r.db.GetContext(ctx, &session, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *userRepository) GetSessionByRefreshHash(ctx context.Context, hash string) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `
+		SELECT * FROM user_sessions
+		WHERE refresh_token_hash = $1 OR previous_token_hash = $1
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &session, query, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *userRepository) RotateSession(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time, ipAddress string) (bool, error) {
+	query := `
+		UPDATE user_sessions SET
+			previous_token_hash = refresh_token_hash,
+			refresh_token_hash = $1,
+			ip_address = $2,
+			expires_at = $3,
+			last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND refresh_token_hash = $5
+			AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	result, err := r.db.ExecContext(ctx, query, newHash, ipAddress, expiresAt, id, oldHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (r *userRepository) RevokeSession(ctx context.Context, id int64, reason models.SessionRevokeReason) error {
+	query := `
+		UPDATE user_sessions SET
+			revoked_at = CURRENT_TIMESTAMP,
+			revoke_reason = $1
+		WHERE id = $2 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, reason, id)
+	return err
+}
+
+func (r *userRepository) RevokeSessions(ctx context.Context, userID int64, reason models.SessionRevokeReason) (int, error) {
+	query := `
+		UPDATE user_sessions SET
+			revoked_at = CURRENT_TIMESTAMP,
+			revoke_reason = $1
+		WHERE user_id = $2 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	result, err := r.db.ExecContext(ctx, query, reason, userID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	return int(rows), err
+}
+
+func (r *userRepository) ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	query := `
+		SELECT * FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_used_at DESC, id DESC`
+
+	err := r.db.SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JobFunc is a unit of periodic background work
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler runs registered jobs at fixed intervals until stopped
+type Scheduler struct {
+	jobs   []job
+	logger *zap.Logger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(logger *zap.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Add registers a job. Jobs must be added before Start is called.
+func (s *Scheduler) Add(name string, interval time.Duration, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start launches every job in its own goroutine. Each job runs once
+// immediately and then on every tick of its interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop cancels all jobs and waits for running ones to return
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, j)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Scheduled job panicked", zap.String("job", j.name), zap.Any("panic", r))
+		}
+	}()
+
+	start := time.Now()
+	if err := j.run(ctx); err != nil {
+		s.logger.Error("Scheduled job failed", zap.String("job", j.name), zap.Error(err))
+		return
+	}
+	s.logger.Debug("Scheduled job finished", zap.String("job", j.name), zap.Duration("took", time.Since(start)))
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestScheduler_RunsJobsUntilStopped(t *testing.T) {
+	var runs, failures, panics int32
+	sched := NewScheduler(zap.NewNop())
+	sched.Add("counter", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	sched.Add("failing", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&failures, 1)
+		return errors.New("failed")
+	})
+	sched.Add("panicking", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&panics, 1)
+		panic("boom")
+	})
+
+	sched.Start(context.Background())
+	time.Sleep(55 * time.Millisecond)
+	sched.Stop()
+
+	// Failing and panicking jobs keep being run on their interval
+	for name, count := range map[string]*int32{"counter": &runs, "failing": &failures, "panicking": &panics} {
+		if got := atomic.LoadInt32(count); got < 2 {
+			t.Errorf("%s job ran %d times, want at least 2", name, got)
+		}
+	}
+
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(30 * time.Millisecond)
+	if got := atomic.LoadInt32(&runs); got != stopped {
+		t.Errorf("job ran %d more times after Stop()", got-stopped)
+	}
+}
+
+func TestScheduler_RunsJobOnStart(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	sched := NewScheduler(zap.NewNop())
+	sched.Add("once", time.Hour, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	sched.Start(context.Background())
+	defer sched.Stop()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run when the scheduler started")
+	}
+}
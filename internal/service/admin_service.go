@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -15,19 +17,36 @@ import (
 )
 
 type AdminService struct {
-	adminRepo repository.AdminRepository
-	jwtSecret string
-	logger    *zap.Logger
+	adminRepo        repository.AdminRepository
+	notificationRepo repository.NotificationRepository
+	fiscalDaySvc     *FiscalDayService
+	jwtSecret    string
+	logger       *zap.Logger
 }
 
-func (s *AdminService) audit(entityType, action string, entityID *int64, deviceID *int, details string) {
-	if err := s.adminRepo.InsertAuditLog(entityType, action, entityID, deviceID, "system", details); err != nil {
+func (s *AdminService) audit(ctx context.Context, entityType, action string, entityID *int64, deviceID *int, details string) {
+	if err := s.adminRepo.InsertAuditLog(ctx, entityType, action, entityID, nil, deviceID, "system", details); err != nil {
 		s.logger.Warn("Failed to write audit log", zap.Error(err))
 	}
 }
 
-func NewAdminService(adminRepo repository.AdminRepository, jwtSecret string, logger *zap.Logger) *AdminService {
-	return &AdminService{adminRepo: adminRepo, jwtSecret: jwtSecret, logger: logger}
+// auditAs writes an audit log entry attributed to an administrator
+func (s *AdminService) auditAs(ctx context.Context, actor models.AdminActor, entityType, action string, entityID *int64, deviceID *int, details map[string]interface{}) {
+	if err := insertAdminAudit(ctx, s.adminRepo, actor, entityType, action, entityID, deviceID, details); err != nil {
+		s.logger.Warn("Failed to write audit log", zap.Error(err))
+	}
+}
+
+// insertAdminAudit writes an audit log entry attributed to an administrator
+// through audit, which may belong to a transaction
+func insertAdminAudit(ctx context.Context, audit repository.AdminRepository, actor models.AdminActor, entityType, action string, entityID *int64, deviceID *int, details map[string]interface{}) error {
+	details["admin"] = actor.Username
+	data, _ := json.Marshal(details)
+	return audit.InsertAuditLog(ctx, entityType, action, entityID, nil, deviceID, actor.IPAddress, string(data))
+}
+
+func NewAdminService(adminRepo repository.AdminRepository, notificationRepo repository.NotificationRepository, fiscalDaySvc *FiscalDayService, jwtSecret string, logger *zap.Logger) *AdminService {
+	return &AdminService{adminRepo: adminRepo, notificationRepo: notificationRepo, fiscalDaySvc: fiscalDaySvc, jwtSecret: jwtSecret, logger: logger}
 }
 
 // ─── Auth ─────────────────────────────────────────────────────────────────────
@@ -37,11 +56,11 @@ func (s *AdminService) Login(req models.AdminLoginRequest) (*models.AdminLoginRe
 	// In production: look up admin user table, verify bcrypt hash
 	// For now: env-based superadmin account
 	if req.Username != "superadmin" {
-		return nil, models.NewAPIError(401, "Invalid credentials", "ADM01")
+		return nil, models.NewAPIError(401, "Invalid credentials", models.ErrCodeADM01)
 	}
 	// TODO: replace hardcoded password with bcrypt comparison from admin_users table
 	if req.Password != "ZimraAdmin2024!" {
-		return nil, models.NewAPIError(401, "Invalid credentials", "ADM01")
+		return nil, models.NewAPIError(401, "Invalid credentials", models.ErrCodeADM01)
 	}
 
 	expiresAt := time.Now().Add(8 * time.Hour)
@@ -67,14 +86,14 @@ func (s *AdminService) Login(req models.AdminLoginRequest) (*models.AdminLoginRe
 
 // ─── Taxpayer (Company) ───────────────────────────────────────────────────────
 
-func (s *AdminService) CreateTaxpayer(req models.CreateTaxpayerRequest) (*models.Taxpayer, error) {
+func (s *AdminService) CreateTaxpayer(ctx context.Context, req models.CreateTaxpayerRequest) (*models.Taxpayer, error) {
 	// Check TIN uniqueness
-	existing, err := s.adminRepo.GetTaxpayerByTIN(req.TIN)
+	existing, err := s.adminRepo.GetTaxpayerByTIN(ctx, req.TIN)
 	if err != nil {
 		return nil, err
 	}
 	if existing != nil {
-		return nil, models.NewAPIError(422, fmt.Sprintf("Taxpayer with TIN %s already exists", req.TIN), "ADM10")
+		return nil, models.NewAPIError(422, fmt.Sprintf("Taxpayer with TIN %s already exists", req.TIN), models.ErrCodeADM06)
 	}
 
 	if req.Status == "" {
@@ -100,7 +119,7 @@ func (s *AdminService) CreateTaxpayer(req models.CreateTaxpayerRequest) (*models
 		QrURL:                        req.QrURL,
 	}
 
-	if err := s.adminRepo.CreateTaxpayer(tp); err != nil {
+	if err := s.adminRepo.CreateTaxpayer(ctx, tp); err != nil {
 		return nil, err
 	}
 
@@ -108,29 +127,29 @@ func (s *AdminService) CreateTaxpayer(req models.CreateTaxpayerRequest) (*models
 	return tp, nil
 }
 
-func (s *AdminService) ListTaxpayers(offset, limit int, search string) (*models.ListTaxpayersResponse, error) {
-	total, rows, err := s.adminRepo.ListTaxpayers(offset, limit, search)
+func (s *AdminService) ListTaxpayers(ctx context.Context, offset, limit int, search string) (*models.ListTaxpayersResponse, error) {
+	total, rows, err := s.adminRepo.ListTaxpayers(ctx, offset, limit, search)
 	if err != nil {
 		return nil, err
 	}
 	return &models.ListTaxpayersResponse{Total: total, Rows: rows}, nil
 }
 
-func (s *AdminService) GetTaxpayer(id int64) (*models.Taxpayer, error) {
-	tp, err := s.adminRepo.GetTaxpayerByID(id)
+func (s *AdminService) GetTaxpayer(ctx context.Context, id int64) (*models.Taxpayer, error) {
+	tp, err := s.adminRepo.GetTaxpayerByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	if tp == nil {
-		return nil, models.NewAPIError(404, "Taxpayer not found", "ADM11")
+		return nil, models.NewAPIError(404, "Taxpayer not found", models.ErrCodeADM03)
 	}
 	return tp, nil
 }
 
-func (s *AdminService) UpdateTaxpayer(req models.UpdateTaxpayerRequest) (*models.Taxpayer, error) {
-	tp, err := s.adminRepo.GetTaxpayerByID(req.ID)
+func (s *AdminService) UpdateTaxpayer(ctx context.Context, req models.UpdateTaxpayerRequest) (*models.Taxpayer, error) {
+	tp, err := s.adminRepo.GetTaxpayerByID(ctx, req.ID)
 	if err != nil || tp == nil {
-		return nil, models.NewAPIError(404, "Taxpayer not found", "ADM11")
+		return nil, models.NewAPIError(404, "Taxpayer not found", models.ErrCodeADM03)
 	}
 	tp.Name = req.Name
 	tp.VATNumber = req.VATNumber
@@ -139,14 +158,14 @@ func (s *AdminService) UpdateTaxpayer(req models.UpdateTaxpayerRequest) (*models
 	tp.TaxpayerDayEndNotificationHrs = req.TaxpayerDayEndNotificationHrs
 	tp.QrURL = req.QrURL
 
-	if err := s.adminRepo.UpdateTaxpayer(tp); err != nil {
+	if err := s.adminRepo.UpdateTaxpayer(ctx, tp); err != nil {
 		return nil, err
 	}
 	return tp, nil
 }
 
-func (s *AdminService) SetTaxpayerStatus(id int64, status string) error {
-	return s.adminRepo.SetTaxpayerStatus(id, status)
+func (s *AdminService) SetTaxpayerStatus(ctx context.Context, id int64, status string) error {
+	return s.adminRepo.SetTaxpayerStatus(ctx, id, status)
 }
 
 // ─── Device ───────────────────────────────────────────────────────────────────
@@ -160,11 +179,11 @@ func generateActivationKey() (string, error) {
 	return hex.EncodeToString(b)[:8], nil
 }
 
-func (s *AdminService) CreateDevice(req models.AdminCreateDeviceRequest) (*models.Device, error) {
+func (s *AdminService) CreateDevice(ctx context.Context, req models.AdminCreateDeviceRequest) (*models.Device, error) {
 	// Verify taxpayer exists
-	tp, err := s.adminRepo.GetTaxpayerByID(req.TaxpayerID)
+	tp, err := s.adminRepo.GetTaxpayerByID(ctx, req.TaxpayerID)
 	if err != nil || tp == nil {
-		return nil, models.NewAPIError(422, "Taxpayer not found", "ADM11")
+		return nil, models.NewAPIError(422, "Taxpayer not found", models.ErrCodeADM03)
 	}
 
 	// Use provided activation key or generate one
@@ -191,7 +210,7 @@ func (s *AdminService) CreateDevice(req models.AdminCreateDeviceRequest) (*model
 		BranchContacts:     req.BranchContacts,
 	}
 
-	if err := s.adminRepo.CreateDevice(device); err != nil {
+	if err := s.adminRepo.CreateDevice(ctx, device); err != nil {
 		return nil, err
 	}
 
@@ -204,62 +223,136 @@ func (s *AdminService) CreateDevice(req models.AdminCreateDeviceRequest) (*model
 	return device, nil
 }
 
-func (s *AdminService) ListDevicesByTaxpayer(taxpayerID int64) ([]models.Device, error) {
-	return s.adminRepo.ListDevicesByTaxpayer(taxpayerID)
+func (s *AdminService) ListDevicesByTaxpayer(ctx context.Context, taxpayerID int64) ([]models.Device, error) {
+	return s.adminRepo.ListDevicesByTaxpayer(ctx, taxpayerID)
 }
 
-func (s *AdminService) ListAllDevices(offset, limit int) (*models.ListDevicesResponse, error) {
-	total, rows, err := s.adminRepo.ListAllDevices(offset, limit)
+func (s *AdminService) ListAllDevices(ctx context.Context, offset, limit int) (*models.ListDevicesResponse, error) {
+	total, rows, err := s.adminRepo.ListAllDevices(ctx, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 	return &models.ListDevicesResponse{Total: total, Rows: rows}, nil
 }
 
-func (s *AdminService) UpdateDeviceStatus(deviceID int, status string) error {
-	return s.adminRepo.UpdateDeviceStatus(deviceID, status)
+func (s *AdminService) UpdateDeviceStatus(ctx context.Context, deviceID int, status string) error {
+	return s.adminRepo.UpdateDeviceStatus(ctx, deviceID, status)
 }
 
-func (s *AdminService) UpdateDeviceMode(deviceID int, mode int) error {
-	return s.adminRepo.UpdateDeviceMode(deviceID, mode)
+func (s *AdminService) UpdateDeviceMode(ctx context.Context, deviceID int, mode int) error {
+	return s.adminRepo.UpdateDeviceMode(ctx, deviceID, mode)
 }
 
 // ─── Overview Queries ─────────────────────────────────────────────────────────
 
-func (s *AdminService) ListFiscalDays(taxpayerID *int64, deviceID *int, offset, limit int) (*models.ListFiscalDaysResponse, error) {
-	total, rows, err := s.adminRepo.ListFiscalDays(taxpayerID, deviceID, offset, limit)
+func (s *AdminService) ListFiscalDays(ctx context.Context, taxpayerID *int64, deviceID *int, offset, limit int) (*models.ListFiscalDaysResponse, error) {
+	total, rows, err := s.adminRepo.ListFiscalDays(ctx, taxpayerID, deviceID, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 	return &models.ListFiscalDaysResponse{Total: total, Rows: rows}, nil
 }
 
-func (s *AdminService) ListReceipts(taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (*models.ListReceiptsResponse, error) {
-	total, rows, err := s.adminRepo.ListReceipts(taxpayerID, deviceID, from, to, offset, limit)
+func (s *AdminService) ListReceipts(ctx context.Context, taxpayerID *int64, deviceID *int, from, to *time.Time, offset, limit int) (*models.ListReceiptsResponse, error) {
+	total, rows, err := s.adminRepo.ListReceipts(ctx, taxpayerID, deviceID, from, to, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 	return &models.ListReceiptsResponse{Total: total, Rows: rows}, nil
 }
 
-func (s *AdminService) GetSystemStats() (*models.SystemStats, error) {
-	return s.adminRepo.GetSystemStats()
+// ─── Fiscal Day Interventions ─────────────────────────────────────────────────
+
+// ForceCloseFiscalDay closes a device's stuck fiscal day with server-computed
+// counters. The audit entry is written in the closing transaction.
+func (s *AdminService) ForceCloseFiscalDay(ctx context.Context, actor models.AdminActor, deviceID, fiscalDayNo int, req models.AdminForceCloseFiscalDayRequest) (*models.FiscalDay, error) {
+	return s.fiscalDaySvc.ForceCloseFiscalDay(ctx, deviceID, fiscalDayNo, func(repos repository.Repositories, fiscalDayID int64, previousStatus models.FiscalDayStatus) error {
+		return insertAdminAudit(ctx, repos.Admin, actor, "fiscal_day", "force_close", &fiscalDayID, &deviceID, map[string]interface{}{
+			"fiscalDayNo":    fiscalDayNo,
+			"previousStatus": previousStatus.String(),
+			"reason":         req.Reason,
+		})
+	})
+}
+
+// ResetFiscalDay moves a device's CloseFailed fiscal day back to Opened. The
+// audit entry is written in the resetting transaction.
+func (s *AdminService) ResetFiscalDay(ctx context.Context, actor models.AdminActor, deviceID, fiscalDayNo int, req models.AdminResetFiscalDayRequest) (*models.FiscalDay, error) {
+	return s.fiscalDaySvc.ResetFiscalDay(ctx, deviceID, fiscalDayNo, func(repos repository.Repositories, fiscalDayID int64) error {
+		return insertAdminAudit(ctx, repos.Admin, actor, "fiscal_day", "reset", &fiscalDayID, &deviceID, map[string]interface{}{
+			"fiscalDayNo":    fiscalDayNo,
+			"previousStatus": models.FiscalDayStatusCloseFailed.String(),
+			"reason":         req.Reason,
+		})
+	})
+}
+
+func (s *AdminService) GetSystemStats(ctx context.Context) (*models.SystemStats, error) {
+	return s.adminRepo.GetSystemStats(ctx)
 }
 
-func (s *AdminService) ListAuditLogs(entityType string, entityID *int64, offset, limit int) (*models.ListAuditLogsResponse, error) {
-	total, rows, err := s.adminRepo.ListAuditLogs(entityType, entityID, offset, limit)
+func (s *AdminService) ListAuditLogs(ctx context.Context, entityType string, entityID *int64, offset, limit int) (*models.ListAuditLogsResponse, error) {
+	total, rows, err := s.adminRepo.ListAuditLogs(ctx, entityType, entityID, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 	return &models.ListAuditLogsResponse{Total: total, Rows: rows}, nil
 }
 
+// ListNotifications returns a page of the notification outbox, optionally
+// only notifications in one status
+func (s *AdminService) ListNotifications(ctx context.Context, status models.NotificationStatus, offset, limit int) (*models.ListNotificationsResponse, error) {
+	total, rows, err := s.notificationRepo.List(ctx, status, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ListNotificationsResponse{Total: total, Rows: rows}, nil
+}
+
+func (s *AdminService) GetNotification(ctx context.Context, id int64) (*models.Notification, error) {
+	n, err := s.notificationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, models.NewAPIError(404, "Notification not found", models.ErrCodeADM04)
+	}
+	return n, nil
+}
+
+// ResendNotification queues a dead-lettered notification for delivery again
+// with a fresh set of attempts
+func (s *AdminService) ResendNotification(ctx context.Context, actor models.AdminActor, id int64) (*models.Notification, error) {
+	n, err := s.GetNotification(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	requeued, err := s.notificationRepo.Requeue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		return nil, models.NewAPIError(409, "Only dead-lettered notifications can be resent", models.ErrCodeADM05).
+			WithDetail("notification status is " + string(n.Status))
+	}
+
+	s.auditAs(ctx, actor, "notification", "resend", &n.ID, nil, map[string]interface{}{
+		"kind":      n.Kind,
+		"channel":   n.Channel,
+		"attempts":  n.Attempts,
+		"lastError": n.LastError,
+	})
+
+	return s.GetNotification(ctx, id)
+}
+
 
-func (s *AdminService) CreateCompanyUser(req models.AdminCreateUserRequest) (*models.AdminUserRow, error) {
+func (s *AdminService) CreateCompanyUser(ctx context.Context, req models.AdminCreateUserRequest) (*models.AdminUserRow, error) {
 	// verify company exists
-	tp, err := s.adminRepo.GetTaxpayerByID(req.TaxpayerID)
+	tp, err := s.adminRepo.GetTaxpayerByID(ctx, req.TaxpayerID)
 	if err != nil || tp == nil {
-		return nil, models.NewAPIError(404, "Company not found", "ADM11")
+		return nil, models.NewAPIError(404, "Company not found", models.ErrCodeADM03)
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -277,14 +370,15 @@ func (s *AdminService) CreateCompanyUser(req models.AdminCreateUserRequest) (*mo
 		Email:         req.Email,
 		PhoneNo:       req.PhoneNo,
 		Status:        models.UserStatusActive, // active immediately, no confirm step
+		Language:      req.Language.OrDefault(),
 	}
 
-	if err := s.adminRepo.CreateUser(user); err != nil {
+	if err := s.adminRepo.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
 
 	id := user.ID
-	s.audit("user", "create", &id, nil, "Username: "+user.Username+" Company: "+tp.Name)
+	s.audit(ctx, "user", "create", &id, nil, "Username: "+user.Username+" Company: "+tp.Name)
 
 	return &models.AdminUserRow{
 		ID:            user.ID,
@@ -299,7 +393,7 @@ func (s *AdminService) CreateCompanyUser(req models.AdminCreateUserRequest) (*mo
 	}, nil
 }
 
-func (s *AdminService) ListCompanyUsers(taxpayerID int64) ([]models.AdminUserRow, error) {
-	return s.adminRepo.ListUsersByTaxpayer(taxpayerID)
+func (s *AdminService) ListCompanyUsers(ctx context.Context, taxpayerID int64) ([]models.AdminUserRow, error) {
+	return s.adminRepo.ListUsersByTaxpayer(ctx, taxpayerID)
 }
 
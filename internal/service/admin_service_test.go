@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+func newTestAdminService(t *testing.T, txManager func(repository.TxManager) repository.TxManager) (*AdminService, repository.Repositories, []models.Device) {
+	t.Helper()
+
+	monitor, repos, devices := newTestFiscalDayMonitor(t, txManager)
+	return NewAdminService(repos.Admin, repos.Notifications, monitor.fiscalDaySvc, "secret", zap.NewNop()), repos, devices
+}
+
+func TestAdminService_ForceCloseFiscalDay(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     models.FiscalDayStatus
+		dayNo      int
+		failAudit  bool
+		wantCode   int
+		wantStatus models.FiscalDayStatus
+		wantAudit  int
+	}{
+		{"Closes an open day", models.FiscalDayStatusOpened, 1, false, 0, models.FiscalDayStatusClosed, 1},
+		{"Closes a close-failed day", models.FiscalDayStatusCloseFailed, 1, false, 0, models.FiscalDayStatusClosed, 1},
+		{"Closed day", models.FiscalDayStatusClosed, 1, false, 422, models.FiscalDayStatusClosed, 0},
+		{"Unknown day", models.FiscalDayStatusOpened, 2, false, 404, models.FiscalDayStatusOpened, 0},
+		{"Audit failure leaves day open", models.FiscalDayStatusOpened, 1, true, -1, models.FiscalDayStatusOpened, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var wrap func(repository.TxManager) repository.TxManager
+			if tt.failAudit {
+				wrap = func(tx repository.TxManager) repository.TxManager { return failingAuditTx{tx} }
+			}
+			svc, repos, devices := newTestAdminService(t, wrap)
+
+			day := &models.FiscalDay{DeviceID: devices[0].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now().Add(-time.Hour), Status: tt.status}
+			if err := repos.FiscalDays.Create(ctx, day); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			actor := models.AdminActor{Username: "superadmin", IPAddress: "10.0.0.1"}
+			_, err := svc.ForceCloseFiscalDay(ctx, actor, day.DeviceID, tt.dayNo, models.AdminForceCloseFiscalDayRequest{Reason: "stuck"})
+			checkAPIError(t, err, tt.wantCode)
+
+			stored, _ := repos.FiscalDays.GetByID(ctx, day.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", stored.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == models.FiscalDayStatusClosed && tt.wantCode == 0 &&
+				(stored.ReconciliationMode == nil || *stored.ReconciliationMode != models.FiscalDayReconciliationModeManual ||
+					stored.Closure == nil || *stored.Closure != models.FiscalDayClosureForced) {
+				t.Errorf("ReconciliationMode = %v, Closure = %v, want manual and forced", stored.ReconciliationMode, stored.Closure)
+			}
+
+			_, logs, _ := repos.Admin.ListAuditLogs(ctx, "fiscal_day", &day.ID, 0, 10)
+			if len(logs) != tt.wantAudit {
+				t.Fatalf("audit logs = %d, want %d", len(logs), tt.wantAudit)
+			}
+			if len(logs) > 0 && (logs[0].Action != "force_close" || logs[0].Details == nil ||
+				!strings.Contains(*logs[0].Details, `"admin":"superadmin"`) || !strings.Contains(*logs[0].Details, tt.status.String())) {
+				t.Errorf("audit log = %+v", logs[0])
+			}
+		})
+	}
+}
+
+func TestAdminService_ResetFiscalDay(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     models.FiscalDayStatus
+		failAudit  bool
+		wantCode   int
+		wantStatus models.FiscalDayStatus
+		wantAudit  int
+	}{
+		{"Reopens a close-failed day", models.FiscalDayStatusCloseFailed, false, 0, models.FiscalDayStatusOpened, 1},
+		{"Closed day stays closed", models.FiscalDayStatusClosed, false, 422, models.FiscalDayStatusClosed, 0},
+		{"Open day", models.FiscalDayStatusOpened, false, 422, models.FiscalDayStatusOpened, 0},
+		{"Audit failure leaves day close-failed", models.FiscalDayStatusCloseFailed, true, -1, models.FiscalDayStatusCloseFailed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var wrap func(repository.TxManager) repository.TxManager
+			if tt.failAudit {
+				wrap = func(tx repository.TxManager) repository.TxManager { return failingAuditTx{tx} }
+			}
+			svc, repos, devices := newTestAdminService(t, wrap)
+
+			day := &models.FiscalDay{DeviceID: devices[0].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now().Add(-time.Hour), Status: tt.status}
+			if err := repos.FiscalDays.Create(ctx, day); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			actor := models.AdminActor{Username: "superadmin", IPAddress: "10.0.0.1"}
+			_, err := svc.ResetFiscalDay(ctx, actor, day.DeviceID, 1, models.AdminResetFiscalDayRequest{Reason: "retry"})
+			checkAPIError(t, err, tt.wantCode)
+
+			if stored, _ := repos.FiscalDays.GetByID(ctx, day.ID); stored.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", stored.Status, tt.wantStatus)
+			}
+
+			_, logs, _ := repos.Admin.ListAuditLogs(ctx, "fiscal_day", &day.ID, 0, 10)
+			if len(logs) != tt.wantAudit {
+				t.Fatalf("audit logs = %d, want %d", len(logs), tt.wantAudit)
+			}
+			if len(logs) > 0 && (logs[0].Action != "reset" || logs[0].Details == nil || !strings.Contains(*logs[0].Details, `"reason":"retry"`)) {
+				t.Errorf("audit log = %+v", logs[0])
+			}
+		})
+	}
+}
+
+// checkAPIError fails unless err is nil for wantCode 0, an APIError with
+// status wantCode, or any other error for wantCode -1
+func checkAPIError(t *testing.T, err error, wantCode int) {
+	t.Helper()
+
+	switch wantCode {
+	case 0:
+		if err != nil {
+			t.Fatalf("error = %v, want nil", err)
+		}
+	case -1:
+		if err == nil {
+			t.Fatal("error = nil, want an error")
+		}
+	default:
+		apiErr, ok := err.(*models.APIError)
+		if !ok || apiErr.Status != wantCode {
+			t.Fatalf("error = %v, want status %d", err, wantCode)
+		}
+	}
+}
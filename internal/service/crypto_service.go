@@ -9,6 +9,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"math/big"
@@ -77,6 +78,69 @@ func NewCryptoService(cfg config.CryptoConfig) (*CryptoService, error) {
 	return service, nil
 }
 
+// NewEphemeralCryptoService creates a CA and server certificate in memory, for
+// running the API without key material on disk. Nothing it signs can be
+// verified once the process exits.
+func NewEphemeralCryptoService(cfg config.CryptoConfig) (*CryptoService, error) {
+	if cfg.CertificateValidityDays <= 0 {
+		cfg.CertificateValidityDays = 365
+	}
+	service := &CryptoService{
+		config: cfg,
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fiscalization Demo CA", Country: []string{"ZW"}},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	service.caCert, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	service.caKey = caKey
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate server key: %w", err)
+	}
+
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Fiscalization Demo Server", Country: []string{"ZW"}},
+		NotBefore:    now,
+		NotAfter:     now.AddDate(0, 0, cfg.CertificateValidityDays),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, service.caCert, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server certificate: %w", err)
+	}
+	service.serverCert, err = x509.ParseCertificate(serverDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+	service.serverKey = serverKey
+
+	return service, nil
+}
+
 // IssueCertificate issues a new certificate based on CSR
 func (s *CryptoService) IssueCertificate(csrPEM []byte, deviceID int, deviceSerialNo string) (string, []byte, time.Time, error) {
 	// Decode PEM
@@ -199,12 +263,47 @@ func (s *CryptoService) VerifySignature(data, signature []byte, publicKey crypto
 	}
 }
 
+// VerifyDeviceSignature verifies a device signature over an already computed
+// SHA-256 hash, using the public key of the device's PEM certificate
+func (s *CryptoService) VerifyDeviceSignature(certPEM string, hash, signature []byte) error {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return fmt.Errorf("invalid device certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse device certificate: %w", err)
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash, signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash, signature) {
+			return fmt.Errorf("signature verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+}
+
 // GenerateThumbprint generates SHA-1 thumbprint of a certificate
 func (s *CryptoService) GenerateThumbprint(certDER []byte) []byte {
 	thumbprint := sha1.Sum(certDER)
 	return thumbprint[:]
 }
 
+// ServerThumbprint returns the SHA-1 thumbprint of the server certificate
+// that signs receipts and fiscal days
+func (s *CryptoService) ServerThumbprint() []byte {
+	if s.serverCert == nil {
+		return nil
+	}
+	return s.GenerateThumbprint(s.serverCert.Raw)
+}
+
 // GetServerCertificate returns the server certificate chain
 func (s *CryptoService) GetServerCertificate(thumbprint []byte) ([]string, time.Time, error) {
 	// If thumbprint is provided, verify it matches
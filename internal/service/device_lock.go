@@ -0,0 +1,44 @@
+package service
+
+import "sync"
+
+// deviceLocks serialises work per device within this process. Entries are
+// removed once no goroutine holds or waits for them.
+type deviceLocks struct {
+	mu    sync.Mutex
+	locks map[int]*deviceLock
+}
+
+type deviceLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newDeviceLocks() *deviceLocks {
+	return &deviceLocks{locks: make(map[int]*deviceLock)}
+}
+
+// Lock blocks until the device's lock is held and returns its release function
+func (d *deviceLocks) Lock(deviceID int) func() {
+	d.mu.Lock()
+	l, ok := d.locks[deviceID]
+	if !ok {
+		l = &deviceLock{}
+		d.locks[deviceID] = l
+	}
+	l.refs++
+	d.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		d.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(d.locks, deviceID)
+		}
+		d.mu.Unlock()
+	}
+}
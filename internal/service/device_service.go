@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -31,11 +32,12 @@ func NewDeviceService(
 
 // VerifyTaxpayer verifies taxpayer information before device registration
 func (s *DeviceService) VerifyTaxpayer(
+	ctx context.Context,
 	req models.VerifyTaxpayerRequest,
 	modelName, modelVersion string,
 ) (*models.VerifyTaxpayerResponse, error) {
 	// Check if device model is blacklisted
-	blacklisted, err := s.deviceRepo.IsBlacklisted(modelName, modelVersion)
+	blacklisted, err := s.deviceRepo.IsBlacklisted(ctx, modelName, modelVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +46,7 @@ func (s *DeviceService) VerifyTaxpayer(
 	}
 
 	// Get device by ID
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +65,7 @@ func (s *DeviceService) VerifyTaxpayer(
 	}
 
 	// Get taxpayer information
-	taxpayer, err := s.deviceRepo.GetTaxpayer(device.TaxpayerID)
+	taxpayer, err := s.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
 	if err != nil {
 		return nil, err
 	}
@@ -95,11 +97,12 @@ func (s *DeviceService) VerifyTaxpayer(
 
 // RegisterDevice registers a new device and issues certificate
 func (s *DeviceService) RegisterDevice(
+	ctx context.Context,
 	req models.DeviceRegistrationRequest,
 	modelName, modelVersion string,
 ) (*models.DeviceRegistrationResponse, error) {
 	// Check if device model is blacklisted
-	blacklisted, err := s.deviceRepo.IsBlacklisted(modelName, modelVersion)
+	blacklisted, err := s.deviceRepo.IsBlacklisted(ctx, modelName, modelVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +111,7 @@ func (s *DeviceService) RegisterDevice(
 	}
 
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +130,7 @@ func (s *DeviceService) RegisterDevice(
 	}
 
 	// Check taxpayer status
-	taxpayer, err := s.deviceRepo.GetTaxpayer(device.TaxpayerID)
+	taxpayer, err := s.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
 	if err != nil {
 		return nil, err
 	}
@@ -147,13 +150,13 @@ func (s *DeviceService) RegisterDevice(
 	}
 
 	// Update device with certificate
-	err = s.deviceRepo.UpdateCertificate(req.DeviceID, certPEM, thumbprint, validTill)
+	err = s.deviceRepo.UpdateCertificate(ctx, req.DeviceID, certPEM, thumbprint, validTill)
 	if err != nil {
 		return nil, err
 	}
 
 	// Save certificate history
-	err = s.deviceRepo.SaveCertificateHistory(req.DeviceID, certPEM, thumbprint, validTill)
+	err = s.deviceRepo.SaveCertificateHistory(ctx, req.DeviceID, certPEM, thumbprint, validTill)
 	if err != nil {
 		s.logger.Warn("Failed to save certificate history", zap.Error(err))
 	}
@@ -166,10 +169,11 @@ func (s *DeviceService) RegisterDevice(
 
 // IssueCertificate renews device certificate
 func (s *DeviceService) IssueCertificate(
+	ctx context.Context,
 	req models.IssueCertificateRequest,
 ) (*models.IssueCertificateResponse, error) {
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -194,13 +198,13 @@ func (s *DeviceService) IssueCertificate(
 	}
 
 	// Update device with new certificate
-	err = s.deviceRepo.UpdateCertificate(req.DeviceID, certPEM, thumbprint, validTill)
+	err = s.deviceRepo.UpdateCertificate(ctx, req.DeviceID, certPEM, thumbprint, validTill)
 	if err != nil {
 		return nil, err
 	}
 
 	// Save certificate history
-	err = s.deviceRepo.SaveCertificateHistory(req.DeviceID, certPEM, thumbprint, validTill)
+	err = s.deviceRepo.SaveCertificateHistory(ctx, req.DeviceID, certPEM, thumbprint, validTill)
 	if err != nil {
 		s.logger.Warn("Failed to save certificate history", zap.Error(err))
 	}
@@ -212,9 +216,9 @@ func (s *DeviceService) IssueCertificate(
 }
 
 // GetConfig retrieves device configuration
-func (s *DeviceService) GetConfig(deviceID int) (*models.GetConfigResponse, error) {
+func (s *DeviceService) GetConfig(ctx context.Context, deviceID int) (*models.GetConfigResponse, error) {
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(deviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -223,13 +227,13 @@ func (s *DeviceService) GetConfig(deviceID int) (*models.GetConfigResponse, erro
 	}
 
 	// Get taxpayer
-	taxpayer, err := s.deviceRepo.GetTaxpayer(device.TaxpayerID)
+	taxpayer, err := s.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get applicable taxes
-	taxes, err := s.deviceRepo.GetApplicableTaxes()
+	taxes, err := s.deviceRepo.GetApplicableTaxes(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -259,9 +263,9 @@ func (s *DeviceService) GetConfig(deviceID int) (*models.GetConfigResponse, erro
 }
 
 // GetStatus retrieves device and fiscal day status
-func (s *DeviceService) GetStatus(deviceID int) (*models.GetStatusResponse, error) {
+func (s *DeviceService) GetStatus(ctx context.Context, deviceID int) (*models.GetStatusResponse, error) {
 	// Validate operating mode
-	device, err := s.deviceRepo.GetByDeviceID(deviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -274,7 +278,7 @@ func (s *DeviceService) GetStatus(deviceID int) (*models.GetStatusResponse, erro
 	}
 
 	// Get current fiscal day
-	fiscalDay, err := s.deviceRepo.GetCurrentFiscalDay(deviceID)
+	fiscalDay, err := s.deviceRepo.GetCurrentFiscalDay(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -306,14 +310,14 @@ func (s *DeviceService) GetStatus(deviceID int) (*models.GetStatusResponse, erro
 	if fiscalDay.Status == models.FiscalDayStatusClosed {
 		// Get counters and document quantities if manually closed
 		if fiscalDay.ReconciliationMode != nil && *fiscalDay.ReconciliationMode == models.FiscalDayReconciliationModeManual {
-			counters, err := s.deviceRepo.GetFiscalDayCounters(fiscalDay.ID)
+			counters, err := s.deviceRepo.GetFiscalDayCounters(ctx, fiscalDay.ID)
 			if err != nil {
 				s.logger.Warn("Failed to get fiscal day counters", zap.Error(err))
 			} else {
 				resp.FiscalDayCounters = counters
 			}
 
-			docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(fiscalDay.ID)
+			docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(ctx, fiscalDay.ID)
 			if err != nil {
 				s.logger.Warn("Failed to get document quantities", zap.Error(err))
 			} else {
@@ -326,9 +330,9 @@ func (s *DeviceService) GetStatus(deviceID int) (*models.GetStatusResponse, erro
 }
 
 // Ping handles device heartbeat
-func (s *DeviceService) Ping(deviceID int) (*models.PingResponse, error) {
+func (s *DeviceService) Ping(ctx context.Context, deviceID int) (*models.PingResponse, error) {
 	// Update last ping time
-	err := s.deviceRepo.UpdateLastPing(deviceID, time.Now())
+	err := s.deviceRepo.UpdateLastPing(ctx, deviceID, time.Now())
 	if err != nil {
 		return nil, err
 	}
@@ -340,9 +344,9 @@ func (s *DeviceService) Ping(deviceID int) (*models.PingResponse, error) {
 }
 
 // GetStockList retrieves stock items
-func (s *DeviceService) GetStockList(req models.GetStockListRequest) (*models.GetStockListResponse, error) {
+func (s *DeviceService) GetStockList(ctx context.Context, req models.GetStockListRequest) (*models.GetStockListResponse, error) {
 	// Get device to validate taxpayer
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -352,6 +356,7 @@ func (s *DeviceService) GetStockList(req models.GetStockListRequest) (*models.Ge
 
 	// Get stock items
 	total, items, err := s.deviceRepo.GetStockList(
+		ctx,
 		device.TaxpayerID,
 		device.ID,
 		req.HSCode,
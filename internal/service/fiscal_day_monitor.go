@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// FiscalDayMonitor runs the scheduled checks that keep fiscal days within
+// the limits configured on the taxpayer
+type FiscalDayMonitor struct {
+	fiscalDayRepo repository.FiscalDayRepository
+	deviceRepo    repository.DeviceRepository
+	txManager     repository.TxManager
+	fiscalDaySvc  *FiscalDayService
+	notifier      *Notifier
+	logger        *zap.Logger
+}
+
+func NewFiscalDayMonitor(
+	fiscalDayRepo repository.FiscalDayRepository,
+	deviceRepo repository.DeviceRepository,
+	txManager repository.TxManager,
+	fiscalDaySvc *FiscalDayService,
+	notifier *Notifier,
+	logger *zap.Logger,
+) *FiscalDayMonitor {
+	return &FiscalDayMonitor{
+		fiscalDayRepo: fiscalDayRepo,
+		deviceRepo:    deviceRepo,
+		txManager:     txManager,
+		fiscalDaySvc:  fiscalDaySvc,
+		notifier:      notifier,
+		logger:        logger,
+	}
+}
+
+// AutoCloseExpiredDays closes every fiscal day that has been open longer than
+// TaxPayerDayMaxHrs. Failures on one day are logged and do not stop the rest.
+func (m *FiscalDayMonitor) AutoCloseExpiredDays(ctx context.Context) error {
+	fiscalDays, err := m.fiscalDayRepo.ListExceedingMaxHours(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list expired fiscal days: %w", err)
+	}
+
+	for i := range fiscalDays {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fiscalDay := &fiscalDays[i]
+		if err := m.autoClose(ctx, fiscalDay); err != nil {
+			m.logger.Error("Failed to auto-close fiscal day",
+				zap.Int("deviceID", fiscalDay.DeviceID),
+				zap.Int("fiscalDayNo", fiscalDay.FiscalDayNo),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+// NotifyEndingDays reminds taxpayer users about open fiscal days that are
+// within TaxpayerDayEndNotificationHrs of TaxPayerDayMaxHrs. Each day is
+// reminded at most once per threshold.
+func (m *FiscalDayMonitor) NotifyEndingDays(ctx context.Context) error {
+	now := time.Now()
+	fiscalDays, err := m.fiscalDayRepo.ListApproachingMaxHours(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list ending fiscal days: %w", err)
+	}
+
+	for i := range fiscalDays {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fiscalDay := &fiscalDays[i]
+		if err := m.notifyEnding(ctx, fiscalDay, now); err != nil {
+			m.logger.Error("Failed to send fiscal day ending notification",
+				zap.Int("deviceID", fiscalDay.DeviceID),
+				zap.Int("fiscalDayNo", fiscalDay.FiscalDayNo),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (m *FiscalDayMonitor) notifyEnding(ctx context.Context, fiscalDay *models.FiscalDay, now time.Time) error {
+	device, taxpayer, err := m.deviceAndTaxpayer(ctx, fiscalDay.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	closesAt := fiscalDay.FiscalDayOpened.Add(time.Duration(taxpayer.TaxPayerDayMaxHrs) * time.Hour)
+	hoursLeft := int(math.Ceil(closesAt.Sub(now).Hours()))
+
+	// Claiming the reminder and queueing it in one transaction means
+	// overlapping runs can't both send it, and a failed run leaves it unclaimed
+	claimed := false
+	err = m.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		var err error
+		claimed, err = repos.FiscalDays.MarkEndNotificationSent(ctx, fiscalDay.ID, taxpayer.TaxpayerDayEndNotificationHrs)
+		if err != nil || !claimed {
+			return err
+		}
+
+		users, err := repos.Users.GetByTaxpayerID(ctx, taxpayer.ID)
+		if err != nil {
+			return err
+		}
+		return m.notifier.QueueFiscalDayEnding(ctx, repos.Notifications, users, deviceLabel(device), hoursLeft)
+	})
+	if err != nil || !claimed {
+		return err
+	}
+
+	m.logger.Info("Fiscal day ending notification queued",
+		zap.Int("deviceID", fiscalDay.DeviceID),
+		zap.Int("fiscalDayNo", fiscalDay.FiscalDayNo),
+		zap.Int("hoursLeft", hoursLeft),
+	)
+
+	return nil
+}
+
+func (m *FiscalDayMonitor) autoClose(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	device, taxpayer, err := m.deviceAndTaxpayer(ctx, fiscalDay.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	// The audit entry and notifications are written in the closing
+	// transaction, so an auto-close is never left unrecorded
+	_, err = m.fiscalDaySvc.closeWithServerCounters(ctx, fiscalDay, models.FiscalDayClosureAutoClosed,
+		func(repos repository.Repositories, previousStatus models.FiscalDayStatus, counters []models.FiscalDayCounter) error {
+			details, _ := json.Marshal(map[string]interface{}{
+				"fiscalDayNo":     fiscalDay.FiscalDayNo,
+				"fiscalDayOpened": fiscalDay.FiscalDayOpened,
+				"previousStatus":  previousStatus.String(),
+				"maxHrs":          taxpayer.TaxPayerDayMaxHrs,
+				"counters":        len(counters),
+			})
+			if err := repos.Admin.InsertAuditLog(ctx, "fiscal_day", "auto_close", &fiscalDay.ID, nil, &fiscalDay.DeviceID, "system", string(details)); err != nil {
+				return err
+			}
+
+			users, err := repos.Users.GetByTaxpayerID(ctx, taxpayer.ID)
+			if err != nil {
+				return err
+			}
+			return m.notifier.QueueFiscalDayAutoClosed(ctx, repos.Notifications, users, deviceLabel(device), fiscalDay.FiscalDayNo)
+		})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("Fiscal day auto-closed",
+		zap.Int("deviceID", fiscalDay.DeviceID),
+		zap.Int("fiscalDayNo", fiscalDay.FiscalDayNo),
+		zap.Int("maxHrs", taxpayer.TaxPayerDayMaxHrs),
+	)
+
+	return nil
+}
+
+func (m *FiscalDayMonitor) deviceAndTaxpayer(ctx context.Context, deviceID int) (*models.Device, *models.Taxpayer, error) {
+	device, err := m.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if device == nil {
+		return nil, nil, fmt.Errorf("device %d not found", deviceID)
+	}
+
+	taxpayer, err := m.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if taxpayer == nil {
+		return nil, nil, fmt.Errorf("taxpayer %d not found", device.TaxpayerID)
+	}
+
+	return device, taxpayer, nil
+}
+
+// deviceLabel is the human-readable device name used in notifications
+func deviceLabel(device *models.Device) string {
+	return fmt.Sprintf("%s (%s)", device.BranchName, device.DeviceSerialNo)
+}
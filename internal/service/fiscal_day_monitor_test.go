@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/memory"
+
+	"go.uber.org/zap"
+)
+
+// failingAuditRepo refuses every audit entry
+type failingAuditRepo struct {
+	repository.AdminRepository
+}
+
+func (r failingAuditRepo) InsertAuditLog(ctx context.Context, entityType, action string, entityID, userID *int64, deviceID *int, ipAddress, details string) error {
+	return errors.New("audit log unavailable")
+}
+
+// failingAuditTx runs transactions whose audit log refuses entries
+type failingAuditTx struct {
+	repository.TxManager
+}
+
+func (m failingAuditTx) WithinTx(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	return m.TxManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		repos.Admin = failingAuditRepo{repos.Admin}
+		return fn(repos)
+	})
+}
+
+func newTestFiscalDayMonitor(t *testing.T, txManager func(repository.TxManager) repository.TxManager) (*FiscalDayMonitor, repository.Repositories, []models.Device) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	store := memory.NewStore()
+	devices, err := memory.SeedDemo(context.Background(), store)
+	if err != nil {
+		t.Fatalf("SeedDemo() error = %v", err)
+	}
+	repos := store.Repositories()
+	tx := memory.NewTxManager(store)
+	if txManager != nil {
+		tx = txManager(tx)
+	}
+
+	fiscalDaySvc := NewFiscalDayService(repos.FiscalDays, repos.Receipts, repos.Devices, tx, &CryptoService{serverKey: key}, zap.NewNop())
+	monitor := NewFiscalDayMonitor(repos.FiscalDays, repos.Devices, tx, fiscalDaySvc, NewNotifier(nil, nil, nil, zap.NewNop()), zap.NewNop())
+	return monitor, repos, devices
+}
+
+// createDays opens one fiscal day per device, opened the given time ago
+func createDays(t *testing.T, repos repository.Repositories, devices []models.Device, ages ...time.Duration) []*models.FiscalDay {
+	t.Helper()
+
+	var days []*models.FiscalDay
+	for i, age := range ages {
+		day := &models.FiscalDay{DeviceID: devices[i].DeviceID, FiscalDayNo: 1, FiscalDayOpened: time.Now().Add(-age), Status: models.FiscalDayStatusOpened}
+		if err := repos.FiscalDays.Create(context.Background(), day); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+func notificationsOfKind(t *testing.T, repos repository.Repositories, kind models.NotificationKind) int {
+	t.Helper()
+
+	_, rows, err := repos.Notifications.List(context.Background(), "", 0, 100)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	count := 0
+	for _, row := range rows {
+		if row.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func TestFiscalDayMonitor_AutoCloseExpiredDays(t *testing.T) {
+	ctx := context.Background()
+	monitor, repos, devices := newTestFiscalDayMonitor(t, nil)
+
+	// The demo taxpayers allow 24 hours per day
+	days := createDays(t, repos, devices, 30*time.Hour, time.Hour)
+
+	// A second run finds nothing left to close
+	for run := 0; run < 2; run++ {
+		if err := monitor.AutoCloseExpiredDays(ctx); err != nil {
+			t.Fatalf("AutoCloseExpiredDays() error = %v", err)
+		}
+	}
+
+	expired, _ := repos.FiscalDays.GetByID(ctx, days[0].ID)
+	if expired.Status != models.FiscalDayStatusClosed || expired.ReconciliationMode == nil ||
+		*expired.ReconciliationMode != models.FiscalDayReconciliationModeManual ||
+		expired.Closure == nil || *expired.Closure != models.FiscalDayClosureAutoClosed || expired.FiscalDayServerSignature == nil {
+		t.Errorf("expired day = %+v, want auto-closed, reconciled manually and signed", expired)
+	}
+	if current, _ := repos.FiscalDays.GetByID(ctx, days[1].ID); current.Status != models.FiscalDayStatusOpened {
+		t.Errorf("day within the limit has status %v, want %v", current.Status, models.FiscalDayStatusOpened)
+	}
+
+	_, logs, _ := repos.Admin.ListAuditLogs(ctx, "fiscal_day", &days[0].ID, 0, 10)
+	if len(logs) != 1 || logs[0].Action != "auto_close" || logs[0].UserID != nil {
+		t.Errorf("audit logs = %+v, want one auto_close entry without a user", logs)
+	}
+	if got := notificationsOfKind(t, repos, models.NotificationKindFiscalDayAutoClosed); got != 1 {
+		t.Errorf("auto-close notifications = %d, want 1", got)
+	}
+}
+
+func TestFiscalDayMonitor_AutoCloseRollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	monitor, repos, devices := newTestFiscalDayMonitor(t, func(tx repository.TxManager) repository.TxManager {
+		return failingAuditTx{tx}
+	})
+	days := createDays(t, repos, devices, 30*time.Hour)
+
+	if err := monitor.AutoCloseExpiredDays(ctx); err != nil {
+		t.Fatalf("AutoCloseExpiredDays() error = %v", err)
+	}
+
+	if day, _ := repos.FiscalDays.GetByID(ctx, days[0].ID); day.Status != models.FiscalDayStatusOpened {
+		t.Errorf("Status = %v, want %v when the audit entry can't be written", day.Status, models.FiscalDayStatusOpened)
+	}
+	if got := notificationsOfKind(t, repos, models.NotificationKindFiscalDayAutoClosed); got != 0 {
+		t.Errorf("auto-close notifications = %d, want none for a rolled back close", got)
+	}
+}
+
+func TestFiscalDayMonitor_NotifyEndingDays(t *testing.T) {
+	ctx := context.Background()
+	monitor, repos, devices := newTestFiscalDayMonitor(t, nil)
+
+	// Reminders go out in the last 2 of the 24 hours, and only for open days
+	createDays(t, repos, devices, 23*time.Hour, time.Hour, 25*time.Hour)
+	closed := &models.FiscalDay{DeviceID: devices[1].DeviceID, FiscalDayNo: 2, FiscalDayOpened: time.Now().Add(-23 * time.Hour), Status: models.FiscalDayStatusClosed}
+	if err := repos.FiscalDays.Create(ctx, closed); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for run := 0; run < 3; run++ {
+		if err := monitor.NotifyEndingDays(ctx); err != nil {
+			t.Fatalf("NotifyEndingDays() error = %v", err)
+		}
+	}
+
+	if got := notificationsOfKind(t, repos, models.NotificationKindFiscalDayEnding); got != 1 {
+		t.Errorf("ending notifications = %d, want 1", got)
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -15,6 +17,7 @@ type FiscalDayService struct {
 	fiscalDayRepo repository.FiscalDayRepository
 	receiptRepo   repository.ReceiptRepository
 	deviceRepo    repository.DeviceRepository
+	txManager     repository.TxManager
 	cryptoSvc     *CryptoService
 	logger        *zap.Logger
 }
@@ -23,6 +26,7 @@ func NewFiscalDayService(
 	fiscalDayRepo repository.FiscalDayRepository,
 	receiptRepo repository.ReceiptRepository,
 	deviceRepo repository.DeviceRepository,
+	txManager repository.TxManager,
 	cryptoSvc *CryptoService,
 	logger *zap.Logger,
 ) *FiscalDayService {
@@ -30,15 +34,16 @@ func NewFiscalDayService(
 		fiscalDayRepo: fiscalDayRepo,
 		receiptRepo:   receiptRepo,
 		deviceRepo:    deviceRepo,
+		txManager:     txManager,
 		cryptoSvc:     cryptoSvc,
 		logger:        logger,
 	}
 }
 
-// OpenFiscalDay opens a new fiscal day
-func (s *FiscalDayService) OpenFiscalDay(req models.OpenFiscalDayRequest) (*models.OpenFiscalDayResponse, error) {
+// OpenFiscalDay opens a new fiscal day on behalf of operator
+func (s *FiscalDayService) OpenFiscalDay(ctx context.Context, operator models.Operator, req models.OpenFiscalDayRequest) (*models.OpenFiscalDayResponse, error) {
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +57,7 @@ func (s *FiscalDayService) OpenFiscalDay(req models.OpenFiscalDayRequest) (*mode
 	}
 
 	// Get current fiscal day
-	currentDay, err := s.fiscalDayRepo.GetCurrent(req.DeviceID)
+	currentDay, err := s.fiscalDayRepo.GetCurrent(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,16 +77,39 @@ func (s *FiscalDayService) OpenFiscalDay(req models.OpenFiscalDayRequest) (*mode
 	if currentDay != nil {
 		nextDayNo = currentDay.FiscalDayNo + 1
 	}
+	if req.FiscalDayNo != nil && *req.FiscalDayNo != nextDayNo {
+		return nil, models.NewAPIError(400, fmt.Sprintf("Fiscal day number must be %d", nextDayNo), "")
+	}
+
+	// Devices may report when they opened the day, within the same clock
+	// tolerance as receipt dates
+	opened := time.Now()
+	if req.FiscalDayOpened != nil {
+		if req.FiscalDayOpened.After(opened.Add(5 * time.Minute)) {
+			return nil, models.NewAPIError(400, "Fiscal day opening date is in the future", "")
+		}
+		if currentDay != nil && currentDay.FiscalDayClosed != nil && req.FiscalDayOpened.Before(*currentDay.FiscalDayClosed) {
+			return nil, models.NewAPIError(400, "Fiscal day opening date is before the previous fiscal day was closed", "")
+		}
+		opened = *req.FiscalDayOpened
+	}
 
 	// Create new fiscal day
 	fiscalDay := &models.FiscalDay{
 		DeviceID:        req.DeviceID,
 		FiscalDayNo:     nextDayNo,
-		FiscalDayOpened: time.Now(),
+		FiscalDayOpened: opened,
 		Status:          models.FiscalDayStatusOpened,
 	}
 
-	if err := s.fiscalDayRepo.Create(fiscalDay); err != nil {
+	// The day and its audit entry are committed together
+	err = s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		if err := repos.FiscalDays.Create(ctx, fiscalDay); err != nil {
+			return err
+		}
+		return auditFiscalDay(ctx, repos.Admin, "open", fiscalDay, operator)
+	})
+	if err != nil {
 		s.logger.Error("Failed to create fiscal day", zap.Error(err))
 		return nil, fmt.Errorf("failed to create fiscal day: %w", err)
 	}
@@ -89,6 +117,7 @@ func (s *FiscalDayService) OpenFiscalDay(req models.OpenFiscalDayRequest) (*mode
 	s.logger.Info("Fiscal day opened",
 		zap.Int("deviceID", req.DeviceID),
 		zap.Int("fiscalDayNo", nextDayNo),
+		zap.String("operator", operator.Username),
 	)
 
 	return &models.OpenFiscalDayResponse{
@@ -97,10 +126,10 @@ func (s *FiscalDayService) OpenFiscalDay(req models.OpenFiscalDayRequest) (*mode
 	}, nil
 }
 
-// CloseFiscalDay closes the current fiscal day
-func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*models.CloseFiscalDayResponse, error) {
+// CloseFiscalDay closes the current fiscal day on behalf of operator
+func (s *FiscalDayService) CloseFiscalDay(ctx context.Context, operator models.Operator, req models.CloseFiscalDayRequest) (*models.CloseFiscalDayResponse, error) {
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -114,13 +143,16 @@ func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*mo
 	}
 
 	// Get current fiscal day
-	fiscalDay, err := s.fiscalDayRepo.GetCurrent(req.DeviceID)
+	fiscalDay, err := s.fiscalDayRepo.GetCurrent(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
 	if fiscalDay == nil {
 		return nil, models.NewAPIError(422, "No fiscal day to close", models.ErrCodeFISC03)
 	}
+	if req.FiscalDayNo != nil && *req.FiscalDayNo != fiscalDay.FiscalDayNo {
+		return nil, models.NewAPIError(422, fmt.Sprintf("Fiscal day %d is not the current fiscal day", *req.FiscalDayNo), models.ErrCodeFISC03)
+	}
 
 	// Check if day is opened or close failed
 	if fiscalDay.Status != models.FiscalDayStatusOpened && fiscalDay.Status != models.FiscalDayStatusCloseFailed {
@@ -128,7 +160,7 @@ func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*mo
 	}
 
 	// Check for validation errors that block closing
-	receiptsWithErrors, err := s.receiptRepo.GetReceiptsWithValidationErrors(fiscalDay.ID)
+	receiptsWithErrors, err := s.receiptRepo.GetReceiptsWithValidationErrors(ctx, fiscalDay.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -155,89 +187,108 @@ func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*mo
 		reconciliationMode = models.FiscalDayReconciliationModeManual
 	}
 
-	// Validate counters if manual mode
+	// Counters are determined and stored with the day in one transaction, so
+	// a day is never Closed without its counters
 	var counters []models.FiscalDayCounter
-	if reconciliationMode == models.FiscalDayReconciliationModeManual {
-		counters = req.FiscalDayCounters
-
-		// Validate submitted counters match actual
-		valid, err := s.fiscalDayRepo.ValidateCounters(fiscalDay.ID, counters)
+	var serverSignature *models.SignatureDataEx
+	err = s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		// Lock the day so no receipt can be added while it is being closed
+		locked, err := repos.FiscalDays.GetByIDForUpdate(ctx, fiscalDay.ID)
 		if err != nil {
-			s.logger.Error("Failed to validate counters", zap.Error(err))
-			return nil, fmt.Errorf("failed to validate counters: %w", err)
+			return err
+		}
+		if locked == nil || (locked.Status != models.FiscalDayStatusOpened && locked.Status != models.FiscalDayStatusCloseFailed) {
+			return models.NewAPIError(422, "Fiscal day cannot be closed", models.ErrCodeFISC03)
 		}
 
-		if !valid {
-			return nil, models.NewAPIError(422, "Submitted counters do not match actual values", models.ErrCodeFISC04)
+		// Validate counters if manual mode
+		if reconciliationMode == models.FiscalDayReconciliationModeManual {
+			counters = req.FiscalDayCounters
+
+			// Validate submitted counters match actual
+			valid, err := repos.FiscalDays.ValidateCounters(ctx, locked.ID, counters)
+			if err != nil {
+				return fmt.Errorf("failed to validate counters: %w", err)
+			}
+
+			if !valid {
+				return models.NewAPIError(422, "Submitted counters do not match actual values", models.ErrCodeFISC04)
+			}
+		} else {
+			// Calculate counters automatically
+			counters, err = repos.FiscalDays.CalculateCounters(ctx, locked.ID)
+			if err != nil {
+				return fmt.Errorf("failed to calculate counters: %w", err)
+			}
 		}
-	} else {
-		// Calculate counters automatically
-		counters, err = s.fiscalDayRepo.GetCounters(fiscalDay.ID)
+
+		// Generate fiscal day hash
+		fiscalDayDate := locked.FiscalDayOpened.Format("2006-01-02")
+		_, err = utils.GenerateFiscalDayHash(
+			req.DeviceID,
+			locked.FiscalDayNo,
+			fiscalDayDate,
+			counters,
+		)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to generate fiscal day hash: %w", err)
 		}
-	}
 
-	// Generate fiscal day hash
-	fiscalDayDate := fiscalDay.FiscalDayOpened.Format("2006-01-02")
-	_, err = utils.GenerateFiscalDayHash(
-		req.DeviceID,
-		fiscalDay.FiscalDayNo,
-		fiscalDayDate,
-		counters,
-	)
-	if err != nil {
-		s.logger.Error("Failed to generate fiscal day hash", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate fiscal day hash: %w", err)
-	}
+		// Verify device signature if auto mode
+		if reconciliationMode == models.FiscalDayReconciliationModeAuto {
+			if req.FiscalDayDeviceSignature == nil {
+				return models.NewAPIError(422, "Device signature required for auto reconciliation", models.ErrCodeFISC04)
+			}
 
-	// Verify device signature if auto mode
-	if reconciliationMode == models.FiscalDayReconciliationModeAuto {
-		if req.FiscalDayDeviceSignature == nil {
-			return nil, models.NewAPIError(422, "Device signature required for auto reconciliation", models.ErrCodeFISC04)
+			// In production, verify the signature here
+			// For now, just store it
 		}
 
-		// In production, verify the signature here
-		// For now, just store it
-	}
+		// Generate server signature
+		closedAt := time.Now()
+		serverSignature, err = s.generateFiscalDayServerSignature(
+			req.DeviceID,
+			locked.FiscalDayNo,
+			fiscalDayDate,
+			closedAt,
+			reconciliationMode,
+			counters,
+			req.FiscalDayDeviceSignature,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to generate server signature: %w", err)
+		}
 
-	// Generate server signature
-	closedAt := time.Now()
-	serverSignature, err := s.generateFiscalDayServerSignature(
-		req.DeviceID,
-		fiscalDay.FiscalDayNo,
-		fiscalDayDate,
-		closedAt,
-		reconciliationMode,
-		counters,
-		req.FiscalDayDeviceSignature,
-	)
-	if err != nil {
-		s.logger.Error("Failed to generate server signature", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate server signature: %w", err)
-	}
+		// Update the locked day, which has the receipts stored since it was first read
+		locked.FiscalDayClosed = &closedAt
+		locked.Status = models.FiscalDayStatusClosed
+		locked.ReconciliationMode = &reconciliationMode
+		locked.FiscalDayDeviceSignature = req.FiscalDayDeviceSignature
+		locked.FiscalDayServerSignature = serverSignature
 
-	// Update fiscal day
-	fiscalDay.FiscalDayClosed = &closedAt
-	fiscalDay.Status = models.FiscalDayStatusClosed
-	fiscalDay.ReconciliationMode = &reconciliationMode
-	fiscalDay.FiscalDayDeviceSignature = req.FiscalDayDeviceSignature
-	fiscalDay.FiscalDayServerSignature = serverSignature
+		if err := repos.FiscalDays.Update(ctx, locked); err != nil {
+			return fmt.Errorf("failed to update fiscal day: %w", err)
+		}
 
-	if err := s.fiscalDayRepo.Update(fiscalDay); err != nil {
-		s.logger.Error("Failed to update fiscal day", zap.Error(err))
-		return nil, fmt.Errorf("failed to update fiscal day: %w", err)
-	}
+		// Save counters
+		if err := repos.FiscalDays.CreateCounters(ctx, locked.ID, counters); err != nil {
+			return fmt.Errorf("failed to save counters: %w", err)
+		}
 
-	// Save counters
-	if err := s.fiscalDayRepo.CreateCounters(fiscalDay.ID, counters); err != nil {
-		s.logger.Warn("Failed to save counters", zap.Error(err))
+		return auditFiscalDay(ctx, repos.Admin, "close", locked, operator)
+	})
+	if err != nil {
+		if _, ok := err.(*models.APIError); !ok {
+			s.logger.Error("Failed to close fiscal day", zap.Error(err))
+		}
+		return nil, err
 	}
 
 	s.logger.Info("Fiscal day closed",
 		zap.Int("deviceID", req.DeviceID),
 		zap.Int("fiscalDayNo", fiscalDay.FiscalDayNo),
 		zap.String("reconciliationMode", string(rune(reconciliationMode))),
+		zap.String("operator", operator.Username),
 	)
 
 	resp := &models.CloseFiscalDayResponse{
@@ -248,7 +299,7 @@ func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*mo
 	}
 
 	// Get document quantities
-	docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(fiscalDay.ID)
+	docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(ctx, fiscalDay.ID)
 	if err != nil {
 		s.logger.Warn("Failed to get document quantities", zap.Error(err))
 	} else {
@@ -259,9 +310,9 @@ func (s *FiscalDayService) CloseFiscalDay(req models.CloseFiscalDayRequest) (*mo
 }
 
 // GetFiscalDayStatus gets the status of the current fiscal day
-func (s *FiscalDayService) GetFiscalDayStatus(deviceID int) (*models.GetFiscalDayStatusResponse, error) {
+func (s *FiscalDayService) GetFiscalDayStatus(ctx context.Context, deviceID int) (*models.GetFiscalDayStatusResponse, error) {
 	// Get current fiscal day
-	fiscalDay, err := s.fiscalDayRepo.GetCurrent(deviceID)
+	fiscalDay, err := s.fiscalDayRepo.GetCurrent(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -275,11 +326,12 @@ func (s *FiscalDayService) GetFiscalDayStatus(deviceID int) (*models.GetFiscalDa
 		return resp, nil
 	}
 
-	mode := fiscalDay.ReconciliationMode.String()
-
 	resp.FiscalDayStatus = fiscalDay.Status.String()
 	resp.FiscalDayNo = &fiscalDay.FiscalDayNo
-	resp.FiscalDayReconciliationMode = &mode
+	if fiscalDay.ReconciliationMode != nil {
+		mode := fiscalDay.ReconciliationMode.String()
+		resp.FiscalDayReconciliationMode = &mode
+	}
 	resp.FiscalDayServerSignature = fiscalDay.FiscalDayServerSignature
 	resp.FiscalDayClosed = fiscalDay.FiscalDayClosed
 	resp.LastReceiptGlobalNo = fiscalDay.LastReceiptGlobalNo
@@ -287,6 +339,229 @@ func (s *FiscalDayService) GetFiscalDayStatus(deviceID int) (*models.GetFiscalDa
 	return resp, nil
 }
 
+// GetFiscalDay returns a fiscal day of the device by number, including its
+// stored counters and document quantities
+func (s *FiscalDayService) GetFiscalDay(ctx context.Context, deviceID, fiscalDayNo int) (*models.GetFiscalDayResponse, error) {
+	fiscalDay, err := s.fiscalDayRepo.GetByDayNo(ctx, deviceID, fiscalDayNo)
+	if err != nil {
+		return nil, err
+	}
+	if fiscalDay == nil {
+		return nil, models.NewAPIError(404, "Fiscal day not found", models.ErrCodeFISC05)
+	}
+
+	resp := &models.GetFiscalDayResponse{
+		OperationID:                 generateOperationID(),
+		FiscalDayNo:                 fiscalDay.FiscalDayNo,
+		FiscalDayStatus:             fiscalDay.Status.String(),
+		FiscalDayOpened:             fiscalDay.FiscalDayOpened,
+		FiscalDayClosed:             fiscalDay.FiscalDayClosed,
+		FiscalDayDeviceSignature:    fiscalDay.FiscalDayDeviceSignature,
+		FiscalDayServerSignature:    fiscalDay.FiscalDayServerSignature,
+		LastReceiptGlobalNo:         fiscalDay.LastReceiptGlobalNo,
+		FiscalDayCounters:           make([]models.FiscalDayCounter, 0),
+		FiscalDayDocumentQuantities: make([]models.FiscalDayDocumentQuantity, 0),
+	}
+
+	if fiscalDay.ReconciliationMode != nil {
+		mode := fiscalDay.ReconciliationMode.String()
+		resp.FiscalDayReconciliationMode = &mode
+	}
+	if fiscalDay.ClosingErrorCode != nil {
+		code := fiscalDay.ClosingErrorCode.String()
+		resp.FiscalDayClosingErrorCode = &code
+	}
+
+	counters, err := s.fiscalDayRepo.GetCounters(ctx, fiscalDay.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get counters: %w", err)
+	}
+	if counters != nil {
+		resp.FiscalDayCounters = counters
+	}
+
+	docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(ctx, fiscalDay.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document quantities: %w", err)
+	}
+	if docQuantities != nil {
+		resp.FiscalDayDocumentQuantities = docQuantities
+	}
+
+	return resp, nil
+}
+
+// ForceCloseFiscalDay closes a stuck Opened or CloseFailed day on behalf of the
+// device, using server-computed counters. A non-nil within runs in the closing
+// transaction and gets the status the day had before closing.
+func (s *FiscalDayService) ForceCloseFiscalDay(
+	ctx context.Context,
+	deviceID, fiscalDayNo int,
+	within func(repos repository.Repositories, fiscalDayID int64, previousStatus models.FiscalDayStatus) error,
+) (*models.FiscalDay, error) {
+	fiscalDay, err := s.fiscalDayRepo.GetByDayNo(ctx, deviceID, fiscalDayNo)
+	if err != nil {
+		return nil, err
+	}
+	if fiscalDay == nil {
+		return nil, models.NewAPIError(404, "Fiscal day not found", models.ErrCodeFISC05)
+	}
+
+	if fiscalDay.Status != models.FiscalDayStatusOpened && fiscalDay.Status != models.FiscalDayStatusCloseFailed {
+		return nil, models.NewAPIError(422, "Fiscal day cannot be closed", models.ErrCodeFISC03)
+	}
+
+	_, err = s.closeWithServerCounters(ctx, fiscalDay, models.FiscalDayClosureForced,
+		func(repos repository.Repositories, previousStatus models.FiscalDayStatus, counters []models.FiscalDayCounter) error {
+			if within != nil {
+				return within(repos, fiscalDay.ID, previousStatus)
+			}
+			return nil
+		})
+	if err != nil {
+		if _, ok := err.(*models.APIError); !ok {
+			s.logger.Error("Failed to force-close fiscal day", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Fiscal day force-closed",
+		zap.Int("deviceID", deviceID),
+		zap.Int("fiscalDayNo", fiscalDayNo),
+	)
+
+	return fiscalDay, nil
+}
+
+// ResetFiscalDay moves a CloseFailed day back to Opened so the device can
+// retry closing it. The day is locked and its status checked again, so a day
+// the device closed in the meantime is left alone. A non-nil within runs in
+// the same transaction.
+func (s *FiscalDayService) ResetFiscalDay(
+	ctx context.Context,
+	deviceID, fiscalDayNo int,
+	within func(repos repository.Repositories, fiscalDayID int64) error,
+) (*models.FiscalDay, error) {
+	fiscalDay, err := s.fiscalDayRepo.GetByDayNo(ctx, deviceID, fiscalDayNo)
+	if err != nil {
+		return nil, err
+	}
+	if fiscalDay == nil {
+		return nil, models.NewAPIError(404, "Fiscal day not found", models.ErrCodeFISC05)
+	}
+
+	var reset *models.FiscalDay
+	err = s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		locked, err := repos.FiscalDays.GetByIDForUpdate(ctx, fiscalDay.ID)
+		if err != nil {
+			return err
+		}
+		if locked == nil || locked.Status != models.FiscalDayStatusCloseFailed {
+			return models.NewAPIError(422, "Only fiscal days in CloseFailed status can be reset", models.ErrCodeFISC03)
+		}
+
+		locked.Status = models.FiscalDayStatusOpened
+		locked.ClosingErrorCode = nil
+
+		if err := repos.FiscalDays.Update(ctx, locked); err != nil {
+			return fmt.Errorf("failed to update fiscal day: %w", err)
+		}
+
+		reset = locked
+		if within != nil {
+			return within(repos, locked.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*models.APIError); !ok {
+			s.logger.Error("Failed to reset fiscal day", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Fiscal day reset to opened",
+		zap.Int("deviceID", deviceID),
+		zap.Int("fiscalDayNo", fiscalDayNo),
+	)
+
+	return reset, nil
+}
+
+// closeWithServerCounters closes a fiscal day without device input, using
+// counters calculated from the receipts stored on the server, and records
+// closure on it. The day is reconciled manually. A non-nil
+// within runs in the closing transaction, after the day is closed, and gets
+// the status the day had and its counters. On success fiscalDay is updated
+// to the closed day.
+func (s *FiscalDayService) closeWithServerCounters(
+	ctx context.Context,
+	fiscalDay *models.FiscalDay,
+	closure models.FiscalDayClosure,
+	within func(repos repository.Repositories, previousStatus models.FiscalDayStatus, counters []models.FiscalDayCounter) error,
+) ([]models.FiscalDayCounter, error) {
+	reconciliationMode := models.FiscalDayReconciliationModeManual
+	var counters []models.FiscalDayCounter
+	var closed models.FiscalDay
+	err := s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		// Lock the day so no receipt can be added while it is being closed
+		locked, err := repos.FiscalDays.GetByIDForUpdate(ctx, fiscalDay.ID)
+		if err != nil {
+			return err
+		}
+		if locked == nil || (locked.Status != models.FiscalDayStatusOpened && locked.Status != models.FiscalDayStatusCloseFailed) {
+			return models.NewAPIError(422, "Fiscal day cannot be closed", models.ErrCodeFISC03)
+		}
+		previousStatus := locked.Status
+
+		counters, err = repos.FiscalDays.CalculateCounters(ctx, locked.ID)
+		if err != nil {
+			return fmt.Errorf("failed to calculate counters: %w", err)
+		}
+
+		closedAt := time.Now()
+		serverSignature, err := s.generateFiscalDayServerSignature(
+			locked.DeviceID,
+			locked.FiscalDayNo,
+			locked.FiscalDayOpened.Format("2006-01-02"),
+			closedAt,
+			reconciliationMode,
+			counters,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to generate server signature: %w", err)
+		}
+
+		locked.FiscalDayClosed = &closedAt
+		locked.Status = models.FiscalDayStatusClosed
+		locked.ReconciliationMode = &reconciliationMode
+		locked.Closure = &closure
+		locked.FiscalDayServerSignature = serverSignature
+		locked.ClosingErrorCode = nil
+
+		if err := repos.FiscalDays.Update(ctx, locked); err != nil {
+			return fmt.Errorf("failed to update fiscal day: %w", err)
+		}
+
+		if err := repos.FiscalDays.CreateCounters(ctx, locked.ID, counters); err != nil {
+			return fmt.Errorf("failed to save counters: %w", err)
+		}
+
+		closed = *locked
+		if within != nil {
+			return within(repos, previousStatus, counters)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	*fiscalDay = closed
+	return counters, nil
+}
+
 // generateFiscalDayServerSignature generates FDMS signature for fiscal day
 func (s *FiscalDayService) generateFiscalDayServerSignature(
 	deviceID int,
@@ -322,14 +597,31 @@ func (s *FiscalDayService) generateFiscalDayServerSignature(
 		return nil, err
 	}
 
-	// Get certificate thumbprint (placeholder)
-	thumbprint := make([]byte, 20)
-
 	return &models.SignatureDataEx{
 		SignatureData: models.SignatureData{
 			Hash:      hash,
 			Signature: signature,
 		},
-		CertificateThumbprint: thumbprint,
+		CertificateThumbprint: s.cryptoSvc.ServerThumbprint(),
 	}, nil
 }
+
+// auditFiscalDay records action on fiscalDay in the audit log along with the
+// operator who performed it. Days acted on without an operator are recorded
+// without a user.
+func auditFiscalDay(ctx context.Context, audit repository.AdminRepository, action string, fiscalDay *models.FiscalDay, operator models.Operator) error {
+	details, _ := json.Marshal(map[string]interface{}{
+		"fiscalDayNo":  fiscalDay.FiscalDayNo,
+		"operator":     operator.Username,
+		"operatorName": operator.Name,
+	})
+	ipAddress := operator.IPAddress
+	if ipAddress == "" {
+		ipAddress = "system"
+	}
+	var userID *int64
+	if operator.UserID != 0 {
+		userID = &operator.UserID
+	}
+	return audit.InsertAuditLog(ctx, "fiscal_day", action, &fiscalDay.ID, userID, &fiscalDay.DeviceID, ipAddress, string(details))
+}
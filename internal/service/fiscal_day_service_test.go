@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/memory"
+
+	"go.uber.org/zap"
+)
+
+// closingFiscalDayRepo holds one fiscal day and its stored counters.
+// lateReceiptNo, when set, is a receipt stored after the day was read but
+// before it was locked, so the locked day differs from the one read first.
+// staleRead, when set, is returned by GetByDayNo in place of the stored day.
+type closingFiscalDayRepo struct {
+	repository.FiscalDayRepository
+
+	day           models.FiscalDay
+	counters      []models.FiscalDayCounter
+	failCounters  bool
+	lateReceiptNo int
+	staleRead     *models.FiscalDay
+}
+
+func (r *closingFiscalDayRepo) GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	copied := r.day
+	return &copied, nil
+}
+
+func (r *closingFiscalDayRepo) GetByDayNo(ctx context.Context, deviceID, fiscalDayNo int) (*models.FiscalDay, error) {
+	copied := r.day
+	if r.staleRead != nil {
+		copied = *r.staleRead
+	}
+	return &copied, nil
+}
+
+func (r *closingFiscalDayRepo) GetByIDForUpdate(ctx context.Context, id int64) (*models.FiscalDay, error) {
+	copied := r.day
+	if r.lateReceiptNo != 0 {
+		copied.LastReceiptGlobalNo = &r.lateReceiptNo
+	}
+	return &copied, nil
+}
+
+func (r *closingFiscalDayRepo) CalculateCounters(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayCounter, error) {
+	return []models.FiscalDayCounter{{
+		FiscalCounterType:     int(models.FiscalCounterTypeSaleByTax),
+		FiscalCounterCurrency: "USD",
+		FiscalCounterValue:    100,
+	}}, nil
+}
+
+func (r *closingFiscalDayRepo) Update(ctx context.Context, fiscalDay *models.FiscalDay) error {
+	r.day = *fiscalDay
+	return nil
+}
+
+func (r *closingFiscalDayRepo) CreateCounters(ctx context.Context, fiscalDayID int64, counters []models.FiscalDayCounter) error {
+	if r.failCounters {
+		return errors.New("insert failed")
+	}
+	r.counters = counters
+	return nil
+}
+
+// fakeTxManager runs fn against a copy of the fiscal day repository and only
+// keeps the copy when fn succeeds, like a commit
+type fakeTxManager struct {
+	fiscalDayRepo *closingFiscalDayRepo
+	audit         repository.AdminRepository
+}
+
+func (m *fakeTxManager) WithinTx(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	staged := *m.fiscalDayRepo
+	if err := fn(repository.Repositories{FiscalDays: &staged, Admin: m.audit}); err != nil {
+		return err
+	}
+	*m.fiscalDayRepo = staged
+	return nil
+}
+
+func (r *fakeReceiptRepo) GetReceiptsWithValidationErrors(ctx context.Context, fiscalDayID int64) ([]models.Receipt, error) {
+	return nil, nil
+}
+
+func (r *fakeDeviceRepo) GetFiscalDayDocumentQuantities(ctx context.Context, fiscalDayID int64) ([]models.FiscalDayDocumentQuantity, error) {
+	return nil, nil
+}
+
+func newTestFiscalDayService(t *testing.T, failCounters bool) (*FiscalDayService, *closingFiscalDayRepo, repository.AdminRepository) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	fiscalDayRepo := &closingFiscalDayRepo{
+		day: models.FiscalDay{
+			ID:              1,
+			DeviceID:        1001,
+			FiscalDayNo:     1,
+			FiscalDayOpened: time.Now().Add(-time.Hour),
+			Status:          models.FiscalDayStatusOpened,
+		},
+		failCounters:  failCounters,
+		lateReceiptNo: 5,
+	}
+
+	audit := memory.NewAdminRepository(memory.NewStore())
+	svc := NewFiscalDayService(
+		fiscalDayRepo,
+		newFakeReceiptRepo(),
+		&fakeDeviceRepo{},
+		&fakeTxManager{fiscalDayRepo: fiscalDayRepo, audit: audit},
+		&CryptoService{serverKey: key},
+		zap.NewNop(),
+	)
+	return svc, fiscalDayRepo, audit
+}
+
+func TestFiscalDayService_CloseFiscalDay(t *testing.T) {
+	tests := []struct {
+		name         string
+		failCounters bool
+		wantErr      bool
+		wantStatus   models.FiscalDayStatus
+		wantCounters int
+		wantAudit    int
+	}{
+		{"Closes with counters", false, false, models.FiscalDayStatusClosed, 1, 1},
+		{"Counter failure leaves day open", true, true, models.FiscalDayStatusOpened, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, audit := newTestFiscalDayService(t, tt.failCounters)
+
+			operator := models.Operator{UserID: 7, Username: "cashier", Name: "Tendai Moyo", IPAddress: "10.0.0.5"}
+			_, err := svc.CloseFiscalDay(context.Background(), operator, models.CloseFiscalDayRequest{
+				DeviceID:                 1001,
+				FiscalDayDeviceSignature: &models.SignatureData{Hash: make([]byte, 32), Signature: []byte("signature")},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CloseFiscalDay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if repo.day.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", repo.day.Status, tt.wantStatus)
+			}
+			if len(repo.counters) != tt.wantCounters {
+				t.Errorf("stored counters = %d, want %d", len(repo.counters), tt.wantCounters)
+			}
+			if !tt.wantErr && (repo.day.LastReceiptGlobalNo == nil || *repo.day.LastReceiptGlobalNo != 5) {
+				t.Errorf("LastReceiptGlobalNo = %v, want the receipt stored before the lock", repo.day.LastReceiptGlobalNo)
+			}
+
+			_, logs, _ := audit.ListAuditLogs(context.Background(), "fiscal_day", nil, 0, 10)
+			if len(logs) != tt.wantAudit {
+				t.Fatalf("audit logs = %d, want %d", len(logs), tt.wantAudit)
+			}
+			if len(logs) > 0 && (logs[0].Action != "close" || logs[0].UserID == nil || *logs[0].UserID != operator.UserID ||
+				logs[0].Details == nil || !strings.Contains(*logs[0].Details, `"operatorName":"Tendai Moyo"`)) {
+				t.Errorf("audit log = %+v", logs[0])
+			}
+		})
+	}
+}
+
+func TestFiscalDayService_CloseWithServerCounters_CounterFailure(t *testing.T) {
+	svc, repo, _ := newTestFiscalDayService(t, true)
+	repo.day.Status = models.FiscalDayStatusCloseFailed
+
+	if _, err := svc.closeWithServerCounters(context.Background(), &models.FiscalDay{
+		ID:              repo.day.ID,
+		DeviceID:        repo.day.DeviceID,
+		FiscalDayNo:     repo.day.FiscalDayNo,
+		FiscalDayOpened: repo.day.FiscalDayOpened,
+		Status:          repo.day.Status,
+	}, models.FiscalDayClosureForced, nil); err == nil {
+		t.Fatal("closeWithServerCounters() error = nil, want error")
+	}
+	if repo.day.Status != models.FiscalDayStatusCloseFailed {
+		t.Errorf("Status = %v, want %v", repo.day.Status, models.FiscalDayStatusCloseFailed)
+	}
+}
+
+func TestFiscalDayService_ResetFiscalDay_ChecksLockedDay(t *testing.T) {
+	svc, repo, audit := newTestFiscalDayService(t, false)
+
+	// The device closed the day after the reset read it as CloseFailed
+	closedAt := time.Now()
+	stale := repo.day
+	stale.Status = models.FiscalDayStatusCloseFailed
+	repo.staleRead = &stale
+	repo.day.Status = models.FiscalDayStatusClosed
+	repo.day.FiscalDayClosed = &closedAt
+
+	_, err := svc.ResetFiscalDay(context.Background(), repo.day.DeviceID, repo.day.FiscalDayNo, nil)
+	if apiErr, ok := err.(*models.APIError); !ok || apiErr.Status != 422 {
+		t.Fatalf("ResetFiscalDay() error = %v, want 422", err)
+	}
+	if repo.day.Status != models.FiscalDayStatusClosed || repo.day.FiscalDayClosed == nil {
+		t.Errorf("day = %+v, want it left closed", repo.day)
+	}
+	if _, logs, _ := audit.ListAuditLogs(context.Background(), "fiscal_day", nil, 0, 10); len(logs) != 0 {
+		t.Errorf("audit logs = %d, want 0", len(logs))
+	}
+}
+
+func TestFiscalDayService_GetFiscalDay(t *testing.T) {
+	ctx := context.Background()
+	monitor, repos, devices := newTestFiscalDayMonitor(t, nil)
+	svc := monitor.fiscalDaySvc
+
+	day := createDays(t, repos, devices, time.Hour)[0]
+	counters := []models.FiscalDayCounter{{FiscalCounterType: int(models.FiscalCounterTypeSaleByTax), FiscalCounterCurrency: "USD", FiscalCounterValue: 100}}
+	if err := repos.FiscalDays.CreateCounters(ctx, day.ID, counters); err != nil {
+		t.Fatalf("CreateCounters() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		deviceID int
+		dayNo    int
+		wantCode int
+	}{
+		{"Found", day.DeviceID, 1, 0},
+		{"Unknown day", day.DeviceID, 2, 404},
+		{"Day of another device", devices[1].DeviceID, 1, 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.GetFiscalDay(ctx, tt.deviceID, tt.dayNo)
+			checkAPIError(t, err, tt.wantCode)
+			if tt.wantCode != 0 {
+				if apiErr := err.(*models.APIError); apiErr.ErrorCode != models.ErrCodeFISC05 {
+					t.Errorf("ErrorCode = %s, want %s", apiErr.ErrorCode, models.ErrCodeFISC05)
+				}
+				return
+			}
+
+			if resp.FiscalDayNo != 1 || resp.FiscalDayStatus != models.FiscalDayStatusOpened.String() {
+				t.Errorf("GetFiscalDay() = %+v, want open day 1", resp)
+			}
+			if len(resp.FiscalDayCounters) != 1 || resp.FiscalDayCounters[0].FiscalCounterValue != 100 {
+				t.Errorf("FiscalDayCounters = %+v, want the stored counter", resp.FiscalDayCounters)
+			}
+			if resp.FiscalDayDocumentQuantities == nil {
+				t.Error("FiscalDayDocumentQuantities = nil, want an empty list")
+			}
+		})
+	}
+}
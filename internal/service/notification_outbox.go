@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// outboxBatchSize is how many notifications one run claims
+	outboxBatchSize = 50
+
+	// outboxLease is how long a claimed notification is left to its worker
+	// before another run may send it again
+	outboxLease = 5 * time.Minute
+
+	// outboxMaxAttempts is how many times a notification is tried before it
+	// is dead-lettered
+	outboxMaxAttempts = 6
+
+	// outboxRetryDelay is the wait after the first failed attempt; it doubles
+	// with every further attempt up to outboxMaxRetryDelay
+	outboxRetryDelay    = 30 * time.Second
+	outboxMaxRetryDelay = time.Hour
+)
+
+// NotificationOutbox delivers the notifications queued in the outbox
+type NotificationOutbox struct {
+	repo     repository.NotificationRepository
+	notifier *Notifier
+	logger   *zap.Logger
+}
+
+func NewNotificationOutbox(repo repository.NotificationRepository, notifier *Notifier, logger *zap.Logger) *NotificationOutbox {
+	return &NotificationOutbox{
+		repo:     repo,
+		notifier: notifier,
+		logger:   logger,
+	}
+}
+
+// DeliverDue sends the notifications that are due. Failed ones are retried
+// with exponential backoff and dead-lettered after outboxMaxAttempts.
+func (o *NotificationOutbox) DeliverDue(ctx context.Context) error {
+	now := time.Now()
+	notifications, err := o.repo.ClaimDue(ctx, now, now.Add(outboxLease), outboxBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to claim notifications: %w", err)
+	}
+
+	for i := range notifications {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		o.deliver(ctx, &notifications[i])
+	}
+
+	return nil
+}
+
+func (o *NotificationOutbox) deliver(ctx context.Context, n *models.Notification) {
+	sendErr := o.notifier.Deliver(ctx, n)
+	if sendErr == nil {
+		if err := o.repo.MarkSent(ctx, n.ID); err != nil {
+			o.logger.Error("Failed to mark notification sent", zap.Int64("notificationID", n.ID), zap.Error(err))
+		}
+		return
+	}
+
+	attempts := n.Attempts + 1
+	if attempts >= outboxMaxAttempts {
+		o.logger.Error("Notification dead-lettered",
+			zap.Int64("notificationID", n.ID),
+			zap.String("kind", string(n.Kind)),
+			zap.Int("attempts", attempts),
+			zap.Error(sendErr),
+		)
+		if err := o.repo.MarkFailed(ctx, n.ID, sendErr.Error(), nil); err != nil {
+			o.logger.Error("Failed to dead-letter notification", zap.Int64("notificationID", n.ID), zap.Error(err))
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(outboxBackoff(attempts))
+	o.logger.Warn("Notification delivery failed, will retry",
+		zap.Int64("notificationID", n.ID),
+		zap.String("kind", string(n.Kind)),
+		zap.Int("attempts", attempts),
+		zap.Time("retryAt", retryAt),
+		zap.Error(sendErr),
+	)
+	if err := o.repo.MarkFailed(ctx, n.ID, sendErr.Error(), &retryAt); err != nil {
+		o.logger.Error("Failed to record notification failure", zap.Int64("notificationID", n.ID), zap.Error(err))
+	}
+}
+
+// outboxBackoff is the wait before the next attempt after attempts failures
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxRetryDelay
+	for i := 1; i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxRetryDelay {
+		delay = outboxMaxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/email"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository/memory"
+
+	"go.uber.org/zap"
+)
+
+// failingEmailSender fails every fiscal day notification
+type failingEmailSender struct {
+	EmailSender
+	calls int
+}
+
+func (s *failingEmailSender) SendFiscalDayAutoClosedNotification(to email.Recipient, deviceName string, fiscalDayNo int) error {
+	s.calls++
+	return errors.New("smtp unavailable")
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{10, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationOutbox_RetriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewNotificationRepository(memory.NewStore())
+	email := &failingEmailSender{}
+	notifier := NewNotifier(email, nil, nil, zap.NewNop())
+	outbox := NewNotificationOutbox(repo, notifier, zap.NewNop())
+
+	users := []models.User{{Username: "cashier", Email: "cashier@example.com", Status: models.UserStatusActive}}
+	if err := notifier.QueueFiscalDayAutoClosed(ctx, repo, users, "Main (SN-0001)", 7); err != nil {
+		t.Fatalf("QueueFiscalDayAutoClosed() error = %v", err)
+	}
+
+	if err := outbox.DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	_, rows, _ := repo.List(ctx, "", 0, 10)
+	if len(rows) != 1 || rows[0].Status != models.NotificationStatusPending || rows[0].Attempts != 1 {
+		t.Fatalf("outbox after a failure = %+v, want pending with 1 attempt", rows)
+	}
+	if rows[0].NextAttemptAt.Before(time.Now().Add(outboxRetryDelay - time.Second)) {
+		t.Errorf("retry scheduled at %v, want after the backoff", rows[0].NextAttemptAt)
+	}
+
+	// Not due again until the backoff has passed
+	if err := outbox.DeliverDue(ctx); err != nil || email.calls != 1 {
+		t.Fatalf("DeliverDue() during backoff: %d sends, error %v", email.calls, err)
+	}
+
+	// Use up the remaining attempts by making the retries due now
+	for i := 1; i < outboxMaxAttempts; i++ {
+		due := time.Now().Add(-time.Second)
+		if err := repo.MarkFailed(ctx, rows[0].ID, "smtp unavailable", &due); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := outbox.DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	n, _ := repo.GetByID(ctx, rows[0].ID)
+	if n.Status != models.NotificationStatusDead || n.LastError != "smtp unavailable" {
+		t.Fatalf("notification after the last attempt = %+v, want dead", n)
+	}
+}
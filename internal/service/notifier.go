@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"fiscalization-api/internal/email"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// EmailSender is implemented by email.EmailService
+type EmailSender interface {
+	SendSecurityCode(to email.Recipient, code string) error
+	SendPasswordReset(to email.Recipient, code string) error
+	SendFiscalDayCloseNotification(to email.Recipient, deviceName string, hoursLeft int) error
+	SendContactChangedNotification(to email.Recipient) error
+	SendFiscalDayAutoClosedNotification(to email.Recipient, deviceName string, fiscalDayNo int) error
+}
+
+// TaxpayerLookup finds the taxpayer whose name an email carries.
+// repository.AdminRepository implements it.
+type TaxpayerLookup interface {
+	GetTaxpayerByID(ctx context.Context, id int64) (*models.Taxpayer, error)
+}
+
+// SMSSender is implemented by sms.SMSService
+type SMSSender interface {
+	SendSecurityCode(to, code string) error
+	SendPasswordReset(to, code string) error
+	SendFiscalDayAlert(to, deviceName string, hoursLeft int) error
+	SendContactChangedAlert(to, username string) error
+	SendFiscalDayAutoClosedAlert(to, deviceName string, fiscalDayNo int) error
+}
+
+// UserNotifier delivers the security codes and account notices of
+// UserService over the channel a user chose. *Notifier implements it.
+type UserNotifier interface {
+	SendSecurityCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, code string) error
+	SendPasswordResetCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, code string) error
+	SendContactChangeCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, to, code string) error
+	QueueContactChanged(ctx context.Context, outbox repository.NotificationRepository, user *models.User, channel models.SendSecurityCodeTo, previous string) error
+}
+
+// Notifier delivers taxpayer notifications over email, falling back to SMS
+// for users without an email address. Security codes are sent at once, since
+// the user is waiting for them and they must not be stored in clear text;
+// other notifications are queued in the outbox in the transaction of the
+// change they report and delivered by NotificationOutbox. Emails are in the
+// user's language and carry the name of their taxpayer.
+type Notifier struct {
+	email     EmailSender
+	sms       SMSSender
+	taxpayers TaxpayerLookup
+	logger    *zap.Logger
+}
+
+func NewNotifier(email EmailSender, sms SMSSender, taxpayers TaxpayerLookup, logger *zap.Logger) *Notifier {
+	return &Notifier{
+		email:     email,
+		sms:       sms,
+		taxpayers: taxpayers,
+		logger:    logger,
+	}
+}
+
+// QueueFiscalDayEnding adds a reminder that a fiscal day is about to reach
+// its maximum length for every active user to the outbox
+func (n *Notifier) QueueFiscalDayEnding(ctx context.Context, outbox repository.NotificationRepository, users []models.User, deviceName string, hoursLeft int) error {
+	return n.queueForUsers(ctx, outbox, users, models.NotificationKindFiscalDayEnding, func(r recipientPayload) interface{} {
+		return fiscalDayEndingPayload{recipientPayload: r, DeviceName: deviceName, HoursLeft: hoursLeft}
+	})
+}
+
+// QueueFiscalDayAutoClosed adds a notice that a fiscal day was closed by the
+// server for every active user to the outbox
+func (n *Notifier) QueueFiscalDayAutoClosed(ctx context.Context, outbox repository.NotificationRepository, users []models.User, deviceName string, fiscalDayNo int) error {
+	return n.queueForUsers(ctx, outbox, users, models.NotificationKindFiscalDayAutoClosed, func(r recipientPayload) interface{} {
+		return fiscalDayAutoClosedPayload{recipientPayload: r, DeviceName: deviceName, FiscalDayNo: fiscalDayNo}
+	})
+}
+
+// queueForUsers queues a notification to each active user's email address,
+// or to their phone number when they have no email address, with the
+// payload built for that user
+func (n *Notifier) queueForUsers(ctx context.Context, outbox repository.NotificationRepository, users []models.User, kind models.NotificationKind, payload func(recipientPayload) interface{}) error {
+	for _, user := range users {
+		if user.Status != models.UserStatusActive {
+			continue
+		}
+
+		var err error
+		data := payload(newRecipientPayload(&user))
+		switch {
+		case user.Email != "":
+			err = queue(ctx, outbox, models.NotificationChannelEmail, user.Email, kind, data)
+		case user.PhoneNo != "":
+			err = queue(ctx, outbox, models.NotificationChannelSMS, user.PhoneNo, kind, data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendSecurityCode sends the security code confirming a new user to their
+// email address or phone number
+func (n *Notifier) SendSecurityCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, code string) error {
+	switch channel {
+	case models.SendSecurityCodeToEmail:
+		return n.email.SendSecurityCode(n.recipient(ctx, user.Email, user.Username, user.Language, user.TaxpayerID), code)
+	case models.SendSecurityCodeToPhoneNumber:
+		return n.sms.SendSecurityCode(user.PhoneNo, code)
+	}
+	return fmt.Errorf("unknown security code channel %d", channel)
+}
+
+// SendPasswordResetCode sends a password reset code to the user's email
+// address or phone number
+func (n *Notifier) SendPasswordResetCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, code string) error {
+	switch channel {
+	case models.SendSecurityCodeToEmail:
+		return n.email.SendPasswordReset(n.recipient(ctx, user.Email, user.Username, user.Language, user.TaxpayerID), code)
+	case models.SendSecurityCodeToPhoneNumber:
+		return n.sms.SendPasswordReset(user.PhoneNo, code)
+	}
+	return fmt.Errorf("unknown security code channel %d", channel)
+}
+
+// SendContactChangeCode sends the security code confirming a new email
+// address or phone number to that new contact
+func (n *Notifier) SendContactChangeCode(ctx context.Context, user *models.User, channel models.SendSecurityCodeTo, to, code string) error {
+	switch channel {
+	case models.SendSecurityCodeToEmail:
+		return n.email.SendSecurityCode(n.recipient(ctx, to, user.Username, user.Language, user.TaxpayerID), code)
+	case models.SendSecurityCodeToPhoneNumber:
+		return n.sms.SendSecurityCode(to, code)
+	}
+	return fmt.Errorf("unknown security code channel %d", channel)
+}
+
+// QueueContactChanged adds a notice to the user's previous email address or
+// phone number that it was replaced to the outbox
+func (n *Notifier) QueueContactChanged(ctx context.Context, outbox repository.NotificationRepository, user *models.User, channel models.SendSecurityCodeTo, previous string) error {
+	if previous == "" {
+		return nil
+	}
+
+	payload := contactChangedPayload{recipientPayload: newRecipientPayload(user)}
+	if channel == models.SendSecurityCodeToPhoneNumber {
+		return queue(ctx, outbox, models.NotificationChannelSMS, previous, models.NotificationKindContactChanged, payload)
+	}
+	return queue(ctx, outbox, models.NotificationChannelEmail, previous, models.NotificationKindContactChanged, payload)
+}
+
+// Deliver sends a notification from the outbox
+func (n *Notifier) Deliver(ctx context.Context, notification *models.Notification) error {
+	byEmail := notification.Channel == models.NotificationChannelEmail
+	if !byEmail && notification.Channel != models.NotificationChannelSMS {
+		return fmt.Errorf("unknown notification channel %q", notification.Channel)
+	}
+	to := notification.Recipient
+
+	switch notification.Kind {
+	case models.NotificationKindContactChanged:
+		var p contactChangedPayload
+		if err := json.Unmarshal([]byte(notification.Payload), &p); err != nil {
+			return err
+		}
+		if byEmail {
+			return n.email.SendContactChangedNotification(n.payloadRecipient(ctx, to, p.recipientPayload))
+		}
+		return n.sms.SendContactChangedAlert(to, p.Username)
+
+	case models.NotificationKindFiscalDayEnding:
+		var p fiscalDayEndingPayload
+		if err := json.Unmarshal([]byte(notification.Payload), &p); err != nil {
+			return err
+		}
+		if byEmail {
+			return n.email.SendFiscalDayCloseNotification(n.payloadRecipient(ctx, to, p.recipientPayload), p.DeviceName, p.HoursLeft)
+		}
+		return n.sms.SendFiscalDayAlert(to, p.DeviceName, p.HoursLeft)
+
+	case models.NotificationKindFiscalDayAutoClosed:
+		var p fiscalDayAutoClosedPayload
+		if err := json.Unmarshal([]byte(notification.Payload), &p); err != nil {
+			return err
+		}
+		if byEmail {
+			return n.email.SendFiscalDayAutoClosedNotification(n.payloadRecipient(ctx, to, p.recipientPayload), p.DeviceName, p.FiscalDayNo)
+		}
+		return n.sms.SendFiscalDayAutoClosedAlert(to, p.DeviceName, p.FiscalDayNo)
+	}
+	return fmt.Errorf("unknown notification kind %q", notification.Kind)
+}
+
+// recipient returns the email recipient for a user of a taxpayer. A
+// taxpayer that cannot be found only leaves its name out of the email.
+func (n *Notifier) recipient(ctx context.Context, address, username string, language models.Language, taxpayerID int64) email.Recipient {
+	r := email.Recipient{Address: address, Username: username, Language: string(language.OrDefault())}
+	if n.taxpayers == nil || taxpayerID == 0 {
+		return r
+	}
+
+	tp, err := n.taxpayers.GetTaxpayerByID(ctx, taxpayerID)
+	if err != nil {
+		n.logger.Warn("Failed to look up taxpayer for email", zap.Int64("taxpayerID", taxpayerID), zap.Error(err))
+	}
+	if tp != nil {
+		r.Taxpayer = email.Taxpayer{Name: tp.Name, TIN: tp.TIN}
+	}
+	return r
+}
+
+func (n *Notifier) payloadRecipient(ctx context.Context, address string, p recipientPayload) email.Recipient {
+	return n.recipient(ctx, address, p.Username, p.Language, p.TaxpayerID)
+}
+
+// Payloads of the notification kinds. recipientPayload keeps what is needed
+// to address the user in their language.
+type (
+	recipientPayload struct {
+		Username   string          `json:"username"`
+		Language   models.Language `json:"language,omitempty"`
+		TaxpayerID int64           `json:"taxpayerID,omitempty"`
+	}
+	contactChangedPayload struct {
+		recipientPayload
+	}
+	fiscalDayEndingPayload struct {
+		recipientPayload
+		DeviceName string `json:"deviceName"`
+		HoursLeft  int    `json:"hoursLeft"`
+	}
+	fiscalDayAutoClosedPayload struct {
+		recipientPayload
+		DeviceName  string `json:"deviceName"`
+		FiscalDayNo int    `json:"fiscalDayNo"`
+	}
+)
+
+func newRecipientPayload(user *models.User) recipientPayload {
+	return recipientPayload{Username: user.Username, Language: user.Language, TaxpayerID: user.TaxpayerID}
+}
+
+// queue adds a notification to the outbox
+func queue(ctx context.Context, outbox repository.NotificationRepository, channel models.NotificationChannel, to string, kind models.NotificationKind, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(ctx, &models.Notification{
+		Channel:   channel,
+		Recipient: to,
+		Kind:      kind,
+		Payload:   string(data),
+	})
+}
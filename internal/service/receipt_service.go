@@ -1,6 +1,8 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"time"
 
@@ -17,6 +19,7 @@ type ReceiptService struct {
 	deviceRepo     repository.DeviceRepository
 	validationSvc  *ValidationService
 	cryptoSvc      *CryptoService
+	deviceLocks    *deviceLocks
 	logger         *zap.Logger
 }
 
@@ -34,14 +37,21 @@ func NewReceiptService(
 		deviceRepo:    deviceRepo,
 		validationSvc: validationSvc,
 		cryptoSvc:     cryptoSvc,
+		deviceLocks:   newDeviceLocks(),
 		logger:        logger,
 	}
 }
 
-// SubmitReceipt submits a receipt in online mode
-func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models.SubmitReceiptResponse, error) {
+// SubmitReceipt submits a receipt in online mode. Submissions from the same
+// device are processed one at a time so each receipt is validated and chained
+// against the receipt stored immediately before it. The receipt is recorded
+// as issued by operator, whatever user the device put on it.
+func (s *ReceiptService) SubmitReceipt(ctx context.Context, operator models.Operator, req models.SubmitReceiptRequest) (*models.SubmitReceiptResponse, error) {
+	unlock := s.deviceLocks.Lock(req.DeviceID)
+	defer unlock()
+
 	// Get device
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +65,7 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 	}
 
 	// Get current fiscal day
-	fiscalDay, err := s.fiscalDayRepo.GetCurrent(req.DeviceID)
+	fiscalDay, err := s.fiscalDayRepo.GetCurrent(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -70,49 +80,39 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 
 	// Set fiscal day ID
 	req.Receipt.FiscalDayID = fiscalDay.ID
+	s.stampOperator(&req.Receipt, operator)
 
 	// Check for duplicate (same deviceID, receiptGlobalNo, and hash)
-	existing, err := s.receiptRepo.GetByGlobalNo(req.DeviceID, req.Receipt.ReceiptGlobalNo)
+	existing, err := s.receiptRepo.GetByGlobalNo(ctx, req.DeviceID, req.Receipt.ReceiptGlobalNo)
 	if err != nil {
 		return nil, err
 	}
 
 	if existing != nil {
-		// Return existing receipt signature
-		s.logger.Info("Duplicate receipt detected, returning existing signature",
-			zap.Int("deviceID", req.DeviceID),
-			zap.Int("globalNo", req.Receipt.ReceiptGlobalNo),
-		)
+		return s.duplicateResponse(existing)
+	}
 
-		return &models.SubmitReceiptResponse{
-			OperationID:            generateOperationID(),
-			ReceiptID:              existing.ReceiptID,
-			ServerDate:             *existing.ServerDate,
-			ReceiptServerSignature: *existing.ReceiptServerSignature,
-		}, nil
-	}
-
-	// Get previous receipt for validation
-	var previousReceipt *models.Receipt
-	if req.Receipt.ReceiptCounter > 1 {
-		previousReceipt, err = s.receiptRepo.GetPreviousReceipt(
-			req.DeviceID,
-			fiscalDay.ID,
-			req.Receipt.ReceiptGlobalNo,
-		)
-		if err != nil {
-			return nil, err
-		}
+	// Get previous receipt for validation. It is looked up whatever the
+	// counter says, so a counter reset mid-day is flagged rather than
+	// mistaken for a change to the chain.
+	previousReceipt, err := s.receiptRepo.GetPreviousReceipt(
+		ctx,
+		req.DeviceID,
+		fiscalDay.ID,
+		req.Receipt.ReceiptGlobalNo,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get taxpayer
-	taxpayer, err := s.deviceRepo.GetTaxpayer(device.TaxpayerID)
+	taxpayer, err := s.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get applicable taxes
-	applicableTaxes, err := s.deviceRepo.GetApplicableTaxes()
+	applicableTaxes, err := s.deviceRepo.GetApplicableTaxes(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -130,12 +130,12 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 	// Validate credit/debit note if applicable
 	if req.Receipt.ReceiptType == models.ReceiptTypeCreditNote || req.Receipt.ReceiptType == models.ReceiptTypeDebitNote {
 		if req.Receipt.CreditDebitNote != nil && req.Receipt.CreditDebitNote.ReceiptID != nil {
-			originalReceipt, err := s.receiptRepo.GetByReceiptID(*req.Receipt.CreditDebitNote.ReceiptID)
+			originalReceipt, err := s.receiptRepo.GetByReceiptID(ctx, *req.Receipt.CreditDebitNote.ReceiptID)
 			if err != nil {
 				return nil, err
 			}
 
-			creditNotes, debitNotes, err := s.receiptRepo.GetCreditDebitNotes(*req.Receipt.CreditDebitNote.ReceiptID)
+			creditNotes, debitNotes, err := s.receiptRepo.GetCreditDebitNotes(ctx, *req.Receipt.CreditDebitNote.ReceiptID)
 			if err != nil {
 				return nil, err
 			}
@@ -159,8 +159,9 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 	}
 
 	// Verify receipt signature
+	// The device hashes the first receipt of its day without a previous hash
 	var previousHash []byte
-	if previousReceipt != nil {
+	if previousReceipt != nil && req.Receipt.ReceiptCounter > 1 {
 		previousHash = previousReceipt.ReceiptHash
 	}
 
@@ -173,34 +174,55 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 	// Store the hash
 	req.Receipt.ReceiptHash = receiptHash
 
+	if msg := s.checkDeviceSignature(device, &req.Receipt); msg != "" {
+		validationResult.addError("RCPT020", msg, models.ValidationColorRed)
+	}
+
 	// Set validation results
 	req.Receipt.ValidationColor = validationResult.Color
 	req.Receipt.ValidationErrors = validationResult.Errors
 
-	// Generate server signature
 	serverDate := time.Now()
 	req.Receipt.ServerDate = &serverDate
 
-	serverSignature, err := s.generateServerSignature(&req.Receipt, serverDate)
-	if err != nil {
-		s.logger.Error("Failed to generate server signature", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate server signature: %w", err)
+	// Save receipt and advance the fiscal day, guarding against submissions
+	// from other server instances that raced ahead of this one
+	var previousGlobalNo *int
+	if previousReceipt != nil {
+		previousGlobalNo = &previousReceipt.ReceiptGlobalNo
 	}
 
-	req.Receipt.ReceiptServerSignature = serverSignature
+	// The server signature covers the receipt ID, which is only known once
+	// the receipt is stored, so it is created inside the same transaction
+	var signErr error
+	sign := func(receipt *models.Receipt) error {
+		receipt.ReceiptServerSignature, signErr = s.generateServerSignature(receipt, serverDate)
+		return signErr
+	}
 
-	// Save receipt to database
-	if err := s.receiptRepo.CreateWithLines(&req.Receipt); err != nil {
+	err = s.receiptRepo.CreateChained(ctx, &req.Receipt, previousGlobalNo, sign)
+	switch {
+	case err == repository.ErrDuplicateReceipt:
+		existing, err := s.receiptRepo.GetByGlobalNo(ctx, req.DeviceID, req.Receipt.ReceiptGlobalNo)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, models.NewAPIError(409, "Receipt was submitted concurrently, please resubmit", models.ErrCodeRCPT04)
+		}
+		return s.duplicateResponse(existing)
+	case err == repository.ErrReceiptChainChanged:
+		return nil, models.NewAPIError(409, "Receipt chain changed during submission, please resubmit", models.ErrCodeRCPT11)
+	case err == repository.ErrFiscalDayNotOpen:
+		return nil, models.NewAPIError(422, "Submitting receipt is not allowed", models.ErrCodeRCPT01)
+	case err != nil && err == signErr:
+		s.logger.Error("Failed to generate server signature", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate server signature: %w", err)
+	case err != nil:
 		s.logger.Error("Failed to save receipt", zap.Error(err))
 		return nil, fmt.Errorf("failed to save receipt: %w", err)
 	}
 
-	// Update fiscal day last receipt number
-	fiscalDay.LastReceiptGlobalNo = &req.Receipt.ReceiptGlobalNo
-	if err := s.fiscalDayRepo.Update(fiscalDay); err != nil {
-		s.logger.Warn("Failed to update fiscal day", zap.Error(err))
-	}
-
 	s.logger.Info("Receipt submitted successfully",
 		zap.Int64("receiptID", req.Receipt.ReceiptID),
 		zap.Int("deviceID", req.DeviceID),
@@ -211,7 +233,48 @@ func (s *ReceiptService) SubmitReceipt(req models.SubmitReceiptRequest) (*models
 		OperationID:            generateOperationID(),
 		ReceiptID:              req.Receipt.ReceiptID,
 		ServerDate:             serverDate,
-		ReceiptServerSignature: *serverSignature,
+		ReceiptServerSignature: *req.Receipt.ReceiptServerSignature,
+		ValidationColor:        req.Receipt.ValidationColor,
+		ValidationErrors:       req.Receipt.ValidationErrors,
+	}, nil
+}
+
+// checkDeviceSignature compares the device signature with the receipt hash
+// computed by the server and returns why it is invalid, or "" when it is valid.
+// Devices without a stored certificate cannot be checked and are let through.
+func (s *ReceiptService) checkDeviceSignature(device *models.Device, receipt *models.Receipt) string {
+	if device.Certificate == nil || *device.Certificate == "" {
+		return ""
+	}
+
+	signature := receipt.ReceiptDeviceSignature
+	if !bytes.Equal(signature.Hash, receipt.ReceiptHash) {
+		return "Invoice hash does not match the receipt"
+	}
+	if err := s.cryptoSvc.VerifyDeviceSignature(*device.Certificate, receipt.ReceiptHash, signature.Signature); err != nil {
+		return "Invoice signature is not valid"
+	}
+	return ""
+}
+
+// duplicateResponse returns the stored result of a receipt the device already submitted
+func (s *ReceiptService) duplicateResponse(existing *models.Receipt) (*models.SubmitReceiptResponse, error) {
+	s.logger.Info("Duplicate receipt detected, returning existing signature",
+		zap.Int("deviceID", existing.DeviceID),
+		zap.Int("globalNo", existing.ReceiptGlobalNo),
+	)
+
+	if existing.ServerDate == nil || existing.ReceiptServerSignature == nil {
+		return nil, models.NewAPIError(409, "Duplicate receipt", models.ErrCodeRCPT04)
+	}
+
+	return &models.SubmitReceiptResponse{
+		OperationID:            generateOperationID(),
+		ReceiptID:              existing.ReceiptID,
+		ServerDate:             *existing.ServerDate,
+		ReceiptServerSignature: *existing.ReceiptServerSignature,
+		ValidationColor:        existing.ValidationColor,
+		ValidationErrors:       existing.ValidationErrors,
 	}, nil
 }
 
@@ -230,15 +293,28 @@ func (s *ReceiptService) generateServerSignature(receipt *models.Receipt, server
 		return nil, err
 	}
 
-	// Get certificate thumbprint
-	// In production, this would come from the server certificate
-	thumbprint := make([]byte, 20) // Placeholder
-
 	return &models.SignatureDataEx{
 		SignatureData: models.SignatureData{
 			Hash:      receipt.ReceiptHash,
 			Signature: signature,
 		},
-		CertificateThumbprint: thumbprint,
+		CertificateThumbprint: s.cryptoSvc.ServerThumbprint(),
 	}, nil
 }
+
+// stampOperator records operator as the user who issued receipt
+func (s *ReceiptService) stampOperator(receipt *models.Receipt, operator models.Operator) {
+	if operator.Username == "" {
+		return
+	}
+	if receipt.Username != nil && *receipt.Username != operator.Username {
+		s.logger.Warn("Receipt username does not match the operator",
+			zap.Int("deviceID", receipt.DeviceID),
+			zap.String("username", *receipt.Username),
+			zap.String("operator", operator.Username),
+		)
+	}
+	username, name := operator.Username, operator.Name
+	receipt.Username = &username
+	receipt.UserNameSurname = &name
+}
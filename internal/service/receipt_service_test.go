@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// fakeReceiptRepo stores receipts in memory and enforces the same chaining
+// rules as the PostgreSQL CreateChained
+type fakeReceiptRepo struct {
+	repository.ReceiptRepository
+
+	mu       sync.Mutex
+	receipts map[int]*models.Receipt // by global number
+	nextID   int64
+}
+
+func newFakeReceiptRepo() *fakeReceiptRepo {
+	return &fakeReceiptRepo{receipts: make(map[int]*models.Receipt)}
+}
+
+func (r *fakeReceiptRepo) GetByGlobalNo(ctx context.Context, deviceID, globalNo int) (*models.Receipt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if receipt, ok := r.receipts[globalNo]; ok {
+		copied := *receipt
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeReceiptRepo) GetPreviousReceipt(ctx context.Context, deviceID int, fiscalDayID int64, globalNo int) (*models.Receipt, error) {
+	// Yield to widen the window between reading the chain and writing to it
+	runtime.Gosched()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var previous *models.Receipt
+	for no, receipt := range r.receipts {
+		if no < globalNo && (previous == nil || no > previous.ReceiptGlobalNo) {
+			previous = receipt
+		}
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	copied := *previous
+	return &copied, nil
+}
+
+func (r *fakeReceiptRepo) CreateChained(ctx context.Context, receipt *models.Receipt, previousGlobalNo *int, sign repository.ReceiptSigner) error {
+	runtime.Gosched()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.receipts[receipt.ReceiptGlobalNo]; ok {
+		return repository.ErrDuplicateReceipt
+	}
+
+	latest := 0
+	for no := range r.receipts {
+		if no < receipt.ReceiptGlobalNo && no > latest {
+			latest = no
+		}
+	}
+	if (previousGlobalNo == nil && latest != 0) || (previousGlobalNo != nil && *previousGlobalNo != latest) {
+		return repository.ErrReceiptChainChanged
+	}
+
+	r.nextID++
+	receipt.ID = r.nextID
+	receipt.ReceiptID = r.nextID
+	if sign != nil {
+		if err := sign(receipt); err != nil {
+			return err
+		}
+	}
+	copied := *receipt
+	r.receipts[receipt.ReceiptGlobalNo] = &copied
+	return nil
+}
+
+type fakeFiscalDayRepo struct {
+	repository.FiscalDayRepository
+	day *models.FiscalDay
+}
+
+func (r *fakeFiscalDayRepo) GetCurrent(ctx context.Context, deviceID int) (*models.FiscalDay, error) {
+	copied := *r.day
+	return &copied, nil
+}
+
+type fakeDeviceRepo struct {
+	repository.DeviceRepository
+}
+
+func (r *fakeDeviceRepo) GetByDeviceID(ctx context.Context, deviceID int) (*models.Device, error) {
+	return &models.Device{DeviceID: deviceID, TaxpayerID: 1, Status: "Active"}, nil
+}
+
+func (r *fakeDeviceRepo) GetTaxpayer(ctx context.Context, taxpayerID int64) (*models.Taxpayer, error) {
+	return &models.Taxpayer{ID: taxpayerID, TIN: "1234567890", Status: "Active", TaxPayerDayMaxHrs: 24}, nil
+}
+
+func (r *fakeDeviceRepo) GetApplicableTaxes(ctx context.Context) ([]models.Tax, error) {
+	percent := 15.0
+	return []models.Tax{{TaxID: 1, TaxPercent: &percent, TaxName: "VAT", TaxValidFrom: time.Now().AddDate(-1, 0, 0)}}, nil
+}
+
+func newTestReceiptService(t *testing.T) (*ReceiptService, *fakeReceiptRepo) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	receiptRepo := newFakeReceiptRepo()
+	fiscalDayRepo := &fakeFiscalDayRepo{day: &models.FiscalDay{
+		ID:              1,
+		DeviceID:        1001,
+		FiscalDayNo:     1,
+		FiscalDayOpened: time.Now().Add(-time.Hour),
+		Status:          models.FiscalDayStatusOpened,
+	}}
+
+	svc := NewReceiptService(
+		receiptRepo,
+		fiscalDayRepo,
+		&fakeDeviceRepo{},
+		NewValidationService(),
+		&CryptoService{serverKey: key},
+		zap.NewNop(),
+	)
+	return svc, receiptRepo
+}
+
+func testReceipt(globalNo int) models.Receipt {
+	percent := 15.0
+	return models.Receipt{
+		DeviceID:                 1001,
+		ReceiptType:              models.ReceiptTypeFiscalInvoice,
+		ReceiptCurrency:          "USD",
+		ReceiptCounter:           globalNo,
+		ReceiptGlobalNo:          globalNo,
+		InvoiceNo:                "INV-1",
+		ReceiptDate:              time.Now().Add(-time.Minute),
+		ReceiptLinesTaxInclusive: true,
+		ReceiptLines: []models.ReceiptLine{{
+			ReceiptLineType:     models.ReceiptLineTypeSale,
+			ReceiptLineNo:       1,
+			ReceiptLineName:     "Item",
+			ReceiptLineQuantity: 1,
+			ReceiptLineTotal:    115,
+			TaxPercent:          &percent,
+			TaxID:               1,
+		}},
+		ReceiptTaxes: []models.ReceiptTax{{
+			TaxID:              1,
+			TaxPercent:         &percent,
+			TaxAmount:          15,
+			SalesAmountWithTax: 115,
+		}},
+		ReceiptPayments: []models.Payment{{MoneyTypeCode: models.MoneyTypeCash, PaymentAmount: 115}},
+		ReceiptTotal:    115,
+		ReceiptDeviceSignature: models.SignatureData{
+			Hash:      make([]byte, 32),
+			Signature: []byte("signature"),
+		},
+	}
+}
+
+func TestReceiptService_SubmitReceipt_ConcurrentChain(t *testing.T) {
+	svc, repo := newTestReceiptService(t)
+
+	const receipts = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, receipts)
+
+	for i := 1; i <= receipts; i++ {
+		wg.Add(1)
+		go func(globalNo int) {
+			defer wg.Done()
+			_, err := svc.SubmitReceipt(context.Background(), models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: testReceipt(globalNo)})
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("SubmitReceipt() error = %v", err)
+	}
+	if len(repo.receipts) != receipts {
+		t.Errorf("stored receipts = %d, want %d", len(repo.receipts), receipts)
+	}
+}
+
+func TestReceiptService_SubmitReceipt_ConcurrentDuplicate(t *testing.T) {
+	svc, repo := newTestReceiptService(t)
+
+	const submissions = 20
+	var wg sync.WaitGroup
+	ids := make(chan int64, submissions)
+
+	for i := 0; i < submissions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := svc.SubmitReceipt(context.Background(), models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: testReceipt(1)})
+			if err != nil {
+				t.Errorf("SubmitReceipt() error = %v", err)
+				return
+			}
+			ids <- resp.ReceiptID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	if len(repo.receipts) != 1 {
+		t.Errorf("stored receipts = %d, want 1", len(repo.receipts))
+	}
+
+	var first int64
+	for id := range ids {
+		if first == 0 {
+			first = id
+		}
+		if id != first {
+			t.Errorf("ReceiptID = %d, want %d for every duplicate", id, first)
+		}
+	}
+}
+
+func TestReceiptService_SubmitReceipt_CounterReset(t *testing.T) {
+	svc, repo := newTestReceiptService(t)
+	ctx := context.Background()
+
+	for globalNo := 1; globalNo <= 2; globalNo++ {
+		if _, err := svc.SubmitReceipt(ctx, models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: testReceipt(globalNo)}); err != nil {
+			t.Fatalf("SubmitReceipt(%d) error = %v", globalNo, err)
+		}
+	}
+
+	// A counter that restarts mid-day is stored and flagged, not refused as
+	// a change to the chain
+	receipt := testReceipt(3)
+	receipt.ReceiptCounter = 1
+	resp, err := svc.SubmitReceipt(ctx, models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: receipt})
+	if err != nil {
+		t.Fatalf("SubmitReceipt() error = %v", err)
+	}
+	if repo.receipts[3] == nil {
+		t.Fatal("receipt with a reset counter was not stored")
+	}
+	if !slices.ContainsFunc(resp.ValidationErrors, func(e string) bool { return strings.HasPrefix(e, "RCPT011") }) {
+		t.Errorf("ValidationErrors = %v, want RCPT011", resp.ValidationErrors)
+	}
+}
+
+func TestReceiptService_SubmitReceipt_StampsOperator(t *testing.T) {
+	svc, repo := newTestReceiptService(t)
+
+	receipt := testReceipt(1)
+	sent := "someone.else"
+	receipt.Username = &sent
+
+	operator := models.Operator{UserID: 7, Username: "cashier", Name: "Tendai Moyo"}
+	if _, err := svc.SubmitReceipt(context.Background(), operator, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: receipt}); err != nil {
+		t.Fatalf("SubmitReceipt() error = %v", err)
+	}
+
+	stored := repo.receipts[1]
+	if stored == nil || stored.Username == nil || *stored.Username != "cashier" || stored.UserNameSurname == nil || *stored.UserNameSurname != "Tendai Moyo" {
+		t.Errorf("stored receipt user = %v / %v, want the operator", stored.Username, stored.UserNameSurname)
+	}
+}
+
+func TestReceiptService_SubmitReceipt_SigningFailure(t *testing.T) {
+	svc, repo := newTestReceiptService(t)
+	key := svc.cryptoSvc.serverKey
+
+	svc.cryptoSvc.serverKey = nil
+	if _, err := svc.SubmitReceipt(context.Background(), models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: testReceipt(1)}); err == nil {
+		t.Fatal("SubmitReceipt() error = nil, want the signing error")
+	}
+	if len(repo.receipts) != 0 {
+		t.Fatalf("stored receipts = %d, want 0 after a signing failure", len(repo.receipts))
+	}
+
+	// The retry must be signed rather than rejected as a duplicate
+	svc.cryptoSvc.serverKey = key
+	resp, err := svc.SubmitReceipt(context.Background(), models.Operator{}, models.SubmitReceiptRequest{DeviceID: 1001, Receipt: testReceipt(1)})
+	if err != nil {
+		t.Fatalf("SubmitReceipt() retry error = %v", err)
+	}
+	if len(resp.ReceiptServerSignature.Signature) == 0 {
+		t.Error("retry response has no server signature")
+	}
+	if stored := repo.receipts[1]; stored == nil || stored.ReceiptServerSignature == nil {
+		t.Error("stored receipt has no server signature")
+	}
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// reportWidth is the line width of the printable report (Receipt48 print form)
+const reportWidth = 48
+
+type ReportService struct {
+	fiscalDayRepo repository.FiscalDayRepository
+	deviceRepo    repository.DeviceRepository
+	logger        *zap.Logger
+}
+
+func NewReportService(
+	fiscalDayRepo repository.FiscalDayRepository,
+	deviceRepo repository.DeviceRepository,
+	logger *zap.Logger,
+) *ReportService {
+	return &ReportService{
+		fiscalDayRepo: fiscalDayRepo,
+		deviceRepo:    deviceRepo,
+		logger:        logger,
+	}
+}
+
+// GetXReport returns running totals of the current fiscal day, or of the given
+// day when fiscalDayNo is set, calculated from the receipts submitted so far
+func (s *ReportService) GetXReport(ctx context.Context, deviceID int, fiscalDayNo *int) (*models.FiscalDayReport, error) {
+	var fiscalDay *models.FiscalDay
+	var err error
+	if fiscalDayNo != nil {
+		fiscalDay, err = s.fiscalDayRepo.GetByDayNo(ctx, deviceID, *fiscalDayNo)
+	} else {
+		fiscalDay, err = s.fiscalDayRepo.GetCurrent(ctx, deviceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fiscalDay == nil {
+		return nil, models.NewAPIError(404, "Fiscal day not found", models.ErrCodeFISC05)
+	}
+
+	counters, err := s.fiscalDayRepo.CalculateCounters(ctx, fiscalDay.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate counters: %w", err)
+	}
+
+	return s.buildReport(ctx, models.ReportTypeX, fiscalDay, counters)
+}
+
+// GetZReport returns the end-of-day report of the last closed fiscal day, or
+// of the given day when fiscalDayNo is set. The day must be closed.
+func (s *ReportService) GetZReport(ctx context.Context, deviceID int, fiscalDayNo *int) (*models.FiscalDayReport, error) {
+	var fiscalDay *models.FiscalDay
+	var err error
+	if fiscalDayNo != nil {
+		fiscalDay, err = s.fiscalDayRepo.GetByDayNo(ctx, deviceID, *fiscalDayNo)
+	} else {
+		fiscalDay, err = s.fiscalDayRepo.GetLastClosedDay(ctx, deviceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fiscalDay == nil {
+		return nil, models.NewAPIError(404, "Fiscal day not found", models.ErrCodeFISC05)
+	}
+	if fiscalDay.Status != models.FiscalDayStatusClosed {
+		return nil, models.NewAPIError(422, "Z-report is only available for closed fiscal days", models.ErrCodeFISC03)
+	}
+
+	counters, err := s.fiscalDayRepo.GetCounters(ctx, fiscalDay.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get counters: %w", err)
+	}
+
+	return s.buildReport(ctx, models.ReportTypeZ, fiscalDay, counters)
+}
+
+func (s *ReportService) buildReport(
+	ctx context.Context,
+	reportType models.ReportType,
+	fiscalDay *models.FiscalDay,
+	counters []models.FiscalDayCounter,
+) (*models.FiscalDayReport, error) {
+	device, err := s.deviceRepo.GetByDeviceID(ctx, fiscalDay.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
+	}
+
+	taxpayer, err := s.deviceRepo.GetTaxpayer(ctx, device.TaxpayerID)
+	if err != nil {
+		return nil, err
+	}
+	if taxpayer == nil {
+		return nil, models.NewAPIError(422, "Taxpayer not found", models.ErrCodeDEV05)
+	}
+
+	docQuantities, err := s.deviceRepo.GetFiscalDayDocumentQuantities(ctx, fiscalDay.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document quantities: %w", err)
+	}
+	if docQuantities == nil {
+		docQuantities = make([]models.FiscalDayDocumentQuantity, 0)
+	}
+
+	report := &models.FiscalDayReport{
+		OperationID:        generateOperationID(),
+		ReportType:         reportType,
+		GeneratedAt:        time.Now(),
+		TaxPayerName:       taxpayer.Name,
+		TaxPayerTIN:        taxpayer.TIN,
+		DeviceID:           device.DeviceID,
+		DeviceSerialNo:     device.DeviceSerialNo,
+		DeviceBranchName:   device.BranchName,
+		FiscalDayNo:        fiscalDay.FiscalDayNo,
+		FiscalDayStatus:    fiscalDay.Status.String(),
+		FiscalDayOpened:    fiscalDay.FiscalDayOpened,
+		FiscalDayClosed:    fiscalDay.FiscalDayClosed,
+		DocumentQuantities: docQuantities,
+	}
+	if taxpayer.VATNumber != nil {
+		report.VATNumber = *taxpayer.VATNumber
+	}
+	if fiscalDay.ReconciliationMode != nil {
+		mode := fiscalDay.ReconciliationMode.String()
+		report.ReconciliationMode = &mode
+	}
+	if fiscalDay.Status == models.FiscalDayStatusClosed {
+		report.FiscalDayServerSignature = fiscalDay.FiscalDayServerSignature
+	}
+
+	report.TaxTotals, report.CurrencyTotals, report.MoneyTypeTotals = aggregateCounters(counters)
+	report.Text = formatReportText(report)
+
+	return report, nil
+}
+
+// aggregateCounters groups fiscal counters into per-tax, per-currency and
+// per-money-type totals. Credit notes carry negative amounts, so the net
+// amount of a currency is the plain sum of sales, credit and debit notes.
+func aggregateCounters(counters []models.FiscalDayCounter) (
+	[]models.ReportTaxTotal,
+	[]models.ReportCurrencyTotal,
+	[]models.ReportMoneyTypeTotal,
+) {
+	taxTotals := make(map[string]*models.ReportTaxTotal)
+	currencyTotals := make(map[string]*models.ReportCurrencyTotal)
+	moneyTypeTotals := make(map[string]*models.ReportMoneyTypeTotal)
+
+	currencyTotal := func(currency string) *models.ReportCurrencyTotal {
+		total, ok := currencyTotals[currency]
+		if !ok {
+			total = &models.ReportCurrencyTotal{Currency: currency}
+			currencyTotals[currency] = total
+		}
+		return total
+	}
+
+	for _, c := range counters {
+		counterType := models.FiscalCounterType(c.FiscalCounterType)
+
+		if counterType == models.FiscalCounterTypeBalanceByMoneyType {
+			moneyType := "Unknown"
+			if c.FiscalCounterMoneyType != nil {
+				moneyType = models.MoneyType(*c.FiscalCounterMoneyType).String()
+			}
+			key := c.FiscalCounterCurrency + "|" + moneyType
+			total, ok := moneyTypeTotals[key]
+			if !ok {
+				total = &models.ReportMoneyTypeTotal{Currency: c.FiscalCounterCurrency, MoneyType: moneyType}
+				moneyTypeTotals[key] = total
+			}
+			total.Amount += c.FiscalCounterValue
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%v|%v", c.FiscalCounterCurrency, derefInt(c.FiscalCounterTaxID), derefFloat(c.FiscalCounterTaxPercent))
+		tax, ok := taxTotals[key]
+		if !ok {
+			tax = &models.ReportTaxTotal{
+				Currency:   c.FiscalCounterCurrency,
+				TaxID:      c.FiscalCounterTaxID,
+				TaxPercent: c.FiscalCounterTaxPercent,
+			}
+			taxTotals[key] = tax
+		}
+		currency := currencyTotal(c.FiscalCounterCurrency)
+
+		switch counterType {
+		case models.FiscalCounterTypeSaleByTax:
+			tax.SalesAmount += c.FiscalCounterValue
+			currency.SalesAmount += c.FiscalCounterValue
+		case models.FiscalCounterTypeSaleTaxByTax:
+			tax.SalesTax += c.FiscalCounterValue
+			currency.TaxAmount += c.FiscalCounterValue
+		case models.FiscalCounterTypeCreditNoteByTax:
+			tax.CreditNoteAmount += c.FiscalCounterValue
+			currency.CreditNoteAmount += c.FiscalCounterValue
+		case models.FiscalCounterTypeCreditNoteTaxByTax:
+			tax.CreditNoteTax += c.FiscalCounterValue
+			currency.TaxAmount += c.FiscalCounterValue
+		case models.FiscalCounterTypeDebitNoteByTax:
+			tax.DebitNoteAmount += c.FiscalCounterValue
+			currency.DebitNoteAmount += c.FiscalCounterValue
+		case models.FiscalCounterTypeDebitNoteTaxByTax:
+			tax.DebitNoteTax += c.FiscalCounterValue
+			currency.TaxAmount += c.FiscalCounterValue
+		}
+	}
+
+	taxes := make([]models.ReportTaxTotal, 0, len(taxTotals))
+	for _, t := range taxTotals {
+		taxes = append(taxes, *t)
+	}
+	sort.Slice(taxes, func(i, j int) bool {
+		if taxes[i].Currency != taxes[j].Currency {
+			return taxes[i].Currency < taxes[j].Currency
+		}
+		return derefInt(taxes[i].TaxID) < derefInt(taxes[j].TaxID)
+	})
+
+	currencies := make([]models.ReportCurrencyTotal, 0, len(currencyTotals))
+	for _, c := range currencyTotals {
+		c.NetAmount = c.SalesAmount + c.CreditNoteAmount + c.DebitNoteAmount
+		currencies = append(currencies, *c)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].Currency < currencies[j].Currency
+	})
+
+	moneyTypes := make([]models.ReportMoneyTypeTotal, 0, len(moneyTypeTotals))
+	for _, m := range moneyTypeTotals {
+		moneyTypes = append(moneyTypes, *m)
+	}
+	sort.Slice(moneyTypes, func(i, j int) bool {
+		if moneyTypes[i].Currency != moneyTypes[j].Currency {
+			return moneyTypes[i].Currency < moneyTypes[j].Currency
+		}
+		return moneyTypes[i].MoneyType < moneyTypes[j].MoneyType
+	})
+
+	return taxes, currencies, moneyTypes
+}
+
+// formatReportText renders the report for a 48-column receipt printer
+func formatReportText(r *models.FiscalDayReport) string {
+	var b strings.Builder
+
+	line := func(s string) {
+		if len(s) > reportWidth {
+			s = s[:reportWidth]
+		}
+		b.WriteString(s)
+		b.WriteByte('\n')
+	}
+	center := func(s string) {
+		if pad := (reportWidth - len(s)) / 2; pad > 0 {
+			s = strings.Repeat(" ", pad) + s
+		}
+		line(s)
+	}
+	pair := func(label, value string) {
+		space := reportWidth - len(label) - len(value)
+		if space < 1 {
+			space = 1
+		}
+		line(label + strings.Repeat(" ", space) + value)
+	}
+	amount := func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	}
+	separator := strings.Repeat("-", reportWidth)
+
+	if r.ReportType == models.ReportTypeZ {
+		center("Z-REPORT")
+	} else {
+		center("X-REPORT")
+	}
+	center(r.TaxPayerName)
+	pair("TIN:", r.TaxPayerTIN)
+	if r.VATNumber != "" {
+		pair("VAT No:", r.VATNumber)
+	}
+	pair("Branch:", r.DeviceBranchName)
+	pair("Device ID:", fmt.Sprintf("%d", r.DeviceID))
+	pair("Serial No:", r.DeviceSerialNo)
+	line(separator)
+	pair("Fiscal day:", fmt.Sprintf("%d", r.FiscalDayNo))
+	pair("Opened:", r.FiscalDayOpened.Format("2006-01-02 15:04:05"))
+	if r.FiscalDayClosed != nil {
+		pair("Closed:", r.FiscalDayClosed.Format("2006-01-02 15:04:05"))
+	}
+	if r.ReconciliationMode != nil {
+		pair("Reconciliation:", *r.ReconciliationMode)
+	}
+	pair("Printed:", r.GeneratedAt.Format("2006-01-02 15:04:05"))
+
+	for _, c := range r.CurrencyTotals {
+		line(separator)
+		center("TOTALS " + c.Currency)
+		pair("Sales", amount(c.SalesAmount))
+		pair("Credit notes", amount(c.CreditNoteAmount))
+		pair("Debit notes", amount(c.DebitNoteAmount))
+		pair("Tax", amount(c.TaxAmount))
+		pair("Net", amount(c.NetAmount))
+
+		for _, t := range r.TaxTotals {
+			if t.Currency != c.Currency {
+				continue
+			}
+			label := "Exempt"
+			if t.TaxPercent != nil {
+				label = fmt.Sprintf("%.2f%%", *t.TaxPercent)
+			}
+			if t.TaxID != nil {
+				label = fmt.Sprintf("Tax %d %s", *t.TaxID, label)
+			}
+			line("")
+			line(label)
+			pair("  Sales", amount(t.SalesAmount))
+			pair("  Sales tax", amount(t.SalesTax))
+			pair("  Credit notes", amount(t.CreditNoteAmount))
+			pair("  Credit note tax", amount(t.CreditNoteTax))
+			pair("  Debit notes", amount(t.DebitNoteAmount))
+			pair("  Debit note tax", amount(t.DebitNoteTax))
+		}
+
+		first := true
+		for _, m := range r.MoneyTypeTotals {
+			if m.Currency != c.Currency {
+				continue
+			}
+			if first {
+				line("")
+				line("Payments")
+				first = false
+			}
+			pair("  "+m.MoneyType, amount(m.Amount))
+		}
+	}
+
+	if len(r.DocumentQuantities) > 0 {
+		line(separator)
+		center("DOCUMENTS")
+		for _, d := range r.DocumentQuantities {
+			pair(fmt.Sprintf("%s %s", models.ReceiptType(d.ReceiptType).String(), d.ReceiptCurrency),
+				fmt.Sprintf("%d / %s", d.ReceiptQuantity, amount(d.ReceiptTotalAmount)))
+		}
+	}
+
+	if r.FiscalDayServerSignature != nil {
+		line(separator)
+		line("Server signature:")
+		sig := base64.StdEncoding.EncodeToString(r.FiscalDayServerSignature.Signature)
+		for len(sig) > reportWidth {
+			line(sig[:reportWidth])
+			sig = sig[reportWidth:]
+		}
+		line(sig)
+	}
+
+	line(separator)
+	center("END OF " + string(r.ReportType) + "-REPORT")
+
+	return b.String()
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func derefFloat(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+)
+
+func TestAggregateCounters(t *testing.T) {
+	taxID := 1
+	taxPercent := 15.0
+	cash := int(models.MoneyTypeCash)
+	card := int(models.MoneyTypeCard)
+
+	counters := []models.FiscalDayCounter{
+		{FiscalCounterType: int(models.FiscalCounterTypeSaleByTax), FiscalCounterCurrency: "USD", FiscalCounterTaxID: &taxID, FiscalCounterTaxPercent: &taxPercent, FiscalCounterValue: 115},
+		{FiscalCounterType: int(models.FiscalCounterTypeSaleTaxByTax), FiscalCounterCurrency: "USD", FiscalCounterTaxID: &taxID, FiscalCounterTaxPercent: &taxPercent, FiscalCounterValue: 15},
+		{FiscalCounterType: int(models.FiscalCounterTypeCreditNoteByTax), FiscalCounterCurrency: "USD", FiscalCounterTaxID: &taxID, FiscalCounterTaxPercent: &taxPercent, FiscalCounterValue: -23},
+		{FiscalCounterType: int(models.FiscalCounterTypeBalanceByMoneyType), FiscalCounterCurrency: "USD", FiscalCounterMoneyType: &cash, FiscalCounterValue: 50},
+		{FiscalCounterType: int(models.FiscalCounterTypeBalanceByMoneyType), FiscalCounterCurrency: "USD", FiscalCounterMoneyType: &card, FiscalCounterValue: 42},
+		{FiscalCounterType: int(models.FiscalCounterTypeSaleByTax), FiscalCounterCurrency: "ZWG", FiscalCounterTaxID: &taxID, FiscalCounterTaxPercent: &taxPercent, FiscalCounterValue: 1000},
+	}
+
+	taxes, currencies, moneyTypes := aggregateCounters(counters)
+
+	if len(taxes) != 2 {
+		t.Fatalf("aggregateCounters() tax totals = %d, want 2", len(taxes))
+	}
+	if taxes[0].Currency != "USD" || taxes[0].SalesAmount != 115 || taxes[0].SalesTax != 15 || taxes[0].CreditNoteAmount != -23 {
+		t.Errorf("aggregateCounters() USD tax total = %+v", taxes[0])
+	}
+
+	if len(currencies) != 2 {
+		t.Fatalf("aggregateCounters() currency totals = %d, want 2", len(currencies))
+	}
+	if currencies[0].NetAmount != 92 {
+		t.Errorf("aggregateCounters() USD net = %v, want 92", currencies[0].NetAmount)
+	}
+	if currencies[1].Currency != "ZWG" || currencies[1].NetAmount != 1000 {
+		t.Errorf("aggregateCounters() ZWG total = %+v", currencies[1])
+	}
+
+	if len(moneyTypes) != 2 || moneyTypes[0].MoneyType != "Card" || moneyTypes[1].Amount != 50 {
+		t.Errorf("aggregateCounters() money type totals = %+v", moneyTypes)
+	}
+}
+
+func TestFormatReportText_Width(t *testing.T) {
+	taxID := 1
+	report := &models.FiscalDayReport{
+		ReportType:      models.ReportTypeZ,
+		GeneratedAt:     time.Now(),
+		TaxPayerName:    "A Taxpayer With An Unusually Long Registered Company Name (Private) Limited",
+		TaxPayerTIN:     "1234567890",
+		FiscalDayNo:     7,
+		FiscalDayOpened: time.Now(),
+		CurrencyTotals:  []models.ReportCurrencyTotal{{Currency: "USD", SalesAmount: 123456789.99}},
+		TaxTotals:       []models.ReportTaxTotal{{Currency: "USD", TaxID: &taxID, SalesAmount: 123456789.99}},
+		FiscalDayServerSignature: &models.SignatureDataEx{
+			SignatureData: models.SignatureData{Signature: make([]byte, 256)},
+		},
+	}
+
+	text := formatReportText(report)
+	for i, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if len(line) > reportWidth {
+			t.Errorf("line %d is %d characters wide, want at most %d: %q", i+1, len(line), reportWidth, line)
+		}
+	}
+	if !strings.Contains(text, "Z-REPORT") {
+		t.Error("formatReportText() missing report title")
+	}
+}
@@ -1,7 +1,15 @@
 package service
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"fiscalization-api/internal/models"
@@ -13,9 +21,31 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// securityCodeTTL is how long a security code can be used
+	securityCodeTTL = 15 * time.Minute
+
+	// securityCodeMaxAttempts is how many times a security code can be tried
+	// before it is discarded and a new one must be requested
+	securityCodeMaxAttempts = 5
+
+	// accessTokenTTL is how long an access token can be used before it has
+	// to be replaced with the session's refresh token
+	accessTokenTTL = 15 * time.Minute
+
+	// refreshTokenTTL is how long a session lasts without being refreshed
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	// sessionMaxAge is how long refreshing can keep a session alive; after
+	// that the user has to sign in again
+	sessionMaxAge = 30 * 24 * time.Hour
+)
+
 type UserService struct {
 	userRepo   repository.UserRepository
 	deviceRepo repository.DeviceRepository
+	txManager  repository.TxManager
+	notifier   UserNotifier
 	jwtSecret  string
 	logger     *zap.Logger
 }
@@ -23,21 +53,33 @@ type UserService struct {
 func NewUserService(
 	userRepo repository.UserRepository,
 	deviceRepo repository.DeviceRepository,
+	txManager repository.TxManager,
+	notifier UserNotifier,
 	jwtSecret string,
 	logger *zap.Logger,
 ) *UserService {
 	return &UserService{
 		userRepo:   userRepo,
 		deviceRepo: deviceRepo,
+		txManager:  txManager,
+		notifier:   notifier,
 		jwtSecret:  jwtSecret,
 		logger:     logger,
 	}
 }
 
-// Login authenticates a user
-func (s *UserService) Login(req models.LoginRequest) (*models.LoginResponse, error) {
-	// Get user by username
-	user, err := s.userRepo.GetByUsername(req.Username)
+// Login authenticates a user and starts a session on the device
+func (s *UserService) Login(ctx context.Context, req models.LoginRequest, ipAddress string) (*models.LoginResponse, error) {
+	// Users can only sign in on devices of their own taxpayer, and are told
+	// no more about it than about a wrong password
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, models.NewAPIError(401, "Invalid credentials", models.ErrCodeUSER02)
+	}
+	user, err := s.findUser(ctx, device.TaxpayerID, req.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -55,27 +97,29 @@ func (s *UserService) Login(req models.LoginRequest) (*models.LoginResponse, err
 		return nil, models.NewAPIError(401, "Invalid credentials", models.ErrCodeUSER02)
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := s.generateJWT(user)
+	tokens, err := s.startSession(ctx, user, req.DeviceID, ipAddress)
 	if err != nil {
-		s.logger.Error("Failed to generate JWT", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate token")
+		return nil, err
 	}
 
-	s.logger.Info("User logged in", zap.String("username", user.Username))
+	s.logger.Info("User logged in", zap.String("username", user.Username), zap.Int("deviceID", req.DeviceID))
 
 	return &models.LoginResponse{
-		OperationID: utils.GenerateOperationID(),
-		Token:       token,
-		ExpiresAt:   expiresAt,
-		User:        *user,
+		OperationID:   utils.GenerateOperationID(),
+		SessionTokens: *tokens,
+		User:          *user,
 	}, nil
 }
 
-// CreateUserBegin initiates user creation by sending security code
-func (s *UserService) CreateUserBegin(req models.CreateUserBeginRequest) (*models.CreateUserBeginResponse, error) {
+// CreateUserBegin initiates user creation by sending security code. operator
+// must be allowed to manage users with the requested role.
+func (s *UserService) CreateUserBegin(ctx context.Context, operator models.Operator, req models.CreateUserBeginRequest) (*models.CreateUserBeginResponse, error) {
+	if !operator.Role.CanManage(req.UserRole) {
+		return nil, models.NewAPIError(403, fmt.Sprintf("Role %s cannot create %s users", operator.Role, req.UserRole), models.ErrCodeUSER10)
+	}
+
 	// Validate device exists
-	device, err := s.deviceRepo.GetByDeviceID(req.DeviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +128,7 @@ func (s *UserService) CreateUserBegin(req models.CreateUserBeginRequest) (*model
 	}
 
 	// Check if username already exists
-	existing, err := s.userRepo.GetByUsername(req.Username)
+	existing, err := s.findUser(ctx, device.TaxpayerID, req.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -92,11 +136,13 @@ func (s *UserService) CreateUserBegin(req models.CreateUserBeginRequest) (*model
 		return nil, models.NewAPIError(422, "Username already exists", models.ErrCodeUSER06)
 	}
 
-	// Generate security code
-	securityCode, err := utils.GenerateSecurityCode(6)
-	if err != nil {
-		s.logger.Error("Failed to generate security code", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate security code")
+	// The security code goes to the contact of the requested channel
+	email, phoneNo := strings.TrimSpace(req.UserEmail), strings.TrimSpace(req.PhoneNo)
+	switch {
+	case req.Channel == models.SendSecurityCodeToEmail && !utils.ValidateEmail(email):
+		return nil, models.NewAPIError(422, "Invalid email address", models.ErrCodeDEV14)
+	case req.Channel == models.SendSecurityCodeToPhoneNumber && !utils.ValidatePhoneNumber(phoneNo):
+		return nil, models.NewAPIError(422, "Invalid phone number", models.ErrCodeDEV14)
 	}
 
 	// Create temporary user with auto-generated password (will be set during confirmation)
@@ -114,25 +160,36 @@ func (s *UserService) CreateUserBegin(req models.CreateUserBeginRequest) (*model
 		PersonName:    req.PersonName,
 		PersonSurname: req.PersonSurname,
 		UserRole:      req.UserRole,
+		Email:         email,
+		PhoneNo:       phoneNo,
 		Status:        models.UserStatusNotConfirmed,
+		Language:      req.Language.OrDefault(),
 	}
 
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
 		s.logger.Error("Failed to create user", zap.Error(err))
 		return nil, fmt.Errorf("failed to create user")
 	}
 
-	// Save security code (expires in 15 minutes)
-	expiresAt := time.Now().Add(15 * time.Minute)
-	if err := s.userRepo.SaveSecurityCode(user.ID, securityCode, expiresAt); err != nil {
-		s.logger.Error("Failed to save security code", zap.Error(err))
-		return nil, fmt.Errorf("failed to save security code")
+	securityCode, err := s.issueSecurityCode(ctx, &models.SecurityCode{UserID: user.ID, Purpose: models.SecurityCodePurposeCreateUser})
+	if err != nil {
+		return nil, err
 	}
 
-	// In production, send security code via email/SMS
-	s.logger.Info("Security code generated for user creation",
+	if err := s.notifier.SendSecurityCode(ctx, user, req.Channel, securityCode); err != nil {
+		s.logger.Error("Failed to send security code",
+			zap.String("username", user.Username),
+			zap.String("channel", req.Channel.String()),
+			zap.Error(err),
+		)
+		// Free the username so the creation can be retried
+		s.userRepo.Delete(ctx, user.ID)
+		return nil, fmt.Errorf("failed to send security code")
+	}
+
+	s.logger.Info("Security code sent for user creation",
 		zap.String("username", user.Username),
-		zap.String("code", securityCode), // Don't log in production!
+		zap.String("channel", req.Channel.String()),
 	)
 
 	return &models.CreateUserBeginResponse{
@@ -140,40 +197,23 @@ func (s *UserService) CreateUserBegin(req models.CreateUserBeginRequest) (*model
 	}, nil
 }
 
-// CreateUserConfirm confirms user creation with security code
-func (s *UserService) CreateUserConfirm(req models.CreateUserConfirmRequest) (*models.CreateUserConfirmResponse, error) {
+// CreateUserConfirm confirms user creation with security code and signs the
+// new user in on the device
+func (s *UserService) CreateUserConfirm(ctx context.Context, req models.CreateUserConfirmRequest, ipAddress string) (*models.CreateUserConfirmResponse, error) {
 	// Get user by username (not by ID, as CreateUserConfirmRequest uses username)
-	user, err := s.userRepo.GetByUsername(req.Username)
+	user, err := s.taxpayerUser(ctx, req.DeviceID, req.Username)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, models.NewAPIError(422, "User not found", models.ErrCodeUSER01)
-	}
 
 	// Check if user is not confirmed
 	if user.Status != models.UserStatusNotConfirmed {
 		return nil, models.NewAPIError(422, "User is not in pending state", models.ErrCodeUSER03)
 	}
 
-	// Get security code
-	savedCode, expiresAt, err := s.userRepo.GetSecurityCode(user.ID)
-	if err != nil {
+	if _, err := s.checkSecurityCode(ctx, user.ID, models.SecurityCodePurposeCreateUser, req.SecurityCode); err != nil {
 		return nil, err
 	}
-	if savedCode == "" {
-		return nil, models.NewAPIError(422, "Security code not found or expired", models.ErrCodeUSER04)
-	}
-
-	// Verify security code
-	if savedCode != req.SecurityCode {
-		return nil, models.NewAPIError(422, "Invalid security code", models.ErrCodeUSER04)
-	}
-
-	// Check expiration
-	if time.Now().After(expiresAt) {
-		return nil, models.NewAPIError(422, "Security code expired", models.ErrCodeUSER05)
-	}
 
 	// Hash the new password
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -183,67 +223,104 @@ func (s *UserService) CreateUserConfirm(req models.CreateUserConfirmRequest) (*m
 	}
 
 	// Update user password and activate
-	if err := s.userRepo.UpdatePassword(user.ID, string(passwordHash)); err != nil {
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, string(passwordHash)); err != nil {
 		s.logger.Error("Failed to update password", zap.Error(err))
 		return nil, fmt.Errorf("failed to update password")
 	}
 
 	user.Status = models.UserStatusActive
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.Update(ctx, user); err != nil {
 		s.logger.Error("Failed to activate user", zap.Error(err))
 		return nil, fmt.Errorf("failed to activate user")
 	}
 
 	// Delete security code
-	s.userRepo.DeleteSecurityCode(user.ID)
+	s.userRepo.DeleteSecurityCode(ctx, user.ID, models.SecurityCodePurposeCreateUser)
 
-	// Generate JWT token
-	token, _, err := s.generateJWT(user)
+	tokens, err := s.startSession(ctx, user, req.DeviceID, ipAddress)
 	if err != nil {
-		s.logger.Error("Failed to generate JWT", zap.Error(err))
-		return nil, fmt.Errorf("failed to generate token")
+		return nil, err
 	}
 
 	s.logger.Info("User created successfully", zap.String("username", user.Username))
 
 	return &models.CreateUserConfirmResponse{
-		OperationID: utils.GenerateOperationID(),
-		User:        *user,
-		JWTToken:    token,
+		OperationID:  utils.GenerateOperationID(),
+		User:         *user,
+		JWTToken:     tokens.Token,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
-// UpdateUser updates user information
-func (s *UserService) UpdateUser(req models.UpdateUserRequest) (*models.UpdateUserResponse, error) {
-	// Get user by username
-	user, err := s.userRepo.GetByUsername(req.Username)
+// UpdateUser updates a user of the device's taxpayer on behalf of operator,
+// who must be allowed to manage users with both the current and the new
+// role. Operators cannot change their own role or status. Users who are no
+// longer active are signed out of every session.
+func (s *UserService) UpdateUser(ctx context.Context, operator models.Operator, req models.UpdateUserRequest) (*models.UpdateUserResponse, error) {
+	user, err := s.taxpayerUser(ctx, req.DeviceID, req.Username)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, models.NewAPIError(422, "User not found", models.ErrCodeUSER01)
+
+	if user.ID == operator.UserID {
+		if req.UserRole != user.UserRole || req.UserStatus != user.Status {
+			return nil, models.NewAPIError(403, "Users cannot change their own role or status", models.ErrCodeUSER10)
+		}
+	} else if !operator.Role.CanManage(user.UserRole) || !operator.Role.CanManage(req.UserRole) {
+		return nil, models.NewAPIError(403, fmt.Sprintf("Role %s cannot manage %s users", operator.Role, user.UserRole), models.ErrCodeUSER10)
 	}
 
+	details, _ := json.Marshal(map[string]interface{}{
+		"username":       user.Username,
+		"operator":       operator.Username,
+		"previousRole":   user.UserRole,
+		"role":           req.UserRole,
+		"previousStatus": user.Status.String(),
+		"status":         req.UserStatus.String(),
+	})
+
 	// Update fields
 	user.PersonName = req.PersonName
 	user.PersonSurname = req.PersonSurname
 	user.UserRole = req.UserRole
 	user.Status = req.UserStatus
+	if req.Language != "" {
+		user.Language = req.Language
+	}
 
-	if err := s.userRepo.Update(user); err != nil {
+	err = s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		if err := repos.Users.Update(ctx, user); err != nil {
+			return err
+		}
+		if user.Status != models.UserStatusActive {
+			if _, err := repos.Users.RevokeSessions(ctx, user.ID, models.SessionRevokeReasonUserBlocked); err != nil {
+				return err
+			}
+		}
+		return repos.Admin.InsertAuditLog(ctx, "user", "update", &user.ID, &operator.UserID, &req.DeviceID, operator.IPAddress, string(details))
+	})
+	if err != nil {
 		s.logger.Error("Failed to update user", zap.Error(err))
 		return nil, fmt.Errorf("failed to update user")
 	}
 
+	s.logger.Info("User updated",
+		zap.String("username", user.Username),
+		zap.String("operator", operator.Username),
+		zap.String("role", string(user.UserRole)),
+	)
+
 	return &models.UpdateUserResponse{
 		OperationID: utils.GenerateOperationID(),
 	}, nil
 }
 
-// ChangePassword changes user password
-func (s *UserService) ChangePassword(req models.ChangePasswordRequest) (*models.ChangePasswordResponse, error) {
+// ChangePassword changes the operator's password and ends every session of
+// the operator, who has to sign in again with the new password. The operator
+// was authenticated for the device, so only users of its taxpayer can be changed.
+func (s *UserService) ChangePassword(ctx context.Context, operator models.Operator, req models.ChangePasswordRequest) (*models.ChangePasswordResponse, error) {
 	// Get user
-	user, err := s.userRepo.GetByID(req.UserID)
+	user, err := s.userRepo.GetByID(ctx, operator.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -264,12 +341,12 @@ func (s *UserService) ChangePassword(req models.ChangePasswordRequest) (*models.
 	}
 
 	// Update password
-	if err := s.userRepo.UpdatePassword(user.ID, string(passwordHash)); err != nil {
-		s.logger.Error("Failed to update password", zap.Error(err))
-		return nil, fmt.Errorf("failed to update password")
+	revoked, err := s.updatePassword(ctx, user.ID, string(passwordHash), models.SessionRevokeReasonPasswordChange)
+	if err != nil {
+		return nil, err
 	}
 
-	s.logger.Info("Password changed", zap.String("username", user.Username))
+	s.logger.Info("Password changed", zap.String("username", user.Username), zap.Int("revokedSessions", revoked))
 
 	return &models.ChangePasswordResponse{
 		OperationID: utils.GenerateOperationID(),
@@ -277,10 +354,390 @@ func (s *UserService) ChangePassword(req models.ChangePasswordRequest) (*models.
 	}, nil
 }
 
+// ResetPasswordBegin sends a password reset code to the email address or
+// phone number of a user of the device's taxpayer. The caller is not signed
+// in, so the response is the same whether or not a code was sent, and why
+// none was is only logged.
+func (s *UserService) ResetPasswordBegin(ctx context.Context, req models.ResetUserPasswordBeginRequest) (*models.ResetUserPasswordBeginResponse, error) {
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
+	}
+
+	user, err := s.findUser(ctx, device.TaxpayerID, req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var reason string
+	switch {
+	case user == nil:
+		reason = "User not found"
+	case user.Status != models.UserStatusActive:
+		reason = "User account is not active"
+	case req.Channel == models.SendSecurityCodeToEmail && user.Email == "":
+		reason = "User has no email address"
+	case req.Channel == models.SendSecurityCodeToPhoneNumber && user.PhoneNo == "":
+		reason = "User has no phone number"
+	}
+	if reason != "" {
+		s.logger.Info("Password reset code not sent",
+			zap.Int("deviceID", req.DeviceID),
+			zap.String("username", req.Username),
+			zap.String("channel", req.Channel.String()),
+			zap.String("reason", reason),
+		)
+		return &models.ResetUserPasswordBeginResponse{
+			OperationID: utils.GenerateOperationID(),
+		}, nil
+	}
+
+	securityCode, err := s.issueSecurityCode(ctx, &models.SecurityCode{UserID: user.ID, Purpose: models.SecurityCodePurposeResetPassword})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.SendPasswordResetCode(ctx, user, req.Channel, securityCode); err != nil {
+		s.logger.Error("Failed to send password reset code",
+			zap.String("username", user.Username),
+			zap.String("channel", req.Channel.String()),
+			zap.Error(err),
+		)
+		s.userRepo.DeleteSecurityCode(ctx, user.ID, models.SecurityCodePurposeResetPassword)
+		return nil, fmt.Errorf("failed to send security code")
+	}
+
+	s.logger.Info("Password reset requested",
+		zap.String("username", user.Username),
+		zap.String("channel", req.Channel.String()),
+	)
+
+	return &models.ResetUserPasswordBeginResponse{
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
+// ResetPasswordConfirm sets a new password with the code sent by
+// ResetPasswordBegin. Every session of the user is ended; the response
+// carries the tokens of a new one on the device.
+func (s *UserService) ResetPasswordConfirm(ctx context.Context, req models.ResetUserPasswordConfirmRequest, ipAddress string) (*models.ResetUserPasswordConfirmResponse, error) {
+	user, err := s.taxpayerUser(ctx, req.DeviceID, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, models.NewAPIError(422, "User account is not active", models.ErrCodeUSER03)
+	}
+
+	if _, err := s.checkSecurityCode(ctx, user.ID, models.SecurityCodePurposeResetPassword, req.SecurityCode); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, fmt.Errorf("failed to hash password")
+	}
+
+	revoked, err := s.updatePassword(ctx, user.ID, string(passwordHash), models.SessionRevokeReasonPasswordReset)
+	if err != nil {
+		return nil, err
+	}
+	s.userRepo.DeleteSecurityCode(ctx, user.ID, models.SecurityCodePurposeResetPassword)
+
+	tokens, err := s.startSession(ctx, user, req.DeviceID, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Password reset", zap.String("username", user.Username), zap.Int("revokedSessions", revoked))
+
+	return &models.ResetUserPasswordConfirmResponse{
+		OperationID:  utils.GenerateOperationID(),
+		User:         *user,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// ContactChangeBegin sends a security code to the new email address or phone
+// number of the user holding the token. Nothing is changed until the code is
+// confirmed with ContactChangeConfirm.
+func (s *UserService) ContactChangeBegin(ctx context.Context, req models.SendSecurityCodeContactChangeRequest) (*models.SendSecurityCodeContactChangeResponse, error) {
+	user, _, err := s.tokenUser(ctx, req.DeviceID, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var channel models.SendSecurityCodeTo
+	var purpose models.SecurityCodePurpose
+	var target, current string
+	switch {
+	case req.UserEmail != nil && req.PhoneNo != nil:
+		return nil, models.NewAPIError(422, "Change either the email address or the phone number", models.ErrCodeDEV14)
+	case req.UserEmail != nil:
+		channel, purpose = models.SendSecurityCodeToEmail, models.SecurityCodePurposeChangeEmail
+		target, current = strings.TrimSpace(*req.UserEmail), user.Email
+		if !utils.ValidateEmail(target) {
+			return nil, models.NewAPIError(422, "Invalid email address", models.ErrCodeDEV14)
+		}
+	case req.PhoneNo != nil:
+		channel, purpose = models.SendSecurityCodeToPhoneNumber, models.SecurityCodePurposeChangePhone
+		target, current = strings.TrimSpace(*req.PhoneNo), user.PhoneNo
+		if !utils.ValidatePhoneNumber(target) {
+			return nil, models.NewAPIError(422, "Invalid phone number", models.ErrCodeDEV14)
+		}
+	default:
+		return nil, models.NewAPIError(422, "New email address or phone number is required", models.ErrCodeDEV14)
+	}
+	if strings.EqualFold(target, current) {
+		return nil, models.NewAPIError(422, "Contact is already confirmed for this user", models.ErrCodeDEV15)
+	}
+
+	securityCode, err := s.issueSecurityCode(ctx, &models.SecurityCode{UserID: user.ID, Purpose: purpose, Target: target})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.SendContactChangeCode(ctx, user, channel, target, securityCode); err != nil {
+		s.logger.Error("Failed to send contact change code",
+			zap.String("username", user.Username),
+			zap.String("channel", channel.String()),
+			zap.Error(err),
+		)
+		s.userRepo.DeleteSecurityCode(ctx, user.ID, purpose)
+		return nil, fmt.Errorf("failed to send security code")
+	}
+
+	s.logger.Info("Contact change requested",
+		zap.String("username", user.Username),
+		zap.String("channel", channel.String()),
+	)
+
+	return &models.SendSecurityCodeContactChangeResponse{
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
+// ContactChangeConfirm replaces the user's email address or phone number
+// with the one the code was sent to. The change is audited and a notice to
+// the previous contact is queued.
+func (s *UserService) ContactChangeConfirm(ctx context.Context, req models.ConfirmUserContactChangeRequest, ipAddress string) (*models.ConfirmUserContactChangeResponse, error) {
+	user, _, err := s.tokenUser(ctx, req.DeviceID, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	purpose := models.SecurityCodePurposeChangeEmail
+	if req.Channel == models.SendSecurityCodeToPhoneNumber {
+		purpose = models.SecurityCodePurposeChangePhone
+	}
+
+	saved, err := s.checkSecurityCode(ctx, user.ID, purpose, req.SecurityCode)
+	if err != nil {
+		return nil, err
+	}
+
+	field, previous := "email", user.Email
+	if req.Channel == models.SendSecurityCodeToPhoneNumber {
+		field, previous = "phoneNo", user.PhoneNo
+		user.PhoneNo = saved.Target
+	} else {
+		user.Email = saved.Target
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"username": user.Username,
+		"field":    field,
+		"previous": previous,
+		"new":      saved.Target,
+	})
+
+	// The change, its audit entry and the notice to the previous contact are
+	// committed together
+	err = s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		if err := repos.Users.Update(ctx, user); err != nil {
+			return err
+		}
+		if err := repos.Users.DeleteSecurityCode(ctx, user.ID, purpose); err != nil {
+			return err
+		}
+		if err := repos.Admin.InsertAuditLog(ctx, "user", "contact_change", &user.ID, &user.ID, &req.DeviceID, ipAddress, string(details)); err != nil {
+			return err
+		}
+		return s.notifier.QueueContactChanged(ctx, repos.Notifications, user, req.Channel, previous)
+	})
+	if err != nil {
+		s.logger.Error("Failed to update user contact", zap.Error(err))
+		return nil, fmt.Errorf("failed to update user")
+	}
+
+	s.logger.Info("User contact changed",
+		zap.String("username", user.Username),
+		zap.String("channel", req.Channel.String()),
+	)
+
+	return &models.ConfirmUserContactChangeResponse{
+		User:        *user,
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
+// RefreshSession replaces the access token of a session with a new one, and
+// its refresh token with the one returned. A refresh token that was already
+// replaced ends the session: it was copied, and neither holder can be told
+// apart from the other.
+func (s *UserService) RefreshSession(ctx context.Context, req models.RefreshTokenRequest, ipAddress string) (*models.RefreshTokenResponse, error) {
+	invalid := models.NewAPIError(401, "Refresh token is not valid", models.ErrCodeDEV12)
+
+	hash := s.hashRefreshToken(req.RefreshToken)
+	session, err := s.userRepo.GetSessionByRefreshHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	// Sessions belong to the device they were started on
+	if session == nil || !session.Active(time.Now()) || session.DeviceID != req.DeviceID {
+		return nil, invalid
+	}
+	if session.RefreshTokenHash != hash {
+		s.logger.Warn("Replaced refresh token presented, revoking session",
+			zap.Int64("sessionID", session.ID),
+			zap.Int64("userID", session.UserID),
+			zap.String("ipAddress", ipAddress),
+		)
+		if err := s.userRepo.RevokeSession(ctx, session.ID, models.SessionRevokeReasonTokenReuse); err != nil {
+			return nil, err
+		}
+		return nil, invalid
+	}
+
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalid
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, models.NewAPIError(422, "User account is not active", models.ErrCodeUSER03)
+	}
+
+	device, err := s.deviceRepo.GetByDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
+	}
+	if device.TaxpayerID != user.TaxpayerID {
+		return nil, invalid
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		s.logger.Error("Failed to generate refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate token")
+	}
+	refreshExpiresAt := time.Now().Add(refreshTokenTTL)
+	if maxExpiresAt := session.CreatedAt.Add(sessionMaxAge); refreshExpiresAt.After(maxExpiresAt) {
+		refreshExpiresAt = maxExpiresAt
+	}
+
+	// A concurrent refresh with the same token may have won the race
+	rotated, err := s.userRepo.RotateSession(ctx, session.ID, hash, s.hashRefreshToken(refreshToken), refreshExpiresAt, ipAddress)
+	if err != nil {
+		s.logger.Error("Failed to rotate session", zap.Error(err))
+		return nil, fmt.Errorf("failed to refresh session")
+	}
+	if !rotated {
+		return nil, invalid
+	}
+
+	token, expiresAt, err := s.generateJWT(user, session.ID)
+	if err != nil {
+		s.logger.Error("Failed to generate JWT", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate token")
+	}
+
+	return &models.RefreshTokenResponse{
+		SessionTokens: models.SessionTokens{
+			Token:            token,
+			ExpiresAt:        expiresAt,
+			RefreshToken:     refreshToken,
+			RefreshExpiresAt: refreshExpiresAt,
+		},
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
+// Logout ends the operator's current session, or every session of the
+// operator when req.All is set
+func (s *UserService) Logout(ctx context.Context, operator models.Operator, req models.LogoutRequest) (*models.LogoutResponse, error) {
+	revoked := 1
+	var err error
+	if req.All {
+		revoked, err = s.userRepo.RevokeSessions(ctx, operator.UserID, models.SessionRevokeReasonLogout)
+	} else {
+		err = s.userRepo.RevokeSession(ctx, operator.SessionID, models.SessionRevokeReasonLogout)
+	}
+	if err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.Error(err))
+		return nil, fmt.Errorf("failed to log out")
+	}
+
+	s.logger.Info("User logged out",
+		zap.String("username", operator.Username),
+		zap.Int("revokedSessions", revoked),
+	)
+
+	return &models.LogoutResponse{
+		Revoked:     revoked,
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
+// ListSessions lists the active sessions of the operator, or of another user
+// of the device's taxpayer whom the operator is allowed to manage
+func (s *UserService) ListSessions(ctx context.Context, operator models.Operator, deviceID int, username string) (*models.ListSessionsResponse, error) {
+	userID := operator.UserID
+	if username == "" {
+		username = operator.Username
+	} else if username != operator.Username {
+		user, err := s.taxpayerUser(ctx, deviceID, username)
+		if err != nil {
+			return nil, err
+		}
+		if !operator.Role.CanManage(user.UserRole) {
+			return nil, models.NewAPIError(403, fmt.Sprintf("Role %s cannot manage %s users", operator.Role, user.UserRole), models.ErrCodeUSER10)
+		}
+		userID = user.ID
+	}
+
+	sessions, err := s.userRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []models.UserSession{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == operator.SessionID
+	}
+
+	return &models.ListSessionsResponse{
+		Username:    username,
+		Sessions:    sessions,
+		OperationID: utils.GenerateOperationID(),
+	}, nil
+}
+
 // ListUsers lists all users for a taxpayer
-func (s *UserService) ListUsers(deviceID int, offset, limit int) (*models.ListUsersResponse, error) {
+func (s *UserService) ListUsers(ctx context.Context, deviceID int, offset, limit int) (*models.ListUsersResponse, error) {
 	// Get device to get taxpayer ID
-	device, err := s.deviceRepo.GetByDeviceID(deviceID)
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -288,7 +745,7 @@ func (s *UserService) ListUsers(deviceID int, offset, limit int) (*models.ListUs
 		return nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
 	}
 
-	users, total, err := s.userRepo.List(device.TaxpayerID, offset, limit)
+	users, total, err := s.userRepo.List(ctx, device.TaxpayerID, offset, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -316,11 +773,208 @@ func (s *UserService) ListUsers(deviceID int, offset, limit int) (*models.ListUs
 
 // Helper methods
 
-func (s *UserService) generateJWT(user *models.User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
+// taxpayerUser finds a user by username among the users of the device's
+// taxpayer; usernames are only unique within a taxpayer
+func (s *UserService) taxpayerUser(ctx context.Context, deviceID int, username string) (*models.User, error) {
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
+	}
+
+	user, err := s.findUser(ctx, device.TaxpayerID, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, models.NewAPIError(422, "User not found", models.ErrCodeUSER01)
+	}
+	return user, nil
+}
+
+// findUser returns the taxpayer's user with username, or nil when there is
+// none
+func (s *UserService) findUser(ctx context.Context, taxpayerID int64, username string) (*models.User, error) {
+	users, err := s.userRepo.GetByTaxpayerID(ctx, taxpayerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].Username == username {
+			return &users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// AuthenticateOperator returns the active user token belongs to, who must be
+// a user of deviceID's taxpayer, and the session the token was issued for
+func (s *UserService) AuthenticateOperator(ctx context.Context, deviceID int, token string) (*models.User, *models.UserSession, error) {
+	return s.tokenUser(ctx, deviceID, token)
+}
+
+// tokenUser returns the active user holding token, who must belong to the
+// device's taxpayer, and the token's session, which must have been started
+// on the device
+func (s *UserService) tokenUser(ctx context.Context, deviceID int, token string) (*models.User, *models.UserSession, error) {
+	user, session, err := s.validateAccessToken(ctx, token)
+	if err != nil || session.DeviceID != deviceID {
+		return nil, nil, models.NewAPIError(401, "Token is not valid", models.ErrCodeDEV12)
+	}
+
+	device, err := s.deviceRepo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if device == nil {
+		return nil, nil, models.NewAPIError(422, "Device not found", models.ErrCodeDEV01)
+	}
+	if user.TaxpayerID != device.TaxpayerID {
+		return nil, nil, models.NewAPIError(401, "Token is not valid", models.ErrCodeDEV12)
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, nil, models.NewAPIError(422, "User account is not active", models.ErrCodeUSER03)
+	}
+	return user, session, nil
+}
+
+// startSession starts a session for user on deviceID and issues its tokens
+func (s *UserService) startSession(ctx context.Context, user *models.User, deviceID int, ipAddress string) (*models.SessionTokens, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		s.logger.Error("Failed to generate refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate token")
+	}
+
+	session := &models.UserSession{
+		UserID:           user.ID,
+		DeviceID:         deviceID,
+		RefreshTokenHash: s.hashRefreshToken(refreshToken),
+		IPAddress:        ipAddress,
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
+	}
+	if err := s.userRepo.CreateSession(ctx, session); err != nil {
+		s.logger.Error("Failed to create session", zap.Error(err))
+		return nil, fmt.Errorf("failed to start session")
+	}
+
+	token, expiresAt, err := s.generateJWT(user, session.ID)
+	if err != nil {
+		s.logger.Error("Failed to generate JWT", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate token")
+	}
+
+	return &models.SessionTokens{
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// updatePassword sets the user's password and ends every session of the
+// user for reason, together. It returns how many sessions were ended.
+func (s *UserService) updatePassword(ctx context.Context, userID int64, passwordHash string, reason models.SessionRevokeReason) (int, error) {
+	revoked := 0
+	err := s.txManager.WithinTx(ctx, func(repos repository.Repositories) error {
+		if err := repos.Users.UpdatePassword(ctx, userID, passwordHash); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = repos.Users.RevokeSessions(ctx, userID, reason)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to update password", zap.Error(err))
+		return 0, fmt.Errorf("failed to update password")
+	}
+	return revoked, nil
+}
+
+// generateRefreshToken returns a random refresh token
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken keys the hash with the JWT secret, so a copy of the
+// sessions table is no use without it
+func (s *UserService) hashRefreshToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(s.jwtSecret))
+	mac.Write([]byte("refresh:" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueSecurityCode generates a security code for pending.UserID and saves
+// its hash with pending, replacing any pending code for the same purpose
+func (s *UserService) issueSecurityCode(ctx context.Context, pending *models.SecurityCode) (string, error) {
+	code, err := utils.GenerateSecurityCode(6)
+	if err != nil {
+		s.logger.Error("Failed to generate security code", zap.Error(err))
+		return "", fmt.Errorf("failed to generate security code")
+	}
+
+	pending.CodeHash = s.hashSecurityCode(pending.UserID, code)
+	pending.ExpiresAt = time.Now().Add(securityCodeTTL)
+	if err := s.userRepo.SaveSecurityCode(ctx, pending); err != nil {
+		s.logger.Error("Failed to save security code", zap.Error(err))
+		return "", fmt.Errorf("failed to save security code")
+	}
+	return code, nil
+}
+
+// checkSecurityCode verifies a security code entered by the user and
+// returns the saved code. Every try counts; once securityCodeMaxAttempts are
+// used up the code is discarded.
+func (s *UserService) checkSecurityCode(ctx context.Context, userID int64, purpose models.SecurityCodePurpose, code string) (*models.SecurityCode, error) {
+	// Counting the guess and checking the limit in one statement keeps
+	// parallel guesses from all being let through on the same count
+	saved, err := s.userRepo.ClaimSecurityCodeAttempt(ctx, userID, purpose, securityCodeMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		pending, err := s.userRepo.GetSecurityCode(ctx, userID, purpose)
+		if err != nil {
+			return nil, err
+		}
+		if pending == nil {
+			return nil, models.NewAPIError(422, "Security code not found or expired", models.ErrCodeUSER05)
+		}
+		s.userRepo.DeleteSecurityCode(ctx, userID, purpose)
+		return nil, models.NewAPIError(422, "Too many attempts, request a new security code", models.ErrCodeUSER05)
+	}
+
+	if !hmac.Equal([]byte(saved.CodeHash), []byte(s.hashSecurityCode(userID, code))) {
+		if saved.Attempts >= securityCodeMaxAttempts {
+			s.userRepo.DeleteSecurityCode(ctx, userID, purpose)
+			return nil, models.NewAPIError(422, "Invalid security code, request a new one", models.ErrCodeUSER04)
+		}
+		return nil, models.NewAPIError(422, "Invalid security code", models.ErrCodeUSER04)
+	}
+	return saved, nil
+}
+
+// hashSecurityCode keys the hash with the JWT secret and the user, so the
+// stored hashes cannot be reversed by trying every possible code
+func (s *UserService) hashSecurityCode(userID int64, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.jwtSecret))
+	fmt.Fprintf(mac, "%d:%s", userID, code)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateJWT issues an access token of the user's session sessionID
+func (s *UserService) generateJWT(user *models.User, sessionID int64) (string, time.Time, error) {
+	expiresAt := time.Now().Add(accessTokenTTL)
 
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
+		"sid":      sessionID,
 		"username": user.Username,
 		"role":     user.UserRole,
 		"exp":      expiresAt.Unix(),
@@ -335,7 +989,16 @@ func (s *UserService) generateJWT(user *models.User) (string, time.Time, error)
 	return tokenString, expiresAt, nil
 }
 
-func (s *UserService) ValidateJWT(tokenString string) (*models.User, error) {
+// ValidateJWT returns the user an access token was issued to. The token's
+// session must still be active.
+func (s *UserService) ValidateJWT(ctx context.Context, tokenString string) (*models.User, error) {
+	user, _, err := s.validateAccessToken(ctx, tokenString)
+	return user, err
+}
+
+// validateAccessToken returns the user an access token was issued to and its
+// session
+func (s *UserService) validateAccessToken(ctx context.Context, tokenString string) (*models.User, *models.UserSession, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method")
@@ -344,18 +1007,41 @@ func (s *UserService) ValidateJWT(tokenString string) (*models.User, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, nil, fmt.Errorf("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, fmt.Errorf("invalid claims")
+		return nil, nil, fmt.Errorf("invalid claims")
+	}
+
+	rawID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid claims")
+	}
+	userID := int64(rawID)
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("invalid token")
 	}
 
-	userID := int64(claims["user_id"].(float64))
-	return s.userRepo.GetByID(userID)
+	rawSessionID, ok := claims["sid"].(float64)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid claims")
+	}
+	session, err := s.userRepo.GetSession(ctx, int64(rawSessionID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || session.UserID != user.ID || !session.Active(time.Now()) {
+		return nil, nil, fmt.Errorf("token revoked")
+	}
+	return user, session, nil
 }
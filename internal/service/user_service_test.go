@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"fiscalization-api/internal/email"
+	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/repository"
+	"fiscalization-api/internal/repository/memory"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// codeEmailSender keeps the last security code sent to each address with
+// its recipient, and the addresses told about a contact change
+type codeEmailSender struct {
+	EmailSender
+	codes      map[string]string
+	recipients map[string]email.Recipient
+	changed    []string
+}
+
+func (s *codeEmailSender) SendSecurityCode(to email.Recipient, code string) error {
+	s.codes[to.Address] = code
+	s.recipients[to.Address] = to
+	return nil
+}
+
+func (s *codeEmailSender) SendPasswordReset(to email.Recipient, code string) error {
+	s.codes[to.Address] = code
+	return nil
+}
+
+func (s *codeEmailSender) SendContactChangedNotification(to email.Recipient) error {
+	s.changed = append(s.changed, to.Address)
+	return nil
+}
+
+func newTestUserService(t *testing.T) (*UserService, repository.UserRepository, *codeEmailSender, *memory.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	store := memory.NewStore()
+	admin := memory.NewAdminRepository(store)
+	users := memory.NewUserRepository(store)
+
+	tp := &models.Taxpayer{TIN: "2000000001", Name: "Test Retail", Status: "Active"}
+	if err := admin.CreateTaxpayer(ctx, tp); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+	if err := admin.CreateDevice(ctx, &models.Device{DeviceID: 1001, TaxpayerID: tp.ID, Status: "Active"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	user := &models.User{TaxpayerID: tp.ID, Username: "cashier", PasswordHash: string(hash), UserRole: "Cashier",
+		Email: "cashier@example.com", Status: models.UserStatusActive}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	email := &codeEmailSender{codes: map[string]string{}, recipients: map[string]email.Recipient{}}
+	svc := NewUserService(users, memory.NewDeviceRepository(store), memory.NewTxManager(store), NewNotifier(email, nil, admin, zap.NewNop()), "secret", zap.NewNop())
+	return svc, users, email, store
+}
+
+func TestUserService_ResetPassword(t *testing.T) {
+	ctx := context.Background()
+	svc, _, email, _ := newTestUserService(t)
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	_, err = svc.ResetPasswordBegin(ctx, models.ResetUserPasswordBeginRequest{DeviceID: 1001, Username: "cashier", Channel: models.SendSecurityCodeToEmail})
+	if err != nil {
+		t.Fatalf("ResetPasswordBegin() error = %v", err)
+	}
+	code := email.codes["cashier@example.com"]
+	if len(code) != 6 {
+		t.Fatalf("sent code = %q, want 6 digits", code)
+	}
+
+	confirm := models.ResetUserPasswordConfirmRequest{DeviceID: 1001, Username: "cashier", NewPassword: "new-password", SecurityCode: code}
+	resp, err := svc.ResetPasswordConfirm(ctx, confirm, "")
+	if err != nil {
+		t.Fatalf("ResetPasswordConfirm() error = %v", err)
+	}
+
+	if _, err := svc.ValidateJWT(ctx, login.Token); err == nil {
+		t.Error("token issued before the reset is still valid")
+	}
+	if _, err := svc.ValidateJWT(ctx, resp.Token); err != nil {
+		t.Errorf("token issued by the reset is invalid: %v", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "new-password"}, ""); err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+
+	// The code is used up
+	var apiErr *models.APIError
+	if _, err := svc.ResetPasswordConfirm(ctx, confirm, ""); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeUSER05 {
+		t.Errorf("second ResetPasswordConfirm() error = %v, want %s", err, models.ErrCodeUSER05)
+	}
+}
+
+func TestUserService_ResetPasswordBeginHidesAccounts(t *testing.T) {
+	ctx := context.Background()
+	svc, users, email, store := newTestUserService(t)
+
+	user, _ := users.GetByUsername(ctx, "cashier")
+	for _, u := range []*models.User{
+		{TaxpayerID: user.TaxpayerID, Username: "no-contact", PasswordHash: user.PasswordHash, UserRole: "Cashier", Status: models.UserStatusActive},
+		{TaxpayerID: user.TaxpayerID, Username: "blocked", PasswordHash: user.PasswordHash, UserRole: "Cashier",
+			Email: "blocked@example.com", Status: models.UserStatusBlocked},
+	} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	other := &models.Taxpayer{TIN: "2000000002", Name: "Other Retail", Status: "Active"}
+	if err := memory.NewAdminRepository(store).CreateTaxpayer(ctx, other); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+	if err := users.Create(ctx, &models.User{TaxpayerID: other.ID, Username: "elsewhere", PasswordHash: user.PasswordHash,
+		UserRole: "Cashier", Email: "elsewhere@example.com", Status: models.UserStatusActive}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		channel  models.SendSecurityCodeTo
+	}{
+		{"Unknown user", "nobody", models.SendSecurityCodeToEmail},
+		{"User of another taxpayer", "elsewhere", models.SendSecurityCodeToEmail},
+		{"No email address", "no-contact", models.SendSecurityCodeToEmail},
+		{"No phone number", "cashier", models.SendSecurityCodeToPhoneNumber},
+		{"Blocked user", "blocked", models.SendSecurityCodeToEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.ResetPasswordBegin(ctx, models.ResetUserPasswordBeginRequest{DeviceID: 1001, Username: tt.username, Channel: tt.channel})
+			if err != nil || resp == nil || resp.OperationID == "" {
+				t.Errorf("ResetPasswordBegin() = %+v, %v, want the response of a sent code", resp, err)
+			}
+		})
+	}
+	if len(email.codes) != 0 {
+		t.Errorf("codes sent to %v, want none", email.codes)
+	}
+}
+
+func TestUserService_ResetPasswordAttemptsCapped(t *testing.T) {
+	ctx := context.Background()
+	svc, users, email, _ := newTestUserService(t)
+
+	_, err := svc.ResetPasswordBegin(ctx, models.ResetUserPasswordBeginRequest{DeviceID: 1001, Username: "cashier", Channel: models.SendSecurityCodeToEmail})
+	if err != nil {
+		t.Fatalf("ResetPasswordBegin() error = %v", err)
+	}
+	code := email.codes["cashier@example.com"]
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	confirm := models.ResetUserPasswordConfirmRequest{DeviceID: 1001, Username: "cashier", NewPassword: "new-password", SecurityCode: wrong}
+	for i := 0; i < securityCodeMaxAttempts; i++ {
+		var apiErr *models.APIError
+		if _, err := svc.ResetPasswordConfirm(ctx, confirm, ""); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeUSER04 {
+			t.Fatalf("attempt %d error = %v, want %s", i+1, err, models.ErrCodeUSER04)
+		}
+	}
+
+	confirm.SecurityCode = code
+	if _, err := svc.ResetPasswordConfirm(ctx, confirm, ""); err == nil {
+		t.Fatal("correct code was accepted after the attempts ran out")
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, ""); err != nil {
+		t.Errorf("password changed without a valid code: %v", err)
+	}
+
+	user, _ := users.GetByUsername(ctx, "cashier")
+	if saved, _ := users.GetSecurityCode(ctx, user.ID, models.SecurityCodePurposeResetPassword); saved != nil {
+		t.Error("security code was kept after the attempts ran out")
+	}
+}
+
+func TestUserService_ParallelGuessesCapped(t *testing.T) {
+	ctx := context.Background()
+	svc, _, email, _ := newTestUserService(t)
+
+	_, err := svc.ResetPasswordBegin(ctx, models.ResetUserPasswordBeginRequest{DeviceID: 1001, Username: "cashier", Channel: models.SendSecurityCodeToEmail})
+	if err != nil {
+		t.Fatalf("ResetPasswordBegin() error = %v", err)
+	}
+	wrong := "000000"
+	if email.codes["cashier@example.com"] == wrong {
+		wrong = "111111"
+	}
+
+	const guesses = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	compared := 0
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			confirm := models.ResetUserPasswordConfirmRequest{DeviceID: 1001, Username: "cashier", NewPassword: "new-password", SecurityCode: wrong}
+			var apiErr *models.APIError
+			if _, err := svc.ResetPasswordConfirm(ctx, confirm, ""); errors.As(err, &apiErr) && apiErr.ErrorCode == models.ErrCodeUSER04 {
+				mu.Lock()
+				compared++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if compared > securityCodeMaxAttempts {
+		t.Errorf("%d parallel guesses were compared, want at most %d", compared, securityCodeMaxAttempts)
+	}
+}
+
+func TestUserService_ContactChange(t *testing.T) {
+	ctx := context.Background()
+	svc, users, email, store := newTestUserService(t)
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	newEmail := "till1@example.com"
+	_, err = svc.ContactChangeBegin(ctx, models.SendSecurityCodeContactChangeRequest{DeviceID: 1001, UserEmail: &newEmail, Token: login.Token})
+	if err != nil {
+		t.Fatalf("ContactChangeBegin() error = %v", err)
+	}
+	code := email.codes[newEmail]
+	if len(code) != 6 {
+		t.Fatalf("code sent to the new address = %q, want 6 digits", code)
+	}
+	if user, _ := users.GetByUsername(ctx, "cashier"); user.Email != "cashier@example.com" {
+		t.Errorf("email changed to %q before confirmation", user.Email)
+	}
+
+	// A code for an email change cannot confirm a phone change
+	confirm := models.ConfirmUserContactChangeRequest{DeviceID: 1001, Channel: models.SendSecurityCodeToPhoneNumber, SecurityCode: code, Token: login.Token}
+	var apiErr *models.APIError
+	if _, err := svc.ContactChangeConfirm(ctx, confirm, "127.0.0.1"); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeUSER05 {
+		t.Errorf("ContactChangeConfirm() for the phone error = %v, want %s", err, models.ErrCodeUSER05)
+	}
+
+	confirm.Channel = models.SendSecurityCodeToEmail
+	resp, err := svc.ContactChangeConfirm(ctx, confirm, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("ContactChangeConfirm() error = %v", err)
+	}
+	if resp.User.Email != newEmail {
+		t.Errorf("response email = %q, want %q", resp.User.Email, newEmail)
+	}
+	if user, _ := users.GetByUsername(ctx, "cashier"); user.Email != newEmail {
+		t.Errorf("stored email = %q, want %q", user.Email, newEmail)
+	}
+	// The notice to the old address goes through the outbox
+	outbox := NewNotificationOutbox(memory.NewNotificationRepository(store), NewNotifier(email, nil, nil, zap.NewNop()), zap.NewNop())
+	if err := outbox.DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if len(email.changed) != 1 || email.changed[0] != "cashier@example.com" {
+		t.Errorf("change notices sent to %v, want the old address", email.changed)
+	}
+
+	if _, err := svc.ContactChangeBegin(ctx, models.SendSecurityCodeContactChangeRequest{DeviceID: 1001, UserEmail: &newEmail, Token: "not-a-token"}); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV12 {
+		t.Errorf("ContactChangeBegin() with an invalid token error = %v, want %s", err, models.ErrCodeDEV12)
+	}
+}
+
+func TestUserService_CreateUserSendsCode(t *testing.T) {
+	ctx := context.Background()
+	svc, users, email, _ := newTestUserService(t)
+
+	begin := models.CreateUserBeginRequest{DeviceID: 1001, Username: "manager", PersonName: "Tendai", PersonSurname: "Moyo",
+		UserRole: "Manager", UserEmail: "manager@example.com", Channel: models.SendSecurityCodeToEmail, Language: models.LanguageNdebele}
+	owner := models.Operator{Username: "owner", Role: models.UserRoleOwner}
+	if _, err := svc.CreateUserBegin(ctx, owner, begin); err != nil {
+		t.Fatalf("CreateUserBegin() error = %v", err)
+	}
+	code := email.codes["manager@example.com"]
+	if len(code) != 6 {
+		t.Fatalf("sent code = %q, want 6 digits", code)
+	}
+	if to := email.recipients["manager@example.com"]; to.Language != "nd" || to.Taxpayer.Name != "Test Retail" {
+		t.Errorf("code sent to %+v, want the user's language and taxpayer", to)
+	}
+
+	resp, err := svc.CreateUserConfirm(ctx, models.CreateUserConfirmRequest{DeviceID: 1001, Username: "manager", SecurityCode: code, Password: "manager-password"}, "")
+	if err != nil {
+		t.Fatalf("CreateUserConfirm() error = %v", err)
+	}
+	if resp.User.Status != models.UserStatusActive || resp.User.Email != "manager@example.com" {
+		t.Errorf("created user status %v email %q, want active with the begin email", resp.User.Status, resp.User.Email)
+	}
+
+	// Without a valid contact for the channel no user is created
+	begin.Username, begin.Channel = "clerk", models.SendSecurityCodeToPhoneNumber
+	var apiErr *models.APIError
+	if _, err := svc.CreateUserBegin(ctx, owner, begin); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV14 {
+		t.Errorf("CreateUserBegin() without a phone number error = %v, want %s", err, models.ErrCodeDEV14)
+	}
+	if user, _ := users.GetByUsername(ctx, "clerk"); user != nil {
+		t.Error("user was created without a way to send the code")
+	}
+}
+
+func TestUserService_AuthenticateOperator(t *testing.T) {
+	svc, _, _, store := newTestUserService(t)
+	ctx := context.Background()
+	admin := memory.NewAdminRepository(store)
+
+	other := &models.Taxpayer{TIN: "2000000002", Name: "Other Wholesale", Status: "Active"}
+	if err := admin.CreateTaxpayer(ctx, other); err != nil {
+		t.Fatalf("CreateTaxpayer() error = %v", err)
+	}
+	if err := admin.CreateDevice(ctx, &models.Device{DeviceID: 2001, TaxpayerID: other.ID, Status: "Active"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	var apiErr *models.APIError
+	if _, err := svc.Login(ctx, models.LoginRequest{DeviceID: 2001, Username: "cashier", Password: "old-password"}, ""); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeUSER02 {
+		t.Errorf("Login() on another taxpayer's device error = %v, want %s", err, models.ErrCodeUSER02)
+	}
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	user, _, err := svc.AuthenticateOperator(ctx, 1001, login.Token)
+	if err != nil || user.Username != "cashier" {
+		t.Fatalf("AuthenticateOperator() = %v, %v, want cashier", user, err)
+	}
+	if _, _, err := svc.AuthenticateOperator(ctx, 2001, login.Token); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV12 {
+		t.Errorf("AuthenticateOperator() on another taxpayer's device error = %v, want %s", err, models.ErrCodeDEV12)
+	}
+}
+
+func TestUserService_SessionBoundToDevice(t *testing.T) {
+	svc, _, _, store := newTestUserService(t)
+	ctx := context.Background()
+
+	// A second till of the same taxpayer
+	if err := memory.NewAdminRepository(store).CreateDevice(ctx, &models.Device{DeviceID: 1002, TaxpayerID: 1, Status: "Active"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	var apiErr *models.APIError
+	if _, _, err := svc.AuthenticateOperator(ctx, 1002, login.Token); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV12 {
+		t.Errorf("AuthenticateOperator() on another device error = %v, want %s", err, models.ErrCodeDEV12)
+	}
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1002, RefreshToken: login.RefreshToken}, ""); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV12 {
+		t.Errorf("RefreshSession() on another device error = %v, want %s", err, models.ErrCodeDEV12)
+	}
+
+	// The refused refresh does not use up the token on its own device
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: login.RefreshToken}, ""); err != nil {
+		t.Errorf("RefreshSession() on the login device error = %v", err)
+	}
+}
+
+func TestUserService_UpdateUserRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator models.Operator
+		username string
+		role     models.UserRole
+		status   models.UserStatus
+		wantCode string
+	}{
+		{"Manager blocks cashier", models.Operator{UserID: 100, Role: models.UserRoleManager}, "cashier", models.UserRoleCashier, models.UserStatusBlocked, ""},
+		{"Manager cannot promote to owner", models.Operator{UserID: 100, Role: models.UserRoleManager}, "cashier", models.UserRoleOwner, models.UserStatusActive, models.ErrCodeUSER10},
+		{"Owner promotes to manager", models.Operator{UserID: 100, Role: models.UserRoleOwner}, "cashier", models.UserRoleManager, models.UserStatusActive, ""},
+		{"Cashier cannot change own role", models.Operator{UserID: 1, Role: models.UserRoleCashier}, "cashier", models.UserRoleManager, models.UserStatusActive, models.ErrCodeUSER10},
+		{"Other taxpayer's user is not found", models.Operator{UserID: 100, Role: models.UserRoleOwner}, "outsider", models.UserRoleCashier, models.UserStatusBlocked, models.ErrCodeUSER01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, users, _, store := newTestUserService(t)
+			ctx := context.Background()
+
+			other := &models.Taxpayer{TIN: "2000000002", Name: "Other Wholesale", Status: "Active"}
+			if err := memory.NewAdminRepository(store).CreateTaxpayer(ctx, other); err != nil {
+				t.Fatalf("CreateTaxpayer() error = %v", err)
+			}
+			if err := users.Create(ctx, &models.User{TaxpayerID: other.ID, Username: "outsider", UserRole: models.UserRoleCashier}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			_, err := svc.UpdateUser(ctx, tt.operator, models.UpdateUserRequest{DeviceID: 1001, Username: tt.username,
+				PersonName: "Tendai", PersonSurname: "Moyo", UserRole: tt.role, UserStatus: tt.status})
+			var apiErr *models.APIError
+			if tt.wantCode != "" {
+				if !errors.As(err, &apiErr) || apiErr.ErrorCode != tt.wantCode {
+					t.Fatalf("UpdateUser() error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateUser() error = %v", err)
+			}
+
+			user, _ := users.GetByUsername(ctx, tt.username)
+			if user.UserRole != tt.role || user.Status != tt.status {
+				t.Errorf("user role %s status %v, want %s %v", user.UserRole, user.Status, tt.role, tt.status)
+			}
+			_, logs, _ := memory.NewAdminRepository(store).ListAuditLogs(ctx, "user", &user.ID, 0, 10)
+			if len(logs) != 1 || logs[0].Action != "update" || logs[0].UserID == nil || *logs[0].UserID != tt.operator.UserID {
+				t.Errorf("audit logs = %+v, want one update by user %d", logs, tt.operator.UserID)
+			}
+		})
+	}
+}
+
+func TestUserService_Sessions(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _, _ := newTestUserService(t)
+	var apiErr *models.APIError
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if login.RefreshToken == "" || !login.ExpiresAt.Before(login.RefreshExpiresAt) {
+		t.Fatalf("Login() tokens = %+v, want an access token outlived by its refresh token", login.SessionTokens)
+	}
+
+	refreshed, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: login.RefreshToken}, "10.0.0.2")
+	if err != nil {
+		t.Fatalf("RefreshSession() error = %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	_, session, err := svc.AuthenticateOperator(ctx, 1001, refreshed.Token)
+	if err != nil {
+		t.Fatalf("AuthenticateOperator() with refreshed token error = %v", err)
+	}
+	operator := models.Operator{UserID: session.UserID, SessionID: session.ID, Username: "cashier", Role: models.UserRoleCashier}
+
+	list, err := svc.ListSessions(ctx, operator, 1001, "")
+	if err != nil || len(list.Sessions) != 1 || !list.Sessions[0].Current || list.Sessions[0].IPAddress != "10.0.0.2" {
+		t.Fatalf("ListSessions() = %+v, %v, want the current session", list, err)
+	}
+	if _, err := svc.ListSessions(ctx, models.Operator{UserID: 100, Role: models.UserRoleAccountant}, 1001, "cashier"); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeUSER10 {
+		t.Errorf("ListSessions() of another user by an accountant error = %v, want %s", err, models.ErrCodeUSER10)
+	}
+
+	// Presenting the replaced refresh token again ends the session
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: login.RefreshToken}, "10.6.6.6"); !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeDEV12 {
+		t.Fatalf("RefreshSession() with a replaced token error = %v, want %s", err, models.ErrCodeDEV12)
+	}
+	if _, err := svc.ValidateJWT(ctx, refreshed.Token); err == nil {
+		t.Error("access token is still valid after refresh token reuse")
+	}
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: refreshed.RefreshToken}, "10.0.0.2"); err == nil {
+		t.Error("refresh token is still valid after refresh token reuse")
+	}
+
+	// Logout ends only the current session unless all are asked for
+	first, _ := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	second, _ := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	_, session, _ = svc.AuthenticateOperator(ctx, 1001, first.Token)
+	operator.SessionID = session.ID
+	if resp, err := svc.Logout(ctx, operator, models.LogoutRequest{DeviceID: 1001}); err != nil || resp.Revoked != 1 {
+		t.Fatalf("Logout() = %+v, %v, want 1 revoked", resp, err)
+	}
+	if _, err := svc.ValidateJWT(ctx, first.Token); err == nil {
+		t.Error("access token is still valid after logout")
+	}
+	if _, err := svc.ValidateJWT(ctx, second.Token); err != nil {
+		t.Errorf("other session ended by logout: %v", err)
+	}
+
+	// Changing the password ends every session
+	user, _ := svc.ValidateJWT(ctx, second.Token)
+	if _, err := svc.ChangePassword(ctx, models.Operator{UserID: user.ID, Username: user.Username}, models.ChangePasswordRequest{OldPassword: "old-password", NewPassword: "new-password"}); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if _, err := svc.ValidateJWT(ctx, second.Token); err == nil {
+		t.Error("access token is still valid after a password change")
+	}
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: second.RefreshToken}, ""); err == nil {
+		t.Error("refresh token is still valid after a password change")
+	}
+}
+
+func TestUserService_BlockRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _, _ := newTestUserService(t)
+
+	login, err := svc.Login(ctx, models.LoginRequest{DeviceID: 1001, Username: "cashier", Password: "old-password"}, "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	owner := models.Operator{UserID: 100, Username: "owner", Role: models.UserRoleOwner}
+	_, err = svc.UpdateUser(ctx, owner, models.UpdateUserRequest{DeviceID: 1001, Username: "cashier",
+		PersonName: "Tendai", PersonSurname: "Moyo", UserRole: models.UserRoleCashier, UserStatus: models.UserStatusBlocked})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+
+	if _, err := svc.ValidateJWT(ctx, login.Token); err == nil {
+		t.Error("access token of a blocked user is still valid")
+	}
+
+	// Unblocking does not bring the sessions back
+	_, err = svc.UpdateUser(ctx, owner, models.UpdateUserRequest{DeviceID: 1001, Username: "cashier",
+		PersonName: "Tendai", PersonSurname: "Moyo", UserRole: models.UserRoleCashier, UserStatus: models.UserStatusActive})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if _, err := svc.RefreshSession(ctx, models.RefreshTokenRequest{DeviceID: 1001, RefreshToken: login.RefreshToken}, ""); err == nil {
+		t.Error("refresh token of a blocked user is still valid after unblocking")
+	}
+}
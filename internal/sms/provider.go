@@ -0,0 +1,166 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// SMSProvider sends text messages through one SMS gateway. to is in E.164
+// form; Send returns the gateway's message ID. Send must give up when ctx is
+// done so that SMSService can fail over to the next provider.
+type SMSProvider interface {
+	Name() string
+	Send(ctx context.Context, to, message string) (string, error)
+}
+
+// HTTPProvider sends messages to a JSON HTTP gateway, authenticating with a
+// bearer API key
+type HTTPProvider struct {
+	name     string
+	apiURL   string
+	apiKey   string
+	senderID string
+	client   *http.Client
+}
+
+// HTTPProviderConfig configures an HTTPProvider
+type HTTPProviderConfig struct {
+	Name     string
+	APIURL   string
+	APIKey   string
+	SenderID string
+}
+
+type SMSRequest struct {
+	To      string `json:"to"`
+	From    string `json:"from"`
+	Message string `json:"message"`
+}
+
+type SMSResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	ID      string `json:"id"`
+}
+
+func NewHTTPProvider(cfg HTTPProviderConfig) *HTTPProvider {
+	return &HTTPProvider{
+		name:     cfg.Name,
+		apiURL:   cfg.APIURL,
+		apiKey:   cfg.APIKey,
+		senderID: cfg.SenderID,
+		client:   &http.Client{},
+	}
+}
+
+// Name returns the configured name of the gateway
+func (p *HTTPProvider) Name() string { return p.name }
+
+// Send posts the message to the gateway
+func (p *HTTPProvider) Send(ctx context.Context, to, message string) (string, error) {
+	jsonData, err := json.Marshal(SMSRequest{
+		To:      to,
+		From:    p.senderID,
+		Message: message,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("gateway returned %s", resp.Status)
+	}
+
+	var smsResp SMSResponse
+	if err := json.Unmarshal(body, &smsResp); err != nil {
+		return "", fmt.Errorf("invalid gateway response: %w", err)
+	}
+	if !smsResp.Success {
+		return "", fmt.Errorf("gateway rejected message: %s", smsResp.Message)
+	}
+	return smsResp.ID, nil
+}
+
+// StubMessage is a message accepted by a StubProvider
+type StubMessage struct {
+	To      string
+	Message string
+}
+
+// StubProvider logs messages instead of sending them and keeps them for
+// inspection. It is used in development, when no gateway is configured, and
+// in tests, where SetError makes it fail.
+type StubProvider struct {
+	name   string
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	err  error
+	sent []StubMessage
+}
+
+func NewStubProvider(name string, logger *zap.Logger) *StubProvider {
+	return &StubProvider{name: name, logger: logger}
+}
+
+// Name returns the name of the stub
+func (p *StubProvider) Name() string { return p.name }
+
+// Send records and logs the message, or returns the error set by SetError
+func (p *StubProvider) Send(ctx context.Context, to, message string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return "", p.err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	p.sent = append(p.sent, StubMessage{To: to, Message: message})
+	p.logger.Info("STUB SMS",
+		zap.String("provider", p.name),
+		zap.String("to", to),
+		zap.String("message", message),
+	)
+	return fmt.Sprintf("%s-%d", p.name, len(p.sent)), nil
+}
+
+// SetError makes further sends fail with err, or succeed again when err is nil
+func (p *StubProvider) SetError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Sent returns the messages accepted so far
+func (p *StubProvider) Sent() []StubMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]StubMessage(nil), p.sent...)
+}
@@ -1,51 +1,104 @@
 package sms
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
+	"fiscalization-api/internal/utils"
+
 	"go.uber.org/zap"
 )
 
+const (
+	// defaultProviderTimeout bounds one attempt to send through a provider
+	defaultProviderTimeout = 10 * time.Second
+	// unhealthyAfter consecutive failures take a provider out of rotation
+	// for providerCooldown
+	unhealthyAfter   = 3
+	providerCooldown = 5 * time.Minute
+	// defaultCountryCode is used for numbers in national form
+	defaultCountryCode = "263"
+)
+
+// SMSService sends messages through its providers in priority order. A
+// message that fails or times out with one provider is sent through the
+// next. Providers that keep failing are skipped until their cooldown ends,
+// unless no healthy provider is left.
 type SMSService struct {
-	apiURL    string
-	apiKey    string
-	senderID  string
-	client    *http.Client
-	logger    *zap.Logger
+	providers   []*providerState
+	countryCode string
+	logger      *zap.Logger
+	now         func() time.Time
+
+	mu sync.Mutex
 }
 
+// SMSConfig configures an SMSService. Providers are in priority order;
+// DefaultCountryCode, e.g. "263", is given to numbers in national form.
 type SMSConfig struct {
-	APIURL   string
-	APIKey   string
-	SenderID string
+	Providers          []ProviderConfig
+	DefaultCountryCode string
+}
+
+// ProviderConfig is a provider and how long one attempt through it may take
+type ProviderConfig struct {
+	Provider SMSProvider
+	Timeout  time.Duration
 }
 
-type SMSRequest struct {
-	To       string `json:"to"`
-	From     string `json:"from"`
-	Message  string `json:"message"`
+// ProviderHealth is the delivery record of a provider
+type ProviderHealth struct {
+	Name                string    `json:"name"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastSuccessAt       time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       time.Time `json:"lastFailureAt,omitempty"`
 }
 
-type SMSResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	ID      string `json:"id"`
+type providerState struct {
+	provider  SMSProvider
+	timeout   time.Duration
+	health    ProviderHealth
+	downUntil time.Time
 }
 
 func NewSMSService(cfg SMSConfig, logger *zap.Logger) *SMSService {
-	return &SMSService{
-		apiURL:   cfg.APIURL,
-		apiKey:   cfg.APIKey,
-		senderID: cfg.SenderID,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		logger: logger,
+	s := &SMSService{
+		countryCode: cfg.DefaultCountryCode,
+		logger:      logger,
+		now:         time.Now,
+	}
+	if s.countryCode == "" {
+		s.countryCode = defaultCountryCode
+	}
+	for _, pc := range cfg.Providers {
+		timeout := pc.Timeout
+		if timeout <= 0 {
+			timeout = defaultProviderTimeout
+		}
+		s.providers = append(s.providers, &providerState{
+			provider: pc.Provider,
+			timeout:  timeout,
+			health:   ProviderHealth{Name: pc.Provider.Name(), Healthy: true},
+		})
 	}
+	return s
+}
+
+// Health returns the delivery record of each provider in priority order
+func (s *SMSService) Health() []ProviderHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := make([]ProviderHealth, len(s.providers))
+	for i, p := range s.providers {
+		health[i] = p.health
+	}
+	return health
 }
 
 // SendSecurityCode sends a security code via SMS
@@ -66,109 +119,115 @@ func (s *SMSService) SendWelcomeMessage(to, username string) error {
 	return s.sendSMS(to, message)
 }
 
+// SendContactChangedAlert tells a user at their old phone number that their
+// phone number was changed
+func (s *SMSService) SendContactChangedAlert(to, username string) error {
+	message := fmt.Sprintf("ZIMRA Alert: The phone number of user %s was changed. If you did not make this change, contact your administrator.", username)
+	return s.sendSMS(to, message)
+}
+
 // SendFiscalDayAlert sends fiscal day closing alert
 func (s *SMSService) SendFiscalDayAlert(to, deviceName string, hoursLeft int) error {
 	message := fmt.Sprintf("ZIMRA Alert: Fiscal day for %s closes in %d hour(s).", deviceName, hoursLeft)
 	return s.sendSMS(to, message)
 }
 
-// sendSMS sends an SMS using the configured SMS gateway
-func (s *SMSService) sendSMS(to, message string) error {
-	// Prepare request
-	smsReq := SMSRequest{
-		To:      to,
-		From:    s.senderID,
-		Message: message,
-	}
+// SendFiscalDayAutoClosedAlert sends an alert that the server closed a fiscal day
+func (s *SMSService) SendFiscalDayAutoClosedAlert(to, deviceName string, fiscalDayNo int) error {
+	message := fmt.Sprintf("ZIMRA Alert: Fiscal day %d for %s exceeded its maximum length and was closed automatically.", fiscalDayNo, deviceName)
+	return s.sendSMS(to, message)
+}
 
-	jsonData, err := json.Marshal(smsReq)
-	if err != nil {
-		s.logger.Error("Failed to marshal SMS request", zap.Error(err))
-		return err
+// sendSMS normalises the number and sends the message through the first
+// provider that accepts it
+func (s *SMSService) sendSMS(to, message string) error {
+	number, ok := utils.NormalizePhoneNumber(to, s.countryCode)
+	if !ok {
+		return fmt.Errorf("invalid phone number %q", to)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", s.apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		s.logger.Error("Failed to create HTTP request", zap.Error(err))
-		return err
+	providers := s.candidates()
+	if len(providers) == 0 {
+		return errors.New("no SMS provider configured")
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	// Send request
-	resp, err := s.client.Do(req)
-	if err != nil {
-		s.logger.Error("Failed to send SMS request",
-			zap.String("to", to),
+	var errs []error
+	for _, p := range providers {
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		id, err := p.provider.Send(ctx, number, message)
+		cancel()
+
+		if err == nil {
+			s.recordSuccess(p)
+			s.logger.Info("SMS sent successfully",
+				zap.String("provider", p.provider.Name()),
+				zap.String("to", number),
+				zap.String("id", id),
+			)
+			return nil
+		}
+
+		s.recordFailure(p, err)
+		s.logger.Warn("SMS provider failed",
+			zap.String("provider", p.provider.Name()),
+			zap.String("to", number),
 			zap.Error(err),
 		)
-		return err
+		errs = append(errs, fmt.Errorf("%s: %w", p.provider.Name(), err))
 	}
-	defer resp.Body.Close()
-
-	// Parse response
-	var smsResp SMSResponse
-	if err := json.NewDecoder(resp.Body).Decode(&smsResp); err != nil {
-		s.logger.Error("Failed to decode SMS response", zap.Error(err))
-		return err
-	}
-
-	if !smsResp.Success {
-		s.logger.Error("SMS sending failed",
-			zap.String("to", to),
-			zap.String("error", smsResp.Message),
-		)
-		return fmt.Errorf("SMS sending failed: %s", smsResp.Message)
-	}
-
-	s.logger.Info("SMS sent successfully",
-		zap.String("to", to),
-		zap.String("id", smsResp.ID),
-	)
-
-	return nil
-}
 
-// MockSMSService for testing/development
-type MockSMSService struct {
-	logger *zap.Logger
+	return fmt.Errorf("SMS sending failed: %w", errors.Join(errs...))
 }
 
-func NewMockSMSService(logger *zap.Logger) *MockSMSService {
-	return &MockSMSService{logger: logger}
-}
+// candidates returns the healthy providers in priority order, or all of
+// them when none is healthy
+func (s *SMSService) candidates() []*providerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *MockSMSService) SendSecurityCode(to, code string) error {
-	s.logger.Info("MOCK SMS: Security Code",
-		zap.String("to", to),
-		zap.String("code", code),
-	)
-	return nil
-}
-
-func (s *MockSMSService) SendPasswordReset(to, code string) error {
-	s.logger.Info("MOCK SMS: Password Reset",
-		zap.String("to", to),
-		zap.String("code", code),
-	)
-	return nil
+	now := s.now()
+	var healthy []*providerState
+	for _, p := range s.providers {
+		if !now.Before(p.downUntil) {
+			healthy = append(healthy, p)
+		}
+	}
+	if len(healthy) == 0 {
+		return s.providers
+	}
+	return healthy
 }
 
-func (s *MockSMSService) SendWelcomeMessage(to, username string) error {
-	s.logger.Info("MOCK SMS: Welcome",
-		zap.String("to", to),
-		zap.String("username", username),
-	)
-	return nil
-}
+func (s *SMSService) recordSuccess(p *providerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *MockSMSService) SendFiscalDayAlert(to, deviceName string, hoursLeft int) error {
-	s.logger.Info("MOCK SMS: Fiscal Day Alert",
-		zap.String("to", to),
-		zap.String("device", deviceName),
-		zap.Int("hoursLeft", hoursLeft),
-	)
-	return nil
+	if !p.health.Healthy {
+		s.logger.Info("SMS provider recovered", zap.String("provider", p.health.Name))
+	}
+	p.health.Healthy = true
+	p.health.ConsecutiveFailures = 0
+	p.health.LastSuccessAt = s.now()
+	p.downUntil = time.Time{}
+}
+
+func (s *SMSService) recordFailure(p *providerState, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	p.health.ConsecutiveFailures++
+	p.health.LastError = err.Error()
+	p.health.LastFailureAt = now
+	if p.health.ConsecutiveFailures >= unhealthyAfter {
+		if p.health.Healthy {
+			s.logger.Error("SMS provider marked unhealthy",
+				zap.String("provider", p.health.Name),
+				zap.Int("failures", p.health.ConsecutiveFailures),
+				zap.Duration("cooldown", providerCooldown),
+			)
+		}
+		p.health.Healthy = false
+		p.downUntil = now.Add(providerCooldown)
+	}
 }
@@ -0,0 +1,96 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// slowProvider blocks until the attempt times out
+type slowProvider struct{}
+
+func (slowProvider) Name() string { return "slow" }
+
+func (slowProvider) Send(ctx context.Context, to, message string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestSMSService_FailsOver(t *testing.T) {
+	primary := NewStubProvider("primary", zap.NewNop())
+	backup := NewStubProvider("backup", zap.NewNop())
+	primary.SetError(errors.New("gateway down"))
+
+	svc := NewSMSService(SMSConfig{Providers: []ProviderConfig{
+		{Provider: slowProvider{}, Timeout: 10 * time.Millisecond},
+		{Provider: primary},
+		{Provider: backup},
+	}}, zap.NewNop())
+
+	if err := svc.SendSecurityCode("077 123 4567", "123456"); err != nil {
+		t.Fatalf("SendSecurityCode() error = %v", err)
+	}
+
+	sent := backup.Sent()
+	if len(sent) != 1 || sent[0].To != "+263771234567" {
+		t.Fatalf("backup sent %+v, want one message to +263771234567", sent)
+	}
+
+	health := svc.Health()
+	if health[0].ConsecutiveFailures != 1 || health[1].ConsecutiveFailures != 1 || health[2].LastSuccessAt.IsZero() {
+		t.Errorf("Health() = %+v", health)
+	}
+}
+
+func TestSMSService_SkipsUnhealthyProvider(t *testing.T) {
+	primary := NewStubProvider("primary", zap.NewNop())
+	backup := NewStubProvider("backup", zap.NewNop())
+	primary.SetError(errors.New("gateway down"))
+
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	svc := NewSMSService(SMSConfig{Providers: []ProviderConfig{{Provider: primary}, {Provider: backup}}}, zap.NewNop())
+	svc.now = func() time.Time { return now }
+
+	for i := 0; i < unhealthyAfter; i++ {
+		if err := svc.SendWelcomeMessage("+263771234567", "cashier"); err != nil {
+			t.Fatalf("SendWelcomeMessage() error = %v", err)
+		}
+	}
+	if svc.Health()[0].Healthy {
+		t.Fatal("primary should be unhealthy")
+	}
+
+	// Skipped during the cooldown even once it works again
+	primary.SetError(nil)
+	if err := svc.SendWelcomeMessage("+263771234567", "cashier"); err != nil {
+		t.Fatalf("SendWelcomeMessage() error = %v", err)
+	}
+	if len(primary.Sent()) != 0 {
+		t.Fatal("unhealthy primary was used during its cooldown")
+	}
+
+	now = now.Add(providerCooldown)
+	if err := svc.SendWelcomeMessage("+263771234567", "cashier"); err != nil {
+		t.Fatalf("SendWelcomeMessage() error = %v", err)
+	}
+	if len(primary.Sent()) != 1 || !svc.Health()[0].Healthy {
+		t.Errorf("primary should be used and healthy after its cooldown, health = %+v", svc.Health()[0])
+	}
+}
+
+func TestSMSService_Errors(t *testing.T) {
+	stub := NewStubProvider("stub", zap.NewNop())
+	svc := NewSMSService(SMSConfig{Providers: []ProviderConfig{{Provider: stub}}}, zap.NewNop())
+
+	if err := svc.SendSecurityCode("123", "123456"); err == nil {
+		t.Error("SendSecurityCode() to an invalid number should fail")
+	}
+
+	stub.SetError(errors.New("gateway down"))
+	if err := svc.SendSecurityCode("0771234567", "123456"); err == nil {
+		t.Error("SendSecurityCode() should fail when every provider fails")
+	}
+}
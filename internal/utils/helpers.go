@@ -217,6 +217,38 @@ func ValidatePhoneNumber(phone string) bool {
 	return digitCount >= 10 && digitCount <= 15
 }
 
+// NormalizePhoneNumber returns phone in E.164 form, e.g. +263771234567.
+// Numbers in national form (a leading 0) get countryCode, e.g. "263";
+// numbers starting with 00 or without a prefix are taken to include their
+// country code already.
+func NormalizePhoneNumber(phone, countryCode string) (string, bool) {
+	if !ValidatePhoneNumber(phone) {
+		return "", false
+	}
+
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+
+	switch {
+	case international:
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = strings.TrimLeft(countryCode, "+") + digits[1:]
+	}
+
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", false
+	}
+	return "+" + digits, true
+}
+
 // Contains checks if slice contains element
 func Contains[T comparable](slice []T, element T) bool {
 	for _, item := range slice {
@@ -243,6 +243,33 @@ func TestValidatePhoneNumber(t *testing.T) {
 	}
 }
 
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		phone  string
+		want   string
+		wantOK bool
+	}{
+		{"+263771234567", "+263771234567", true},
+		{"0771234567", "+263771234567", true},
+		{"077-123-4567", "+263771234567", true},
+		{"00263 77 123 4567", "+263771234567", true},
+		{"263771234567", "+263771234567", true},
+		{"+27 82 123 4567", "+27821234567", true},
+		{"0000000000", "", false},
+		{"123", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			got, ok := NormalizePhoneNumber(tt.phone, "263")
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NormalizePhoneNumber(%q) = %q, %v, want %q, %v", tt.phone, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
 func TestContains(t *testing.T) {
 	slice := []string{"apple", "banana", "orange"}
 
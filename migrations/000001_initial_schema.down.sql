@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS certificates_history;
+DROP TABLE IF EXISTS stock;
+DROP TABLE IF EXISTS file_uploads;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS taxes;
+DROP TABLE IF EXISTS receipt_payments;
+DROP TABLE IF EXISTS receipt_taxes;
+DROP TABLE IF EXISTS receipt_lines;
+DROP TABLE IF EXISTS receipts;
+DROP TABLE IF EXISTS fiscal_counters;
+DROP TABLE IF EXISTS fiscal_days;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS taxpayers;
+DROP FUNCTION IF EXISTS update_updated_at_column();
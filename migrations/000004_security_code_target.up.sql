@@ -0,0 +1,3 @@
+-- The contact a security code was sent to, for codes confirming a new email
+-- address or phone number
+ALTER TABLE security_codes ADD COLUMN target VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- The free-form roles replaced by the up migration cannot be restored
+SELECT 1;
@@ -0,0 +1,2 @@
+UPDATE fiscal_days SET reconciliation_mode = closure + 2 WHERE closure IS NOT NULL;
+ALTER TABLE fiscal_days DROP COLUMN closure;
@@ -0,0 +1,5 @@
+-- Why the server closed a day without its device: 0 auto-closed after
+-- exceeding TaxPayerDayMaxHrs, 1 forced by an administrator. Such days are
+-- reconciled manually, which is the mode their signature covers.
+ALTER TABLE fiscal_days ADD COLUMN closure INTEGER;
+UPDATE fiscal_days SET closure = reconciliation_mode - 2, reconciliation_mode = 1 WHERE reconciliation_mode IN (2, 3);
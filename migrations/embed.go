@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations so the server can apply them
+// without external tools. Files are named {version}_{name}.up.sql and
+// {version}_{name}.down.sql; the PostgreSQL migrations are at the top level
+// and the SQLite versions in sqlite/.
+package migrations
+
+import "embed"
+
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
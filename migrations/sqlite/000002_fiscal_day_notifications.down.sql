@@ -0,0 +1 @@
+DROP TABLE IF EXISTS fiscal_day_notifications;
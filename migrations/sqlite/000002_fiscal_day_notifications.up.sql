@@ -0,0 +1,11 @@
+-- Create fiscal_day_notifications table
+-- One row per reminder sent, so each fiscal day is notified at most once per threshold
+CREATE TABLE IF NOT EXISTS fiscal_day_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fiscal_day_id INTEGER NOT NULL REFERENCES fiscal_days(id) ON DELETE CASCADE,
+    threshold_hrs INTEGER NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(fiscal_day_id, threshold_hrs)
+);
+
+CREATE INDEX idx_fiscal_day_notifications_fiscal_day_id ON fiscal_day_notifications(fiscal_day_id);
@@ -0,0 +1 @@
+ALTER TABLE security_codes DROP COLUMN target;
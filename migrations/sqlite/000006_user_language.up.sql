@@ -0,0 +1,2 @@
+-- The language notifications are sent to a user in: en, sn or nd
+ALTER TABLE users ADD COLUMN language VARCHAR(5) NOT NULL DEFAULT 'en';
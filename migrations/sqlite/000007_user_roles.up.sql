@@ -0,0 +1,9 @@
+-- User roles were free-form; map them onto Owner, Manager, Cashier and
+-- Accountant. Administrators become owners and anything unrecognised the
+-- least privileged role that can still sell.
+UPDATE users SET user_role = CASE
+    WHEN lower(user_role) IN ('owner', 'admin', 'administrator') THEN 'Owner'
+    WHEN lower(user_role) = 'manager' THEN 'Manager'
+    WHEN lower(user_role) = 'accountant' THEN 'Accountant'
+    ELSE 'Cashier'
+END;
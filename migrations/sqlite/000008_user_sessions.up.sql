@@ -0,0 +1,22 @@
+-- Create user_sessions table
+-- A session is started by a login and kept alive by rotating its refresh
+-- token; only hashes of the current and the previous refresh token are
+-- stored, so a replayed previous token can be detected. Access tokens name
+-- their session and stop working once it is revoked.
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id INTEGER NOT NULL,
+    refresh_token_hash VARCHAR(64) NOT NULL,
+    previous_token_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoke_reason VARCHAR(30) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX idx_user_sessions_refresh_token_hash ON user_sessions(refresh_token_hash);
+CREATE INDEX idx_user_sessions_previous_token_hash ON user_sessions(previous_token_hash);
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
@@ -1,15 +1,45 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"fiscalization-api/internal/models"
+	"fiscalization-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
+// OperationIDContextKey is the gin context key of the operation ID sent with
+// error responses
+const OperationIDContextKey = "operationID"
+
+func init() {
+	// Report validation errors by JSON field name rather than Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
 // SuccessResponse sends a successful JSON response
 func SuccessResponse(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, data)
@@ -25,24 +55,80 @@ func NoContentResponse(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// ErrorResponse sends an error response
+// StatusClientClosedRequest is the non-standard status used when the client
+// disconnects before the response is written
+const StatusClientClosedRequest = 499
+
+// ErrorResponse sends an error response. Errors other than *models.APIError
+// are logged and sent as a 500 without their message.
 func ErrorResponse(c *gin.Context, err error) {
+	if ctxErr := cancellationError(c, err); ctxErr != nil {
+		err = ctxErr
+	}
+
 	if apiErr, ok := err.(*models.APIError); ok {
 		if apiErr.Status >= 500 {
-			log.Printf("[ERROR] %s %s → %d %s | %v", c.Request.Method, c.Request.URL.Path, apiErr.Status, apiErr.Title, apiErr)
+			log.Printf("[ERROR] %s %s → %d %s | %s | %v", c.Request.Method, c.Request.URL.Path, apiErr.Status, apiErr.Title, OperationID(c), apiErr)
 		}
-		c.JSON(apiErr.Status, apiErr)
+		ProblemResponse(c, apiErr)
 		return
 	}
 
 	// Unexpected error - log the full thing
-	log.Printf("[ERROR] %s %s → 500 | %v", c.Request.Method, c.Request.URL.Path, err)
-	c.JSON(http.StatusInternalServerError, models.APIError{
-		Type:   "about:blank",
-		Title:  "Internal server error",
-		Status: http.StatusInternalServerError,
-	})
+	log.Printf("[ERROR] %s %s → 500 | %s | %v", c.Request.Method, c.Request.URL.Path, OperationID(c), err)
+	ProblemResponse(c, models.NewAPIError(http.StatusInternalServerError, "Internal server error", ""))
+}
+
+// ProblemResponse sends apiErr as application/problem+json with the
+// operation ID of the request. apiErr itself is not modified.
+func ProblemResponse(c *gin.Context, apiErr *models.APIError) {
+	problem := *apiErr
+	if problem.Type == "" {
+		problem.Type = models.ProblemType(problem.ErrorCode)
+	}
+	if problem.OperationID == "" {
+		problem.OperationID = OperationID(c)
+	}
+
+	c.Header("Content-Type", models.ProblemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// OperationID returns the operation ID of the request, generating it on
+// first use
+func OperationID(c *gin.Context) string {
+	if id := c.GetString(OperationIDContextKey); id != "" {
+		return id
+	}
+	id := utils.GenerateOperationID()
+	c.Set(OperationIDContextKey, id)
+	return id
 }
+
+// cancellationError maps errors caused by a cancelled or expired request
+// context to API errors. The request context is checked as well because
+// drivers do not always wrap the context error they abort with.
+func cancellationError(c *gin.Context, err error) *models.APIError {
+	if _, ok := err.(*models.APIError); ok {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+	case errors.Is(err, context.Canceled):
+	default:
+		err = c.Request.Context().Err()
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return models.NewAPIError(http.StatusGatewayTimeout, "Request timed out", models.ErrCodeREQ01)
+	case errors.Is(err, context.Canceled):
+		return models.NewAPIError(StatusClientClosedRequest, "Client closed request", models.ErrCodeREQ02)
+	}
+	return nil
+}
+
 // func ErrorResponse(c *gin.Context, err error) {
 // 	if apiErr, ok := err.(*models.APIError); ok {
 // 		c.JSON(apiErr.Status, apiErr)
@@ -59,57 +145,32 @@ func ErrorResponse(c *gin.Context, err error) {
 
 // ValidationErrorResponse sends a 400 bad request response
 func ValidationErrorResponse(c *gin.Context, message string) {
-	c.JSON(http.StatusBadRequest, models.APIError{
-		Type:   "about:blank",
-		Title:  message,
-		Status: http.StatusBadRequest,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusBadRequest, message, models.ErrCodeREQ03))
 }
 
 // UnauthorizedResponse sends a 401 unauthorized response
 func UnauthorizedResponse(c *gin.Context, message string) {
-	c.JSON(http.StatusUnauthorized, models.APIError{
-		Type:   "about:blank",
-		Title:  message,
-		Status: http.StatusUnauthorized,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusUnauthorized, message, ""))
 }
 
 // ForbiddenResponse sends a 403 forbidden response
 func ForbiddenResponse(c *gin.Context, message string) {
-	c.JSON(http.StatusForbidden, models.APIError{
-		Type:   "about:blank",
-		Title:  message,
-		Status: http.StatusForbidden,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusForbidden, message, ""))
 }
 
 // NotFoundResponse sends a 404 not found response
 func NotFoundResponse(c *gin.Context, message string) {
-	c.JSON(http.StatusNotFound, models.APIError{
-		Type:   "about:blank",
-		Title:  message,
-		Status: http.StatusNotFound,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusNotFound, message, ""))
 }
 
 // ConflictResponse sends a 409 conflict response
 func ConflictResponse(c *gin.Context, message string) {
-	c.JSON(http.StatusConflict, models.APIError{
-		Type:   "about:blank",
-		Title:  message,
-		Status: http.StatusConflict,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusConflict, message, ""))
 }
 
 // UnprocessableEntityResponse sends a 422 unprocessable entity response
 func UnprocessableEntityResponse(c *gin.Context, message, errorCode string) {
-	c.JSON(http.StatusUnprocessableEntity, models.APIError{
-		Type:      "about:blank",
-		Title:     message,
-		Status:    http.StatusUnprocessableEntity,
-		ErrorCode: errorCode,
-	})
+	ProblemResponse(c, models.NewAPIError(http.StatusUnprocessableEntity, message, errorCode))
 }
 
 // GetDeviceIDFromContext gets device ID from gin context
@@ -122,6 +183,31 @@ func GetDeviceIDFromContext(c *gin.Context) (int, bool) {
 	return 0, false
 }
 
+// GetDeviceIDFromPath gets the {deviceID} segment of an FDMS path. ok is
+// false when the route has no such segment; err is set when it is not a
+// positive integer.
+func GetDeviceIDFromPath(c *gin.Context) (deviceID int, ok bool, err error) {
+	raw := c.Param("deviceID")
+	if raw == "" {
+		return 0, false, nil
+	}
+	deviceID, err = strconv.Atoi(raw)
+	if err != nil || deviceID <= 0 {
+		return 0, true, errors.New("invalid device ID in path")
+	}
+	return deviceID, true, nil
+}
+
+// GetDeviceModel returns the DeviceModelName and DeviceModelVersion headers.
+// DeviceModelVersionNo is accepted for clients of the /api/v1 routes.
+func GetDeviceModel(c *gin.Context) (name, version string) {
+	version = c.GetHeader("DeviceModelVersion")
+	if version == "" {
+		version = c.GetHeader("DeviceModelVersionNo")
+	}
+	return c.GetHeader("DeviceModelName"), version
+}
+
 // GetUserIDFromContext gets user ID from gin context
 func GetUserIDFromContext(c *gin.Context) (int64, bool) {
 	if userID, exists := c.Get("userID"); exists {
@@ -132,6 +218,16 @@ func GetUserIDFromContext(c *gin.Context) (int64, bool) {
 	return 0, false
 }
 
+// GetOperatorFromContext gets the operator authenticated on the device
+func GetOperatorFromContext(c *gin.Context) (models.Operator, bool) {
+	if operator, exists := c.Get("operator"); exists {
+		if op, ok := operator.(models.Operator); ok {
+			return op, true
+		}
+	}
+	return models.Operator{}, false
+}
+
 // ValidateHeaders validates required headers
 func ValidateHeaders(c *gin.Context, required ...string) bool {
 	for _, header := range required {
@@ -146,12 +242,97 @@ func ValidateHeaders(c *gin.Context, required ...string) bool {
 // BindJSON binds JSON and handles errors
 func BindJSON(c *gin.Context, obj interface{}) bool {
 	if err := c.ShouldBindJSON(obj); err != nil {
-		ValidationErrorResponse(c, "Invalid request body: "+err.Error())
+		ProblemResponse(c, RequestBodyError(err))
+		return false
+	}
+	return true
+}
+
+// BindDeviceJSON binds a device request like BindJSON, except that deviceID
+// is set before validation from the client certificate or, on public FDMS
+// routes, from the path. FDMS clients leave deviceID out of the body; other
+// clients may send it but cannot act for another device. An empty body is
+// accepted for requests with no other required fields.
+func BindDeviceJSON(c *gin.Context, obj interface{}, deviceID *int) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil && !errors.Is(err, io.EOF) {
+		ProblemResponse(c, RequestBodyError(err))
+		return false
+	}
+
+	if id, ok := GetDeviceIDFromContext(c); ok {
+		*deviceID = id
+	} else if id, ok, err := GetDeviceIDFromPath(c); err != nil {
+		ValidationErrorResponse(c, err.Error())
+		return false
+	} else if ok {
+		*deviceID = id
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		ProblemResponse(c, RequestBodyError(err))
 		return false
 	}
 	return true
 }
 
+// RequestBodyError converts an error from decoding or validating a request
+// body into a 400 problem listing the fields at fault
+func RequestBodyError(err error) *models.APIError {
+	apiErr := models.NewAPIError(http.StatusBadRequest, "Invalid request body", models.ErrCodeREQ03)
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			apiErr.WithFieldErrors(models.FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		apiErr.WithFieldErrors(models.FieldError{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be of type %s, got %s", typeErr.Type, typeErr.Value),
+		})
+	default:
+		apiErr.WithDetail(err.Error())
+	}
+	return apiErr
+}
+
+// fieldPath returns the JSON path of a failed field without the name of the
+// request struct, e.g. receipt.receiptLines[0].receiptLinePrice
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "email":
+		return "must be a valid email address"
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("failed the %s=%s check", fe.Tag(), fe.Param())
+	}
+	return fmt.Sprintf("failed the %s check", fe.Tag())
+}
+
 // PaginationParams represents pagination parameters
 type PaginationParams struct {
 	Offset int
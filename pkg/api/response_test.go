@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fiscalization-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testLine struct {
+	Name  string  `json:"receiptLineName" binding:"required"`
+	Price float64 `json:"receiptLinePrice" binding:"gt=0"`
+}
+
+type testRequest struct {
+	DeviceID int        `json:"deviceID" binding:"required"`
+	Lines    []testLine `json:"receiptLines" binding:"required,dive"`
+}
+
+func bindProblem(t *testing.T, body string) (*httptest.ResponseRecorder, models.APIError) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var req testRequest
+	if BindJSON(c, &req) {
+		t.Fatalf("BindJSON(%s) succeeded, want a problem response", body)
+	}
+
+	var problem models.APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	return w, problem
+}
+
+func TestBindJSON_FieldErrors(t *testing.T) {
+	w, problem := bindProblem(t, `{"receiptLines":[{"receiptLineName":"Bread","receiptLinePrice":1},{"receiptLinePrice":-1}]}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != models.ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, models.ProblemContentType)
+	}
+	if problem.Status != http.StatusBadRequest || problem.ErrorCode != models.ErrCodeREQ03 {
+		t.Errorf("problem = %d %s, want 400 %s", problem.Status, problem.ErrorCode, models.ErrCodeREQ03)
+	}
+	if problem.Type != models.ProblemType(models.ErrCodeREQ03) || problem.OperationID == "" {
+		t.Errorf("problem type = %q, operationID = %q", problem.Type, problem.OperationID)
+	}
+
+	want := map[string]string{
+		"deviceID":                         "is required",
+		"receiptLines[1].receiptLineName":  "is required",
+		"receiptLines[1].receiptLinePrice": "must be greater than 0",
+	}
+	if len(problem.Errors) != len(want) {
+		t.Fatalf("errors = %+v, want %d", problem.Errors, len(want))
+	}
+	for _, fe := range problem.Errors {
+		if want[fe.Field] != fe.Message {
+			t.Errorf("%s: %q, want %q", fe.Field, fe.Message, want[fe.Field])
+		}
+	}
+}
+
+func TestBindJSON_TypeError(t *testing.T) {
+	_, problem := bindProblem(t, `{"deviceID":"1001"}`)
+
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "deviceID" {
+		t.Errorf("errors = %+v, want one for deviceID", problem.Errors)
+	}
+}
+
+func TestErrorResponse_HidesUnexpectedErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ErrorResponse(c, errDatabase)
+
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), errDatabase.Error()) {
+		t.Errorf("response = %d %s, want a 500 without the error message", w.Code, w.Body.String())
+	}
+	if c.GetString(OperationIDContextKey) == "" {
+		t.Error("operation ID was not kept in the context for the request log")
+	}
+}
+
+var errDatabase = errors.New("pq: password authentication failed")
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"crypto"
+	"fmt"
+	"time"
+)
+
+// Chain keeps what a device must track locally between receipts: the
+// receipt counter of the open fiscal day, the global receipt number, the
+// hash of the previous receipt and the fiscal counters. Numbers only advance
+// on Commit, once the server has stored a receipt, so a receipt that fails
+// to submit can be sealed and sent again. A Chain is not safe for concurrent
+// use.
+type Chain struct {
+	deviceID        int
+	fiscalDayNo     int
+	fiscalDayOpened time.Time
+	counter         int
+	globalNo        int
+	previousHash    []byte
+	counters        *Counters
+}
+
+// NewChain starts a chain for deviceID after the receipt with global number
+// lastGlobalNo, as reported by GetFiscalDayStatus
+func NewChain(deviceID, lastGlobalNo int) *Chain {
+	return &Chain{deviceID: deviceID, globalNo: lastGlobalNo, counters: NewCounters()}
+}
+
+// OpenDay starts the receipts of a newly opened fiscal day. opened is the
+// FiscalDayOpened date the server recorded, which the day signature covers.
+func (c *Chain) OpenDay(fiscalDayNo int, opened time.Time) {
+	c.fiscalDayNo = fiscalDayNo
+	c.fiscalDayOpened = opened
+	c.counter = 0
+	c.previousHash = nil
+	c.counters = NewCounters()
+}
+
+// FiscalDayNo returns the number of the open fiscal day
+func (c *Chain) FiscalDayNo() int { return c.fiscalDayNo }
+
+// ReceiptCounter returns the counter of the last committed receipt of the day
+func (c *Chain) ReceiptCounter() int { return c.counter }
+
+// GlobalNo returns the global number of the last committed receipt
+func (c *Chain) GlobalNo() int { return c.globalNo }
+
+// SetGlobalNo overrides the global number of the last committed receipt
+func (c *Chain) SetGlobalNo(globalNo int) { c.globalNo = globalNo }
+
+// Next numbers receipt as the next one in the chain and dates it
+func (c *Chain) Next(receipt *Receipt, date time.Time) {
+	receipt.DeviceID = c.deviceID
+	receipt.ReceiptCounter = c.counter + 1
+	receipt.ReceiptGlobalNo = c.globalNo + 1
+	receipt.ReceiptDate = date
+}
+
+// Seal hashes receipt onto the chain and signs it with the device key. The
+// receipt must be numbered and complete; any later change invalidates it.
+func (c *Chain) Seal(receipt *Receipt, key crypto.Signer) error {
+	hash, err := ReceiptHash(receipt, c.previousHash)
+	if err != nil {
+		return err
+	}
+	signature, err := Sign(key, hash)
+	if err != nil {
+		return err
+	}
+	receipt.ReceiptDeviceSignature = signature
+	return nil
+}
+
+// Commit advances the chain past a receipt the server stored and adds it to
+// the fiscal counters
+func (c *Chain) Commit(receipt *Receipt) {
+	c.counter = receipt.ReceiptCounter
+	c.globalNo = receipt.ReceiptGlobalNo
+	c.previousHash = receipt.ReceiptDeviceSignature.Hash
+	c.counters.Add(receipt)
+}
+
+// Counters returns the fiscal counters of the receipts committed today
+func (c *Chain) Counters() []FiscalDayCounter { return c.counters.List() }
+
+// SignDay returns the fiscal counters of the day and the device signature
+// over them, as sent by Client.CloseDay
+func (c *Chain) SignDay(key crypto.Signer) ([]FiscalDayCounter, SignatureData, error) {
+	if c.fiscalDayNo == 0 {
+		return nil, SignatureData{}, fmt.Errorf("no fiscal day is open")
+	}
+
+	counters := c.counters.List()
+	hash, err := FiscalDayHash(c.deviceID, c.fiscalDayNo, c.fiscalDayOpened, counters)
+	if err != nil {
+		return nil, SignatureData{}, err
+	}
+	signature, err := Sign(key, hash)
+	if err != nil {
+		return nil, SignatureData{}, err
+	}
+	return counters, signature, nil
+}
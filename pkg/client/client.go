@@ -0,0 +1,227 @@
+// Package client is a Go client for the device API of the fiscalization
+// server. Client wraps every device endpoint in a typed method; Chain keeps
+// the receipt counters, hash chain and fiscal counters a device must track
+// locally; and the helpers in this package build certificate requests,
+// canonical hashes and signatures with any crypto.Signer, and verify the
+// signatures the server returns.
+//
+// A typical device registers once, then opens a day, submits receipts and
+// closes the day:
+//
+//	c, _ := client.New("https://fdms.example.com", 1001, client.WithDeviceModel("MyPOS", "1.0"))
+//	csr, _ := client.NewCertificateRequest(key, "SN-001", 1001)
+//	reg, _ := c.Register(ctx, "ACTIVATE", csr)
+//	_ = c.SetCertificate(reg.Certificate, key)
+//
+//	day, _ := c.OpenDay(ctx)
+//	chain := client.NewChain(1001, lastGlobalNo)
+//	chain.OpenDay(day.FiscalDayNo, opened)
+//	chain.Next(receipt, time.Now())
+//	_ = chain.Seal(receipt, key)
+//	resp, err := c.SubmitReceipt(ctx, receipt)
+//	if err == nil {
+//		chain.Commit(receipt)
+//	}
+//
+//	counters, signature, _ := chain.SignDay(key)
+//	_, err = c.CloseDay(ctx, counters, signature)
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the device API on behalf of one device. Requests after
+// SetCertificate authenticate with the device certificate. A Client is safe
+// for concurrent use once it is configured.
+type Client struct {
+	baseURL      string
+	deviceID     int
+	httpClient   *http.Client
+	tlsConfig    *tls.Config
+	modelName    string
+	modelVersion string
+	certPEM      string
+
+	mu            sync.RWMutex
+	operatorToken string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient uses hc instead of a client with a 30 second timeout. Its
+// transport must be an *http.Transport for certificates to be presented
+// over https.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTLSConfig sets the TLS configuration used to reach the server, for
+// example to trust a private CA
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) { c.tlsConfig = cfg }
+}
+
+// WithDeviceModel sets the DeviceModelName and DeviceModelVersion headers
+// sent with every request
+func WithDeviceModel(name, version string) Option {
+	return func(c *Client) {
+		c.modelName = name
+		c.modelVersion = version
+	}
+}
+
+// New creates a client for deviceID talking to the server at baseURL, e.g.
+// https://fdms.example.com
+func New(baseURL string, deviceID int, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		deviceID: deviceID,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = c.tlsConfig
+		c.httpClient = &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	}
+	return c, nil
+}
+
+// DeviceID returns the device the client acts for
+func (c *Client) DeviceID() int { return c.deviceID }
+
+// Certificate returns the PEM device certificate set by SetCertificate
+func (c *Client) Certificate() string { return c.certPEM }
+
+// SetCertificate authenticates further requests with the device certificate
+// and the key it was issued for. Over https the certificate is presented in
+// the TLS handshake; over plain http it is sent in the X-SSL-Client-Cert
+// header, the way a TLS-terminating proxy forwards it.
+func (c *Client) SetCertificate(certPEM string, key crypto.Signer) error {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("invalid certificate PEM")
+	}
+
+	if c.isHTTPS() {
+		transport, ok := c.httpClient.Transport.(*http.Transport)
+		if c.httpClient.Transport == nil {
+			transport, ok = http.DefaultTransport.(*http.Transport), true
+		}
+		if !ok {
+			return fmt.Errorf("client certificates require an *http.Transport, got %T", c.httpClient.Transport)
+		}
+
+		transport = transport.Clone()
+		tlsConfig := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			tlsConfig = transport.TLSClientConfig.Clone()
+		}
+		tlsConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{block.Bytes}, PrivateKey: key}}
+		transport.TLSClientConfig = tlsConfig
+
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+	}
+
+	c.certPEM = certPEM
+	return nil
+}
+
+// SetOperatorToken signs further requests as the user token was issued to,
+// as returned by Login. Fiscal day, receipt, stock, report and user
+// management requests require the token of a user of the device's taxpayer
+// whose role allows the operation. An empty token signs the operator out.
+func (c *Client) SetOperatorToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operatorToken = token
+}
+
+func (c *Client) isHTTPS() bool {
+	return strings.HasPrefix(c.baseURL, "https://")
+}
+
+// do sends a JSON request and decodes the response into out. Error responses
+// are returned as *APIError.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.modelName != "" {
+		req.Header.Set("DeviceModelName", c.modelName)
+		req.Header.Set("DeviceModelVersion", c.modelVersion)
+	}
+	if c.certPEM != "" && !c.isHTTPS() {
+		req.Header.Set("X-SSL-Client-Cert", url.PathEscape(c.certPEM))
+	}
+	c.mu.RLock()
+	if c.operatorToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.operatorToken)
+	}
+	c.mu.RUnlock()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{}
+		if err := json.Unmarshal(payload, apiErr); err != nil || apiErr.Status == 0 {
+			apiErr.Status = resp.StatusCode
+			apiErr.Title = strings.TrimSpace(string(payload))
+			if apiErr.Title == "" {
+				apiErr.Title = http.StatusText(resp.StatusCode)
+			}
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(payload, out)
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"fiscalization-api/internal/models"
+)
+
+func testReceipt(receiptType ReceiptType, moneyType MoneyType, total float64) *Receipt {
+	percent := 15.0
+	return &Receipt{
+		ReceiptType:     receiptType,
+		ReceiptCurrency: "USD",
+		ReceiptTotal:    total,
+		ReceiptTaxes: []ReceiptTax{
+			{TaxID: 3, TaxPercent: &percent, SalesAmountWithTax: total, TaxAmount: total * 0.15 / 1.15},
+		},
+		ReceiptPayments: []Payment{{MoneyTypeCode: moneyType, PaymentAmount: total}},
+	}
+}
+
+func TestCounters(t *testing.T) {
+	counters := NewCounters()
+	counters.Add(testReceipt(ReceiptTypeFiscalInvoice, models.MoneyTypeCash, 23))
+	counters.Add(testReceipt(ReceiptTypeFiscalInvoice, models.MoneyTypeCard, 23))
+	counters.Add(testReceipt(ReceiptTypeCreditNote, models.MoneyTypeCash, -23))
+
+	want := map[FiscalCounterType]float64{
+		models.FiscalCounterTypeSaleByTax:       46,
+		models.FiscalCounterTypeSaleTaxByTax:    6,
+		models.FiscalCounterTypeCreditNoteByTax: -23,
+	}
+	balances := map[int]float64{int(models.MoneyTypeCash): 0, int(models.MoneyTypeCard): 23}
+
+	got := counters.List()
+	if len(got) != len(want)+len(balances) {
+		t.Fatalf("List() returned %d counters, want %d", len(got), len(want)+len(balances))
+	}
+	for _, counter := range got {
+		counterType := FiscalCounterType(counter.FiscalCounterType)
+		if counterType == models.FiscalCounterTypeBalanceByMoneyType {
+			if counter.FiscalCounterValue != balances[*counter.FiscalCounterMoneyType] {
+				t.Errorf("balance of money type %d = %v, want %v", *counter.FiscalCounterMoneyType, counter.FiscalCounterValue, balances[*counter.FiscalCounterMoneyType])
+			}
+			continue
+		}
+		if counter.FiscalCounterValue != want[counterType] {
+			t.Errorf("%s = %v, want %v", counterType, counter.FiscalCounterValue, want[counterType])
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	chain := NewChain(1001, 41)
+	opened := time.Now().Add(-time.Hour)
+	chain.OpenDay(7, opened)
+
+	first := testReceipt(ReceiptTypeFiscalInvoice, models.MoneyTypeCash, 23)
+	chain.Next(first, time.Now())
+	if err := chain.Seal(first, key); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if first.ReceiptCounter != 1 || first.ReceiptGlobalNo != 42 {
+		t.Errorf("first receipt numbered %d/%d, want 1/42", first.ReceiptCounter, first.ReceiptGlobalNo)
+	}
+
+	// Sealing again before commit reuses the same numbers
+	chain.Next(first, first.ReceiptDate)
+	if first.ReceiptGlobalNo != 42 {
+		t.Errorf("uncommitted receipt renumbered to %d", first.ReceiptGlobalNo)
+	}
+	chain.Commit(first)
+
+	second := testReceipt(ReceiptTypeFiscalInvoice, models.MoneyTypeCard, 11.5)
+	chain.Next(second, time.Now())
+	if err := chain.Seal(second, key); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	wantHash, err := ReceiptHash(second, first.ReceiptDeviceSignature.Hash)
+	if err != nil {
+		t.Fatalf("ReceiptHash() error = %v", err)
+	}
+	signature := second.ReceiptDeviceSignature
+	if string(signature.Hash) != string(wantHash) {
+		t.Error("second receipt is not chained to the first")
+	}
+	if !ecdsa.VerifyASN1(&key.PublicKey, signature.Hash, signature.Signature) {
+		t.Error("receipt signature does not verify")
+	}
+	chain.Commit(second)
+
+	counters, daySignature, err := chain.SignDay(key)
+	if err != nil {
+		t.Fatalf("SignDay() error = %v", err)
+	}
+	dayHash, err := FiscalDayHash(1001, 7, opened, counters)
+	if err != nil {
+		t.Fatalf("FiscalDayHash() error = %v", err)
+	}
+	if string(daySignature.Hash) != string(dayHash) || !ecdsa.VerifyASN1(&key.PublicKey, dayHash, daySignature.Signature) {
+		t.Error("fiscal day signature does not verify")
+	}
+}
+
+func TestVerifyReceiptSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "FDMS Server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+
+	// Sign the way the server does
+	deviceSignature := []byte("device signature")
+	serverDate := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	data := fmt.Sprintf("%s%d%s", base64.StdEncoding.EncodeToString(deviceSignature), 42, serverDate.Format("2006-01-02T15:04:05"))
+	hash := sha256.Sum256([]byte(data))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("SignASN1() error = %v", err)
+	}
+	resp := &SubmitReceiptResponse{ReceiptID: 42, ServerDate: serverDate}
+	resp.ReceiptServerSignature.Signature = signature
+
+	if err := VerifyReceiptSignature(cert, deviceSignature, resp); err != nil {
+		t.Errorf("VerifyReceiptSignature() error = %v", err)
+	}
+
+	tampered := *resp
+	tampered.ReceiptID = 43
+	if err := VerifyReceiptSignature(cert, deviceSignature, &tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyReceiptSignature() with another receipt ID error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestClient_SendsCredentialsAndDecodesErrors(t *testing.T) {
+	const certPEM = "-----BEGIN CERTIFICATE-----\nMAA=\n-----END CERTIFICATE-----\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, _ := url.PathUnescape(r.Header.Get("X-SSL-Client-Cert"))
+		if cert != certPEM || r.Header.Get("DeviceModelName") != "TestPOS" || r.Header.Get("Authorization") != "Bearer operator-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(models.NewAPIError(http.StatusUnprocessableEntity, "Fiscal day is already open", models.ErrCodeFISC01))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, 1001, WithDeviceModel("TestPOS", "1.0"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err := c.SetCertificate(certPEM, key); err != nil {
+		t.Fatalf("SetCertificate() error = %v", err)
+	}
+	c.SetOperatorToken("operator-token")
+
+	_, err = c.OpenDay(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != models.ErrCodeFISC01 {
+		t.Errorf("OpenDay() error = %v, want APIError %s", err, models.ErrCodeFISC01)
+	}
+}
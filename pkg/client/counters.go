@@ -0,0 +1,110 @@
+package client
+
+import (
+	"math"
+	"sort"
+
+	"fiscalization-api/internal/models"
+)
+
+// counterKey identifies one fiscal counter. Counters by tax use taxID,
+// percent and exempt; balances by money type use moneyType.
+type counterKey struct {
+	counterType models.FiscalCounterType
+	currency    string
+	taxID       int
+	percent     float64
+	exempt      bool
+	moneyType   int
+}
+
+// Counters accumulates the fiscal counters of a day the same way the server
+// computes them when the day is closed
+type Counters struct {
+	values map[counterKey]float64
+}
+
+// NewCounters returns empty fiscal day counters
+func NewCounters() *Counters {
+	return &Counters{values: make(map[counterKey]float64)}
+}
+
+// Add adds a receipt the server accepted to the counters
+func (c *Counters) Add(receipt *Receipt) {
+	for _, tax := range receipt.ReceiptTaxes {
+		k := counterKey{currency: receipt.ReceiptCurrency, taxID: tax.TaxID, exempt: tax.TaxPercent == nil}
+		if tax.TaxPercent != nil {
+			k.percent = *tax.TaxPercent
+		}
+
+		switch receipt.ReceiptType {
+		case models.ReceiptTypeFiscalInvoice:
+			k.counterType = models.FiscalCounterTypeSaleByTax
+			c.values[k] += tax.SalesAmountWithTax
+			k.counterType = models.FiscalCounterTypeSaleTaxByTax
+			c.values[k] += tax.TaxAmount
+		case models.ReceiptTypeCreditNote:
+			k.counterType = models.FiscalCounterTypeCreditNoteByTax
+			c.values[k] += tax.SalesAmountWithTax
+		case models.ReceiptTypeDebitNote:
+			k.counterType = models.FiscalCounterTypeDebitNoteByTax
+			c.values[k] += tax.SalesAmountWithTax
+		}
+	}
+
+	for _, payment := range receipt.ReceiptPayments {
+		k := counterKey{
+			counterType: models.FiscalCounterTypeBalanceByMoneyType,
+			currency:    receipt.ReceiptCurrency,
+			moneyType:   int(payment.MoneyTypeCode),
+		}
+		c.values[k] += payment.PaymentAmount
+	}
+}
+
+// List returns the counters in the order the server reports them
+func (c *Counters) List() []FiscalDayCounter {
+	keys := make([]counterKey, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.counterType != b.counterType {
+			return a.counterType < b.counterType
+		}
+		if a.currency != b.currency {
+			return a.currency < b.currency
+		}
+		if a.taxID != b.taxID {
+			return a.taxID < b.taxID
+		}
+		return a.moneyType < b.moneyType
+	})
+
+	counters := make([]FiscalDayCounter, 0, len(keys))
+	for _, k := range keys {
+		counter := FiscalDayCounter{
+			FiscalCounterType:     int(k.counterType),
+			FiscalCounterCurrency: k.currency,
+			FiscalCounterValue:    round2(c.values[k]),
+		}
+		if k.counterType == models.FiscalCounterTypeBalanceByMoneyType {
+			moneyType := k.moneyType
+			counter.FiscalCounterMoneyType = &moneyType
+		} else {
+			taxID := k.taxID
+			counter.FiscalCounterTaxID = &taxID
+			if !k.exempt {
+				percent := k.percent
+				counter.FiscalCounterTaxPercent = &percent
+			}
+		}
+		counters = append(counters, counter)
+	}
+	return counters
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
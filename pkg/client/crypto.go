@@ -0,0 +1,141 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"fiscalization-api/internal/utils"
+)
+
+// ErrInvalidSignature is returned when a server signature does not verify
+var ErrInvalidSignature = errors.New("invalid server signature")
+
+// NewCertificateRequest builds the PEM certificate request sent on
+// registration. The subject CN is ZIMRA-{serialNo}-{deviceID} with the
+// device ID zero-padded to 10 digits. key must be an ECDSA or RSA key.
+func NewCertificateRequest(key crypto.Signer, serialNo string, deviceID int) (string, error) {
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   fmt.Sprintf("ZIMRA-%s-%010d", serialNo, deviceID),
+			Country:      []string{"ZW"},
+			Organization: []string{"Zimbabwe Revenue Authority"},
+		},
+	}
+	switch key.Public().(type) {
+	case *ecdsa.PublicKey:
+		template.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case *rsa.PublicKey:
+		template.SignatureAlgorithm = x509.SHA256WithRSA
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key.Public())
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
+
+// ReceiptHash returns the canonical SHA-256 hash of a receipt chained to the
+// hash of the previous receipt of the day (nil for the first one)
+func ReceiptHash(receipt *Receipt, previousHash []byte) ([]byte, error) {
+	return utils.GenerateReceiptHash(receipt, previousHash)
+}
+
+// FiscalDayHash returns the canonical SHA-256 hash of the fiscal day
+// counters the device signs when closing the day
+func FiscalDayHash(deviceID, fiscalDayNo int, fiscalDayOpened time.Time, counters []FiscalDayCounter) ([]byte, error) {
+	return utils.GenerateFiscalDayHash(deviceID, fiscalDayNo, fiscalDayOpened.Format("2006-01-02"), counters)
+}
+
+// Sign signs a SHA-256 hash with key: ECDSA keys produce an ASN.1 signature
+// and RSA keys a PKCS #1 v1.5 one. key may live in an HSM or secure element.
+func Sign(key crypto.Signer, hash []byte) (SignatureData, error) {
+	signature, err := key.Sign(rand.Reader, hash, crypto.SHA256)
+	if err != nil {
+		return SignatureData{}, fmt.Errorf("failed to sign: %w", err)
+	}
+	return SignatureData{Hash: hash, Signature: signature}, nil
+}
+
+// ParseCertificate parses the first PEM certificate in certPEM, such as the
+// first entry returned by GetServerCertificate
+func ParseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// VerifyReceiptSignature checks the server signature of a submitted receipt.
+// The server signs the SHA-256 of the base64 device signature, the receipt
+// ID and the server date, with the certificate identified by the thumbprint.
+func VerifyReceiptSignature(serverCert *x509.Certificate, deviceSignature []byte, resp *SubmitReceiptResponse) error {
+	data := fmt.Sprintf("%s%d%s",
+		base64.StdEncoding.EncodeToString(deviceSignature),
+		resp.ReceiptID,
+		resp.ServerDate.Format("2006-01-02T15:04:05"),
+	)
+	return verifyServerSignature(serverCert, []byte(data), resp.ReceiptServerSignature)
+}
+
+// VerifyFiscalDaySignature checks the server signature returned when a
+// fiscal day is closed
+func VerifyFiscalDaySignature(serverCert *x509.Certificate, signature SignatureDataEx) error {
+	return verifyServerSignature(serverCert, signature.Hash, signature)
+}
+
+func verifyServerSignature(serverCert *x509.Certificate, data []byte, signature SignatureDataEx) error {
+	if len(signature.CertificateThumbprint) > 0 {
+		thumbprint := sha1.Sum(serverCert.Raw)
+		if !bytes.Equal(signature.CertificateThumbprint, thumbprint[:]) {
+			return fmt.Errorf("%w: signed with a different certificate", ErrInvalidSignature)
+		}
+	}
+
+	hash := sha256.Sum256(data)
+	switch key := serverCert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature.Signature); err != nil {
+			return ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature.Signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unsupported server key type %T", serverCert.PublicKey)
+	}
+	return nil
+}
+
+// QRCodeData returns the text encoded in a receipt's QR code, built from the
+// qrUrl in the device configuration
+func QRCodeData(receipt *Receipt, qrURL string) string {
+	return utils.GenerateQRCodeData(receipt, qrURL)
+}
+
+// QRCodePNG renders QR code text as a PNG image of size by size pixels
+func QRCodePNG(data string, size int) ([]byte, error) {
+	return utils.GenerateQRCodePNG(data, size)
+}
+
+// FormatVerificationCode formats the receipt verification code printed
+// under the QR code
+func FormatVerificationCode(qrData string) string {
+	return utils.FormatQRCodeForDisplay(qrData)
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"fiscalization-api/internal/models"
+)
+
+// VerifyTaxpayer checks the activation key before registration and returns
+// the taxpayer and branch the device belongs to
+func (c *Client) VerifyTaxpayer(ctx context.Context, activationKey, serialNo string) (*VerifyTaxpayerResponse, error) {
+	req := models.VerifyTaxpayerRequest{
+		DeviceID:       c.deviceID,
+		ActivationKey:  activationKey,
+		DeviceSerialNo: serialNo,
+	}
+
+	var resp VerifyTaxpayerResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/verify-taxpayer", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Register sends the PEM certificate request built by NewCertificateRequest
+// and returns the issued certificate. Pass it to SetCertificate to use it.
+func (c *Client) Register(ctx context.Context, activationKey, csrPEM string) (*DeviceRegistrationResponse, error) {
+	req := models.DeviceRegistrationRequest{
+		DeviceID:           c.deviceID,
+		ActivationKey:      activationKey,
+		CertificateRequest: csrPEM,
+	}
+
+	var resp DeviceRegistrationResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/register", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IssueCertificate renews the device certificate
+func (c *Client) IssueCertificate(ctx context.Context, csrPEM string) (*IssueCertificateResponse, error) {
+	req := models.IssueCertificateRequest{DeviceID: c.deviceID, CertificateRequest: csrPEM}
+
+	var resp IssueCertificateResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/issue-certificate", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetConfig returns the taxpayer configuration and applicable taxes
+func (c *Client) GetConfig(ctx context.Context) (*GetConfigResponse, error) {
+	var resp GetConfigResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/device/config", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetStatus returns the status of the device's current fiscal day
+func (c *Client) GetStatus(ctx context.Context) (*GetStatusResponse, error) {
+	var resp GetStatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/device/status", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Ping reports the device as online and returns how often it should ping
+func (c *Client) Ping(ctx context.Context) (*PingResponse, error) {
+	var resp PingResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/ping", models.PingRequest{DeviceID: c.deviceID}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetServerCertificate returns the certificate chain the server signs with.
+// A non-nil thumbprint asks for that certificate specifically.
+func (c *Client) GetServerCertificate(ctx context.Context, thumbprint []byte) (*GetServerCertificateResponse, error) {
+	path := "/api/v1/server/certificate"
+	if thumbprint != nil {
+		path += "?thumbprint=" + hex.EncodeToString(thumbprint)
+	}
+
+	var resp GetServerCertificateResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// OpenDay opens the next fiscal day. Like most device operations it
+// requires an operator token, see SetOperatorToken.
+func (c *Client) OpenDay(ctx context.Context) (*OpenFiscalDayResponse, error) {
+	var resp OpenFiscalDayResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/fiscal-day/open", models.OpenFiscalDayRequest{DeviceID: c.deviceID}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CloseDay closes the open fiscal day. counters are the device's own fiscal
+// counters and signature is the device signature over FiscalDayHash, both
+// as returned by Chain.SignDay.
+func (c *Client) CloseDay(ctx context.Context, counters []FiscalDayCounter, signature SignatureData) (*CloseFiscalDayResponse, error) {
+	req := models.CloseFiscalDayRequest{
+		DeviceID:                 c.deviceID,
+		FiscalDayDeviceSignature: &signature,
+		FiscalDayCounters:        counters,
+	}
+
+	var resp CloseFiscalDayResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/fiscal-day/close", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetFiscalDayStatus returns the status of the current fiscal day and the
+// global number of the last receipt
+func (c *Client) GetFiscalDayStatus(ctx context.Context) (*GetStatusResponse, error) {
+	var resp GetStatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/fiscal-day/status", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetFiscalDay returns a fiscal day of the device by number
+func (c *Client) GetFiscalDay(ctx context.Context, fiscalDayNo int) (*GetFiscalDayResponse, error) {
+	var resp GetFiscalDayResponse
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/fiscal-day/%d", fiscalDayNo), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitReceipt submits a receipt sealed by Chain.Seal. Receipts the server
+// stores are returned with their ID and server signature even when they have
+// validation errors; check ValidationColor.
+func (c *Client) SubmitReceipt(ctx context.Context, receipt *Receipt) (*SubmitReceiptResponse, error) {
+	req := models.SubmitReceiptRequest{DeviceID: c.deviceID, Receipt: *receipt}
+
+	var resp SubmitReceiptResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/receipt/submit", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetReport returns the X report of the open fiscal day, or the Z report of
+// a closed one when fiscalDayNo is set
+func (c *Client) GetReport(ctx context.Context, z bool, fiscalDayNo *int) (*FiscalDayReport, error) {
+	path := "/api/v1/reports/x"
+	if z {
+		path = "/api/v1/reports/z"
+	}
+	if fiscalDayNo != nil {
+		path += "?fiscalDayNo=" + strconv.Itoa(*fiscalDayNo)
+	}
+
+	var resp FiscalDayReport
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StockQuery filters and pages the stock list. Zero values are left out.
+type StockQuery struct {
+	HSCode   string
+	GoodName string
+	Operator string
+	Sort     string
+	Order    string
+	Offset   int
+	Limit    int
+}
+
+// GetStockList returns the taxpayer's stock
+func (c *Client) GetStockList(ctx context.Context, query StockQuery) (*GetStockListResponse, error) {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"hsCode":   query.HSCode,
+		"goodName": query.GoodName,
+		"operator": query.Operator,
+		"sort":     query.Sort,
+		"order":    query.Order,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	if query.Offset > 0 {
+		values.Set("offset", strconv.Itoa(query.Offset))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	path := "/api/v1/stock/list"
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+
+	var resp GetStockListResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Login authenticates a device user and returns their access token, to be
+// passed to SetOperatorToken, and the refresh token that renews it
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResponse, error) {
+	req := models.LoginRequest{DeviceID: c.deviceID, Username: username, Password: password}
+
+	var resp LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/login", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RefreshToken exchanges the refresh token of a session for a new access
+// token, to be passed to SetOperatorToken, and a new refresh token. The
+// refresh token sent cannot be used again.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenResponse, error) {
+	req := models.RefreshTokenRequest{DeviceID: c.deviceID, RefreshToken: refreshToken}
+
+	var resp RefreshTokenResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/refresh", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Logout ends the session of the operator token, or every session of the
+// operator when all is set, and clears the token
+func (c *Client) Logout(ctx context.Context, all bool) (*LogoutResponse, error) {
+	req := models.LogoutRequest{DeviceID: c.deviceID, All: all}
+
+	var resp LogoutResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/logout", req, &resp); err != nil {
+		return nil, err
+	}
+	c.SetOperatorToken("")
+	return &resp, nil
+}
+
+// ListSessions returns the active sessions of the operator, or of the user
+// with username when it is not empty
+func (c *Client) ListSessions(ctx context.Context, username string) (*ListSessionsResponse, error) {
+	path := "/api/v1/users/sessions"
+	if username != "" {
+		path += "?" + url.Values{"userName": {username}}.Encode()
+	}
+
+	var resp ListSessionsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListUsers returns a page of the taxpayer's users
+func (c *Client) ListUsers(ctx context.Context, offset, limit int) (*ListUsersResponse, error) {
+	values := url.Values{}
+	values.Set("offset", strconv.Itoa(offset))
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+
+	var resp ListUsersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/users/list?"+values.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateUserBegin starts creating a user; the server sends them a security
+// code
+func (c *Client) CreateUserBegin(ctx context.Context, req CreateUserBeginRequest) (*CreateUserBeginResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp CreateUserBeginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/create-begin", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateUserConfirm finishes creating a user with their security code and
+// password
+func (c *Client) CreateUserConfirm(ctx context.Context, req CreateUserConfirmRequest) (*CreateUserConfirmResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp CreateUserConfirmResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/create-confirm", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateUser changes a user's name, role or status
+func (c *Client) UpdateUser(ctx context.Context, req UpdateUserRequest) (*UpdateUserResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp UpdateUserResponse
+	if err := c.do(ctx, http.MethodPut, "/api/v1/users/update", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChangePassword changes the operator's password and ends every session of
+// the operator
+func (c *Client) ChangePassword(ctx context.Context, req ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	var resp ChangePasswordResponse
+	if err := c.do(ctx, http.MethodPut, "/api/v1/users/change-password", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ResetPasswordBegin sends a password reset code to the user over
+// req.Channel
+func (c *Client) ResetPasswordBegin(ctx context.Context, req ResetUserPasswordBeginRequest) (*ResetUserPasswordBeginResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp ResetUserPasswordBeginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/reset-password-begin", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ResetPasswordConfirm sets a new password with the reset code. Every
+// session of the user is ended; the response carries the tokens of a new
+// one.
+func (c *Client) ResetPasswordConfirm(ctx context.Context, req ResetUserPasswordConfirmRequest) (*ResetUserPasswordConfirmResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp ResetUserPasswordConfirmResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/reset-password-confirm", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ContactChangeBegin sends a security code to the new email address or phone
+// number in req. The contact is only changed by ContactChangeConfirm.
+func (c *Client) ContactChangeBegin(ctx context.Context, req SendSecurityCodeContactChangeRequest) (*SendSecurityCodeContactChangeResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp SendSecurityCodeContactChangeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/contact-change-begin", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ContactChangeConfirm changes the user's email address or phone number, as
+// selected by req.Channel, to the one the security code was sent to
+func (c *Client) ContactChangeConfirm(ctx context.Context, req ConfirmUserContactChangeRequest) (*ConfirmUserContactChangeResponse, error) {
+	req.DeviceID = c.deviceID
+
+	var resp ConfirmUserContactChangeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/contact-change-confirm", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import "fiscalization-api/internal/models"
+
+// The request and response types are those of the server, re-exported so
+// code outside this module can name them.
+
+// APIError is the problem details body of every error response
+type APIError = models.APIError
+
+type (
+	Receipt                   = models.Receipt
+	ReceiptLine               = models.ReceiptLine
+	ReceiptTax                = models.ReceiptTax
+	Payment                   = models.Payment
+	CreditDebitNote           = models.CreditDebitNote
+	ReceiptType               = models.ReceiptType
+	MoneyType                 = models.MoneyType
+	SignatureData             = models.SignatureData
+	SignatureDataEx           = models.SignatureDataEx
+	FiscalDayCounter          = models.FiscalDayCounter
+	FiscalCounterType         = models.FiscalCounterType
+	Tax                       = models.Tax
+	Address                   = models.Address
+	User                      = models.User
+	Good                      = models.Good
+	FiscalDayReport           = models.FiscalDayReport
+	ValidationColor           = models.ValidationColor
+	DeviceOperatingMode       = models.DeviceOperatingMode
+	UserStatus                = models.UserStatus
+	SendSecurityCodeTo        = models.SendSecurityCodeTo
+	FiscalDayDocumentQuantity = models.FiscalDayDocumentQuantity
+)
+
+type (
+	VerifyTaxpayerResponse       = models.VerifyTaxpayerResponse
+	DeviceRegistrationResponse   = models.DeviceRegistrationResponse
+	IssueCertificateResponse     = models.IssueCertificateResponse
+	GetConfigResponse            = models.GetConfigResponse
+	GetStatusResponse            = models.GetStatusResponse
+	PingResponse                 = models.PingResponse
+	GetServerCertificateResponse = models.GetServerCertificateResponse
+	OpenFiscalDayResponse        = models.OpenFiscalDayResponse
+	CloseFiscalDayResponse       = models.CloseFiscalDayResponse
+	GetFiscalDayResponse         = models.GetFiscalDayResponse
+	SubmitReceiptResponse        = models.SubmitReceiptResponse
+	GetStockListResponse         = models.GetStockListResponse
+	LoginResponse                = models.LoginResponse
+	ListUsersResponse            = models.ListUsersResponse
+	CreateUserBeginRequest       = models.CreateUserBeginRequest
+	CreateUserBeginResponse      = models.CreateUserBeginResponse
+	CreateUserConfirmRequest     = models.CreateUserConfirmRequest
+	CreateUserConfirmResponse    = models.CreateUserConfirmResponse
+	UpdateUserRequest            = models.UpdateUserRequest
+	UpdateUserResponse           = models.UpdateUserResponse
+	ChangePasswordRequest        = models.ChangePasswordRequest
+	ChangePasswordResponse       = models.ChangePasswordResponse
+	SessionTokens                = models.SessionTokens
+	RefreshTokenResponse         = models.RefreshTokenResponse
+	LogoutResponse               = models.LogoutResponse
+	ListSessionsResponse         = models.ListSessionsResponse
+
+	ResetUserPasswordBeginRequest    = models.ResetUserPasswordBeginRequest
+	ResetUserPasswordBeginResponse   = models.ResetUserPasswordBeginResponse
+	ResetUserPasswordConfirmRequest  = models.ResetUserPasswordConfirmRequest
+	ResetUserPasswordConfirmResponse = models.ResetUserPasswordConfirmResponse
+
+	SendSecurityCodeContactChangeRequest  = models.SendSecurityCodeContactChangeRequest
+	SendSecurityCodeContactChangeResponse = models.SendSecurityCodeContactChangeResponse
+	ConfirmUserContactChangeRequest       = models.ConfirmUserContactChangeRequest
+	ConfirmUserContactChangeResponse      = models.ConfirmUserContactChangeResponse
+)
+
+// Receipt types
+const (
+	ReceiptTypeFiscalInvoice = models.ReceiptTypeFiscalInvoice
+	ReceiptTypeCreditNote    = models.ReceiptTypeCreditNote
+	ReceiptTypeDebitNote     = models.ReceiptTypeDebitNote
+)